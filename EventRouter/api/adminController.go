@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// @Summary list all subscriptions
+// @Description List the subscriptions of all owners ordered by id. The subscriptions can be filtered on owner, status, event type, callback host and labels. Only administrators can list all subscriptions.
+// @Accept  json
+// @Produce  json
+// @Param owner query string false "owner of the subscriptions"
+// @Param status query string false "subscription status" Enums(ACTIVE, SUSPENDED, PAUSED, PENDING_VERIFICATION)
+// @Param eventType query string false "event type of one of the filters" Enums(DIRECTORY_BLOCK_COMMIT, DIRECTORY_BLOCK_ANCHOR, CHAIN_COMMIT, ENTRY_COMMIT, ENTRY_REVEAL, STATE_CHANGE, PROCESS_LIST_EVENT, NODE_MESSAGE)
+// @Param callbackHost query string false "host of the callback url"
+// @Param label query []string false "label formatted as name:value, the parameter can be repeated to match multiple labels" collectionFormat(multi)
+// @Param offset query int false "offset of the first subscription" default(0)
+// @Param limit query int false "maximum number of subscriptions" default(25) maximum(100)
+// @Success 200 {object} models.SubscriptionList "subscriptions"
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/subscriptions [get]
+func listAllSubscriptions(writer http.ResponseWriter, request *http.Request) {
+	query, err := parseSubscriptionQuery(request.URL.Query())
+	if err != nil {
+		log.Debug("invalid list all subscriptions request %v: %v", request.URL.Query(), err)
+		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(err.Error()))
+		return
+	}
+	query.Owner = request.URL.Query().Get("owner")
+
+	respondSubscriptionList(request.Context(), writer, query)
+}
+
+// @Summary change the status of a subscription
+// @Description Force the status of a subscription of any owner, to suspend an abusive subscription or to reactivate a subscription. The reason is stored as the subscription info and the failures are reset. A reactivated subscription receives the events in its queue. Only administrators can change the status.
+// @Accept  json
+// @Produce  json
+// @Param id path int true "subscription id"
+// @Param statusChange body models.SubscriptionStatusChange true "the new status"
+// @Success 200 {object} models.Subscription "subscription updated"
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/subscriptions/{id}/status [put]
+func (api *api) changeSubscriptionStatus(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	statusChange := &models.SubscriptionStatusChange{}
+	if decode(writer, request, statusChange) {
+		return
+	}
+
+	switch statusChange.Status {
+	case models.Active, models.Suspended:
+	default:
+		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(fmt.Sprintf("unknown subscription status: should be one of [%s, %s]", models.Active, models.Suspended)))
+		return
+	}
+
+	id := vars["subscriptionId"]
+	subscriptionContext, ok := readAnySubscription(request.Context(), writer, id)
+	if !ok {
+		return
+	}
+
+	log.Info("change status of subscription %s from %s to %s: %s", id, subscriptionContext.Subscription.SubscriptionStatus, statusChange.Status, statusChange.Reason)
+	subscriptionContext.Subscription.SubscriptionStatus = statusChange.Status
+	subscriptionContext.Subscription.SubscriptionInfo = statusChange.Reason
+	subscriptionContext.Failures = 0
+
+	// the change of the administrator is a change of the subscription and increments the version, the status overrides
+	// the concurrent changes
+	subscriptionContext.Version = 0
+	_, err := repository.SubscriptionRepository.UpdateSubscription(request.Context(), subscriptionContext)
+	if notFoundError, ok := err.(errors.SubscriptionNotFound); ok {
+		responseError(writer, http.StatusNotFound, errors.NewNotFound(notFoundError.Error()))
+		return
+	} else if err != nil {
+		responseError(writer, http.StatusInternalServerError, errors.NewInternalError(fmt.Sprintf("failed to update subscription: %v", err)))
+		return
+	}
+
+	// read the subscription again to respond with the new version
+	if subscriptionContext, ok = readAnySubscription(request.Context(), writer, id); !ok {
+		return
+	}
+
+	if api.queues != nil {
+		api.queues.UpdateSubscription(request.Context(), subscriptionContext)
+	}
+
+	respond(writer, subscriptionContext.Subscription)
+}
+
+// @Summary inspect the queue of a subscription
+// @Description Return the number of events that wait to be delivered to a subscription of any owner. Only administrators can inspect the queues.
+// @Accept  json
+// @Produce  json
+// @Param id path int true "subscription id"
+// @Success 200 {object} models.SubscriptionQueue "queue"
+// @Failure 401 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/subscriptions/{id}/queue [get]
+func (api *api) getSubscriptionQueue(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	id := vars["subscriptionId"]
+	if _, ok := readAnySubscription(request.Context(), writer, id); !ok {
+		return
+	}
+
+	queue := models.SubscriptionQueue{SubscriptionID: id}
+	if api.queues != nil {
+		queue = api.queues.QueueStatus(request.Context(), id)
+	}
+
+	respond(writer, queue)
+}
+
+// @Summary purge the queue of a subscription
+// @Description Remove the events that wait to be delivered to a subscription of any owner. The events are lost. Only administrators can purge the queues.
+// @Accept  json
+// @Produce  json
+// @Param id path int true "subscription id"
+// @Success 200 {object} models.SubscriptionQueue "queue after the purge with the number of purged events"
+// @Failure 401 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/subscriptions/{id}/queue [delete]
+func (api *api) purgeSubscriptionQueue(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	id := vars["subscriptionId"]
+	if _, ok := readAnySubscription(request.Context(), writer, id); !ok {
+		return
+	}
+
+	queue := models.SubscriptionQueue{SubscriptionID: id}
+	if api.queues != nil {
+		purged := api.queues.PurgeQueue(request.Context(), id)
+		queue = api.queues.QueueStatus(request.Context(), id)
+		queue.Purged = purged
+	}
+
+	respond(writer, queue)
+}
+
+// readAnySubscription reads the subscription regardless of the owner
+func readAnySubscription(ctx context.Context, writer http.ResponseWriter, id string) (*models.SubscriptionContext, bool) {
+	subscriptionContext, err := repository.SubscriptionRepository.ReadSubscription(ctx, id)
+	if !readOwnSubscription(writer, nil, id, subscriptionContext, err) {
+		return nil, false
+	}
+	return subscriptionContext, true
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/FactomProject/live-feed-api/EventRouter/config"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const userAPIKey = "user-key"
+
+type testEventQueues struct {
+	depth   map[string]int
+	updated []*models.SubscriptionContext
+}
+
+func (queues *testEventQueues) QueueStatus(_ context.Context, subscriptionID string) models.SubscriptionQueue {
+	return models.SubscriptionQueue{SubscriptionID: subscriptionID, Depth: queues.depth[subscriptionID]}
+}
+
+func (queues *testEventQueues) PurgeQueue(_ context.Context, subscriptionID string) int {
+	n := queues.depth[subscriptionID]
+	delete(queues.depth, subscriptionID)
+	return n
+}
+
+func (queues *testEventQueues) UpdateSubscription(_ context.Context, subscriptionContext *models.SubscriptionContext) {
+	updated := *subscriptionContext
+	queues.updated = append(queues.updated, &updated)
+}
+
+func TestAdminAPI(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+	_, err := repository.SubscriptionRepository.CreateAPIKey(context.Background(), &models.APIKey{Owner: "explorer", Role: models.User, KeyHash: hashAPIKey(userAPIKey)})
+	assert.Nil(t, err)
+
+	explorerSubscription := createTestSubscription(t, "explorer")
+	walletSubscription := createTestSubscription(t, "wallet")
+
+	queues := &testEventQueues{depth: map[string]int{walletSubscription.Subscription.ID: 7}}
+	router := NewSubscriptionAPI(&config.SubscriptionConfig{BasePath: basePath, Scheme: "HTTP", AdminAPIKey: adminAPIKey}, queues, nil).(*api).router()
+
+	// users cannot use the admin endpoints
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		code, _ := routerRequest(t, router, method, "/admin/subscriptions/"+walletSubscription.Subscription.ID+"/queue", userAPIKey, nil)
+		assert.Equal(t, http.StatusForbidden, code)
+	}
+	code, _ := routerRequest(t, router, http.MethodGet, "/admin/subscriptions", userAPIKey, nil)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = routerRequest(t, router, http.MethodGet, "/admin/subscriptions", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// the admin sees the subscriptions of all owners
+	code, body := routerRequest(t, router, http.MethodGet, "/admin/subscriptions", adminAPIKey, nil)
+	assert.Equal(t, http.StatusOK, code)
+	var subscriptionList models.SubscriptionList
+	assert.Nil(t, json.Unmarshal(body, &subscriptionList))
+	assert.Equal(t, 2, subscriptionList.Total)
+
+	code, body = routerRequest(t, router, http.MethodGet, "/admin/subscriptions?owner=wallet", adminAPIKey, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, json.Unmarshal(body, &subscriptionList))
+	if assert.Len(t, subscriptionList.Subscriptions, 1) {
+		assert.Equal(t, walletSubscription.Subscription.ID, subscriptionList.Subscriptions[0].ID)
+	}
+
+	code, _ = routerRequest(t, router, http.MethodGet, "/admin/subscriptions?status=STOPPED", adminAPIKey, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// the admin suspends and reactivates the subscription of another owner
+	statusURL := "/admin/subscriptions/" + walletSubscription.Subscription.ID + "/status"
+	code, body = routerRequest(t, router, http.MethodPut, statusURL, adminAPIKey, &models.SubscriptionStatusChange{Status: models.Suspended, Reason: "abuse"})
+	assert.Equal(t, http.StatusOK, code)
+	var subscription models.Subscription
+	assert.Nil(t, json.Unmarshal(body, &subscription))
+	assert.Equal(t, models.Suspended, subscription.SubscriptionStatus)
+	assert.Equal(t, "abuse", subscription.SubscriptionInfo)
+	assert.Equal(t, "wallet", subscription.Owner)
+
+	// the change of the administrator increments the version, such that the owner reads the subscription again
+	suspended, err := repository.SubscriptionRepository.ReadSubscription(context.Background(), walletSubscription.Subscription.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, walletSubscription.Version+1, suspended.Version)
+
+	code, _ = routerRequest(t, router, http.MethodPut, statusURL, adminAPIKey, &models.SubscriptionStatusChange{Status: "DELETED"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = routerRequest(t, router, http.MethodPut, statusURL, adminAPIKey, &models.SubscriptionStatusChange{Status: models.Active})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, json.Unmarshal(body, &subscription))
+	assert.Equal(t, models.Active, subscription.SubscriptionStatus)
+	assert.Equal(t, "", subscription.SubscriptionInfo)
+
+	// the router is informed about the changes
+	if assert.Len(t, queues.updated, 2) {
+		assert.Equal(t, models.Suspended, queues.updated[0].Subscription.SubscriptionStatus)
+		assert.Equal(t, models.Active, queues.updated[1].Subscription.SubscriptionStatus)
+	}
+
+	// the admin inspects and purges the queue
+	queueURL := "/admin/subscriptions/" + walletSubscription.Subscription.ID + "/queue"
+	code, body = routerRequest(t, router, http.MethodGet, queueURL, adminAPIKey, nil)
+	assert.Equal(t, http.StatusOK, code)
+	var queue models.SubscriptionQueue
+	assert.Nil(t, json.Unmarshal(body, &queue))
+	assert.Equal(t, models.SubscriptionQueue{SubscriptionID: walletSubscription.Subscription.ID, Depth: 7}, queue)
+
+	code, body = routerRequest(t, router, http.MethodDelete, queueURL, adminAPIKey, nil)
+	assert.Equal(t, http.StatusOK, code)
+	queue = models.SubscriptionQueue{}
+	assert.Nil(t, json.Unmarshal(body, &queue))
+	assert.Equal(t, models.SubscriptionQueue{SubscriptionID: walletSubscription.Subscription.ID, Depth: 0, Purged: 7}, queue)
+
+	code, body = routerRequest(t, router, http.MethodGet, "/admin/subscriptions/"+explorerSubscription.Subscription.ID+"/queue", adminAPIKey, nil)
+	assert.Equal(t, http.StatusOK, code)
+	queue = models.SubscriptionQueue{}
+	assert.Nil(t, json.Unmarshal(body, &queue))
+	assert.Equal(t, 0, queue.Depth)
+
+	// unknown subscriptions
+	for _, path := range []string{"/admin/subscriptions/unknown/queue", "/admin/subscriptions/unknown/status"} {
+		method := http.MethodGet
+		var v interface{}
+		if path == "/admin/subscriptions/unknown/status" {
+			method = http.MethodPut
+			v = &models.SubscriptionStatusChange{Status: models.Active}
+		}
+		code, _ = routerRequest(t, router, method, path, adminAPIKey, v)
+		assert.Equal(t, http.StatusNotFound, code, path)
+	}
+}
+
+func createTestSubscription(t *testing.T, owner string) *models.SubscriptionContext {
+	subscriptionContext, err := repository.SubscriptionRepository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        "http://" + owner + "/events",
+			CallbackType:       models.HTTP,
+			SubscriptionStatus: models.Active,
+			Owner:              owner,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+	return subscriptionContext
+}
+
+// without authentication the api keys and the admin endpoints are refused
+func TestAdminAPIWithoutAuthentication(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+	subscription := createTestSubscription(t, "")
+	router := NewSubscriptionAPI(&config.SubscriptionConfig{BasePath: basePath, Scheme: "HTTP"}, &testEventQueues{}, nil).(*api).router()
+
+	for _, path := range []string{"/apikeys", "/admin/subscriptions", "/admin/subscriptions/" + subscription.Subscription.ID + "/queue"} {
+		code, _ := routerRequest(t, router, http.MethodGet, path, "", nil)
+		assert.Equal(t, http.StatusForbidden, code, path)
+	}
+	code, _ := routerRequest(t, router, http.MethodPost, "/apikeys", "", &models.APIKey{Owner: "explorer", Role: models.Admin})
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// the subscriptions can still be managed
+	code, _ = routerRequest(t, router, http.MethodGet, "/subscriptions/"+subscription.Subscription.ID, "", nil)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func routerRequest(t *testing.T, router *mux.Router, method string, path string, key string, v interface{}) (int, []byte) {
+	var body []byte
+	if v != nil {
+		body = content(t, v)
+	}
+
+	request := httptest.NewRequest(method, basePath+path, bytes.NewBuffer(body))
+	if key != "" {
+		request.Header.Set(apiKeyHeader, key)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Body.Bytes()
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+const maxOwnerLength = 255
+
+// @Summary create an api key
+// @Description Issue an api key to a client. The key is only returned in this response, the live feed only stores a hash of the key. Only administrators can create api keys.
+// @Accept  json
+// @Produce  json
+// @Param apiKey body models.APIKey true "api key to be created"
+// @Success 201 {object} models.APIKey "api key created"
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /apikeys [post]
+func createAPIKey(writer http.ResponseWriter, request *http.Request) {
+	apiKey := &models.APIKey{}
+	if decode(writer, request, apiKey) {
+		return
+	}
+
+	if apiKey.Role == "" {
+		apiKey.Role = models.User
+	}
+
+	if err := validateAPIKey(apiKey); err != nil {
+		log.Debug("invalid api key request %v: %v", apiKey, err)
+		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(err.Error()))
+		return
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		log.Error("failed to generate api key: %v", err)
+		responseError(writer, http.StatusInternalServerError, errors.NewInternalError("failed to generate api key"))
+		return
+	}
+	apiKey.ID = ""
+	apiKey.KeyHash = hashAPIKey(key)
+
+	apiKey, err = repository.SubscriptionRepository.CreateAPIKey(request.Context(), apiKey)
+	if err != nil {
+		log.Error("%v", err)
+		responseError(writer, http.StatusInternalServerError, errors.NewInternalError(fmt.Sprintf("failed to store api key: %v", err)))
+		return
+	}
+
+	// the key is only returned to the client and never stored
+	createdAPIKey := *apiKey
+	createdAPIKey.Key = key
+	respondCode(writer, http.StatusCreated, createdAPIKey)
+}
+
+// @Summary list api keys
+// @Description List the api keys that are issued. The keys themselves are not returned. Only administrators can list api keys.
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} models.APIKey "api keys"
+// @Failure 401 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /apikeys [get]
+func listAPIKeys(writer http.ResponseWriter, request *http.Request) {
+	apiKeys, err := repository.SubscriptionRepository.ListAPIKeys(request.Context())
+	if err != nil {
+		log.Error("%v", err)
+		responseError(writer, http.StatusInternalServerError, errors.NewInternalError(fmt.Sprintf("failed to list api keys: %v", err)))
+		return
+	}
+
+	respond(writer, apiKeys)
+}
+
+// @Summary delete an api key
+// @Description Revoke an api key. Only administrators can delete api keys.
+// @Accept  json
+// @Produce  json
+// @Param id path int true "api key id"
+// @Success 200 "api key deleted"
+// @Failure 401 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /apikeys/{id} [delete]
+func deleteAPIKey(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	id := vars["id"]
+	err := repository.SubscriptionRepository.DeleteAPIKey(request.Context(), id)
+	if notFoundError, ok := err.(errors.APIKeyNotFound); ok {
+		responseError(writer, http.StatusNotFound, errors.NewNotFound(notFoundError.Error()))
+		return
+	} else if err != nil {
+		responseError(writer, http.StatusInternalServerError, errors.NewInternalError(fmt.Sprintf("failed to delete api key: %v", err)))
+		return
+	}
+}
+
+func validateAPIKey(apiKey *models.APIKey) error {
+	if apiKey.Owner == "" || len(apiKey.Owner) > maxOwnerLength {
+		return fmt.Errorf("invalid owner: the owner is required and has at most %d characters", maxOwnerLength)
+	}
+
+	switch apiKey.Role {
+	case models.User:
+	case models.Admin:
+	default:
+		return fmt.Errorf("unknown role: should be one of [%s, %s]", models.User, models.Admin)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestValidateAPIKey(t *testing.T) {
+	testCases := map[string]struct {
+		APIKey *models.APIKey
+		Error  error
+	}{
+		"user": {
+			APIKey: &models.APIKey{Owner: "explorer", Role: models.User},
+			Error:  nil,
+		},
+		"admin": {
+			APIKey: &models.APIKey{Owner: "operator", Role: models.Admin},
+			Error:  nil,
+		},
+		"no owner": {
+			APIKey: &models.APIKey{Role: models.User},
+			Error:  fmt.Errorf("invalid owner: the owner is required and has at most 255 characters"),
+		},
+		"owner too long": {
+			APIKey: &models.APIKey{Owner: strings.Repeat("x", 256), Role: models.User},
+			Error:  fmt.Errorf("invalid owner: the owner is required and has at most 255 characters"),
+		},
+		"unknown role": {
+			APIKey: &models.APIKey{Owner: "explorer", Role: "ROOT"},
+			Error:  fmt.Errorf("unknown role: should be one of [USER, ADMIN]"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateAPIKey(testCase.APIKey)
+			assert.EqualValues(t, testCase.Error, err)
+		})
+	}
+}
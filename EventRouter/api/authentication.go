@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"net/http"
+	"strings"
+)
+
+const (
+	apiKeyHeader        = "X-API-Key"
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+
+	// the owner of the subscriptions that are created with the admin api key of the configuration
+	adminOwner = "admin"
+)
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+// principal is the authenticated client of the api
+type principal struct {
+	Owner string
+	Role  models.Role
+}
+
+// authenticate the client with a bearer JWT in the Authorization header or with the api key in the X-API-Key header.
+// Authentication is disabled when no admin api key and no jwt issuer are configured, then every client can manage all
+// subscriptions, but no client is an administrator.
+func (api *api) authenticate(f http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !api.authenticationEnabled() {
+			f.ServeHTTP(writer, request)
+			return
+		}
+
+		principal, err := api.authenticateRequest(request)
+		if _, ok := err.(authenticationError); ok {
+			log.Debug("failed to authenticate: %v", err)
+			responseError(writer, http.StatusUnauthorized, errors.NewUnauthorized())
+			return
+		} else if err != nil {
+			log.Error("failed to authenticate: %v", err)
+			responseError(writer, http.StatusInternalServerError, errors.NewInternalError("failed to authenticate"))
+			return
+		}
+
+		f.ServeHTTP(writer, request.WithContext(context.WithValue(request.Context(), principalKey, principal)))
+	})
+}
+
+func (api *api) authenticationEnabled() bool {
+	return api.apiConfig.AdminAPIKey != "" || api.jwt != nil
+}
+
+func (api *api) authenticateRequest(request *http.Request) (*principal, error) {
+	if authorization := request.Header.Get(authorizationHeader); authorization != "" {
+		if api.jwt == nil || !strings.HasPrefix(authorization, bearerPrefix) {
+			return nil, authenticationError{fmt.Errorf("unsupported authorization")}
+		}
+		return api.jwt.Authenticate(strings.TrimPrefix(authorization, bearerPrefix))
+	}
+
+	key := request.Header.Get(apiKeyHeader)
+	if key == "" {
+		return nil, authenticationError{fmt.Errorf("no credentials")}
+	}
+
+	principal, err := api.authenticateAPIKey(request.Context(), key)
+	if _, ok := err.(errors.APIKeyNotFound); ok {
+		return nil, authenticationError{err}
+	}
+	return principal, err
+}
+
+func (api *api) authenticateAPIKey(ctx context.Context, key string) (*principal, error) {
+	if subtle.ConstantTimeCompare([]byte(key), []byte(api.apiConfig.AdminAPIKey)) == 1 {
+		return &principal{Owner: adminOwner, Role: models.Admin}, nil
+	}
+
+	apiKey, err := repository.SubscriptionRepository.FindAPIKey(ctx, hashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	return &principal{Owner: apiKey.Owner, Role: apiKey.Role}, nil
+}
+
+// requireAdmin only allows administrators to call the handler, the handler is refused when authentication is disabled
+func requireAdmin(f http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if !requestPrincipal(request).isAdmin() {
+			responseError(writer, http.StatusForbidden, errors.NewForbidden())
+			return
+		}
+		f(writer, request)
+	}
+}
+
+// requireAdminRole is the middleware variant of requireAdmin
+func requireAdminRole(f http.Handler) http.Handler {
+	return requireAdmin(f.ServeHTTP)
+}
+
+// requestPrincipal returns the authenticated client of the request, which is nil when authentication is disabled
+func requestPrincipal(request *http.Request) *principal {
+	principal, _ := request.Context().Value(principalKey).(*principal)
+	return principal
+}
+
+func (principal *principal) owner() string {
+	if principal == nil {
+		return ""
+	}
+	return principal.Owner
+}
+
+func (principal *principal) owns(subscription *models.Subscription) bool {
+	return principal == nil || principal.Owner == subscription.Owner
+}
+
+// isAdmin reports whether the client is an administrator, without authentication there are no administrators
+func (principal *principal) isAdmin() bool {
+	return principal != nil && principal.Role == models.Admin
+}
+
+// generateAPIKey creates a random api key
+func generateAPIKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// hashAPIKey hashes the api key for storage, the keys are random such that a plain sha256 suffices
+func hashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
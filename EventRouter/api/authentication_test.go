@@ -0,0 +1,187 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/config"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+const (
+	authenticatedPort = 8702
+	adminAPIKey       = "admin-key"
+)
+
+func TestAuthenticatedSubscriptionAPI(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+
+	configuration := &config.SubscriptionConfig{
+		BindAddress: "",
+		Port:        authenticatedPort,
+		BasePath:    basePath,
+		Scheme:      "HTTP",
+		AdminAPIKey: adminAPIKey,
+	}
+	startAPI(configuration)
+
+	// the swagger is public
+	code, _ := apiRequest(t, http.MethodGet, "/swagger.json", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+
+	// requests without a valid api key are rejected
+	code, body := apiRequest(t, http.MethodGet, "/subscriptions", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, errors.NewUnauthorized().Code, parseAPIBody(t, body).Code)
+	code, _ = apiRequest(t, http.MethodGet, "/subscriptions", "unknown-key", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// the admin issues api keys to the clients
+	explorerKey := createTestAPIKey(t, "explorer")
+	walletKey := createTestAPIKey(t, "wallet")
+
+	// clients cannot manage the api keys
+	code, _ = apiRequest(t, http.MethodGet, "/apikeys", explorerKey, nil)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, body = apiRequest(t, http.MethodGet, "/apikeys", adminAPIKey, nil)
+	assert.Equal(t, http.StatusOK, code)
+	var apiKeys []*models.APIKey
+	assert.Nil(t, json.Unmarshal(body, &apiKeys))
+	if assert.Len(t, apiKeys, 2) {
+		assert.Equal(t, "", apiKeys[0].Key)
+	}
+
+	// the subscription is owned by the client that creates it
+	code, body = apiRequest(t, http.MethodPost, "/subscriptions", explorerKey, &models.Subscription{
+		CallbackURL:  "http://explorer/events",
+		CallbackType: models.HTTP,
+		Owner:        "wallet",
+	})
+	assert.Equal(t, http.StatusCreated, code)
+	var subscription models.Subscription
+	assert.Nil(t, json.Unmarshal(body, &subscription))
+	assert.Equal(t, "explorer", subscription.Owner)
+	subscriptionURL := "/subscriptions/" + subscription.ID
+
+	// other clients don't see the subscription
+	code, _ = apiRequest(t, http.MethodGet, subscriptionURL, walletKey, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = apiRequest(t, http.MethodPut, subscriptionURL, walletKey, &models.Subscription{CallbackURL: "http://wallet/events", CallbackType: models.HTTP})
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = apiRequest(t, http.MethodDelete, subscriptionURL, walletKey, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	assertListTotal(t, walletKey, 0)
+	assertListTotal(t, explorerKey, 1)
+
+	// the owner manages the subscription
+	code, body = apiRequest(t, http.MethodPut, subscriptionURL, explorerKey, &models.Subscription{CallbackURL: "http://explorer/v2/events", CallbackType: models.HTTP})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, json.Unmarshal(body, &subscription))
+	assert.Equal(t, "explorer", subscription.Owner)
+	code, _ = apiRequest(t, http.MethodGet, subscriptionURL, explorerKey, nil)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = apiRequest(t, http.MethodDelete, subscriptionURL, explorerKey, nil)
+	assert.Equal(t, http.StatusOK, code)
+
+	// a deleted api key is no longer accepted
+	code, _ = apiRequest(t, http.MethodDelete, "/apikeys/"+apiKeys[1].ID, adminAPIKey, nil)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = apiRequest(t, http.MethodGet, "/subscriptions", walletKey, nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, body = apiRequest(t, http.MethodDelete, "/apikeys/"+apiKeys[1].ID, adminAPIKey, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	apiError := &models.APIError{}
+	assert.Nil(t, json.Unmarshal(body, apiError))
+	assert.Equal(t, "not found", apiError.Message)
+}
+
+func TestPrincipal(t *testing.T) {
+	subscription := &models.Subscription{Owner: "explorer"}
+
+	// without authentication every client manages all subscriptions, but is no administrator
+	var anonymous *principal
+	assert.Equal(t, "", anonymous.owner())
+	assert.True(t, anonymous.owns(subscription))
+	assert.False(t, anonymous.isAdmin())
+
+	user := &principal{Owner: "wallet", Role: models.User}
+	assert.Equal(t, "wallet", user.owner())
+	assert.False(t, user.owns(subscription))
+	assert.False(t, user.isAdmin())
+
+	admin := &principal{Owner: "explorer", Role: models.Admin}
+	assert.True(t, admin.owns(subscription))
+	assert.True(t, admin.isAdmin())
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, err := generateAPIKey()
+	assert.Nil(t, err)
+	assert.Len(t, key, 43)
+
+	other, err := generateAPIKey()
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestHashAPIKey(t *testing.T) {
+	assert.Equal(t, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", hashAPIKey("foo"))
+}
+
+func createTestAPIKey(t *testing.T, owner string) string {
+	code, body := apiRequest(t, http.MethodPost, "/apikeys", adminAPIKey, &models.APIKey{Owner: owner})
+	if code != http.StatusCreated {
+		t.Fatalf("failed to create api key: %d %s", code, body)
+	}
+
+	var apiKey models.APIKey
+	if err := json.Unmarshal(body, &apiKey); err != nil {
+		t.Fatalf("unmarshalling failed: %v", err)
+	}
+	assert.Equal(t, owner, apiKey.Owner)
+	assert.Equal(t, models.User, apiKey.Role)
+	return apiKey.Key
+}
+
+func assertListTotal(t *testing.T, key string, total int) {
+	code, body := apiRequest(t, http.MethodGet, "/subscriptions", key, nil)
+	assert.Equal(t, http.StatusOK, code)
+
+	var subscriptionList models.SubscriptionList
+	assert.Nil(t, json.Unmarshal(body, &subscriptionList))
+	assert.Equal(t, total, subscriptionList.Total)
+}
+
+func apiRequest(t *testing.T, method string, path string, key string, v interface{}) (int, []byte) {
+	var body []byte
+	if v != nil {
+		body = content(t, v)
+	}
+
+	url := fmt.Sprintf("http://localhost:%d%s%s", authenticatedPort, basePath, path)
+	request, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if key != "" {
+		request.Header.Set(apiKeyHeader, key)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("failed to get response: %v", err)
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	return response.StatusCode, responseBody
+}
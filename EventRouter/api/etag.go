@@ -0,0 +1,53 @@
+package api
+
+import (
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	etagHeader    = "ETag"
+	ifMatchHeader = "If-Match"
+)
+
+// etag formats the version of a subscription as a strong entity tag
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// matchesIfMatch checks the entity tags of an If-Match header against the version of the subscription, weak tags never
+// match because If-Match uses the strong comparison (RFC 7232)
+func matchesIfMatch(ifMatch string, version int64) bool {
+	if strings.TrimSpace(ifMatch) == "*" {
+		return true
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(tag) == current {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch checks the If-Match header of the request against the existing subscription and returns the version that
+// the update requires, 0 when the update has no precondition
+func checkIfMatch(writer http.ResponseWriter, request *http.Request, existing *models.SubscriptionContext) (int64, bool) {
+	ifMatch := request.Header.Get(ifMatchHeader)
+	if ifMatch == "" {
+		return 0, true
+	}
+	if !matchesIfMatch(ifMatch, existing.Version) {
+		responseError(writer, http.StatusPreconditionFailed, errors.NewPreconditionFailed(fmt.Sprintf("subscription '%s' has version %s", existing.Subscription.ID, etag(existing.Version))))
+		return 0, false
+	}
+	if strings.TrimSpace(ifMatch) == "*" {
+		return 0, true
+	}
+	return existing.Version, true
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"github.com/FactomProject/live-feed-api/EventRouter/config"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchesIfMatch(t *testing.T) {
+	testCases := map[string]struct {
+		IfMatch string
+		Match   bool
+	}{
+		"same":     {IfMatch: `"3"`, Match: true},
+		"any":      {IfMatch: `*`, Match: true},
+		"list":     {IfMatch: `"1", "3"`, Match: true},
+		"other":    {IfMatch: `"2"`, Match: false},
+		"weak":     {IfMatch: `W/"3"`, Match: false},
+		"unquoted": {IfMatch: `3`, Match: false},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, testCase.Match, matchesIfMatch(testCase.IfMatch, 3))
+		})
+	}
+}
+
+func TestConditionalUpdate(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+	_, err := repository.SubscriptionRepository.CreateAPIKey(context.Background(), &models.APIKey{Owner: "explorer", Role: models.User, KeyHash: hashAPIKey(userAPIKey)})
+	assert.Nil(t, err)
+
+	subscriptionContext := createTestSubscription(t, "explorer")
+	router := NewSubscriptionAPI(&config.SubscriptionConfig{BasePath: basePath, Scheme: "HTTP", AdminAPIKey: adminAPIKey}, nil, nil).(*api).router()
+	subscriptionURL := "/subscriptions/" + subscriptionContext.Subscription.ID
+	subscription := subscriptionContext.Subscription
+
+	code, header := conditionalRequest(t, router, http.MethodGet, subscriptionURL, "", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"1"`, header.Get(etagHeader))
+
+	// an update with the current entity tag succeeds and returns the new entity tag
+	code, header = conditionalRequest(t, router, http.MethodPut, subscriptionURL, `"1"`, string(content(t, subscription)))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"2"`, header.Get(etagHeader))
+
+	// an update with an outdated or weak entity tag fails
+	for _, ifMatch := range []string{`"1"`, `W/"2"`} {
+		code, _ = conditionalRequest(t, router, http.MethodPut, subscriptionURL, ifMatch, string(content(t, subscription)))
+		assert.Equal(t, http.StatusPreconditionFailed, code, ifMatch)
+		code, _ = conditionalRequest(t, router, http.MethodPatch, subscriptionURL, ifMatch, `{"status":"ACTIVE"}`)
+		assert.Equal(t, http.StatusPreconditionFailed, code, ifMatch)
+	}
+
+	code, header = conditionalRequest(t, router, http.MethodPatch, subscriptionURL, `"2"`, `{"labels":{"team":"explorer"}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"3"`, header.Get(etagHeader))
+
+	// a delivery failure of the router keeps the version, the entity tag of the client is still valid
+	delivered, err := repository.SubscriptionRepository.ReadSubscription(context.Background(), subscription.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, repository.SubscriptionRepository.UpdateSubscriptionStatus(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{ID: subscription.ID, SubscriptionStatus: models.Suspended, SubscriptionInfo: "1: failed"},
+		Failures:     delivered.Failures + 1,
+	}))
+
+	code, header = conditionalRequest(t, router, http.MethodPatch, subscriptionURL, `"3"`, `{"labels":{"team":"wallet"}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"4"`, header.Get(etagHeader))
+
+	// any entity tag matches
+	code, header = conditionalRequest(t, router, http.MethodPatch, subscriptionURL, `*`, `{"status":"ACTIVE"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"5"`, header.Get(etagHeader))
+
+	// without a precondition the last update wins
+	code, header = conditionalRequest(t, router, http.MethodPut, subscriptionURL, "", string(content(t, subscription)))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `"6"`, header.Get(etagHeader))
+
+	subscription.ID = ""
+	code, _ = conditionalRequest(t, router, http.MethodPut, "/subscriptions/unknown", `"1"`, string(content(t, subscription)))
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func conditionalRequest(t *testing.T, router http.Handler, method string, path string, ifMatch string, body string) (int, http.Header) {
+	request := httptest.NewRequest(method, basePath+path, bytes.NewBufferString(body))
+	request.Header.Set(apiKeyHeader, userAPIKey)
+	if ifMatch != "" {
+		request.Header.Set(ifMatchHeader, ifMatch)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Header()
+}
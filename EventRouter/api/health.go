@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"net/http"
+	"time"
+)
+
+// the paths of the health and metrics endpoints, which are not below the base path of the api
+const (
+	livenessPath  = "/health/live"
+	readinessPath = "/health/ready"
+	metricsPath   = "/metrics"
+)
+
+// liveness reports that the live feed is running and serving requests
+func liveness(writer http.ResponseWriter, _ *http.Request) {
+	respond(writer, &models.Health{Status: models.Up})
+}
+
+// readiness reports whether the live feed is ready to receive and deliver events, failing checks respond with
+// 503 Service Unavailable
+func (api *api) readiness(writer http.ResponseWriter, request *http.Request) {
+	health := api.checkReadiness(request.Context(), time.Now())
+	if health.Status != models.Up {
+		respondCode(writer, http.StatusServiceUnavailable, health)
+		return
+	}
+	respond(writer, health)
+}
+
+func (api *api) checkReadiness(ctx context.Context, now time.Time) *models.Health {
+	health := &models.Health{
+		Status: models.Up,
+		Checks: make(map[string]*models.HealthCheck),
+	}
+	check := func(name string, up bool, details string) {
+		healthCheck := &models.HealthCheck{Status: models.Up, Details: details}
+		if !up {
+			healthCheck.Status = models.Down
+			health.Status = models.Down
+		}
+		health.Checks[name] = healthCheck
+	}
+
+	err := repository.SubscriptionRepository.Ping(ctx)
+	if err != nil {
+		check("repository", false, err.Error())
+	} else {
+		check("repository", true, "")
+	}
+
+	if api.receiver == nil {
+		return health
+	}
+	status := api.receiver.Status()
+
+	if status.Listening {
+		check("receiver", true, fmt.Sprintf("listening at %s", status.Address))
+	} else {
+		check("receiver", false, fmt.Sprintf("not listening at %s", status.Address))
+	}
+
+	minConnections := int(api.apiConfig.ReadinessMinConnections)
+	check("connections", status.Connections >= minConnections, fmt.Sprintf("%d active factomd connections, %d required", status.Connections, minConnections))
+
+	// the age of the last event is measured from the start of the receiver when no event has been received yet
+	maxEventAge := time.Duration(api.apiConfig.ReadinessMaxEventAge) * time.Second
+	if status.LastEvent.IsZero() {
+		age := now.Sub(status.Started)
+		check("lastEvent", maxEventAge == 0 || age <= maxEventAge, fmt.Sprintf("no event received in %s", age.Round(time.Second)))
+	} else {
+		age := now.Sub(status.LastEvent)
+		check("lastEvent", maxEventAge == 0 || age <= maxEventAge, fmt.Sprintf("last event received %s ago", age.Round(time.Second)))
+	}
+
+	usage := 0
+	if status.QueueCapacity > 0 {
+		usage = status.QueueLength * 100 / status.QueueCapacity
+	}
+	maxUsage := int(api.apiConfig.ReadinessMaxQueueUsage)
+	check("eventQueue", maxUsage == 0 || usage <= maxUsage, fmt.Sprintf("%d of %d events in the queue", status.QueueLength, status.QueueCapacity))
+	return health
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/config"
+	"github.com/FactomProject/live-feed-api/EventRouter/events"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testReceiver struct {
+	status events.ReceiverStatus
+}
+
+func (receiver *testReceiver) Status() events.ReceiverStatus {
+	return receiver.status
+}
+
+func TestLiveness(t *testing.T) {
+	router := NewSubscriptionAPI(&config.SubscriptionConfig{BasePath: basePath, Scheme: "HTTP", AdminAPIKey: adminAPIKey}, nil, nil).(*api).router()
+
+	// the health is probed without authentication
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, livenessPath, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"status":"UP"}`, recorder.Body.String())
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	router := NewSubscriptionAPI(&config.SubscriptionConfig{BasePath: basePath, Scheme: "HTTP", AdminAPIKey: adminAPIKey}, nil, nil).(*api).router()
+
+	// the metrics are scraped without authentication
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "go_goroutines")
+}
+
+func TestReadiness(t *testing.T) {
+	now := time.Now()
+	ready := events.ReceiverStatus{
+		Listening:     true,
+		Address:       ":8040",
+		Connections:   1,
+		Started:       now.Add(-time.Hour),
+		LastEvent:     now.Add(-10 * time.Second),
+		QueueLength:   10,
+		QueueCapacity: 100,
+	}
+
+	testCases := map[string]struct {
+		Status        func(status *events.ReceiverStatus)
+		PingError     error
+		ExpectedCode  int
+		ExpectedCheck string
+		Details       string
+	}{
+		"ready": {
+			ExpectedCode: http.StatusOK,
+		},
+		"repository down": {
+			PingError:     fmt.Errorf("connection refused"),
+			ExpectedCode:  http.StatusServiceUnavailable,
+			ExpectedCheck: "repository",
+			Details:       "connection refused",
+		},
+		"not listening": {
+			Status:        func(status *events.ReceiverStatus) { status.Listening = false },
+			ExpectedCode:  http.StatusServiceUnavailable,
+			ExpectedCheck: "receiver",
+			Details:       "not listening at :8040",
+		},
+		"no connections": {
+			Status:        func(status *events.ReceiverStatus) { status.Connections = 0 },
+			ExpectedCode:  http.StatusServiceUnavailable,
+			ExpectedCheck: "connections",
+			Details:       "0 active factomd connections, 1 required",
+		},
+		"old event": {
+			Status:        func(status *events.ReceiverStatus) { status.LastEvent = now.Add(-2 * time.Minute) },
+			ExpectedCode:  http.StatusServiceUnavailable,
+			ExpectedCheck: "lastEvent",
+			Details:       "last event received 2m0s ago",
+		},
+		"no event": {
+			Status:        func(status *events.ReceiverStatus) { status.LastEvent = time.Time{} },
+			ExpectedCode:  http.StatusServiceUnavailable,
+			ExpectedCheck: "lastEvent",
+			Details:       "no event received in 1h0m0s",
+		},
+		"full queue": {
+			Status:        func(status *events.ReceiverStatus) { status.QueueLength = 95 },
+			ExpectedCode:  http.StatusServiceUnavailable,
+			ExpectedCheck: "eventQueue",
+			Details:       "95 of 100 events in the queue",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			mockRepository := repository.InitMockRepository()
+			mockRepository.On("Ping").Return(testCase.PingError)
+
+			receiver := &testReceiver{status: ready}
+			if testCase.Status != nil {
+				testCase.Status(&receiver.status)
+			}
+			configuration := &config.SubscriptionConfig{ReadinessMinConnections: 1, ReadinessMaxEventAge: 60, ReadinessMaxQueueUsage: 90}
+			subscriptionAPI := NewSubscriptionAPI(configuration, nil, receiver).(*api)
+
+			health := subscriptionAPI.checkReadiness(context.Background(), now)
+			assert.Len(t, health.Checks, 5)
+			if testCase.ExpectedCheck == "" {
+				assert.Equal(t, models.Up, health.Status)
+				for name, check := range health.Checks {
+					assert.Equal(t, models.Up, check.Status, name)
+				}
+			} else {
+				assert.Equal(t, models.Down, health.Status)
+				if assert.Contains(t, health.Checks, testCase.ExpectedCheck) {
+					assert.Equal(t, models.Down, health.Checks[testCase.ExpectedCheck].Status)
+					assert.Equal(t, testCase.Details, health.Checks[testCase.ExpectedCheck].Details)
+				}
+			}
+
+			recorder := httptest.NewRecorder()
+			subscriptionAPI.readiness(recorder, httptest.NewRequest(http.MethodGet, readinessPath, nil))
+			assert.Equal(t, testCase.ExpectedCode, recorder.Code)
+
+			response := &models.Health{}
+			assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), response))
+			assert.Equal(t, health.Status, response.Status)
+		})
+	}
+}
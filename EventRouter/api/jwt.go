@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/config"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwksRequestTimeout = 10 * time.Second
+
+	// a JWT with an unknown key id reloads the key set at most once per interval, to pick up rotated keys
+	jwksMinRefreshInterval = 1 * time.Minute
+
+	// allowed clock skew between the issuer and the live feed
+	jwtLeeway = 1 * time.Minute
+)
+
+// only asymmetric algorithms are accepted, the key set contains public keys
+var jwtAlgorithms = map[string]bool{
+	string(jose.RS256): true, string(jose.RS384): true, string(jose.RS512): true,
+	string(jose.PS256): true, string(jose.PS384): true, string(jose.PS512): true,
+	string(jose.ES256): true, string(jose.ES384): true, string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// jwtAuthenticator authenticates clients with the bearer JWTs of an OIDC issuer
+type jwtAuthenticator struct {
+	issuer     string
+	audience   string
+	ownerClaim string
+	rolesClaim string
+	adminRole  string
+	keys       *jwksCache
+}
+
+// jwksCache loads the JSON web key set from a file or url and caches it
+type jwksCache struct {
+	sync.Mutex
+	location string
+	timeout  time.Duration
+	client   *http.Client
+	keySet   *jose.JSONWebKeySet
+	loaded   time.Time
+}
+
+// authenticationError is returned for credentials that are missing or invalid
+type authenticationError struct {
+	error
+}
+
+func newJWTAuthenticator(apiConfig *config.SubscriptionConfig) *jwtAuthenticator {
+	if apiConfig.JWTIssuer == "" {
+		return nil
+	}
+
+	return &jwtAuthenticator{
+		issuer:     apiConfig.JWTIssuer,
+		audience:   apiConfig.JWTAudience,
+		ownerClaim: apiConfig.JWTOwnerClaim,
+		rolesClaim: apiConfig.JWTRolesClaim,
+		adminRole:  apiConfig.JWTAdminRole,
+		keys: &jwksCache{
+			location: apiConfig.JWKS,
+			timeout:  time.Duration(apiConfig.JWKSCacheTimeout) * time.Second,
+			client:   &http.Client{Timeout: jwksRequestTimeout},
+		},
+	}
+}
+
+// Authenticate verifies the JWT and maps the claims to the principal
+func (authenticator *jwtAuthenticator) Authenticate(rawToken string) (*principal, error) {
+	token, err := jwt.ParseSigned(rawToken)
+	if err != nil {
+		return nil, authenticationError{fmt.Errorf("invalid jwt: %v", err)}
+	}
+	if len(token.Headers) != 1 || !jwtAlgorithms[token.Headers[0].Algorithm] {
+		return nil, authenticationError{fmt.Errorf("invalid jwt: unsupported algorithm")}
+	}
+
+	key, err := authenticator.keys.Key(token.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.Claims{}
+	customClaims := make(map[string]interface{})
+	if err := token.Claims(key, &claims, &customClaims); err != nil {
+		return nil, authenticationError{fmt.Errorf("invalid jwt: %v", err)}
+	}
+
+	expected := jwt.Expected{Issuer: authenticator.issuer, Time: time.Now()}
+	if authenticator.audience != "" {
+		expected.Audience = jwt.Audience{authenticator.audience}
+	}
+	if err := claims.ValidateWithLeeway(expected, jwtLeeway); err != nil {
+		return nil, authenticationError{fmt.Errorf("invalid jwt: %v", err)}
+	}
+
+	owner, ok := claimValue(customClaims, authenticator.ownerClaim).(string)
+	if !ok || owner == "" {
+		return nil, authenticationError{fmt.Errorf("invalid jwt: missing owner claim '%s'", authenticator.ownerClaim)}
+	}
+
+	role := models.User
+	for _, value := range claimValues(customClaims, authenticator.rolesClaim) {
+		if value == authenticator.adminRole {
+			role = models.Admin
+		}
+	}
+	return &principal{Owner: owner, Role: role}, nil
+}
+
+// Key returns the key with the key id, the key set is reloaded when it is expired or doesn't contain the key
+func (cache *jwksCache) Key(keyID string) (*jose.JSONWebKey, error) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	now := time.Now()
+	if cache.keySet == nil || now.Sub(cache.loaded) > cache.timeout {
+		if err := cache.load(now); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := cache.keySet.Key(keyID)
+	if len(keys) == 0 && now.Sub(cache.loaded) > jwksMinRefreshInterval {
+		if err := cache.load(now); err != nil {
+			return nil, err
+		}
+		keys = cache.keySet.Key(keyID)
+	}
+	if len(keys) == 0 {
+		return nil, authenticationError{fmt.Errorf("invalid jwt: unknown key '%s'", keyID)}
+	}
+	return &keys[0], nil
+}
+
+func (cache *jwksCache) load(now time.Time) error {
+	data, err := cache.read()
+	if err != nil {
+		return fmt.Errorf("failed to load jwks '%s': %v", cache.location, err)
+	}
+
+	keySet := &jose.JSONWebKeySet{}
+	if err := json.Unmarshal(data, keySet); err != nil {
+		return fmt.Errorf("failed to load jwks '%s': %v", cache.location, err)
+	}
+
+	log.Debug("loaded jwks '%s' with %d keys", cache.location, len(keySet.Keys))
+	cache.keySet = keySet
+	cache.loaded = now
+	return nil
+}
+
+func (cache *jwksCache) read() ([]byte, error) {
+	if cache.location == "" {
+		return nil, fmt.Errorf("no jwks configured")
+	}
+	if !strings.HasPrefix(cache.location, "http://") && !strings.HasPrefix(cache.location, "https://") {
+		return ioutil.ReadFile(cache.location)
+	}
+
+	response, err := cache.client.Get(cache.location)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", response.Status)
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+// claimValue returns the value of the claim, nested claims are separated by dots
+func claimValue(claims map[string]interface{}, name string) interface{} {
+	var value interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+// claimValues returns the values of a claim that is either a string or an array of strings
+func claimValues(claims map[string]interface{}, name string) []string {
+	switch value := claimValue(claims, name).(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// applyMergePatch applies a JSON merge patch (RFC 7396) to the subscription and returns the patched subscription
+func applyMergePatch(subscription *models.Subscription, patch []byte) (*models.Subscription, error) {
+	var patchValue interface{}
+	if err := unmarshalNumbers(patch, &patchValue); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %v", err)
+	}
+	if _, ok := patchValue.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("invalid merge patch: should be a json object")
+	}
+
+	document, err := json.Marshal(subscription)
+	if err != nil {
+		return nil, err
+	}
+	var target interface{}
+	if err := unmarshalNumbers(document, &target); err != nil {
+		return nil, err
+	}
+
+	patched, err := json.Marshal(mergePatch(target, patchValue))
+	if err != nil {
+		return nil, err
+	}
+
+	patchedSubscription := &models.Subscription{}
+	if err := json.Unmarshal(patched, patchedSubscription); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %v", err)
+	}
+	return patchedSubscription, nil
+}
+
+// mergePatch merges the patch into the target: members of the patch replace the members of the target, null removes
+// the member and objects are merged recursively
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+// unmarshalNumbers keeps the numbers as json.Number such that large integers keep their precision
+func unmarshalNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// examples of RFC 7396 appendix A
+func TestMergePatch(t *testing.T) {
+	testCases := []struct {
+		Target string
+		Patch  string
+		Result string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, testCase := range testCases {
+		var target, patch interface{}
+		assert.Nil(t, unmarshalNumbers([]byte(testCase.Target), &target))
+		assert.Nil(t, unmarshalNumbers([]byte(testCase.Patch), &patch))
+
+		result, err := json.Marshal(mergePatch(target, patch))
+		assert.Nil(t, err)
+		assert.JSONEq(t, testCase.Result, string(result), "%s + %s", testCase.Target, testCase.Patch)
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	subscription := &models.Subscription{
+		ID:                 "1",
+		CallbackURL:        "https://server.com/events",
+		CallbackType:       models.BearerToken,
+		SubscriptionStatus: models.Suspended,
+		Credentials:        models.Credentials{AccessToken: "token"},
+		Filters: map[models.EventType]models.Filter{
+			models.ChainCommit: {Filtering: "filtering 1"},
+			models.EntryCommit: {Filtering: "filtering 2"},
+		},
+		FileSink: models.FileSink{MaxSize: 9007199254740993},
+	}
+
+	patched, err := applyMergePatch(subscription, []byte(`{"status":"ACTIVE","filters":{"ENTRY_COMMIT":null,"ENTRY_REVEAL":{"filtering":"filtering 3"}}}`))
+	assert.Nil(t, err)
+
+	expected := *subscription
+	expected.SubscriptionStatus = models.Active
+	expected.Filters = map[models.EventType]models.Filter{
+		models.ChainCommit: {Filtering: "filtering 1"},
+		models.EntryReveal: {Filtering: "filtering 3"},
+	}
+	assert.Equal(t, &expected, patched)
+
+	// the subscription itself isn't changed
+	assert.Equal(t, models.Suspended, subscription.SubscriptionStatus)
+	assert.Len(t, subscription.Filters, 2)
+
+	for _, patch := range []string{`["a"]`, `"status"`, `{"status":`, `{"filters":"all"}`} {
+		_, err = applyMergePatch(subscription, []byte(patch))
+		assert.NotNil(t, err, patch)
+	}
+}
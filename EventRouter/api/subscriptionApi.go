@@ -11,13 +11,25 @@ package api
 // @BasePath /live/feed/v1.0
 // @schemes http https
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/FactomProject/live-feed-api/EventRouter/config"
+	"github.com/FactomProject/live-feed-api/EventRouter/events"
 	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
 	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/swaggo/swag"
 	"net/http"
 	"strings"
@@ -27,16 +39,39 @@ import (
 // SubscriptionAPI is API endpoint that users allow to register an callback to receive events
 type SubscriptionAPI interface {
 	Start()
+
+	// Stop the api, the requests in progress are completed until the context is done
+	Stop(ctx context.Context) error
+}
+
+// EventQueues are the queues of the event router with the events that wait to be delivered to the subscriptions
+type EventQueues interface {
+	QueueStatus(ctx context.Context, subscriptionID string) models.SubscriptionQueue
+	PurgeQueue(ctx context.Context, subscriptionID string) int
+	UpdateSubscription(ctx context.Context, subscriptionContext *models.SubscriptionContext)
+}
+
+// EventReceiver is the receiver of the events of factomd, the status of the receiver determines the readiness
+type EventReceiver interface {
+	Status() events.ReceiverStatus
 }
 
 type api struct {
 	apiConfig *config.SubscriptionConfig
+	jwt       *jwtAuthenticator
+	queues    EventQueues
+	receiver  EventReceiver
+	server    *http.Server
 }
 
-// NewSubscriptionAPI create a new SubscriptionAPI with a configuration
-func NewSubscriptionAPI(apiConfig *config.SubscriptionConfig) SubscriptionAPI {
+// NewSubscriptionAPI create a new SubscriptionAPI with a configuration, the queues are managed by the administrators and
+// the receiver is checked for the readiness of the live feed
+func NewSubscriptionAPI(apiConfig *config.SubscriptionConfig, queues EventQueues, receiver EventReceiver) SubscriptionAPI {
 	return &api{
 		apiConfig: apiConfig,
+		jwt:       newJWTAuthenticator(apiConfig),
+		queues:    queues,
+		receiver:  receiver,
 	}
 }
 
@@ -49,34 +84,74 @@ func logInterceptor(f http.Handler) http.Handler {
 }
 
 func (api *api) Start() {
-	router := mux.NewRouter()
-	router.Use(logInterceptor)
-	router.Schemes(api.apiConfig.Scheme)
-
-	subscriptionRouter := router.PathPrefix(api.apiConfig.BasePath).Subrouter()
-	subscriptionRouter.HandleFunc("/subscriptions", subscribe).Methods(http.MethodPost)
-	subscriptionRouter.HandleFunc("/subscriptions/{subscriptionId}", unsubscribe).Methods(http.MethodDelete)
-	subscriptionRouter.HandleFunc("/subscriptions/{subscriptionId}", getSubscription).Methods(http.MethodGet)
-	subscriptionRouter.HandleFunc("/subscriptions/{subscriptionId}", updateSubscription).Methods(http.MethodPut)
-	subscriptionRouter.HandleFunc("/swagger.json", swagger).Methods(http.MethodGet)
+	address := fmt.Sprintf("%s:%d", api.apiConfig.BindAddress, api.apiConfig.Port)
+	api.server = &http.Server{Addr: address, Handler: api.router()}
 
 	go func() {
-		address := fmt.Sprintf("%s:%d", api.apiConfig.BindAddress, api.apiConfig.Port)
 		log.Info("start subscription api at: %s://%s%s", api.apiConfig.Scheme, address, api.apiConfig.BasePath)
 
 		var err error
 		if strings.ToUpper(api.apiConfig.Scheme) == "HTTPS" {
-			err = http.ListenAndServeTLS(address, api.apiConfig.CertificateFile, api.apiConfig.PrivateKeyFile, router)
+			err = api.server.ListenAndServeTLS(api.apiConfig.CertificateFile, api.apiConfig.PrivateKeyFile)
 		} else {
-			err = http.ListenAndServe(address, router)
+			err = api.server.ListenAndServe()
 		}
 
-		if err != nil {
+		if err != nil && err != http.ErrServerClosed {
 			log.Error("failed to start subscription api: %v", err)
 		}
 	}()
 }
 
+func (api *api) Stop(ctx context.Context) error {
+	if api.server == nil {
+		return nil
+	}
+	log.Info("stop subscription api")
+	return api.server.Shutdown(ctx)
+}
+
+func (api *api) router() *mux.Router {
+	router := mux.NewRouter()
+	router.Use(logInterceptor)
+	router.Schemes(api.apiConfig.Scheme)
+
+	// the orchestrator probes the health without authentication
+	router.HandleFunc(livenessPath, liveness).Methods(http.MethodGet)
+	router.HandleFunc(readinessPath, api.readiness).Methods(http.MethodGet)
+	router.Handle(metricsPath, promhttp.Handler()).Methods(http.MethodGet)
+
+	apiRouter := router.PathPrefix(api.apiConfig.BasePath).Subrouter()
+	apiRouter.HandleFunc("/swagger.json", swagger).Methods(http.MethodGet)
+
+	// all other endpoints require authentication
+	if !api.authenticationEnabled() {
+		log.Warn("no admin api key or jwt issuer configured: the subscription api is not protected, the api keys and the admin endpoints are refused")
+	}
+	subscriptionRouter := apiRouter.NewRoute().Subrouter()
+	subscriptionRouter.Use(api.authenticate)
+	subscriptionRouter.HandleFunc("/subscriptions", api.subscribe).Methods(http.MethodPost)
+	subscriptionRouter.HandleFunc("/subscriptions", listSubscriptions).Methods(http.MethodGet)
+	subscriptionRouter.HandleFunc("/subscriptions/{subscriptionId}", unsubscribe).Methods(http.MethodDelete)
+	subscriptionRouter.HandleFunc("/subscriptions/{subscriptionId}", getSubscription).Methods(http.MethodGet)
+	subscriptionRouter.HandleFunc("/subscriptions/{subscriptionId}", api.updateSubscription).Methods(http.MethodPut)
+	subscriptionRouter.HandleFunc("/subscriptions/{subscriptionId}", api.patchSubscription).Methods(http.MethodPatch)
+	subscriptionRouter.HandleFunc("/subscriptions/{subscriptionId}/test", testDelivery).Methods(http.MethodPost)
+	subscriptionRouter.HandleFunc("/subscriptions/{subscriptionId}/verification", api.resendVerification).Methods(http.MethodPost)
+	subscriptionRouter.HandleFunc("/apikeys", requireAdmin(createAPIKey)).Methods(http.MethodPost)
+	subscriptionRouter.HandleFunc("/apikeys", requireAdmin(listAPIKeys)).Methods(http.MethodGet)
+	subscriptionRouter.HandleFunc("/apikeys/{id}", requireAdmin(deleteAPIKey)).Methods(http.MethodDelete)
+
+	// the operators manage the subscriptions of all owners
+	adminRouter := subscriptionRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(requireAdminRole)
+	adminRouter.HandleFunc("/subscriptions", listAllSubscriptions).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/subscriptions/{subscriptionId}/status", api.changeSubscriptionStatus).Methods(http.MethodPut)
+	adminRouter.HandleFunc("/subscriptions/{subscriptionId}/queue", api.getSubscriptionQueue).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/subscriptions/{subscriptionId}/queue", api.purgeSubscriptionQueue).Methods(http.MethodDelete)
+	return router
+}
+
 func swagger(writer http.ResponseWriter, _ *http.Request) {
 	swagger, err := swag.ReadDoc()
 	if err != nil {
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 	docs "github.com/FactomProject/live-feed-api/EventRouter/swagger"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"testing"
@@ -162,8 +164,8 @@ func testSubscriptionAPI(t *testing.T, scheme string, port int) {
 			URL:          "/subscriptions/unknown",
 			Method:       http.MethodGet,
 			content:      nil,
-			responseCode: http.StatusBadRequest,
-			assert:       assertInvalidRequestError,
+			responseCode: http.StatusNotFound,
+			assert:       assertNotFoundError,
 		},
 		"update-subscription": {
 			URL:          "/subscriptions/id",
@@ -196,7 +198,7 @@ func testSubscriptionAPI(t *testing.T, scheme string, port int) {
 			Method:       http.MethodPut,
 			content:      content(t, testSubscription),
 			responseCode: http.StatusNotFound,
-			assert:       assertInvalidRequestError,
+			assert:       assertNotFoundError,
 		},
 		"update-id-mismatch": {
 			URL:    "/subscriptions/id",
@@ -251,6 +253,20 @@ func testSubscriptionAPI(t *testing.T, scheme string, port int) {
 			responseCode: http.StatusMethodNotAllowed,
 			assert:       assertEmptyResponse,
 		},
+		"list-subscriptions": {
+			URL:          "/subscriptions?status=SUSPENDED&label=team:explorer&limit=10",
+			Method:       http.MethodGet,
+			content:      nil,
+			responseCode: http.StatusOK,
+			assert:       assertSubscriptionList,
+		},
+		"list-subscriptions-invalid-limit": {
+			URL:          "/subscriptions?limit=1000",
+			Method:       http.MethodGet,
+			content:      nil,
+			responseCode: http.StatusBadRequest,
+			assert:       assertInvalidRequestError,
+		},
 		"swagger": {
 			URL:          "/swagger.json",
 			Method:       http.MethodGet,
@@ -269,6 +285,7 @@ func testSubscriptionAPI(t *testing.T, scheme string, port int) {
 	mockStore.On("UpdateSubscription", "id").Return(nil, nil).Twice()
 	mockStore.On("UpdateSubscription", "unknown-id").Return(nil, errors.NewSubscriptionNotFound("unknown")).Once()
 	mockStore.On("DeleteSubscription", "0").Return(nil).Once()
+	mockStore.On("ListSubscriptions", models.SubscriptionQuery{Status: models.Suspended, Labels: map[string]string{"team": "explorer"}, Limit: 10}).Return(models.SubscriptionContexts{suspendedSubscriptionContext}, 11, nil).Once()
 
 	for name, testCase := range testCases {
 		t.Run(name, func(t *testing.T) {
@@ -302,13 +319,44 @@ func testSubscriptionAPI(t *testing.T, scheme string, port int) {
 	mockStore.AssertExpectations(t)
 }
 
+func TestStopAPI(t *testing.T) {
+	// reserve a free port for the api
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+	subscriptionAPI := NewSubscriptionAPI(&config.SubscriptionConfig{Scheme: "HTTP", Port: uint16(port), BasePath: basePath}, nil, nil)
+
+	// an api that is not started stops immediately
+	assert.Nil(t, subscriptionAPI.Stop(context.Background()))
+
+	subscriptionAPI.Start()
+	time.Sleep(100 * time.Millisecond)
+	url := fmt.Sprintf("http://localhost:%d%s", port, livenessPath)
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("failed to get response: %v", err)
+	}
+	_ = response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, subscriptionAPI.Stop(ctx))
+
+	_, err = http.Get(url)
+	assert.NotNil(t, err)
+}
+
 func startAPI(configuration *config.SubscriptionConfig) {
 	// use info from swagger to init will be called to register the swagger which is provided through an endpoint
 	info := docs.SwaggerInfo
 	log.Info("start %s api %s %s", configuration.Scheme, info.Title, info.Version)
 
 	// Start the new server at random port
-	server := NewSubscriptionAPI(configuration)
+	server := NewSubscriptionAPI(configuration, nil, nil)
 	server.Start()
 
 	time.Sleep(1 * time.Second)
@@ -349,6 +397,22 @@ func assertSubscribe(t *testing.T, expected *models.Subscription, body []byte) {
 	assert.NotNil(t, actual.ID)
 }
 
+func assertSubscriptionList(t *testing.T, body []byte) {
+	var actual models.SubscriptionList
+	err := json.Unmarshal(body, &actual)
+	if err != nil {
+		t.Fatalf("unmarshalling failed: %v", err)
+	}
+
+	assert.Equal(t, 0, actual.Offset)
+	assert.Equal(t, 10, actual.Limit)
+	assert.Equal(t, 11, actual.Total)
+	if assert.Equal(t, 1, len(actual.Subscriptions)) {
+		assert.Equal(t, suspendedSubscription.CallbackURL, actual.Subscriptions[0].CallbackURL)
+		assert.Equal(t, suspendedSubscription.SubscriptionStatus, actual.Subscriptions[0].SubscriptionStatus)
+	}
+}
+
 func assertEmptyResponse(t *testing.T, body []byte) {
 	assert.Equal(t, "", string(body))
 }
@@ -378,6 +442,13 @@ func assertInternalError(t *testing.T, body []byte) {
 	assert.Equal(t, errors.NewInternalError("").Code, result.Code)
 }
 
+func assertNotFoundError(t *testing.T, body []byte) {
+	result := parseAPIBody(t, body)
+
+	assert.Equal(t, "not found", result.Message)
+	assert.Equal(t, errors.NewNotFound("").Code, result.Code)
+}
+
 func assertNotFound(t *testing.T, body []byte) {
 	assert.Equal(t, "404 page not found\n", string(body))
 }
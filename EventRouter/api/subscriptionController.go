@@ -1,16 +1,35 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/events"
 	"github.com/FactomProject/live-feed-api/EventRouter/log"
 	"github.com/FactomProject/live-feed-api/EventRouter/models"
 	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
 	"github.com/FactomProject/live-feed-api/EventRouter/repository"
 	"github.com/gorilla/mux"
+	"golang.org/x/net/http/httpguts"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
+const (
+	defaultListLimit = 25
+	maxListLimit     = 100
+
+	maxLabels           = 32
+	maxLabelValueLength = 255
+)
+
+// label names are restricted such that they can be used in query parameters, e.g. ?label=team:explorer
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,63}$`)
+
 // @Summary subscribe an application
 // @Description Subscribe an application to receive events.
 // @Accept  json
@@ -18,50 +37,78 @@ import (
 // @Param subscription body models.Subscription true "subscription to be created"
 // @Success 201 {object} models.Subscription "subscription created"
 // @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /subscriptions [post]
-func subscribe(writer http.ResponseWriter, request *http.Request) {
+func (api *api) subscribe(writer http.ResponseWriter, request *http.Request) {
 	subscription := &models.Subscription{}
 	if decode(writer, request, subscription) {
 		return
 	}
 
-	// ignore user input info message
+	// ignore user input info message, the owner is the authenticated client
 	subscription.SubscriptionInfo = ""
+	subscription.Owner = requestPrincipal(request).owner()
 	if subscription.SubscriptionStatus == "" {
 		subscription.SubscriptionStatus = models.Active
 	}
+	if subscription.CallbackMethod == "" {
+		subscription.CallbackMethod = http.MethodPost
+	}
 
 	if err := validateSubscription(subscription); err != nil {
 		log.Debug("invalid subscribe request %v: %v", subscription, err)
 		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(err.Error()))
 		return
 	}
+	// the subscription gets the requested status when the callback is verified
+	verify := api.requiresVerification(subscription)
+	verifiedStatus := subscription.SubscriptionStatus
+	if verify {
+		subscription.SubscriptionStatus = models.PendingVerification
+	}
+
 	subscriptionContext := &models.SubscriptionContext{
 		Subscription: *subscription,
 		Failures:     0,
 	}
 
-	subscriptionContext, err := repository.SubscriptionRepository.CreateSubscription(subscriptionContext)
+	subscriptionContext, err := repository.SubscriptionRepository.CreateSubscription(request.Context(), subscriptionContext)
 	if err != nil {
 		log.Error("	%v", err)
 		responseError(writer, http.StatusInternalServerError, errors.NewInternalError(fmt.Sprintf("failed to store subscription: %v", err)))
 		return
 	}
 
+	if verify {
+		subscriptionContext, err = api.verifyCallback(request.Context(), subscriptionContext, verifiedStatus)
+		if err != nil {
+			responseError(writer, http.StatusInternalServerError, errors.NewInternalError(fmt.Sprintf("failed to verify subscription: %v", err)))
+			return
+		}
+	}
+
 	respondCode(writer, http.StatusCreated, subscriptionContext.Subscription)
 }
 
 // @Summary update a subscription
-// @Description Update a subscription for receiving events. Updating the subscription can be used to change the endpoint url, adjust the filtering, add of remove the subscription for event types. When the subscription failed to deliver and got SUSPENDED, the endpoint can used to re-ACTIVATE the subscription.
+// @Description Update a subscription for receiving events. Updating the subscription can be used to change the endpoint url, adjust the filtering, add of remove the subscription for event types. When the subscription failed to deliver and got SUSPENDED, the endpoint can used to re-ACTIVATE the subscription. With the If-Match header the update only succeeds when the subscription still has the given entity tag.
 // @Accept  json
 // @Produce  json
 // @Param id path int true "subscription id"
 // @Param subscription body models.Subscription true "subscription to be updated"
+// @Param If-Match header string false "entity tag of the subscription, the update fails when the subscription has been changed"
 // @Success 200 {object} models.Subscription "subscription updated"
+// @Header 200 {string} ETag "entity tag of the updated subscription"
 // @Failure 400 {object} models.APIError
 // @Failure 404 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 412 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /subscriptions/{id} [put]
-func updateSubscription(writer http.ResponseWriter, request *http.Request) {
+func (api *api) updateSubscription(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 
 	subscription := &models.Subscription{}
@@ -76,11 +123,32 @@ func updateSubscription(writer http.ResponseWriter, request *http.Request) {
 	}
 	subscription.ID = id
 
-	// ignore user input message
+	// only the owner can update the subscription, the owner cannot be changed
+	subscription.Owner = ""
+	version := int64(0)
+	var existing *models.SubscriptionContext
+	if principal := requestPrincipal(request); principal != nil || request.Header.Get(ifMatchHeader) != "" || api.requiresVerification(subscription) {
+		var err error
+		existing, err = repository.SubscriptionRepository.ReadSubscription(request.Context(), id)
+		if !readOwnSubscription(writer, principal, id, existing, err) {
+			return
+		}
+		subscription.Owner = existing.Subscription.Owner
+
+		var ok bool
+		if version, ok = checkIfMatch(writer, request, existing); !ok {
+			return
+		}
+	}
+
+	// ignore user input message, the verification of the callback cannot be skipped
 	subscription.SubscriptionInfo = ""
-	if subscription.SubscriptionStatus == "" {
+	if subscription.SubscriptionStatus == "" || subscription.SubscriptionStatus == models.PendingVerification {
 		subscription.SubscriptionStatus = models.Active
 	}
+	if subscription.CallbackMethod == "" {
+		subscription.CallbackMethod = http.MethodPost
+	}
 
 	if err := validateSubscription(subscription); err != nil {
 		log.Debug("invalid subscribe request %v: %v", subscription, err)
@@ -91,17 +159,134 @@ func updateSubscription(writer http.ResponseWriter, request *http.Request) {
 	subscriptionContext := &models.SubscriptionContext{
 		Subscription: *subscription,
 		Failures:     0,
+		Version:      version,
 	}
 
-	subscriptionContext, err := repository.SubscriptionRepository.UpdateSubscription(subscriptionContext)
+	api.respondUpdatedSubscription(request.Context(), writer, subscriptionContext, existing)
+}
+
+// @Summary patch a subscription
+// @Description Partially update a subscription with a JSON merge patch (RFC 7396). Only the given fields are changed, a field that is null is removed, for example a filter of an event type. The patched subscription is validated like an updated subscription. Patching the status re-activates a SUSPENDED subscription. With the If-Match header the patch only succeeds when the subscription still has the given entity tag.
+// @Accept  json
+// @Produce  json
+// @Param id path int true "subscription id"
+// @Param patch body models.Subscription true "merge patch with the fields to change"
+// @Param If-Match header string false "entity tag of the subscription, the patch fails when the subscription has been changed"
+// @Success 200 {object} models.Subscription "subscription patched"
+// @Header 200 {string} ETag "entity tag of the patched subscription"
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Failure 412 {object} models.APIError
+// @Failure 415 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /subscriptions/{id} [patch]
+func (api *api) patchSubscription(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	if contentType := request.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != mergePatchContentType && mediaType != "application/json") {
+			responseError(writer, http.StatusUnsupportedMediaType, errors.NewInvalidRequestDetailed(fmt.Sprintf("unsupported content type: should be %s", mergePatchContentType)))
+			return
+		}
+	}
+
+	patch, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		log.Error("failed to read request: %v", err)
+		responseError(writer, http.StatusBadRequest, errors.NewParseError())
+		return
+	}
+
+	// only the owner can patch the subscription
+	id := vars["subscriptionId"]
+	existing, err := repository.SubscriptionRepository.ReadSubscription(request.Context(), id)
+	if !readOwnSubscription(writer, requestPrincipal(request), id, existing, err) {
+		return
+	}
+	version, ok := checkIfMatch(writer, request, existing)
+	if !ok {
+		return
+	}
+
+	subscription, err := applyMergePatch(&existing.Subscription, patch)
+	if err != nil {
+		log.Debug("invalid patch request %s: %v", patch, err)
+		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(err.Error()))
+		return
+	}
+	if subscription.ID != id {
+		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed("subscription id doesn't match"))
+		return
+	}
+
+	// the owner cannot be changed and the info is read only, the failures are reset when the status changes
+	subscription.Owner = existing.Subscription.Owner
+	subscription.SubscriptionInfo = existing.Subscription.SubscriptionInfo
+	failures := existing.Failures
+	if subscription.SubscriptionStatus != existing.Subscription.SubscriptionStatus {
+		subscription.SubscriptionInfo = ""
+		failures = 0
+	}
+	if subscription.SubscriptionStatus == models.PendingVerification {
+		subscription.SubscriptionStatus = models.Active
+	}
+	if subscription.CallbackMethod == "" {
+		subscription.CallbackMethod = http.MethodPost
+	}
+
+	if err := validateSubscription(subscription); err != nil {
+		log.Debug("invalid patch request %v: %v", subscription, err)
+		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(err.Error()))
+		return
+	}
+
+	subscriptionContext := &models.SubscriptionContext{
+		Subscription: *subscription,
+		Failures:     failures,
+		Version:      version,
+	}
+
+	api.respondUpdatedSubscription(request.Context(), writer, subscriptionContext, existing)
+}
+
+// respondUpdatedSubscription stores the updated subscription and responds with the subscription and its new entity tag,
+// a version that is outdated in the mean time fails the precondition. A changed callback url is verified again.
+func (api *api) respondUpdatedSubscription(ctx context.Context, writer http.ResponseWriter, subscriptionContext *models.SubscriptionContext, existing *models.SubscriptionContext) {
+	verify := api.verificationStatus(existing, &subscriptionContext.Subscription)
+	verifiedStatus := subscriptionContext.Subscription.SubscriptionStatus
+	if verify {
+		subscriptionContext.Subscription.SubscriptionStatus = models.PendingVerification
+	}
+
+	subscriptionContext, err := repository.SubscriptionRepository.UpdateSubscription(ctx, subscriptionContext)
 	if notFoundError, ok := err.(errors.SubscriptionNotFound); ok {
-		responseError(writer, http.StatusNotFound, errors.NewInvalidRequestDetailed(notFoundError.Error()))
+		responseError(writer, http.StatusNotFound, errors.NewNotFound(notFoundError.Error()))
+		return
+	} else if conflictError, ok := err.(errors.SubscriptionConflict); ok {
+		responseError(writer, http.StatusPreconditionFailed, errors.NewPreconditionFailed(conflictError.Error()))
 		return
 	} else if err != nil {
 		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(err.Error()))
 		return
 	}
 
+	if verify {
+		subscriptionContext, err = api.verifyCallback(ctx, subscriptionContext, verifiedStatus)
+		if err != nil {
+			responseError(writer, http.StatusInternalServerError, errors.NewInternalError(fmt.Sprintf("failed to verify subscription: %v", err)))
+			return
+		}
+	}
+
+	// the router delivers the queued events with the changed subscription
+	if api.queues != nil {
+		api.queues.UpdateSubscription(ctx, subscriptionContext)
+	}
+
+	writer.Header().Set(etagHeader, etag(subscriptionContext.Version))
 	respond(writer, subscriptionContext.Subscription)
 }
 
@@ -111,21 +296,116 @@ func updateSubscription(writer http.ResponseWriter, request *http.Request) {
 // @Produce  json
 // @Param id path int true "subscription id"
 // @Success 200 {object} models.Subscription "subscription"
+// @Header 200 {string} ETag "entity tag of the subscription"
 // @Failure 400 {object} models.APIError
 // @Failure 404 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /subscriptions/{id} [get]
 func getSubscription(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 
 	id := vars["subscriptionId"]
 
-	subscriptionContext, err := repository.SubscriptionRepository.ReadSubscription(id)
+	subscriptionContext, err := repository.SubscriptionRepository.ReadSubscription(request.Context(), id)
+	if !readOwnSubscription(writer, requestPrincipal(request), id, subscriptionContext, err) {
+		return
+	}
+
+	writer.Header().Set(etagHeader, etag(subscriptionContext.Version))
+	respond(writer, subscriptionContext.Subscription)
+}
+
+// @Summary test the delivery to a subscription
+// @Description Send a synthetic event of the event type to the subscription right away, to check the endpoint without waiting for a real event. The event is filtered and rendered with the filter of the event type, like a real event. The hashes and signatures of the synthetic event are not valid. The failures, status and queue of the subscription are not affected. The delivery result contains the status code, the latency and the body of the response of the endpoint. Only subscriptions that deliver events over http can be tested.
+// @Accept  json
+// @Produce  json
+// @Param id path int true "subscription id"
+// @Param deliveryTest body models.DeliveryTest true "the event type of the synthetic event"
+// @Success 200 {object} models.DeliveryResult "delivery result"
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /subscriptions/{id}/test [post]
+func testDelivery(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	deliveryTest := &models.DeliveryTest{}
+	if decode(writer, request, deliveryTest) {
+		return
+	}
+	if !validEventType(deliveryTest.EventType) {
+		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(fmt.Sprintf("invalid event type: %s", deliveryTest.EventType)))
+		return
+	}
+
+	// only the owner can test the subscription
+	id := vars["subscriptionId"]
+	subscriptionContext, err := repository.SubscriptionRepository.ReadSubscription(request.Context(), id)
+	if !readOwnSubscription(writer, requestPrincipal(request), id, subscriptionContext, err) {
+		return
+	}
+
+	result, err := events.TestDelivery(&subscriptionContext.Subscription, deliveryTest.EventType)
 	if err != nil {
+		log.Debug("invalid test delivery request of subscription %s: %v", id, err)
 		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(err.Error()))
 		return
 	}
 
-	respond(writer, subscriptionContext.Subscription)
+	respond(writer, result)
+}
+
+// @Summary list subscriptions
+// @Description List the subscriptions ordered by id. The subscriptions can be filtered on status, event type, callback host and labels. Subscriptions match when they match all the given criteria.
+// @Accept  json
+// @Produce  json
+// @Param status query string false "subscription status" Enums(ACTIVE, SUSPENDED, PAUSED, PENDING_VERIFICATION)
+// @Param eventType query string false "event type of one of the filters" Enums(DIRECTORY_BLOCK_COMMIT, DIRECTORY_BLOCK_ANCHOR, CHAIN_COMMIT, ENTRY_COMMIT, ENTRY_REVEAL, STATE_CHANGE, PROCESS_LIST_EVENT, NODE_MESSAGE)
+// @Param callbackHost query string false "host of the callback url"
+// @Param label query []string false "label formatted as name:value, the parameter can be repeated to match multiple labels" collectionFormat(multi)
+// @Param offset query int false "offset of the first subscription" default(0)
+// @Param limit query int false "maximum number of subscriptions" default(25) maximum(100)
+// @Success 200 {object} models.SubscriptionList "subscriptions"
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /subscriptions [get]
+func listSubscriptions(writer http.ResponseWriter, request *http.Request) {
+	query, err := parseSubscriptionQuery(request.URL.Query())
+	if err != nil {
+		log.Debug("invalid list subscriptions request %v: %v", request.URL.Query(), err)
+		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(err.Error()))
+		return
+	}
+	query.Owner = requestPrincipal(request).owner()
+
+	respondSubscriptionList(request.Context(), writer, query)
+}
+
+func respondSubscriptionList(ctx context.Context, writer http.ResponseWriter, query *models.SubscriptionQuery) {
+	subscriptionContexts, total, err := repository.SubscriptionRepository.ListSubscriptions(ctx, query)
+	if err != nil {
+		log.Error("%v", err)
+		responseError(writer, http.StatusInternalServerError, errors.NewInternalError(fmt.Sprintf("failed to list subscriptions: %v", err)))
+		return
+	}
+
+	subscriptionList := models.SubscriptionList{
+		Subscriptions: make([]models.Subscription, 0, len(subscriptionContexts)),
+		Offset:        query.Offset,
+		Limit:         query.Limit,
+		Total:         total,
+	}
+	for _, subscriptionContext := range subscriptionContexts {
+		subscriptionList.Subscriptions = append(subscriptionList.Subscriptions, subscriptionContext.Subscription)
+	}
+
+	respond(writer, subscriptionList)
 }
 
 // @Summary delete a subscription
@@ -136,62 +416,266 @@ func getSubscription(writer http.ResponseWriter, request *http.Request) {
 // @Success 200 "subscription deleted"
 // @Failure 400 {object} models.APIError
 // @Failure 404 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /subscriptions/{id} [delete]
 func unsubscribe(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 
 	id := vars["subscriptionId"]
-	err := repository.SubscriptionRepository.DeleteSubscription(id)
+
+	// only the owner can delete the subscription
+	if principal := requestPrincipal(request); principal != nil {
+		existing, err := repository.SubscriptionRepository.ReadSubscription(request.Context(), id)
+		if !readOwnSubscription(writer, principal, id, existing, err) {
+			return
+		}
+	}
+
+	err := repository.SubscriptionRepository.DeleteSubscription(request.Context(), id)
 	if err != nil {
 		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(err.Error()))
 		return
 	}
 }
 
+// readOwnSubscription checks that the read subscription exists and is owned by the principal, subscriptions of other
+// owners are reported as not found
+func readOwnSubscription(writer http.ResponseWriter, principal *principal, id string, subscriptionContext *models.SubscriptionContext, err error) bool {
+	if _, ok := err.(errors.SubscriptionNotFound); ok || (err == nil && !principal.owns(&subscriptionContext.Subscription)) {
+		responseError(writer, http.StatusNotFound, errors.NewNotFound(errors.NewSubscriptionNotFound(id).Error()))
+		return false
+	} else if err != nil {
+		responseError(writer, http.StatusInternalServerError, errors.NewInternalError(fmt.Sprintf("failed to read subscription: %v", err)))
+		return false
+	}
+	return true
+}
+
+func parseSubscriptionQuery(values url.Values) (*models.SubscriptionQuery, error) {
+	query := &models.SubscriptionQuery{
+		Status:       models.SubscriptionStatus(values.Get("status")),
+		EventType:    models.EventType(values.Get("eventType")),
+		CallbackHost: values.Get("callbackHost"),
+		Limit:        defaultListLimit,
+	}
+
+	switch query.Status {
+	case "", models.Active, models.Suspended, models.Paused, models.PendingVerification:
+	default:
+		return nil, fmt.Errorf("unknown subscription status: should be one of [%s, %s, %s, %s]", models.Active, models.Suspended, models.Paused, models.PendingVerification)
+	}
+
+	if query.EventType != "" && !validEventType(query.EventType) {
+		return nil, fmt.Errorf("invalid event type: %s", query.EventType)
+	}
+
+	for _, label := range values["label"] {
+		parts := strings.SplitN(label, ":", 2)
+		if len(parts) != 2 || !labelNamePattern.MatchString(parts[0]) {
+			return nil, fmt.Errorf("invalid label '%s': should be name:value", label)
+		}
+		if query.Labels == nil {
+			query.Labels = make(map[string]string)
+		}
+		query.Labels[parts[0]] = parts[1]
+	}
+
+	if offset := values.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid offset: '%s'", offset)
+		}
+		query.Offset = n
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxListLimit {
+			return nil, fmt.Errorf("invalid limit: '%s' should be between 1 and %d", limit, maxListLimit)
+		}
+		query.Limit = n
+	}
+	return query, nil
+}
+
 func validateSubscription(subscription *models.Subscription) error {
-	u, err := url.ParseRequestURI(subscription.CallbackURL)
-	if err != nil || u.Scheme == "" || u.Host == "" {
-		return fmt.Errorf("invalid callback url: %v", err)
+	// the callback url of a file is a path within the file sink directory, the callback url of a relay is a tcp address
+	switch subscription.CallbackType {
+	case models.File:
+		if err := events.ValidateFilePath(subscription.CallbackURL); err != nil {
+			return fmt.Errorf("invalid callback url: %v", err)
+		}
+	case models.Relay:
+		if _, err := events.RelayAddress(subscription.CallbackURL); err != nil {
+			return fmt.Errorf("invalid callback url: %v", err)
+		}
+	default:
+		u, err := url.ParseRequestURI(subscription.CallbackURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid callback url: %v", err)
+		}
 	}
 
+	credentials := subscription.Credentials
 	switch subscription.CallbackType {
-	case models.HTTP:
-		if subscription.Credentials.AccessToken != "" || subscription.Credentials.BasicAuthUsername != "" || subscription.Credentials.BasicAuthPassword != "" {
+	case models.HTTP, models.File, models.Relay:
+		if credentials.AccessToken != "" || credentials.BasicAuthUsername != "" || credentials.BasicAuthPassword != "" ||
+			credentials.OAuth2TokenURL != "" || credentials.OAuth2ClientID != "" || credentials.OAuth2ClientSecret != "" || len(credentials.OAuth2Scopes) > 0 {
 			return fmt.Errorf("credentials are set but will not be used")
 		}
 	case models.BearerToken:
-		if subscription.Credentials.AccessToken == "" {
+		if credentials.AccessToken == "" {
 			return fmt.Errorf("access token required")
 		}
 	case models.BasicAuth:
-		if subscription.Credentials.BasicAuthUsername == "" || subscription.Credentials.BasicAuthPassword == "" {
+		if credentials.BasicAuthUsername == "" || credentials.BasicAuthPassword == "" {
 			return fmt.Errorf("username and password are required")
 		}
+	case models.OAuth2ClientCredentials:
+		if credentials.OAuth2ClientID == "" || credentials.OAuth2ClientSecret == "" {
+			return fmt.Errorf("client id and client secret are required")
+		}
+		if u, err := url.ParseRequestURI(credentials.OAuth2TokenURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid token url: '%s'", credentials.OAuth2TokenURL)
+		}
+	default:
+		return fmt.Errorf("unknown callback type: should be one of [%s,%s,%s,%s,%s,%s]", models.HTTP, models.BasicAuth, models.BearerToken, models.OAuth2ClientCredentials, models.File, models.Relay)
+	}
+
+	if err := validateFileSink(subscription); err != nil {
+		return err
+	}
+
+	switch subscription.CallbackMethod {
+	case "", http.MethodPost:
+	case http.MethodPut:
+	case http.MethodPatch:
 	default:
-		return fmt.Errorf("unknown callback type: should be one of [%s,%s,%s]", models.HTTP, models.BasicAuth, models.BearerToken)
+		return fmt.Errorf("unknown callback method: should be one of [%s, %s, %s]", http.MethodPost, http.MethodPut, http.MethodPatch)
+	}
+
+	if err := validateCallbackHeaders(subscription); err != nil {
+		return err
 	}
 
 	for eventType := range subscription.Filters {
-		switch eventType {
-		case models.DirectoryBlockAnchor:
-		case models.DirectoryBlockCommit:
-		case models.ChainCommit:
-		case models.EntryCommit:
-		case models.EntryReveal:
-		case models.StateChange:
-		case models.ProcessListEvent:
-		case models.NodeMessage:
-		default:
+		if !validEventType(eventType) {
 			return fmt.Errorf("invalid event type: %s", eventType)
 		}
 	}
 
+	for eventType, filter := range subscription.Filters {
+		// the complete events are relayed
+		if subscription.CallbackType == models.Relay && (filter.Filtering != "" || filter.Template != "") {
+			return fmt.Errorf("filtering of %s is set but will not be used", eventType)
+		}
+		if filter.Template == "" {
+			continue
+		}
+		if err := events.ValidateTemplate(filter.Template); err != nil {
+			return fmt.Errorf("invalid template of %s: %v", eventType, err)
+		}
+	}
+
 	switch subscription.SubscriptionStatus {
 	case models.Active:
 	case models.Suspended:
+	case models.Paused:
+	default:
+		return fmt.Errorf("unknown subscription status: should be one of [%s, %s, %s]", models.Active, models.Suspended, models.Paused)
+	}
+
+	if err := validateLabels(subscription); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validEventType(eventType models.EventType) bool {
+	switch eventType {
+	case models.DirectoryBlockAnchor:
+	case models.DirectoryBlockCommit:
+	case models.ChainCommit:
+	case models.EntryCommit:
+	case models.EntryReveal:
+	case models.StateChange:
+	case models.ProcessListEvent:
+	case models.NodeMessage:
+	default:
+		return false
+	}
+	return true
+}
+
+func validateLabels(subscription *models.Subscription) error {
+	if len(subscription.Labels) > maxLabels {
+		return fmt.Errorf("too many labels: at most %d labels are allowed", maxLabels)
+	}
+	for name, value := range subscription.Labels {
+		if !labelNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid label name: '%s'", name)
+		}
+		if len(value) > maxLabelValueLength {
+			return fmt.Errorf("invalid label value of '%s': at most %d characters are allowed", name, maxLabelValueLength)
+		}
+	}
+	return nil
+}
+
+func validateFileSink(subscription *models.Subscription) error {
+	fileSink := subscription.FileSink
+	if subscription.CallbackType != models.File {
+		if fileSink != (models.FileSink{}) {
+			return fmt.Errorf("file sink is set but will not be used")
+		}
+		return nil
+	}
+
+	switch fileSink.Format {
+	case "", models.JSONLines:
+	case models.Protobuf:
 	default:
-		return fmt.Errorf("unknown subscription status: should be one of [%s, %s]", models.Active, models.Suspended)
+		return fmt.Errorf("unknown file format: should be one of [%s, %s]", models.JSONLines, models.Protobuf)
+	}
+
+	if fileSink.MaxSize < 0 {
+		return fmt.Errorf("invalid max size of file: %d", fileSink.MaxSize)
 	}
+	return nil
+}
+
+// headers that are controlled by the live feed and cannot be set as callback header
+var reservedCallbackHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+func validateCallbackHeaders(subscription *models.Subscription) error {
+	names := make(map[string]bool, len(subscription.CallbackHeaders))
+	for name, value := range subscription.CallbackHeaders {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("invalid callback header name: '%s'", name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("invalid callback header value of: '%s'", name)
+		}
 
+		canonicalName := http.CanonicalHeaderKey(name)
+		if reservedCallbackHeaders[canonicalName] {
+			return fmt.Errorf("callback header '%s' is not allowed", name)
+		}
+		if canonicalName == "Authorization" && subscription.CallbackType != models.HTTP {
+			return fmt.Errorf("callback header '%s' conflicts with callback type %s", name, subscription.CallbackType)
+		}
+		if names[canonicalName] {
+			return fmt.Errorf("duplicate callback header: '%s'", name)
+		}
+		names[canonicalName] = true
+	}
 	return nil
 }
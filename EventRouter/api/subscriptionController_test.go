@@ -1,9 +1,18 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/config"
 	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -34,19 +43,19 @@ func TestValidateSubscription(t *testing.T) {
 		},
 		"empty url": {
 			Subscription: &models.Subscription{},
-			Error:        fmt.Errorf("invalid callback url: parse : empty url"),
+			Error:        fmt.Errorf("invalid callback url: %v", parseRequestURIError("")),
 		},
 		"invalid url": {
 			Subscription: &models.Subscription{
 				CallbackURL: "invalid-callback",
 			},
-			Error: fmt.Errorf("invalid callback url: parse invalid-callback: invalid URI for request"),
+			Error: fmt.Errorf("invalid callback url: %v", parseRequestURIError("invalid-callback")),
 		},
 		"no callback type": {
 			Subscription: &models.Subscription{
 				CallbackURL: "http://test/callback",
 			},
-			Error: fmt.Errorf("unknown callback type: should be one of [HTTP,BASIC_AUTH,BEARER_TOKEN]"),
+			Error: fmt.Errorf("unknown callback type: should be one of [HTTP,BASIC_AUTH,BEARER_TOKEN,OAUTH2_CLIENT_CREDENTIALS,FILE,RELAY]"),
 		},
 		"invalid callback type": {
 			Subscription: &models.Subscription{
@@ -54,7 +63,7 @@ func TestValidateSubscription(t *testing.T) {
 				CallbackType:       "WRONG",
 				SubscriptionStatus: models.Active,
 			},
-			Error: fmt.Errorf("unknown callback type: should be one of [HTTP,BASIC_AUTH,BEARER_TOKEN]"),
+			Error: fmt.Errorf("unknown callback type: should be one of [HTTP,BASIC_AUTH,BEARER_TOKEN,OAUTH2_CLIENT_CREDENTIALS,FILE,RELAY]"),
 		},
 		"invalid filters": {
 			Subscription: &models.Subscription{
@@ -67,6 +76,28 @@ func TestValidateSubscription(t *testing.T) {
 			},
 			Error: fmt.Errorf("invalid event type: invalid"),
 		},
+		"valid template": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				SubscriptionStatus: models.Active,
+				Filters: map[models.EventType]models.Filter{
+					models.NodeMessage: {Template: `{"text": {{json .factomNodeName}}}`},
+				},
+			},
+			Error: nil,
+		},
+		"invalid template": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				SubscriptionStatus: models.Active,
+				Filters: map[models.EventType]models.Filter{
+					models.NodeMessage: {Template: `{{unknown .factomNodeName}}`},
+				},
+			},
+			Error: fmt.Errorf(`invalid template of NODE_MESSAGE: invalid template: template: event:1: function "unknown" not defined`),
+		},
 		"invalid http": {
 			Subscription: &models.Subscription{
 				CallbackURL:        "http://test/callback",
@@ -110,13 +141,211 @@ func TestValidateSubscription(t *testing.T) {
 			},
 			Error: fmt.Errorf("access token required"),
 		},
+		"valid oauth2 client credentials": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.OAuth2ClientCredentials,
+				SubscriptionStatus: models.Active,
+				Credentials:        models.Credentials{OAuth2TokenURL: "https://auth/token", OAuth2ClientID: "client", OAuth2ClientSecret: "secret", OAuth2Scopes: []string{"events"}},
+			},
+			Error: nil,
+		},
+		"invalid oauth2 client credentials": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.OAuth2ClientCredentials,
+				SubscriptionStatus: models.Active,
+				Credentials:        models.Credentials{OAuth2TokenURL: "https://auth/token"},
+			},
+			Error: fmt.Errorf("client id and client secret are required"),
+		},
+		"invalid oauth2 token url": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.OAuth2ClientCredentials,
+				SubscriptionStatus: models.Active,
+				Credentials:        models.Credentials{OAuth2TokenURL: "/token", OAuth2ClientID: "client", OAuth2ClientSecret: "secret"},
+			},
+			Error: fmt.Errorf("invalid token url: '/token'"),
+		},
+		"valid callback method and headers": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				CallbackMethod:     "PUT",
+				CallbackHeaders:    map[string]string{"X-Api-Key": "key", "Content-Type": "application/json"},
+				SubscriptionStatus: models.Active,
+			},
+			Error: nil,
+		},
+		"invalid callback method": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				CallbackMethod:     "GET",
+				SubscriptionStatus: models.Active,
+			},
+			Error: fmt.Errorf("unknown callback method: should be one of [POST, PUT, PATCH]"),
+		},
+		"invalid callback header name": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				CallbackHeaders:    map[string]string{"X Api Key": "key"},
+				SubscriptionStatus: models.Active,
+			},
+			Error: fmt.Errorf("invalid callback header name: 'X Api Key'"),
+		},
+		"invalid callback header value": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				CallbackHeaders:    map[string]string{"X-Api-Key": "key\r\nHost: other"},
+				SubscriptionStatus: models.Active,
+			},
+			Error: fmt.Errorf("invalid callback header value of: 'X-Api-Key'"),
+		},
+		"reserved callback header": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				CallbackHeaders:    map[string]string{"host": "other"},
+				SubscriptionStatus: models.Active,
+			},
+			Error: fmt.Errorf("callback header 'host' is not allowed"),
+		},
+		"conflicting authorization header": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.BearerToken,
+				CallbackHeaders:    map[string]string{"Authorization": "Bearer other"},
+				SubscriptionStatus: models.Active,
+				Credentials:        models.Credentials{AccessToken: "test"},
+			},
+			Error: fmt.Errorf("callback header 'Authorization' conflicts with callback type BEARER_TOKEN"),
+		},
+		"valid file": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "archive/{{.EventType}}/{{.BlockHeight}}.jsonl",
+				CallbackType:       models.File,
+				SubscriptionStatus: models.Active,
+				FileSink:           models.FileSink{Format: models.JSONLines, MaxSize: 1024, MaxAge: 3600, Compress: true},
+			},
+			Error: nil,
+		},
+		"file outside file sink directory": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "../{{.EventType}}.jsonl",
+				CallbackType:       models.File,
+				SubscriptionStatus: models.Active,
+			},
+			Error: fmt.Errorf("invalid callback url: invalid file path '../DIRECTORY_BLOCK_COMMIT.jsonl': the path should be relative within the file sink directory"),
+		},
+		"invalid file path template": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "{{.Unknown}}.jsonl",
+				CallbackType:       models.File,
+				SubscriptionStatus: models.Active,
+			},
+			Error: fmt.Errorf(`invalid callback url: invalid file path: template: path:1:2: executing "path" at <.Unknown>: can't evaluate field Unknown in type events.filePathData`),
+		},
+		"invalid file format": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "events.jsonl",
+				CallbackType:       models.File,
+				SubscriptionStatus: models.Active,
+				FileSink:           models.FileSink{Format: "CSV"},
+			},
+			Error: fmt.Errorf("unknown file format: should be one of [JSON_LINES, PROTOBUF]"),
+		},
+		"unused file sink": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				SubscriptionStatus: models.Active,
+				FileSink:           models.FileSink{Compress: true},
+			},
+			Error: fmt.Errorf("file sink is set but will not be used"),
+		},
+		"valid relay": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "tcp://live-feed:8040",
+				CallbackType:       models.Relay,
+				SubscriptionStatus: models.Active,
+				Filters: map[models.EventType]models.Filter{
+					models.DirectoryBlockCommit: {},
+				},
+			},
+			Error: nil,
+		},
+		"invalid relay url": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://live-feed:8040",
+				CallbackType:       models.Relay,
+				SubscriptionStatus: models.Active,
+			},
+			Error: fmt.Errorf("invalid callback url: relay url 'http://live-feed:8040' should be tcp://host:port"),
+		},
+		"relay without port": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "tcp://live-feed",
+				CallbackType:       models.Relay,
+				SubscriptionStatus: models.Active,
+			},
+			Error: fmt.Errorf("invalid callback url: relay url 'tcp://live-feed' should be tcp://host:port"),
+		},
+		"relay with filtering": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "tcp://live-feed:8040",
+				CallbackType:       models.Relay,
+				SubscriptionStatus: models.Active,
+				Filters: map[models.EventType]models.Filter{
+					models.EntryCommit: {Filtering: "filtering"},
+				},
+			},
+			Error: fmt.Errorf("filtering of ENTRY_COMMIT is set but will not be used"),
+		},
+		"paused": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				SubscriptionStatus: models.Paused,
+			},
+		},
 		"invalid status": {
 			Subscription: &models.Subscription{
 				CallbackURL:        "http://test/callback",
 				CallbackType:       models.HTTP,
 				SubscriptionStatus: "Something different",
 			},
-			Error: fmt.Errorf("unknown subscription status: should be one of [ACTIVE, SUSPENDED]"),
+			Error: fmt.Errorf("unknown subscription status: should be one of [ACTIVE, SUSPENDED, PAUSED]"),
+		},
+		"valid labels": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				SubscriptionStatus: models.Active,
+				Labels:             map[string]string{"team": "explorer", "app.kubernetes.io_name": "live-feed"},
+			},
+			Error: nil,
+		},
+		"invalid label name": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				SubscriptionStatus: models.Active,
+				Labels:             map[string]string{"team:name": "explorer"},
+			},
+			Error: fmt.Errorf("invalid label name: 'team:name'"),
+		},
+		"invalid label value": {
+			Subscription: &models.Subscription{
+				CallbackURL:        "http://test/callback",
+				CallbackType:       models.HTTP,
+				SubscriptionStatus: models.Active,
+				Labels:             map[string]string{"team": strings.Repeat("x", 256)},
+			},
+			Error: fmt.Errorf("invalid label value of 'team': at most 255 characters are allowed"),
 		},
 	}
 
@@ -127,3 +356,227 @@ func TestValidateSubscription(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSubscriptionQuery(t *testing.T) {
+	testCases := map[string]struct {
+		Query    string
+		Expected *models.SubscriptionQuery
+		Error    string
+	}{
+		"defaults": {
+			Query:    "",
+			Expected: &models.SubscriptionQuery{Limit: 25},
+		},
+		"all criteria": {
+			Query: "status=SUSPENDED&eventType=ENTRY_COMMIT&callbackHost=example.com&label=team:explorer&label=env:prod&offset=50&limit=100",
+			Expected: &models.SubscriptionQuery{
+				Status:       models.Suspended,
+				EventType:    models.EntryCommit,
+				CallbackHost: "example.com",
+				Labels:       map[string]string{"team": "explorer", "env": "prod"},
+				Offset:       50,
+				Limit:        100,
+			},
+		},
+		"label value with colon": {
+			Query:    "label=url:http://example.com",
+			Expected: &models.SubscriptionQuery{Labels: map[string]string{"url": "http://example.com"}, Limit: 25},
+		},
+		"invalid status": {
+			Query: "status=STOPPED",
+			Error: "unknown subscription status: should be one of [ACTIVE, SUSPENDED, PAUSED, PENDING_VERIFICATION]",
+		},
+		"invalid event type": {
+			Query: "eventType=BLOCK",
+			Error: "invalid event type: BLOCK",
+		},
+		"invalid label": {
+			Query: "label=team",
+			Error: "invalid label 'team': should be name:value",
+		},
+		"negative offset": {
+			Query: "offset=-1",
+			Error: "invalid offset: '-1'",
+		},
+		"limit too large": {
+			Query: "limit=101",
+			Error: "invalid limit: '101' should be between 1 and 100",
+		},
+		"invalid limit": {
+			Query: "limit=all",
+			Error: "invalid limit: 'all' should be between 1 and 100",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			values, err := url.ParseQuery(testCase.Query)
+			if !assert.Nil(t, err) {
+				return
+			}
+			query, err := parseSubscriptionQuery(values)
+			if testCase.Error != "" {
+				assert.EqualError(t, err, testCase.Error)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.Expected, query)
+		})
+	}
+}
+
+// the formatting of url errors differs between go versions
+func TestPatchSubscription(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+	_, err := repository.SubscriptionRepository.CreateAPIKey(context.Background(), &models.APIKey{Owner: "explorer", Role: models.User, KeyHash: hashAPIKey(userAPIKey)})
+	assert.Nil(t, err)
+
+	subscriptionContext, err := repository.SubscriptionRepository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        "https://explorer/events",
+			CallbackType:       models.BearerToken,
+			CallbackMethod:     http.MethodPost,
+			SubscriptionStatus: models.Suspended,
+			SubscriptionInfo:   "3: failed to deliver",
+			Owner:              "explorer",
+			Credentials:        models.Credentials{AccessToken: "token"},
+			Filters: map[models.EventType]models.Filter{
+				models.ChainCommit: {Filtering: "filtering 1"},
+			},
+			Labels: map[string]string{"team": "explorer"},
+		},
+		Failures: 3,
+	})
+	assert.Nil(t, err)
+	walletSubscription := createTestSubscription(t, "wallet")
+
+	router := NewSubscriptionAPI(&config.SubscriptionConfig{BasePath: basePath, Scheme: "HTTP", AdminAPIKey: adminAPIKey}, nil, nil).(*api).router()
+	subscriptionURL := "/subscriptions/" + subscriptionContext.Subscription.ID
+
+	testCases := []struct {
+		Name  string
+		URL   string
+		Patch string
+		Code  int
+	}{
+		{Name: "not a json object", URL: subscriptionURL, Patch: `["ACTIVE"]`, Code: http.StatusBadRequest},
+		{Name: "invalid json", URL: subscriptionURL, Patch: `{"status":`, Code: http.StatusBadRequest},
+		{Name: "invalid result", URL: subscriptionURL, Patch: `{"credentials":null}`, Code: http.StatusBadRequest},
+		{Name: "other id", URL: subscriptionURL, Patch: `{"id":"other"}`, Code: http.StatusBadRequest},
+		{Name: "other owner", URL: "/subscriptions/" + walletSubscription.Subscription.ID, Patch: `{"status":"ACTIVE"}`, Code: http.StatusNotFound},
+		{Name: "unknown", URL: "/subscriptions/unknown", Patch: `{"status":"ACTIVE"}`, Code: http.StatusNotFound},
+	}
+	for _, testCase := range testCases {
+		code, _ := patchRequest(t, router, testCase.URL, "", testCase.Patch)
+		assert.Equal(t, testCase.Code, code, testCase.Name)
+	}
+
+	code, _ := patchRequest(t, router, subscriptionURL, "text/plain", `{"status":"ACTIVE"}`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, code)
+
+	// re-activate the subscription and add an event type, the other fields are kept
+	code, body := patchRequest(t, router, subscriptionURL, mergePatchContentType, `{"status":"ACTIVE","info":"ignored","owner":"wallet","filters":{"ENTRY_REVEAL":{"filtering":"filtering 2"}}}`)
+	assert.Equal(t, http.StatusOK, code)
+	var subscription models.Subscription
+	assert.Nil(t, json.Unmarshal(body, &subscription))
+
+	expected := subscriptionContext.Subscription
+	expected.SubscriptionStatus = models.Active
+	expected.SubscriptionInfo = ""
+	expected.Filters = map[models.EventType]models.Filter{
+		models.ChainCommit: {Filtering: "filtering 1"},
+		models.EntryReveal: {Filtering: "filtering 2"},
+	}
+	assert.Equal(t, expected, subscription)
+
+	stored, err := repository.SubscriptionRepository.ReadSubscription(context.Background(), subscriptionContext.Subscription.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(0), stored.Failures)
+
+	// remove a filter and a label
+	code, body = patchRequest(t, router, subscriptionURL, "", `{"filters":{"CHAIN_COMMIT":null},"labels":{"team":null,"env":"test"}}`)
+	assert.Equal(t, http.StatusOK, code)
+	subscription = models.Subscription{}
+	assert.Nil(t, json.Unmarshal(body, &subscription))
+	assert.Equal(t, map[models.EventType]models.Filter{models.EntryReveal: {Filtering: "filtering 2"}}, subscription.Filters)
+	assert.Equal(t, map[string]string{"env": "test"}, subscription.Labels)
+	assert.Equal(t, "token", subscription.Credentials.AccessToken)
+}
+
+func TestTestDelivery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+		_, _ = writer.Write([]byte("received"))
+	}))
+	defer server.Close()
+
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+	_, err := repository.SubscriptionRepository.CreateAPIKey(context.Background(), &models.APIKey{Owner: "explorer", Role: models.User, KeyHash: hashAPIKey(userAPIKey)})
+	assert.Nil(t, err)
+
+	subscriptionContext, err := repository.SubscriptionRepository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        server.URL,
+			CallbackType:       models.HTTP,
+			SubscriptionStatus: models.Suspended,
+			Owner:              "explorer",
+			Filters: map[models.EventType]models.Filter{
+				models.EntryReveal: {},
+			},
+		},
+		Failures: 3,
+	})
+	assert.Nil(t, err)
+	walletSubscription := createTestSubscription(t, "wallet")
+
+	router := NewSubscriptionAPI(&config.SubscriptionConfig{BasePath: basePath, Scheme: "HTTP", AdminAPIKey: adminAPIKey}, nil, nil).(*api).router()
+	testURL := "/subscriptions/" + subscriptionContext.Subscription.ID + "/test"
+
+	testCases := []struct {
+		Name      string
+		URL       string
+		EventType models.EventType
+		Code      int
+	}{
+		{Name: "invalid event type", URL: testURL, EventType: "UNKNOWN", Code: http.StatusBadRequest},
+		{Name: "other event type", URL: testURL, EventType: models.ChainCommit, Code: http.StatusBadRequest},
+		{Name: "other owner", URL: "/subscriptions/" + walletSubscription.Subscription.ID + "/test", EventType: models.EntryReveal, Code: http.StatusNotFound},
+		{Name: "unknown", URL: "/subscriptions/unknown/test", EventType: models.EntryReveal, Code: http.StatusNotFound},
+	}
+	for _, testCase := range testCases {
+		code, _ := routerRequest(t, router, http.MethodPost, testCase.URL, userAPIKey, &models.DeliveryTest{EventType: testCase.EventType})
+		assert.Equal(t, testCase.Code, code, testCase.Name)
+	}
+
+	// a suspended subscription can be tested, the failures are not changed
+	code, body := routerRequest(t, router, http.MethodPost, testURL, userAPIKey, &models.DeliveryTest{EventType: models.EntryReveal})
+	assert.Equal(t, http.StatusOK, code)
+	var result models.DeliveryResult
+	assert.Nil(t, json.Unmarshal(body, &result))
+	assert.True(t, result.Delivered)
+	assert.Equal(t, models.EntryReveal, result.EventType)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "received", result.Body)
+
+	stored, err := repository.SubscriptionRepository.ReadSubscription(context.Background(), subscriptionContext.Subscription.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(3), stored.Failures)
+	assert.Equal(t, models.Suspended, stored.Subscription.SubscriptionStatus)
+}
+
+func patchRequest(t *testing.T, router http.Handler, path string, contentType string, patch string) (int, []byte) {
+	request := httptest.NewRequest(http.MethodPatch, basePath+path, bytes.NewBufferString(patch))
+	request.Header.Set(apiKeyHeader, userAPIKey)
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Body.Bytes()
+}
+
+func parseRequestURIError(rawURL string) error {
+	_, err := url.ParseRequestURI(rawURL)
+	return err
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/events"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+// @Summary re-send the verification challenge
+// @Description Send a new challenge to the callback url of a subscription that is PENDING_VERIFICATION. The subscription becomes ACTIVE when the endpoint echoes the challenge, otherwise the subscription stays PENDING_VERIFICATION and the info contains the reason.
+// @Accept  json
+// @Produce  json
+// @Param id path int true "subscription id"
+// @Success 200 {object} models.Subscription "subscription after the verification"
+// @Header 200 {string} ETag "entity tag of the subscription"
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /subscriptions/{id}/verification [post]
+func (api *api) resendVerification(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+
+	// only the owner can verify the subscription
+	id := vars["subscriptionId"]
+	subscriptionContext, err := repository.SubscriptionRepository.ReadSubscription(request.Context(), id)
+	if !readOwnSubscription(writer, requestPrincipal(request), id, subscriptionContext, err) {
+		return
+	}
+	if subscriptionContext.Subscription.SubscriptionStatus != models.PendingVerification {
+		responseError(writer, http.StatusBadRequest, errors.NewInvalidRequestDetailed(fmt.Sprintf("subscription '%s' is not %s", id, models.PendingVerification)))
+		return
+	}
+
+	subscriptionContext, err = api.verifyCallback(request.Context(), subscriptionContext, models.Active)
+	if err != nil {
+		responseError(writer, http.StatusInternalServerError, errors.NewInternalError(fmt.Sprintf("failed to verify subscription: %v", err)))
+		return
+	}
+
+	writer.Header().Set(etagHeader, etag(subscriptionContext.Version))
+	respond(writer, subscriptionContext.Subscription)
+}
+
+// requiresVerification checks whether the callback url of the subscription should be verified, files and relays are
+// configured on the server and are not verified
+func (api *api) requiresVerification(subscription *models.Subscription) bool {
+	if !api.apiConfig.CallbackVerification {
+		return false
+	}
+	switch subscription.CallbackType {
+	case models.File, models.Relay:
+		return false
+	}
+	return true
+}
+
+// verificationStatus determines whether the callback of the updated subscription should be verified. A subscription with
+// a new callback url is verified again, a subscription that is pending verification stays pending until the callback is
+// verified, also when the client changes the status.
+func (api *api) verificationStatus(existing *models.SubscriptionContext, subscription *models.Subscription) (verify bool) {
+	if !api.requiresVerification(subscription) {
+		return false
+	}
+	if existing == nil || existing.Subscription.CallbackURL != subscription.CallbackURL {
+		return true
+	}
+
+	if existing.Subscription.SubscriptionStatus == models.PendingVerification {
+		subscription.SubscriptionStatus = models.PendingVerification
+		subscription.SubscriptionInfo = existing.Subscription.SubscriptionInfo
+	}
+	return false
+}
+
+// verifyCallback sends a challenge to the callback of the stored subscription. The subscription gets the verified status
+// when the endpoint echoes the challenge, otherwise the subscription is pending verification with the reason as info.
+func (api *api) verifyCallback(ctx context.Context, subscriptionContext *models.SubscriptionContext, verifiedStatus models.SubscriptionStatus) (*models.SubscriptionContext, error) {
+	id := subscriptionContext.Subscription.ID
+	timeout := time.Duration(api.apiConfig.VerificationTimeout) * time.Second
+
+	err := events.VerifyCallback(&subscriptionContext.Subscription, timeout)
+	if err != nil {
+		log.Info("failed to verify callback of subscription %s: %v", id, err)
+		subscriptionContext.Subscription.SubscriptionStatus = models.PendingVerification
+		subscriptionContext.Subscription.SubscriptionInfo = fmt.Sprintf("verification failed: %v", err)
+	} else {
+		subscriptionContext.Subscription.SubscriptionStatus = verifiedStatus
+		subscriptionContext.Subscription.SubscriptionInfo = ""
+	}
+	subscriptionContext.Failures = 0
+
+	if err := repository.SubscriptionRepository.UpdateSubscriptionStatus(ctx, subscriptionContext); err != nil {
+		return nil, err
+	}
+
+	// read the subscription again to respond with the stored subscription
+	return repository.SubscriptionRepository.ReadSubscription(ctx, id)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/FactomProject/live-feed-api/EventRouter/config"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallbackVerification(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+	_, err := repository.SubscriptionRepository.CreateAPIKey(context.Background(), &models.APIKey{Owner: "explorer", Role: models.User, KeyHash: hashAPIKey(userAPIKey)})
+	assert.Nil(t, err)
+
+	echo := true
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		challenge := &models.VerificationChallenge{}
+		_ = json.NewDecoder(request.Body).Decode(challenge)
+		if echo {
+			_, _ = writer.Write([]byte(challenge.Challenge))
+		}
+	}))
+	defer server.Close()
+
+	queues := &testEventQueues{}
+	configuration := &config.SubscriptionConfig{BasePath: basePath, Scheme: "HTTP", AdminAPIKey: adminAPIKey, CallbackVerification: true, VerificationTimeout: 1}
+	router := NewSubscriptionAPI(configuration, queues, nil).(*api).router()
+
+	newSubscription := &models.Subscription{
+		CallbackURL:  server.URL + "/events",
+		CallbackType: models.HTTP,
+		Filters:      map[models.EventType]models.Filter{models.NodeMessage: {}},
+	}
+
+	// the subscription is active when the endpoint echoes the challenge
+	code, body := routerRequest(t, router, http.MethodPost, "/subscriptions", userAPIKey, newSubscription)
+	assert.Equal(t, http.StatusCreated, code)
+	subscription := parseSubscription(t, body)
+	assert.Equal(t, models.Active, subscription.SubscriptionStatus)
+
+	// the subscription is pending when the endpoint doesn't echo the challenge
+	echo = false
+	code, body = routerRequest(t, router, http.MethodPost, "/subscriptions", userAPIKey, newSubscription)
+	assert.Equal(t, http.StatusCreated, code)
+	pending := parseSubscription(t, body)
+	assert.Equal(t, models.PendingVerification, pending.SubscriptionStatus)
+	assert.Contains(t, pending.SubscriptionInfo, "verification failed")
+
+	// a pending subscription cannot be activated by the client
+	code, body = routerRequest(t, router, http.MethodPatch, "/subscriptions/"+pending.ID, userAPIKey, map[string]interface{}{"status": models.Active})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, models.PendingVerification, parseSubscription(t, body).SubscriptionStatus)
+
+	// a new callback url is verified again
+	subscription.CallbackURL = server.URL + "/other"
+	code, body = routerRequest(t, router, http.MethodPut, "/subscriptions/"+subscription.ID, userAPIKey, subscription)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, models.PendingVerification, parseSubscription(t, body).SubscriptionStatus)
+	assert.Equal(t, subscription.ID, queues.updated[len(queues.updated)-1].Subscription.ID)
+
+	// the verification is sent again by the owner
+	echo = true
+	code, _ = routerRequest(t, router, http.MethodPost, "/subscriptions/"+pending.ID+"/verification", adminAPIKey, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body = routerRequest(t, router, http.MethodPost, "/subscriptions/"+pending.ID+"/verification", userAPIKey, nil)
+	assert.Equal(t, http.StatusOK, code)
+	verified := parseSubscription(t, body)
+	assert.Equal(t, models.Active, verified.SubscriptionStatus)
+	assert.Empty(t, verified.SubscriptionInfo)
+
+	code, _ = routerRequest(t, router, http.MethodPost, "/subscriptions/"+pending.ID+"/verification", userAPIKey, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// a suspended subscription stays suspended after the verification of the new url
+	code, body = routerRequest(t, router, http.MethodPatch, "/subscriptions/"+pending.ID, userAPIKey, map[string]interface{}{"status": models.Suspended, "callbackUrl": server.URL + "/suspended"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, models.Suspended, parseSubscription(t, body).SubscriptionStatus)
+}
+
+func parseSubscription(t *testing.T, body []byte) *models.Subscription {
+	subscription := &models.Subscription{}
+	if err := json.Unmarshal(body, subscription); err != nil {
+		t.Fatalf("failed to parse subscription '%s': %v", body, err)
+	}
+	return subscription
+}
@@ -23,13 +23,42 @@ const (
 
 	defaultRouterMaxRetries   = 3
 	defaultRouterRetryTimeout = 30
+	defaultRouterFileSinkDir  = ""
+	defaultRouterPauseBuffer  = 10000
+
+	defaultRouterShutdownTimeout  = 30
+	defaultRouterSubscriptionSync = 300
 
 	defaultSubscriptionAPIAddress  = ""
 	defaultSubscriptionAPIPort     = 8700
 	defaultSubscriptionAPIBasePath = "/live/feed/v" + defaultVersion
+	defaultSubscriptionAdminAPIKey = ""
+
+	defaultSubscriptionCallbackVerification = false
+	defaultSubscriptionVerificationTimeout  = 10
+
+	defaultSubscriptionReadinessMinConnections = 1
+	defaultSubscriptionReadinessMaxEventAge    = 300
+	defaultSubscriptionReadinessMaxQueueUsage  = 90
+
+	defaultSubscriptionJWTIssuer        = ""
+	defaultSubscriptionJWTAudience      = ""
+	defaultSubscriptionJWKS             = ""
+	defaultSubscriptionJWKSCacheTimeout = 3600
+	defaultSubscriptionJWTOwnerClaim    = "sub"
+	defaultSubscriptionJWTRolesClaim    = "roles"
+	defaultSubscriptionJWTAdminRole     = "admin"
 
 	defaultDatabase                 = "inmemory"
 	defaultDatabaseConnectionString = ""
+	defaultDatabasePollInterval     = 1
+	defaultDatabaseMigrate          = false
+	defaultDatabaseQueryTimeout     = 10
+
+	defaultDatabaseMaxOpenConnections    = 10
+	defaultDatabaseMaxIdleConnections    = 2
+	defaultDatabaseConnectionMaxLifetime = 300
+	defaultDatabaseConnectRetries        = 4
 )
 
 var defaultSubscriptionAPISchemes = "HTTP"
@@ -59,6 +88,18 @@ type ReceiverConfig struct {
 type RouterConfig struct {
 	MaxRetries   uint16
 	RetryTimeout uint
+	FileSinkDir  string
+
+	// PauseBuffer is the maximum number of events that are buffered for a paused subscription
+	PauseBuffer uint
+
+	// ShutdownTimeout is the time in seconds to deliver the queued events on shutdown, the events that are not
+	// delivered in time are stored and delivered after a restart
+	ShutdownTimeout uint
+
+	// SubscriptionSync is the interval in seconds to reload the cached subscriptions from the database, the cache is
+	// also updated when a subscription changes. The periodic reload is disabled when it is set to 0.
+	SubscriptionSync uint
 }
 
 // SubscriptionConfig configuration for the subscription api
@@ -69,12 +110,72 @@ type SubscriptionConfig struct {
 	BasePath        string
 	CertificateFile string
 	PrivateKeyFile  string
+
+	// AdminAPIKey is the api key of the administrator that manages the api keys of the clients. The api is only
+	// protected with api keys when the admin api key is set.
+	AdminAPIKey string
+
+	// JWTIssuer enables authentication with bearer JWTs of the issuer, the JWTs should be issued for the JWTAudience.
+	JWTIssuer   string
+	JWTAudience string
+
+	// JWKS is the path or the http(s) url of the JSON web key set to verify the JWTs. The key set is cached for
+	// JWKSCacheTimeout seconds.
+	JWKS             string
+	JWKSCacheTimeout uint
+
+	// JWTOwnerClaim is the claim that contains the owner of the subscriptions, JWTRolesClaim is the claim that contains
+	// the roles of the client. Clients with the JWTAdminRole are administrators. Nested claims are separated by dots,
+	// e.g. realm_access.roles.
+	JWTOwnerClaim string
+	JWTRolesClaim string
+	JWTAdminRole  string
+
+	// CallbackVerification enables the verification of the callback urls: a subscription is only active after the
+	// endpoint echoed a challenge. The endpoint should respond within VerificationTimeout seconds. The verification is
+	// disabled by default, such that existing endpoints keep receiving events.
+	CallbackVerification bool
+	VerificationTimeout  uint
+
+	// the readiness check fails when less than ReadinessMinConnections factomd connections are active, when no event is
+	// received for ReadinessMaxEventAge seconds or when more than ReadinessMaxQueueUsage percent of the event queue is
+	// used. A check is disabled when it is set to 0.
+	ReadinessMinConnections uint
+	ReadinessMaxEventAge    uint
+	ReadinessMaxQueueUsage  uint
 }
 
 // DatabaseConfig configuration for the database to store subscriptions
 type DatabaseConfig struct {
 	Database         string
 	ConnectionString string
+
+	// PollInterval is the interval in seconds to poll the changes of the subscriptions in the sql database, the changes
+	// are not watched when it is set to 0
+	PollInterval uint
+
+	// Migrate applies the schema migrations of the sql database on startup
+	Migrate bool
+
+	// QueryTimeout is the time in seconds a call to the sql database may take, the calls are not limited when it is
+	// set to 0
+	QueryTimeout uint
+
+	// MaxOpenConnections is the maximum number of open connections to the sql database, the number of connections is
+	// not limited when it is set to 0
+	MaxOpenConnections int
+
+	// MaxIdleConnections is the maximum number of idle connections that are kept open to the sql database, idle
+	// connections are not kept when it is set to 0
+	MaxIdleConnections int
+
+	// ConnectionMaxLifetime is the time in seconds a connection to the sql database may be reused, the connections are
+	// reused forever when it is set to 0
+	ConnectionMaxLifetime uint
+
+	// ConnectRetries is the number of times to retry connecting to the sql database on startup while the database is
+	// not available, the backoff between the retries doubles from one second
+	ConnectRetries uint
 }
 
 // LoadConfiguration from default paths for factom-live-feed.conf
@@ -155,12 +256,45 @@ func defaultConfig() *Config {
 		Router: &RouterConfig{
 			MaxRetries:   defaultRouterMaxRetries,
 			RetryTimeout: defaultRouterRetryTimeout,
+			FileSinkDir:  defaultRouterFileSinkDir,
+			PauseBuffer:  defaultRouterPauseBuffer,
+
+			ShutdownTimeout:  defaultRouterShutdownTimeout,
+			SubscriptionSync: defaultRouterSubscriptionSync,
 		},
 		Subscription: &SubscriptionConfig{
 			Scheme:      defaultSubscriptionAPISchemes,
 			BindAddress: defaultSubscriptionAPIAddress,
 			Port:        defaultSubscriptionAPIPort,
 			BasePath:    defaultSubscriptionAPIBasePath,
+			AdminAPIKey: defaultSubscriptionAdminAPIKey,
+
+			JWTIssuer:        defaultSubscriptionJWTIssuer,
+			JWTAudience:      defaultSubscriptionJWTAudience,
+			JWKS:             defaultSubscriptionJWKS,
+			JWKSCacheTimeout: defaultSubscriptionJWKSCacheTimeout,
+			JWTOwnerClaim:    defaultSubscriptionJWTOwnerClaim,
+			JWTRolesClaim:    defaultSubscriptionJWTRolesClaim,
+			JWTAdminRole:     defaultSubscriptionJWTAdminRole,
+
+			CallbackVerification: defaultSubscriptionCallbackVerification,
+			VerificationTimeout:  defaultSubscriptionVerificationTimeout,
+
+			ReadinessMinConnections: defaultSubscriptionReadinessMinConnections,
+			ReadinessMaxEventAge:    defaultSubscriptionReadinessMaxEventAge,
+			ReadinessMaxQueueUsage:  defaultSubscriptionReadinessMaxQueueUsage,
+		},
+		Database: &DatabaseConfig{
+			Database:         defaultDatabase,
+			ConnectionString: defaultDatabaseConnectionString,
+			PollInterval:     defaultDatabasePollInterval,
+			Migrate:          defaultDatabaseMigrate,
+			QueryTimeout:     defaultDatabaseQueryTimeout,
+
+			MaxOpenConnections:    defaultDatabaseMaxOpenConnections,
+			MaxIdleConnections:    defaultDatabaseMaxIdleConnections,
+			ConnectionMaxLifetime: defaultDatabaseConnectionMaxLifetime,
+			ConnectRetries:        defaultDatabaseConnectRetries,
 		},
 	}
 }
@@ -177,6 +311,11 @@ func buildRouterDefaults() map[string]interface{} {
 	return map[string]interface{}{
 		"MaxRetries":   defaultRouterMaxRetries,
 		"RetryTimeout": defaultRouterRetryTimeout,
+		"FileSinkDir":  defaultRouterFileSinkDir,
+		"PauseBuffer":  defaultRouterPauseBuffer,
+
+		"ShutdownTimeout":  defaultRouterShutdownTimeout,
+		"SubscriptionSync": defaultRouterSubscriptionSync,
 	}
 }
 
@@ -186,6 +325,22 @@ func buildSubscriptionDefaults() map[string]interface{} {
 		"Port":        defaultSubscriptionAPIPort,
 		"Scheme":      defaultSubscriptionAPISchemes,
 		"BasePath":    defaultSubscriptionAPIBasePath,
+		"AdminAPIKey": defaultSubscriptionAdminAPIKey,
+
+		"JWTIssuer":        defaultSubscriptionJWTIssuer,
+		"JWTAudience":      defaultSubscriptionJWTAudience,
+		"JWKS":             defaultSubscriptionJWKS,
+		"JWKSCacheTimeout": defaultSubscriptionJWKSCacheTimeout,
+		"JWTOwnerClaim":    defaultSubscriptionJWTOwnerClaim,
+		"JWTRolesClaim":    defaultSubscriptionJWTRolesClaim,
+		"JWTAdminRole":     defaultSubscriptionJWTAdminRole,
+
+		"CallbackVerification": defaultSubscriptionCallbackVerification,
+		"VerificationTimeout":  defaultSubscriptionVerificationTimeout,
+
+		"ReadinessMinConnections": defaultSubscriptionReadinessMinConnections,
+		"ReadinessMaxEventAge":    defaultSubscriptionReadinessMaxEventAge,
+		"ReadinessMaxQueueUsage":  defaultSubscriptionReadinessMaxQueueUsage,
 	}
 }
 
@@ -199,6 +354,14 @@ func buildDatabaseDefaults() map[string]interface{} {
 	return map[string]interface{}{
 		"Database":         defaultDatabase,
 		"ConnectionString": defaultDatabaseConnectionString,
+		"PollInterval":     defaultDatabasePollInterval,
+		"Migrate":          defaultDatabaseMigrate,
+		"QueryTimeout":     defaultDatabaseQueryTimeout,
+
+		"MaxOpenConnections":    defaultDatabaseMaxOpenConnections,
+		"MaxIdleConnections":    defaultDatabaseMaxIdleConnections,
+		"ConnectionMaxLifetime": defaultDatabaseConnectionMaxLifetime,
+		"ConnectRetries":        defaultDatabaseConnectRetries,
 	}
 }
 
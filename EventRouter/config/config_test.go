@@ -151,12 +151,39 @@ func testDefaultConfig(t *testing.T) {
 	assert.NotNil(t, routerConfig, "routerConfig shouldn't be nil")
 	assert.EqualValues(t, defaultRouterMaxRetries, routerConfig.MaxRetries, "routerConfig.MaxRetries mismatch %s != %s", defaultRouterMaxRetries, routerConfig.MaxRetries)
 	assert.EqualValues(t, defaultRouterRetryTimeout, routerConfig.RetryTimeout, "routerConfig.RetryTimeout mismatch %s != %d", defaultRouterRetryTimeout, routerConfig.RetryTimeout)
+	assert.EqualValues(t, defaultRouterFileSinkDir, routerConfig.FileSinkDir, "routerConfig.FileSinkDir mismatch %s != %s", defaultRouterFileSinkDir, routerConfig.FileSinkDir)
+	assert.EqualValues(t, defaultRouterPauseBuffer, routerConfig.PauseBuffer, "routerConfig.PauseBuffer mismatch %d != %d", defaultRouterPauseBuffer, routerConfig.PauseBuffer)
+	assert.EqualValues(t, defaultRouterShutdownTimeout, routerConfig.ShutdownTimeout, "routerConfig.ShutdownTimeout mismatch %d != %d", defaultRouterShutdownTimeout, routerConfig.ShutdownTimeout)
+	assert.EqualValues(t, defaultRouterSubscriptionSync, routerConfig.SubscriptionSync, "routerConfig.SubscriptionSync mismatch %d != %d", defaultRouterSubscriptionSync, routerConfig.SubscriptionSync)
 
 	subscriptionConfig := config.Subscription
 	assert.NotNil(t, subscriptionConfig, "SubscriptionConfig shouldn't be nil")
 	assert.EqualValues(t, defaultSubscriptionAPIAddress, subscriptionConfig.BindAddress, "SubscriptionConfig.BindAddress mismatch %s != %s", defaultSubscriptionAPIAddress, subscriptionConfig.BindAddress)
 	assert.EqualValues(t, defaultSubscriptionAPIPort, subscriptionConfig.Port, "SubscriptionConfig.Port mismatch %s != %d", defaultSubscriptionAPIPort, subscriptionConfig.Port)
 	assert.EqualValues(t, defaultSubscriptionAPISchemes, subscriptionConfig.Scheme, "SubscriptionConfig.Schemes mismatch %v != %v", defaultSubscriptionAPISchemes, subscriptionConfig.Scheme)
+	assert.EqualValues(t, defaultSubscriptionAdminAPIKey, subscriptionConfig.AdminAPIKey, "SubscriptionConfig.AdminAPIKey mismatch %v != %v", defaultSubscriptionAdminAPIKey, subscriptionConfig.AdminAPIKey)
+	assert.EqualValues(t, defaultSubscriptionJWTIssuer, subscriptionConfig.JWTIssuer)
+	assert.EqualValues(t, defaultSubscriptionJWKSCacheTimeout, subscriptionConfig.JWKSCacheTimeout)
+	assert.EqualValues(t, defaultSubscriptionJWTOwnerClaim, subscriptionConfig.JWTOwnerClaim)
+	assert.EqualValues(t, defaultSubscriptionJWTRolesClaim, subscriptionConfig.JWTRolesClaim)
+	assert.EqualValues(t, defaultSubscriptionJWTAdminRole, subscriptionConfig.JWTAdminRole)
+	assert.EqualValues(t, defaultSubscriptionCallbackVerification, subscriptionConfig.CallbackVerification)
+	assert.EqualValues(t, defaultSubscriptionVerificationTimeout, subscriptionConfig.VerificationTimeout)
+	assert.EqualValues(t, defaultSubscriptionReadinessMinConnections, subscriptionConfig.ReadinessMinConnections)
+	assert.EqualValues(t, defaultSubscriptionReadinessMaxEventAge, subscriptionConfig.ReadinessMaxEventAge)
+	assert.EqualValues(t, defaultSubscriptionReadinessMaxQueueUsage, subscriptionConfig.ReadinessMaxQueueUsage)
+
+	databaseConfig := config.Database
+	if assert.NotNil(t, databaseConfig, "DatabaseConfig shouldn't be nil") {
+		assert.EqualValues(t, defaultDatabase, databaseConfig.Database)
+		assert.EqualValues(t, defaultDatabasePollInterval, databaseConfig.PollInterval)
+		assert.Equal(t, defaultDatabaseMigrate, databaseConfig.Migrate)
+		assert.EqualValues(t, defaultDatabaseQueryTimeout, databaseConfig.QueryTimeout)
+		assert.EqualValues(t, defaultDatabaseMaxOpenConnections, databaseConfig.MaxOpenConnections)
+		assert.EqualValues(t, defaultDatabaseMaxIdleConnections, databaseConfig.MaxIdleConnections)
+		assert.EqualValues(t, defaultDatabaseConnectionMaxLifetime, databaseConfig.ConnectionMaxLifetime)
+		assert.EqualValues(t, defaultDatabaseConnectRetries, databaseConfig.ConnectRetries)
+	}
 }
 
 func testNoConfigFound(t *testing.T) {
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// VerifyCallback sends a challenge to the endpoint of the subscription. The callback url is verified when the endpoint
+// responds within the timeout with the challenge as body, or with a json object that contains the challenge.
+func VerifyCallback(subscription *models.Subscription, timeout time.Duration) error {
+	challenge, err := newChallenge()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(&models.VerificationChallenge{
+		Type:           models.VerificationChallengeType,
+		SubscriptionID: subscription.ID,
+		Challenge:      challenge,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create challenge: %v", err)
+	}
+
+	request, err := newCallbackRequest(subscription, body)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(request.Context(), timeout)
+	defer cancel()
+
+	response, err := doCallbackRequest(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	echo, err := ioutil.ReadAll(io.LimitReader(response.Body, maxResponseBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read response from '%s': %v", subscription.CallbackURL, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to receive correct response from '%s': code=%d", subscription.CallbackURL, response.StatusCode)
+	}
+	if !echoesChallenge(echo, challenge) {
+		return fmt.Errorf("endpoint '%s' did not echo the challenge", subscription.CallbackURL)
+	}
+
+	log.Info("verified callback of subscription %s: '%s'", subscription.ID, subscription.CallbackURL)
+	return nil
+}
+
+// echoesChallenge checks whether the response is the challenge itself or a json object with the challenge
+func echoesChallenge(response []byte, challenge string) bool {
+	if string(bytes.TrimSpace(response)) == challenge {
+		return true
+	}
+
+	echo := &models.VerificationChallenge{}
+	if err := json.Unmarshal(response, echo); err != nil {
+		return false
+	}
+	return echo.Challenge == challenge
+}
+
+func newChallenge() (string, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", fmt.Errorf("failed to create challenge: %v", err)
+	}
+	return hex.EncodeToString(challenge), nil
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifyCallback(t *testing.T) {
+	testCases := map[string]struct {
+		Respond func(writer http.ResponseWriter, challenge *models.VerificationChallenge)
+		Error   bool
+	}{
+		"echo": {
+			Respond: func(writer http.ResponseWriter, challenge *models.VerificationChallenge) {
+				_, _ = writer.Write([]byte(challenge.Challenge + "\n"))
+			},
+		},
+		"echo json": {
+			Respond: func(writer http.ResponseWriter, challenge *models.VerificationChallenge) {
+				_ = json.NewEncoder(writer).Encode(map[string]string{"challenge": challenge.Challenge})
+			},
+		},
+		"other challenge": {
+			Respond: func(writer http.ResponseWriter, challenge *models.VerificationChallenge) {
+				_, _ = writer.Write([]byte("other"))
+			},
+			Error: true,
+		},
+		"no echo": {
+			Respond: func(writer http.ResponseWriter, challenge *models.VerificationChallenge) {},
+			Error:   true,
+		},
+		"error": {
+			Respond: func(writer http.ResponseWriter, challenge *models.VerificationChallenge) {
+				writer.WriteHeader(http.StatusNotFound)
+				_, _ = writer.Write([]byte(challenge.Challenge))
+			},
+			Error: true,
+		},
+		"timeout": {
+			Respond: func(writer http.ResponseWriter, challenge *models.VerificationChallenge) {
+				time.Sleep(500 * time.Millisecond)
+				_, _ = writer.Write([]byte(challenge.Challenge))
+			},
+			Error: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				challenge := &models.VerificationChallenge{}
+				assert.Nil(t, json.NewDecoder(request.Body).Decode(challenge))
+				assert.Equal(t, models.VerificationChallengeType, challenge.Type)
+				assert.Equal(t, "id", challenge.SubscriptionID)
+				assert.Len(t, challenge.Challenge, 64)
+				assert.Equal(t, "key", request.Header.Get("X-Api-Key"))
+				testCase.Respond(writer, challenge)
+			}))
+			defer server.Close()
+
+			subscription := &models.Subscription{
+				ID:              "id",
+				CallbackURL:     server.URL,
+				CallbackType:    models.HTTP,
+				CallbackHeaders: map[string]string{"X-Api-Key": "key"},
+			}
+
+			err := VerifyCallback(subscription, 100*time.Millisecond)
+			if testCase.Error {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyCallbackUnreachable(t *testing.T) {
+	subscription := &models.Subscription{
+		ID:           "id",
+		CallbackURL:  "http://localhost:1/unreachable",
+		CallbackType: models.HTTP,
+	}
+
+	err := VerifyCallback(subscription, time.Second)
+	assert.NotNil(t, err)
+}
@@ -2,14 +2,19 @@ package events
 
 import (
 	"bufio"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"github.com/FactomProject/live-feed-api/EventRouter/config"
 	"github.com/FactomProject/live-feed-api/EventRouter/eventmessages/generated/eventmessages"
 	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/metrics"
 	"github.com/gogo/protobuf/proto"
 	"io"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -20,8 +25,31 @@ const (
 // EventReceiver responsible to receive events from factomd
 type EventReceiver interface {
 	Start()
+
+	// Stop accepting factomd connections and close the active connections, the event queue is closed when all
+	// connections are closed before the context is done
+	Stop(ctx context.Context) error
+
 	GetEventQueue() chan *eventmessages.FactomEvent
 	GetAddress() string
+
+	// Status returns the state of the receiver, which determines whether the live feed is ready
+	Status() ReceiverStatus
+}
+
+// ReceiverStatus is the state of the receiver
+type ReceiverStatus struct {
+	Listening   bool
+	Address     string
+	Connections int
+
+	// Started is the time the receiver is started, LastEvent is the time the last event is received which is zero
+	// when no event has been received yet
+	Started   time.Time
+	LastEvent time.Time
+
+	QueueLength   int
+	QueueCapacity int
 }
 
 type receiver struct {
@@ -29,19 +57,33 @@ type receiver struct {
 	listener   net.Listener
 	protocol   string
 	address    string
+
+	// the state of the receiver is updated atomically by the connections
+	started     time.Time
+	listening   int32
+	connections int32
+	lastEvent   int64
+
+	// the lock guards the listener and the open connections while the receiver stops
+	lock            sync.Mutex
+	stopping        bool
+	openConnections map[net.Conn]struct{}
+	handlers        sync.WaitGroup
 }
 
 // NewReceiver creates a new receiver
 func NewReceiver(eventListenerConfig *config.ReceiverConfig) EventReceiver {
 	return &receiver{
-		eventQueue: make(chan *eventmessages.FactomEvent, defaultStandardChannelSize),
-		protocol:   eventListenerConfig.Protocol,
-		address:    fmt.Sprintf("%s:%d", eventListenerConfig.BindAddress, eventListenerConfig.Port),
+		eventQueue:      make(chan *eventmessages.FactomEvent, defaultStandardChannelSize),
+		protocol:        eventListenerConfig.Protocol,
+		address:         fmt.Sprintf("%s:%d", eventListenerConfig.BindAddress, eventListenerConfig.Port),
+		openConnections: make(map[net.Conn]struct{}),
 	}
 }
 
 // Start the receiver with listening
 func (receiver *receiver) Start() {
+	receiver.started = time.Now()
 	go receiver.listenIncomingConnections()
 }
 
@@ -52,26 +94,102 @@ func (receiver *receiver) listenIncomingConnections() {
 		log.Error("failed to listen to %s on %s: %v", receiver.protocol, receiver.address, err)
 		return
 	}
+
+	receiver.lock.Lock()
+	if receiver.stopping {
+		receiver.lock.Unlock()
+		_ = listener.Close()
+		return
+	}
 	receiver.listener = listener
+	receiver.lock.Unlock()
+	atomic.StoreInt32(&receiver.listening, 1)
 
 	for {
-		conn, err := receiver.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
+			if receiver.isStopping() {
+				return
+			}
 			log.Error("connection from factomd failed: %v", err)
 			continue
 		}
 
+		if !receiver.track(conn) {
+			_ = conn.Close()
+			return
+		}
 		go receiver.handleConnection(conn)
 	}
 }
 
+// track the connection until it is closed, a connection is not accepted when the receiver is stopping
+func (receiver *receiver) track(conn net.Conn) bool {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	if receiver.stopping {
+		return false
+	}
+	receiver.openConnections[conn] = struct{}{}
+	receiver.handlers.Add(1)
+	return true
+}
+
+func (receiver *receiver) isStopping() bool {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	return receiver.stopping
+}
+
 func (receiver *receiver) handleConnection(conn net.Conn) {
+	atomic.AddInt32(&receiver.connections, 1)
+	defer receiver.handlers.Done()
+	defer atomic.AddInt32(&receiver.connections, -1)
+	defer receiver.untrack(conn)
 	defer finalizeConnection(conn)
-	if err := receiver.readEvents(conn); err != nil {
+
+	if err := receiver.readEvents(conn); err != nil && !receiver.isStopping() {
 		log.Error("failed to read events: %v", err)
 	}
 }
 
+func (receiver *receiver) untrack(conn net.Conn) {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	delete(receiver.openConnections, conn)
+}
+
+// Stop the receiver, the events that are received are left in the queue to be routed
+func (receiver *receiver) Stop(ctx context.Context) error {
+	receiver.lock.Lock()
+	receiver.stopping = true
+	if receiver.listener != nil {
+		_ = receiver.listener.Close()
+	}
+	atomic.StoreInt32(&receiver.listening, 0)
+
+	// closing the connections interrupts the reading, an event that is partially read is discarded
+	for conn := range receiver.openConnections {
+		_ = conn.Close()
+	}
+	receiver.lock.Unlock()
+	log.Info("stop event receiver at: %s", receiver.address)
+
+	done := make(chan struct{})
+	go func() {
+		receiver.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		// no connection writes to the queue anymore, closing the queue informs the router that no events will follow
+		close(receiver.eventQueue)
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to close the factomd connections: %v", ctx.Err())
+	}
+}
+
 func (receiver *receiver) readEvents(conn net.Conn) (err error) {
 	log.Debug("read events from: %s", getRemoteAddress(conn))
 
@@ -117,6 +235,12 @@ func (receiver *receiver) readEvents(conn net.Conn) (err error) {
 			return fmt.Errorf("failed to unmarshal event from %s: %v", getRemoteAddress(conn), err)
 		}
 		log.Debug("read factom event... %v", factomEvent)
+		atomic.StoreInt64(&receiver.lastEvent, time.Now().UnixNano())
+		eventType, err := mapEventType(factomEvent)
+		if err != nil {
+			eventType = "UNKNOWN"
+		}
+		metrics.Recorder.EventReceived(eventType, getRemoteAddress(conn))
 		receiver.eventQueue <- factomEvent
 	}
 }
@@ -142,12 +266,30 @@ func getRemoteAddress(conn net.Conn) string {
 
 // GetAddress to get the address where the receiver is listening to
 func (receiver *receiver) GetAddress() string {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
 	if receiver.listener == nil {
 		return receiver.address
 	}
 	return receiver.listener.Addr().String()
 }
 
+// Status to get the state of the receiver
+func (receiver *receiver) Status() ReceiverStatus {
+	status := ReceiverStatus{
+		Listening:     atomic.LoadInt32(&receiver.listening) == 1,
+		Address:       receiver.address,
+		Connections:   int(atomic.LoadInt32(&receiver.connections)),
+		Started:       receiver.started,
+		QueueLength:   len(receiver.eventQueue),
+		QueueCapacity: cap(receiver.eventQueue),
+	}
+	if lastEvent := atomic.LoadInt64(&receiver.lastEvent); lastEvent > 0 {
+		status.LastEvent = time.Unix(0, lastEvent)
+	}
+	return status
+}
+
 // GetEventQueue to get queue of new events
 func (receiver *receiver) GetEventQueue() chan *eventmessages.FactomEvent {
 	return receiver.eventQueue
@@ -1,6 +1,7 @@
 package events
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"github.com/FactomProject/live-feed-api/EventRouter/config"
@@ -83,11 +84,12 @@ func TestEOFConnection(t *testing.T) {
 			binary.Write(conn, binary.LittleEndian, supportedProtocolVersion)
 			err := binary.Write(conn, binary.LittleEndian, dataSize)
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
+				return
 			}
 			_, err = conn.Write(data)
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
 			}
 		}()
 	}
@@ -96,6 +98,80 @@ func TestEOFConnection(t *testing.T) {
 	assert.EqualValues(t, n, correctSendEvents, "failed to receive the correct number of events %d != %d", n, correctSendEvents)
 }
 
+func TestReceiverStatus(t *testing.T) {
+	receiver := NewReceiver(&config.ReceiverConfig{Protocol: "tcp", BindAddress: "", Port: 0})
+	status := receiver.Status()
+	assert.False(t, status.Listening)
+	assert.Equal(t, defaultStandardChannelSize, status.QueueCapacity)
+
+	receiver.Start()
+	time.Sleep(10 * time.Millisecond) // sleep to allow the server to start before making a connection
+	conn, err := net.Dial("tcp", receiver.GetAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	data := mockData(t)
+	assert.Nil(t, binary.Write(conn, binary.LittleEndian, supportedProtocolVersion))
+	assert.Nil(t, binary.Write(conn, binary.LittleEndian, int32(len(data))))
+	_, err = conn.Write(data)
+	assert.Nil(t, err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for receiver.Status().QueueLength == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	status = receiver.Status()
+	assert.True(t, status.Listening)
+	assert.Equal(t, 1, status.Connections)
+	assert.Equal(t, 1, status.QueueLength)
+	assert.False(t, status.Started.IsZero())
+	assert.False(t, status.LastEvent.IsZero())
+}
+
+func TestReceiverStop(t *testing.T) {
+	receiver := NewReceiver(&config.ReceiverConfig{Protocol: "tcp", BindAddress: "", Port: 0})
+	receiver.Start()
+	time.Sleep(10 * time.Millisecond) // sleep to allow the server to start before making a connection
+	conn, err := net.Dial("tcp", receiver.GetAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	data := mockData(t)
+	assert.Nil(t, binary.Write(conn, binary.LittleEndian, supportedProtocolVersion))
+	assert.Nil(t, binary.Write(conn, binary.LittleEndian, int32(len(data))))
+	_, err = conn.Write(data)
+	assert.Nil(t, err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for receiver.Status().QueueLength == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, receiver.Stop(ctx))
+
+	status := receiver.Status()
+	assert.False(t, status.Listening)
+	assert.Equal(t, 0, status.Connections)
+
+	// the received event is left in the queue, which is closed after the connections are closed
+	queue := receiver.GetEventQueue()
+	_, ok := <-queue
+	assert.True(t, ok)
+	_, ok = <-queue
+	assert.False(t, ok)
+
+	// new connections are refused
+	_, err = net.Dial("tcp", receiver.GetAddress())
+	assert.NotNil(t, err)
+}
+
 func connect(t *testing.T) net.Conn {
 	conn, err := net.Dial("tcp", address)
 	if err != nil {
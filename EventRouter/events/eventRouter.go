@@ -2,28 +2,78 @@ package events
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/FactomProject/live-feed-api/EventRouter/config"
 	"github.com/FactomProject/live-feed-api/EventRouter/eventmessages/generated/eventmessages"
 	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/metrics"
 	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
 	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"github.com/gogo/protobuf/proto"
+	"io"
+	"io/ioutil"
+	"math"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// the maximum size of the response of an endpoint that is read
+const maxResponseBodySize = 64 * 1024
+
+// the interval to check whether the queued events are delivered while the router stops
+const flushInterval = 10 * time.Millisecond
+
 // EventRouter that route the events to subscriptions
 type EventRouter interface {
 	Start()
+
+	// Stop routing the received events and deliver the queued events until the context is done, the events that are
+	// not delivered are stored and delivered after a restart
+	Stop(ctx context.Context) error
+
+	// QueueStatus returns the state of the queue of events that wait to be delivered to the subscription
+	QueueStatus(ctx context.Context, subscriptionID string) models.SubscriptionQueue
+
+	// PurgeQueue removes the events that wait to be delivered to the subscription and returns the number of removed events
+	PurgeQueue(ctx context.Context, subscriptionID string) int
+
+	// UpdateSubscription informs the router that the subscription has been changed, the queued events of a
+	// reactivated subscription are delivered again and the buffered events of a resumed subscription are delivered in order
+	UpdateSubscription(ctx context.Context, subscriptionContext *models.SubscriptionContext)
 }
 
 type eventRouter struct {
 	eventsInQueue chan *eventmessages.FactomEvent
+	queueLock     sync.RWMutex
 	emitQueue     map[string]SubscriptionStack
 	maxRetries    uint16
 	retryTimeout  time.Duration
+	fileSinks     *fileSinks
+	blockHeight   uint32
+
+	// the subscriptions that receive the events are cached, updated when the repository reports a change and reloaded
+	// every sync interval
+	subscriptions *subscriptionIndex
+	syncInterval  time.Duration
+	unwatch       context.CancelFunc
+
+	// the buffer lock orders the buffering of the events of paused subscriptions and the draining of the buffer when
+	// a subscription is resumed
+	bufferLock  sync.Mutex
+	pauseBuffer int
+
+	// the router stops routing when stopping is closed and stops delivering when stopped is closed, routed is closed
+	// when the received events are routed
+	stopping chan struct{}
+	stopped  chan struct{}
+	routed   chan struct{}
+	emitLock sync.Mutex
+	emitters sync.WaitGroup
 }
 
 // NewEventRouter create a new event router that listens to a given queue
@@ -33,38 +83,153 @@ func NewEventRouter(routerConfig *config.RouterConfig, queue chan *eventmessages
 		retryTimeout:  time.Duration(routerConfig.RetryTimeout) * time.Second,
 		eventsInQueue: queue,
 		emitQueue:     make(map[string]SubscriptionStack),
+		fileSinks:     newFileSinks(routerConfig.FileSinkDir),
+		pauseBuffer:   int(routerConfig.PauseBuffer),
+		subscriptions: newSubscriptionIndex(),
+		syncInterval:  time.Duration(routerConfig.SubscriptionSync) * time.Second,
+		stopping:      make(chan struct{}),
+		stopped:       make(chan struct{}),
+		routed:        make(chan struct{}),
 	}
 }
 
 // Start the event router
 func (eventRouter *eventRouter) Start() {
+	// watch before the subscriptions are loaded, such that no change is missed
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, err := repository.SubscriptionRepository.Watch(ctx)
+	if err != nil {
+		log.Error("subscription changes are only applied every %v: %v", eventRouter.syncInterval, err)
+		cancel()
+	} else {
+		eventRouter.unwatch = cancel
+		go eventRouter.watchSubscriptions(changes)
+	}
+
+	if err := eventRouter.subscriptions.resync(); err != nil {
+		log.Error("%v", err)
+	}
+	eventRouter.resumeQueues()
 	go eventRouter.handleEvents()
+	if eventRouter.syncInterval > 0 {
+		go eventRouter.syncSubscriptions()
+	}
 }
 
-func (eventRouter *eventRouter) handleEvents() {
-	for factomEvent := range eventRouter.eventsInQueue {
-		eventType, err := mapEventType(factomEvent)
-		if err != nil {
-			log.Error("invalid event type %v: '%v'", err, factomEvent.Event)
-			continue
+// reload the cached subscriptions periodically, in case a change of a subscription is missed
+func (eventRouter *eventRouter) syncSubscriptions() {
+	ticker := time.NewTicker(eventRouter.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := eventRouter.subscriptions.resync(); err != nil {
+				log.Error("%v", err)
+			}
+		case <-eventRouter.stopping:
+			return
 		}
+	}
+}
+
+// apply the changes of the subscriptions until the watch is cancelled
+func (eventRouter *eventRouter) watchSubscriptions(changes <-chan models.SubscriptionChange) {
+	for change := range changes {
+		eventRouter.handleChange(change)
+	}
+}
 
-		log.Debug("handle %s event: %v", eventType, factomEvent)
+// update the cached subscription and the queue of the changed subscription, the queue of a deleted subscription is
+// removed together with the events that wait to be delivered
+func (eventRouter *eventRouter) handleChange(change models.SubscriptionChange) {
+	log.Debug("subscription %s %s", change.SubscriptionID, change.Type)
+	eventRouter.subscriptions.update(change.SubscriptionID, change.SubscriptionContext)
+	if change.Type == models.Deleted {
+		eventRouter.removeQueue(change.SubscriptionID)
+		eventTemplates.Remove(change.SubscriptionID)
+		oauth2Tokens.Remove(change.SubscriptionID)
+		relayConnections.Remove(change.SubscriptionID)
+		eventRouter.fileSinks.Remove(change.SubscriptionID)
+		return
+	}
+	eventRouter.UpdateSubscription(context.Background(), change.SubscriptionContext)
+}
 
-		subscriptionContexts, err := repository.SubscriptionRepository.GetActiveSubscriptions(eventType)
-		if err != nil {
-			log.Error("%v", err)
-			continue
+// remove the queue and the queue depth of the subscription, the thread that delivers the events of the queue stops
+func (eventRouter *eventRouter) removeQueue(subscriptionID string) {
+	eventRouter.queueLock.Lock()
+	stack, ok := eventRouter.emitQueue[subscriptionID]
+	delete(eventRouter.emitQueue, subscriptionID)
+	eventRouter.queueLock.Unlock()
+	metrics.Recorder.SubscriptionRemoved(subscriptionID)
+
+	if ok {
+		if n := stack.Clear(); n > 0 {
+			log.Info("dropped %d events of deleted subscription %s", n, subscriptionID)
 		}
+	}
+}
 
-		err = eventRouter.send(subscriptionContexts, factomEvent)
-		if err != nil {
-			log.Error("%v", err)
-			continue
+// deliver the events that are stored when the router stopped before the events were delivered
+func (eventRouter *eventRouter) resumeQueues() {
+	subscriptionContexts, _, err := repository.SubscriptionRepository.ListSubscriptions(context.Background(), &models.SubscriptionQuery{Status: models.Active})
+	if err != nil {
+		log.Error("failed to resume the queues of the subscriptions: %v", err)
+		return
+	}
+	for _, subscriptionContext := range subscriptionContexts {
+		eventRouter.UpdateSubscription(context.Background(), subscriptionContext)
+	}
+}
+
+func (eventRouter *eventRouter) handleEvents() {
+	defer close(eventRouter.routed)
+	for {
+		select {
+		case factomEvent, ok := <-eventRouter.eventsInQueue:
+			if !ok {
+				return
+			}
+			eventRouter.handleEvent(factomEvent)
+		case <-eventRouter.stopping:
+			// route the events that are received before the receiver stopped
+			for {
+				select {
+				case factomEvent, ok := <-eventRouter.eventsInQueue:
+					if !ok {
+						return
+					}
+					eventRouter.handleEvent(factomEvent)
+				default:
+					return
+				}
+			}
 		}
 	}
 }
 
+func (eventRouter *eventRouter) handleEvent(factomEvent *eventmessages.FactomEvent) {
+	metrics.Recorder.EventQueueDepth(len(eventRouter.eventsInQueue))
+	eventType, err := mapEventType(factomEvent)
+	if err != nil {
+		log.Error("invalid event type %v: '%v'", err, factomEvent.Event)
+		return
+	}
+
+	log.Debug("handle %s event: %v", eventType, factomEvent)
+
+	subscriptionContexts, err := eventRouter.subscriptions.activeSubscriptions(eventType)
+	if err != nil {
+		log.Error("%v", err)
+		return
+	}
+
+	err = eventRouter.send(subscriptionContexts, factomEvent)
+	if err != nil {
+		log.Error("%v", err)
+	}
+}
+
 func mapEventType(factomEvent *eventmessages.FactomEvent) (models.EventType, error) {
 	switch factomEvent.Event.(type) {
 	case *eventmessages.FactomEvent_DirectoryBlockAnchor:
@@ -93,136 +258,607 @@ func (eventRouter *eventRouter) send(subscriptions models.SubscriptionContexts,
 	if err != nil {
 		return fmt.Errorf("failed to create json from factom event")
 	}
+	eventType, _ := mapEventType(factomEvent)
+
+	// not every event contains the block height, use the last known block height for those events
+	if blockHeight, ok := eventBlockHeight(factomEvent); ok {
+		eventRouter.blockHeight = blockHeight
+	}
+
 	for _, subscription := range subscriptions {
-		eventRouter.sendEvent(subscription, event)
+		payload, err := buildPayload(&subscription.Subscription, eventType, factomEvent, event)
+		if err != nil {
+			log.Error("failed to build event for subscription %s: %v", subscription.Subscription.ID, err)
+			continue
+		}
+		eventRouter.sendEvent(subscription, &QueuedEvent{
+			EventType:   eventType,
+			BlockHeight: eventRouter.blockHeight,
+			Payload:     payload,
+		})
 	}
 	return nil
 }
 
+func eventBlockHeight(factomEvent *eventmessages.FactomEvent) (uint32, bool) {
+	switch event := factomEvent.Event.(type) {
+	case *eventmessages.FactomEvent_DirectoryBlockCommit:
+		header := event.DirectoryBlockCommit.GetDirectoryBlock().GetHeader()
+		return header.GetBlockHeight(), header != nil
+	case *eventmessages.FactomEvent_DirectoryBlockAnchor:
+		return event.DirectoryBlockAnchor.GetBlockHeight(), event.DirectoryBlockAnchor != nil
+	case *eventmessages.FactomEvent_StateChange:
+		return event.StateChange.GetBlockHeight(), event.StateChange != nil
+	case *eventmessages.FactomEvent_ProcessListEvent:
+		if newBlock := event.ProcessListEvent.GetNewBlockEvent(); newBlock != nil {
+			return newBlock.GetNewBlockHeight(), true
+		}
+		if newMinute := event.ProcessListEvent.GetNewMinuteEvent(); newMinute != nil {
+			return newMinute.GetBlockHeight(), true
+		}
+	}
+	return 0, false
+}
+
+// build the event that is delivered to the subscription, the event is filtered and rendered with the template of the filter.
+// Relays and files in the protobuf format receive the complete protobuf event.
+func buildPayload(subscription *models.Subscription, eventType models.EventType, factomEvent *eventmessages.FactomEvent, event []byte) ([]byte, error) {
+	if subscription.CallbackType == models.Relay || (subscription.CallbackType == models.File && subscription.FileSink.Format == models.Protobuf) {
+		return proto.Marshal(factomEvent)
+	}
+
+	filter := subscription.Filters[eventType]
+	payload := event
+	if filter.Filtering != "" {
+		start := time.Now()
+		filteredEvent, err := Filter(filter.Filtering, factomEvent)
+		metrics.Recorder.FilterExecuted(time.Since(start))
+		if err != nil {
+			return nil, err
+		}
+		payload = filteredEvent
+	}
+
+	if filter.Template == "" {
+		return payload, nil
+	}
+	tmpl, err := eventTemplates.Template(subscription.ID, eventType, filter.Template)
+	if err != nil {
+		return nil, err
+	}
+	return renderTemplate(tmpl, payload)
+}
+
 // start a thread if the queue is empty and no thread is already sending events for the subscription
-func (eventRouter *eventRouter) sendEvent(subscriptionContext *models.SubscriptionContext, event []byte) {
-	if _, ok := eventRouter.emitQueue[subscriptionContext.Subscription.ID]; !ok {
-		eventRouter.emitQueue[subscriptionContext.Subscription.ID] = NewSubscriptionStack(subscriptionContext)
+func (eventRouter *eventRouter) sendEvent(subscriptionContext *models.SubscriptionContext, event *QueuedEvent) {
+	stack := eventRouter.subscriptionStack(subscriptionContext)
+
+	// the subscription may be reactivated after it was suspended, the api may have changed the subscription after it
+	// was read for this event
+	eventRouter.bufferLock.Lock()
+	stack.UpdateSubscription(subscriptionContext)
+	subscriptionContext = stack.Subscription()
+	if subscriptionContext.Subscription.SubscriptionStatus == models.Paused {
+		eventRouter.bufferEvent(subscriptionContext.Subscription.ID, event)
+		eventRouter.bufferLock.Unlock()
+		return
 	}
-	eventRouter.emitQueue[subscriptionContext.Subscription.ID].Add(event)
+	stack.Add(event)
+	eventRouter.bufferLock.Unlock()
+	metrics.Recorder.SubscriptionQueueDepth(subscriptionContext.Subscription.ID, stack.Len())
 
 	// start new thread to handle the process list if there isn't already a thread busy sending to the subscription
-	if !eventRouter.emitQueue[subscriptionContext.Subscription.ID].IsProcessing() {
-		go func() {
-			eventRouter.emitEvent(subscriptionContext.Subscription.ID)
-		}()
+	eventRouter.startEmitting(stack, subscriptionContext.Subscription.ID)
+}
+
+// start a thread that delivers the queued events of the subscription if there isn't already a thread busy sending to
+// the subscription, no thread is started when the router stopped
+func (eventRouter *eventRouter) startEmitting(stack SubscriptionStack, subscriptionID string) {
+	eventRouter.emitLock.Lock()
+	defer eventRouter.emitLock.Unlock()
+	if eventRouter.isStopped() || !stack.StartProcessing() {
+		return
 	}
+
+	eventRouter.emitters.Add(1)
+	go func() {
+		defer eventRouter.emitters.Done()
+		eventRouter.emitEvent(subscriptionID)
+	}()
+}
+
+func (eventRouter *eventRouter) isStopped() bool {
+	select {
+	case <-eventRouter.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait before the event is delivered again, the waiting is interrupted when the router stops
+func (eventRouter *eventRouter) waitRetry() {
+	select {
+	case <-time.After(eventRouter.retryTimeout):
+	case <-eventRouter.stopped:
+	}
+}
+
+// get the stack of the subscription, the stack is created when the subscription has no stack yet
+func (eventRouter *eventRouter) subscriptionStack(subscriptionContext *models.SubscriptionContext) SubscriptionStack {
+	eventRouter.queueLock.Lock()
+	defer eventRouter.queueLock.Unlock()
+	stack, ok := eventRouter.emitQueue[subscriptionContext.Subscription.ID]
+	if !ok {
+		stack = NewSubscriptionStack(subscriptionContext)
+		eventRouter.emitQueue[subscriptionContext.Subscription.ID] = stack
+	}
+	return stack
+}
+
+// store the event of a paused subscription until the subscription is resumed, the event is dropped when the buffer
+// of the subscription is full
+func (eventRouter *eventRouter) bufferEvent(subscriptionID string, event *QueuedEvent) {
+	err := repository.SubscriptionRepository.BufferEvent(context.Background(), subscriptionID, &models.BufferedEvent{
+		EventType:   event.EventType,
+		BlockHeight: event.BlockHeight,
+		Payload:     event.Payload,
+	}, eventRouter.pauseBuffer)
+	if _, ok := err.(errors.BufferFull); ok {
+		log.Warn("dropped %s event of paused subscription %s: %v", event.EventType, subscriptionID, err)
+	} else if err != nil {
+		log.Error("failed to buffer %s event of paused subscription %s: %v", event.EventType, subscriptionID, err)
+	}
+}
+
+// move the buffered events of a resumed subscription to the back of the queue, after the events that were queued
+// before the subscription was paused
+func (eventRouter *eventRouter) drainBuffer(ctx context.Context, subscriptionContext *models.SubscriptionContext) SubscriptionStack {
+	subscriptionID := subscriptionContext.Subscription.ID
+	bufferedEvents, err := repository.SubscriptionRepository.PopBufferedEvents(ctx, subscriptionID)
+	if err != nil {
+		log.Error("failed to read the buffered events of subscription %s: %v", subscriptionID, err)
+	}
+
+	stack, ok := eventRouter.queue(subscriptionID)
+	if !ok && len(bufferedEvents) == 0 {
+		return nil
+	}
+	if !ok {
+		stack = eventRouter.subscriptionStack(subscriptionContext)
+	}
+
+	stack.UpdateSubscription(subscriptionContext)
+	for _, bufferedEvent := range bufferedEvents {
+		stack.Add(&QueuedEvent{
+			EventType:   bufferedEvent.EventType,
+			BlockHeight: bufferedEvent.BlockHeight,
+			Payload:     bufferedEvent.Payload,
+		})
+	}
+	if len(bufferedEvents) > 0 {
+		log.Info("resume subscription %s with %d buffered events", subscriptionID, len(bufferedEvents))
+	}
+	return stack
+}
+
+func (eventRouter *eventRouter) queue(subscriptionID string) (SubscriptionStack, bool) {
+	eventRouter.queueLock.RLock()
+	defer eventRouter.queueLock.RUnlock()
+	stack, ok := eventRouter.emitQueue[subscriptionID]
+	return stack, ok
 }
 
 func (eventRouter *eventRouter) emitEvent(subscriptionID string) {
+	stack, ok := eventRouter.queue(subscriptionID)
+	if !ok {
+		return
+	}
+
 	// process all events that should be send to the subscription
-	eventRouter.emitQueue[subscriptionID].Processing(true)
+	stack.Processing(true)
 	for emittingEvents := true; emittingEvents; {
-		subscriptionContext, event := eventRouter.emitQueue[subscriptionID].Pop()
+		// the events that are left in the queue are stored when the router stops
+		if eventRouter.isStopped() {
+			stack.Processing(false)
+			emittingEvents = false
+			continue
+		}
+
+		// the queue is removed when the subscription is deleted
+		if current, ok := eventRouter.queue(subscriptionID); !ok || current != stack {
+			stack.Processing(false)
+			emittingEvents = false
+			continue
+		}
+
+		subscriptionContext, event := stack.Pop()
 		// check if there is nothing left to process
 		if event == nil || subscriptionContext.Subscription.SubscriptionStatus != models.Active {
-			emittingEvents = false
+			// a suspended subscription keeps the event in the queue
+			if event != nil {
+				stack.Push(event)
+			}
+			// an event that is added or a subscription that is activated in the mean time doesn't start a thread
+			// while this thread is processing, this thread continues with them
+			emittingEvents = !stack.StopProcessing()
 			continue
 		}
 
 		// update the subscription if there was a failure in the mean time
 		if subscriptionContext.Failures > 0 {
-			// is subscription context ready updated?
-			var err error
-			subscriptionContext, err = repository.SubscriptionRepository.ReadSubscription(subscriptionID)
+			// is subscription context ready updated? the failure is recorded on the subscription of the queue when the
+			// subscription cannot be read
+			read, err := repository.SubscriptionRepository.ReadSubscription(context.Background(), subscriptionID)
 			if err != nil {
 				log.Error("failed to read subscription before send: %v", err)
-				eventRouter.handleSendFailure(subscriptionContext, err.Error())
+				eventRouter.handleSendFailure(stack, subscriptionContext, err.Error())
 
 				// put the event back on the stack and wait to resend event
-				eventRouter.emitQueue[subscriptionID].Push(event)
-				time.Sleep(eventRouter.retryTimeout)
+				stack.Push(event)
+				eventRouter.waitRetry()
 				continue
 			}
-			eventRouter.emitQueue[subscriptionID].UpdateSubscription(subscriptionContext)
+			subscriptionContext = read
+			stack.UpdateSubscription(subscriptionContext)
 		}
 
-		err := executeSend(&subscriptionContext.Subscription, event)
+		err := eventRouter.deliver(&subscriptionContext.Subscription, event)
+		metrics.Recorder.SubscriptionQueueDepth(subscriptionID, stack.Len())
 
 		// if there was a failure, update the context in case the subscription has been updated in the mean time
 		if err != nil {
 			log.Error("failed to emit event: %v", err)
-			eventRouter.handleSendFailure(subscriptionContext, err.Error())
+			eventRouter.handleSendFailure(stack, subscriptionContext, err.Error())
 
 			// put the event back on the stack and wait to resend event
-			eventRouter.emitQueue[subscriptionID].Push(event)
-			time.Sleep(eventRouter.retryTimeout)
+			stack.Push(event)
+			eventRouter.waitRetry()
 			continue
 		}
 
-		eventRouter.handleSendSuccessful(subscriptionContext)
+		eventRouter.handleSendSuccessful(stack, subscriptionContext)
+	}
+}
+
+func (eventRouter *eventRouter) QueueStatus(ctx context.Context, subscriptionID string) models.SubscriptionQueue {
+	queue := models.SubscriptionQueue{SubscriptionID: subscriptionID}
+	if stack, ok := eventRouter.queue(subscriptionID); ok {
+		queue.Depth = stack.Len()
+		queue.Processing = stack.IsProcessing()
+	}
+
+	buffered, err := repository.SubscriptionRepository.CountBufferedEvents(ctx, subscriptionID)
+	if err != nil {
+		log.Error("failed to count the buffered events of subscription %s: %v", subscriptionID, err)
+	}
+	queue.Buffered = buffered
+	return queue
+}
+
+func (eventRouter *eventRouter) PurgeQueue(ctx context.Context, subscriptionID string) int {
+	n := 0
+	if stack, ok := eventRouter.queue(subscriptionID); ok {
+		n = stack.Clear()
+	}
+
+	eventRouter.bufferLock.Lock()
+	buffered, err := repository.SubscriptionRepository.ClearBufferedEvents(ctx, subscriptionID)
+	eventRouter.bufferLock.Unlock()
+	if err != nil {
+		log.Error("failed to purge the buffered events of subscription %s: %v", subscriptionID, err)
+	}
+
+	log.Info("purged %d events of subscription %s", n+buffered, subscriptionID)
+	return n + buffered
+}
+
+func (eventRouter *eventRouter) UpdateSubscription(ctx context.Context, subscriptionContext *models.SubscriptionContext) {
+	// the access token of changed credentials is requested again and a changed relay connects again
+	oauth2Tokens.Update(&subscriptionContext.Subscription)
+	relayConnections.Update(&subscriptionContext.Subscription)
+
+	eventRouter.bufferLock.Lock()
+	var stack SubscriptionStack
+	if subscriptionContext.Subscription.SubscriptionStatus == models.Active && !eventRouter.isStopped() {
+		stack = eventRouter.drainBuffer(ctx, subscriptionContext)
+	} else if queue, ok := eventRouter.queue(subscriptionContext.Subscription.ID); ok {
+		stack = queue
+		stack.UpdateSubscription(subscriptionContext)
+	}
+	eventRouter.bufferLock.Unlock()
+	if stack == nil {
+		return
+	}
+
+	// deliver the events that are queued while the subscription was suspended or paused
+	if subscriptionContext.Subscription.SubscriptionStatus == models.Active && stack.Len() > 0 {
+		eventRouter.startEmitting(stack, subscriptionContext.Subscription.ID)
+	}
+}
+
+func (eventRouter *eventRouter) Stop(ctx context.Context) error {
+	log.Info("stop event router")
+	close(eventRouter.stopping)
+	select {
+	case <-eventRouter.routed:
+	case <-ctx.Done():
+		log.Warn("stopped routing with %d received events left in the queue", len(eventRouter.eventsInQueue))
+	}
+
+	eventRouter.flush(ctx)
+
+	// stop delivering and wait for the deliveries that are in progress
+	eventRouter.emitLock.Lock()
+	close(eventRouter.stopped)
+	eventRouter.emitLock.Unlock()
+	eventRouter.emitters.Wait()
+	eventRouter.fileSinks.Close()
+
+	// the queues are stored without further changes of the subscriptions
+	if eventRouter.unwatch != nil {
+		eventRouter.unwatch()
+	}
+
+	eventRouter.bufferLock.Lock()
+	defer eventRouter.bufferLock.Unlock()
+	eventRouter.queueLock.RLock()
+	stacks := make(map[string]SubscriptionStack, len(eventRouter.emitQueue))
+	for subscriptionID, stack := range eventRouter.emitQueue {
+		stacks[subscriptionID] = stack
+	}
+	eventRouter.queueLock.RUnlock()
+
+	stored, failed := 0, 0
+	for subscriptionID, stack := range stacks {
+		n, err := eventRouter.storeQueue(subscriptionID, stack)
+		stored += n
+		if err != nil {
+			log.Error("%v", err)
+			failed++
+		}
+	}
+	if stored > 0 {
+		log.Info("stored %d undelivered events", stored)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to store the undelivered events of %d subscriptions", failed)
+	}
+	return nil
+}
+
+// wait until the queued events are delivered or the context is done
+func (eventRouter *eventRouter) flush(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for !eventRouter.delivered() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Warn("failed to deliver the queued events before the deadline: %v", ctx.Err())
+			return
+		}
+	}
+}
+
+// the queued events are delivered when the queues of the active subscriptions are empty and no event is being delivered,
+// the events of suspended and paused subscriptions are not delivered
+func (eventRouter *eventRouter) delivered() bool {
+	eventRouter.queueLock.RLock()
+	defer eventRouter.queueLock.RUnlock()
+	for _, stack := range eventRouter.emitQueue {
+		if stack.IsProcessing() {
+			return false
+		}
+		if stack.Len() > 0 && stack.Subscription().Subscription.SubscriptionStatus == models.Active {
+			return false
+		}
+	}
+	return true
+}
+
+// store the queued events of the subscription in the buffer of the subscription, the events that are buffered while
+// the subscription is paused are stored after the queued events to keep the order. The events are stored with a new
+// context, because the context of the shutdown may be done already.
+func (eventRouter *eventRouter) storeQueue(subscriptionID string, stack SubscriptionStack) (int, error) {
+	var queuedEvents []*QueuedEvent
+	for _, event := stack.Pop(); event != nil; _, event = stack.Pop() {
+		queuedEvents = append(queuedEvents, event)
+	}
+	if len(queuedEvents) == 0 {
+		return 0, nil
+	}
+
+	ctx := context.Background()
+	bufferedEvents, err := repository.SubscriptionRepository.PopBufferedEvents(ctx, subscriptionID)
+	if err != nil {
+		log.Error("failed to read the buffered events of subscription %s: %v", subscriptionID, err)
+	}
+	events := make([]*models.BufferedEvent, 0, len(queuedEvents)+len(bufferedEvents))
+	for _, event := range queuedEvents {
+		events = append(events, &models.BufferedEvent{
+			EventType:   event.EventType,
+			BlockHeight: event.BlockHeight,
+			Payload:     event.Payload,
+		})
+	}
+	events = append(events, bufferedEvents...)
+
+	// the undelivered events are not limited by the size of the buffer of paused subscriptions
+	for i, event := range events {
+		if err := repository.SubscriptionRepository.BufferEvent(ctx, subscriptionID, event, math.MaxInt32); err != nil {
+			return 0, fmt.Errorf("failed to store %d events of subscription %s: %v", len(events)-i, subscriptionID, err)
+		}
+	}
+	return len(queuedEvents), nil
+}
+
+// deliver the event to the callback of the subscription
+func (eventRouter *eventRouter) deliver(subscription *models.Subscription, event *QueuedEvent) (err error) {
+	start := time.Now()
+	statusCode := 0
+	defer func() {
+		metrics.Recorder.DeliveryAttempted(subscription.CallbackType, statusCode, time.Since(start), err)
+	}()
+
+	switch subscription.CallbackType {
+	case models.File:
+		return eventRouter.fileSinks.Write(subscription, event)
+	case models.Relay:
+		return relayConnections.Send(subscription, event.Payload)
+	default:
+		response, err := executeSend(subscription, event.Payload)
+		if response != nil {
+			statusCode = response.statusCode
+		}
+		return err
 	}
-	eventRouter.emitQueue[subscriptionID].Processing(false)
 }
 
-func executeSend(subscription *models.Subscription, event []byte) error {
+// callbackResponse is the response of the endpoint of a subscription
+type callbackResponse struct {
+	statusCode int
+	body       []byte
+}
+
+// send the event to the endpoint of the subscription, the response is returned when the endpoint responded
+func executeSend(subscription *models.Subscription, event []byte) (*callbackResponse, error) {
 	url := subscription.CallbackURL
 
+	response, err := sendRequest(subscription, event)
+	if err != nil {
+		return nil, err
+	}
+
+	// the cached access token may be revoked before it expires, retry once with a new access token
+	if response.StatusCode == http.StatusUnauthorized && subscription.CallbackType == models.OAuth2ClientCredentials {
+		_ = response.Body.Close()
+		oauth2Tokens.Invalidate(subscription)
+
+		log.Debug("retry send event to '%s' with a new access token", url)
+		response, err = sendRequest(subscription, event)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxResponseBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from '%s': %v", url, err)
+	}
+	callback := &callbackResponse{statusCode: response.StatusCode, body: body}
+
+	if response.StatusCode != http.StatusOK {
+		return callback, fmt.Errorf("failed to receive correct response from '%s': code=%d, body=%s", url, response.StatusCode, body)
+	}
+
+	return callback, nil
+}
+
+func sendRequest(subscription *models.Subscription, event []byte) (*http.Response, error) {
+	request, err := newCallbackRequest(subscription, event)
+	if err != nil {
+		return nil, err
+	}
+	return doCallbackRequest(request)
+}
+
+// create the request to the endpoint of the subscription with the headers and the authentication of the subscription
+func newCallbackRequest(subscription *models.Subscription, event []byte) (*http.Request, error) {
+	url := subscription.CallbackURL
+
+	method := subscription.CallbackMethod
+	if method == "" {
+		method = http.MethodPost
+	}
+
 	// Create a new request
-	request, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(event))
+	request, err := http.NewRequest(method, url, bytes.NewBuffer(event))
 	if err != nil || request == nil {
-		return fmt.Errorf("failed to create request to '%s': %v", url, err)
+		return nil, fmt.Errorf("failed to create request to '%s': %v", url, err)
+	}
+
+	// the static headers of the subscription can override the content type
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range subscription.CallbackHeaders {
+		request.Header.Set(name, value)
 	}
 
 	// setup authentication
-	if subscription.CallbackType == models.BasicAuth {
+	switch subscription.CallbackType {
+	case models.BasicAuth:
 		auth := subscription.Credentials.BasicAuthUsername + ":" + subscription.Credentials.BasicAuthPassword
 		authentication := base64.StdEncoding.EncodeToString([]byte(auth))
 		request.Header.Add("Authorization", "Basic "+authentication)
-	} else if subscription.CallbackType == models.BearerToken {
+	case models.BearerToken:
 		bearer := "Bearer " + subscription.Credentials.AccessToken
 		request.Header.Add("Authorization", bearer)
+	case models.OAuth2ClientCredentials:
+		token, err := oauth2Tokens.Token(subscription)
+		if err != nil {
+			return nil, err
+		}
+		token.SetAuthHeader(request)
 	}
 
-	log.Debug("send event to '%s' %v", subscription.CallbackURL, subscription.CallbackType)
+	log.Debug("send event to %s '%s' %v", method, subscription.CallbackURL, subscription.CallbackType)
+	return request, nil
+}
+
+func doCallbackRequest(request *http.Request) (*http.Response, error) {
+	url := request.URL.String()
 
 	// send request using default http Client
 	response, err := http.DefaultClient.Do(request)
 
 	if err != nil {
-		return fmt.Errorf("failed to send event to '%s': %v", url, err)
+		return nil, fmt.Errorf("failed to send event to '%s': %v", url, err)
 	}
 	if response == nil {
-		return fmt.Errorf("failed to receive correct response from '%s': no response", url)
+		return nil, fmt.Errorf("failed to receive correct response from '%s': no response", url)
 	}
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to receive correct response from '%s': code=%d, body=%v", url, response.StatusCode, response)
-	}
-
-	return nil
+	return response, nil
 }
 
 // emit event fails, if the number of failures pass a threshold, suspend the subscription
 // set the reason in the subscription info
-func (eventRouter *eventRouter) handleSendFailure(subscriptionContext *models.SubscriptionContext, reason string) {
+func (eventRouter *eventRouter) handleSendFailure(stack SubscriptionStack, subscriptionContext *models.SubscriptionContext, reason string) {
+	// the subscription is shared with the queue and the index, the changes are made on a copy
+	subscriptionContext = copySubscriptionContext(subscriptionContext)
 	subscriptionContext.Failures++
 	subscriptionContext.Subscription.SubscriptionInfo = fmt.Sprintf("%s%d: %s\n", subscriptionContext.Subscription.SubscriptionInfo, subscriptionContext.Failures, reason)
 	if subscriptionContext.Failures >= eventRouter.maxRetries {
 		subscriptionContext.Subscription.SubscriptionStatus = models.Suspended
 	}
-	// update the database
-	_, err := repository.SubscriptionRepository.UpdateSubscription(subscriptionContext)
+	// update only the fields of the router in the database, such that concurrent changes of the api are not lost
+	err := repository.SubscriptionRepository.UpdateSubscriptionStatus(context.Background(), subscriptionContext)
 	if err != nil {
 		log.Error("failed update subscription after delivery failure: %v", err)
 	}
+	eventRouter.statusUpdated(stack, subscriptionContext)
 }
 
-func (eventRouter *eventRouter) handleSendSuccessful(subscriptionContext *models.SubscriptionContext) {
+func (eventRouter *eventRouter) handleSendSuccessful(stack SubscriptionStack, subscriptionContext *models.SubscriptionContext) {
 	// update only the subscription if the failures and status needs to be reset
 	if subscriptionContext.Failures > 0 {
+		subscriptionContext = copySubscriptionContext(subscriptionContext)
 		subscriptionContext.Failures = 0
 		subscriptionContext.Subscription.SubscriptionStatus = models.Active
 		subscriptionContext.Subscription.SubscriptionInfo = ""
 
 		// update the database
-		_, err := repository.SubscriptionRepository.UpdateSubscription(subscriptionContext)
+		err := repository.SubscriptionRepository.UpdateSubscriptionStatus(context.Background(), subscriptionContext)
 		if err != nil {
 			log.Error("failed update subscription after delivery failure: %v", err)
 		}
+		eventRouter.statusUpdated(stack, subscriptionContext)
 	}
 }
+
+// apply the failures and the status that the router wrote to the queue and the index of the subscriptions, such that
+// the next event is delivered with the changed subscription
+func (eventRouter *eventRouter) statusUpdated(stack SubscriptionStack, subscriptionContext *models.SubscriptionContext) {
+	stack.UpdateSubscription(subscriptionContext)
+	eventRouter.subscriptions.updateStatus(subscriptionContext)
+}
@@ -2,6 +2,7 @@ package events
 
 import (
 	"bytes"
+	"context"
 	"crypto/subtle"
 	"crypto/tls"
 	"encoding/json"
@@ -9,14 +10,20 @@ import (
 	"github.com/FactomProject/live-feed-api/EventRouter/config"
 	"github.com/FactomProject/live-feed-api/EventRouter/eventmessages/generated/eventmessages"
 	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/metrics"
 	"github.com/FactomProject/live-feed-api/EventRouter/models"
 	"github.com/FactomProject/live-feed-api/EventRouter/repository"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -28,11 +35,13 @@ func init() {
 
 func TestHandleEvent(t *testing.T) {
 	port := 23221
-	subscriptionContexts := models.SubscriptionContexts{initSubscription("id", port, 0)}
+	subscriptionContexts := models.SubscriptionContexts{initEntryCommitSubscription("id", port)}
 
 	// init mock repository
 	mockStore := repository.InitMockRepository()
-	mockStore.On("GetActiveSubscriptions", models.EntryCommit).Return(subscriptionContexts, nil).Once()
+	mockStore.On("Watch", mock.Anything).Return(make(chan models.SubscriptionChange), nil).Once()
+	mockStore.On("ListSubscriptions", models.SubscriptionQuery{}).Return(subscriptionContexts, len(subscriptionContexts), nil).Once()
+	mockStore.On("ListSubscriptions", models.SubscriptionQuery{Status: models.Active}).Return(models.SubscriptionContexts{}, 0, nil).Once()
 
 	var eventsReceived int32 = 0
 	factomEvent, expectedEvent := mockFactomEvent(t)
@@ -59,15 +68,17 @@ func TestHandleEvents(t *testing.T) {
 	port1 := 23222
 	port2 := 23223
 
-	subscription1 := initSubscription("id1", port1, 0)
-	subscription2 := initSubscription("id2", port2, 0)
+	subscription1 := initEntryCommitSubscription("id1", port1)
+	subscription2 := initEntryCommitSubscription("id2", port2)
 	subscriptionContexts := models.SubscriptionContexts{
 		subscription1, subscription1, subscription2, subscription2, subscription1,
 	}
 
 	// init mock repository
 	mockStore := repository.InitMockRepository()
-	mockStore.On("GetActiveSubscriptions", models.EntryCommit).Return(subscriptionContexts, nil).Once()
+	mockStore.On("Watch", mock.Anything).Return(make(chan models.SubscriptionChange), nil).Once()
+	mockStore.On("ListSubscriptions", models.SubscriptionQuery{}).Return(subscriptionContexts, len(subscriptionContexts), nil).Once()
+	mockStore.On("ListSubscriptions", models.SubscriptionQuery{Status: models.Active}).Return(models.SubscriptionContexts{}, 0, nil).Once()
 
 	var eventsReceived int32 = 0
 	factomEvent, expectedEvent := mockFactomEvent(t)
@@ -92,11 +103,13 @@ func TestHandleEvents(t *testing.T) {
 
 func TestHandleFactomEvents(t *testing.T) {
 	port := 23224
-	subscriptionContexts := models.SubscriptionContexts{initSubscription("id", port, 0)}
+	subscriptionContexts := models.SubscriptionContexts{initEntryCommitSubscription("id", port)}
 
 	// init mock repository
 	mockStore := repository.InitMockRepository()
-	mockStore.On("GetActiveSubscriptions", models.EntryCommit).Return(subscriptionContexts, nil).Twice()
+	mockStore.On("Watch", mock.Anything).Return(make(chan models.SubscriptionChange), nil).Once()
+	mockStore.On("ListSubscriptions", models.SubscriptionQuery{}).Return(subscriptionContexts, len(subscriptionContexts), nil).Once()
+	mockStore.On("ListSubscriptions", models.SubscriptionQuery{Status: models.Active}).Return(models.SubscriptionContexts{}, 0, nil).Once()
 
 	var eventsReceived int32 = 0
 	factomEvent, expectedEvent := mockFactomEvent(t)
@@ -148,7 +161,7 @@ func TestSendEvents(t *testing.T) {
 
 	// test send events
 	for i := 0; i < n; i++ {
-		eventRouter.sendEvent(subscriptionContext, event)
+		eventRouter.sendEvent(subscriptionContext, &QueuedEvent{EventType: models.EntryCommit, Payload: event})
 	}
 
 	waitOnEventReceived(&eventsReceived, n, 1*time.Minute)
@@ -188,7 +201,7 @@ func TestEmitEvent(t *testing.T) {
 
 	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack)}
 	eventRouter.emitQueue[subscriptionContext.Subscription.ID] = NewSubscriptionStack(subscriptionContext)
-	eventRouter.emitQueue[subscriptionContext.Subscription.ID].Add(event)
+	eventRouter.emitQueue[subscriptionContext.Subscription.ID].Add(&QueuedEvent{EventType: models.EntryCommit, Payload: event})
 
 	// test emit event
 	eventRouter.emitEvent(subscriptionID)
@@ -215,24 +228,25 @@ func TestEmitEventFailureRecover(t *testing.T) {
 	// init mock repository
 	mockStore := repository.InitMockRepository()
 	mockStore.On("ReadSubscription", subscriptionID).Return(updatedSubscriptionContext, nil).Once()
-	mockStore.On("UpdateSubscription", subscriptionID).Return(nil, nil).Times(2)
+	mockStore.On("UpdateSubscriptionStatus", subscriptionID).Return(nil).Times(2)
 
 	eventsReceived := int32(0)
 	_, event := mockFactomEvent(t)
 	startMockServer(t, port, &eventsReceived, nil, event)
 
-	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack), maxRetries: maxRetries, retryTimeout: 1 * time.Millisecond}
+	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack), maxRetries: maxRetries, retryTimeout: 1 * time.Millisecond, subscriptions: newSubscriptionIndex()}
 	eventRouter.emitQueue[subscriptionContext.Subscription.ID] = NewSubscriptionStack(subscriptionContext)
-	eventRouter.emitQueue[subscriptionContext.Subscription.ID].Add(event)
+	eventRouter.emitQueue[subscriptionContext.Subscription.ID].Add(&QueuedEvent{EventType: models.EntryCommit, Payload: event})
 
 	// test emit event retry
 	eventRouter.emitEvent(subscriptionID)
 
 	assert.Equal(t, int32(1), eventsReceived)
-	assert.Equal(t, uint16(1), subscriptionContext.Failures)
-	assert.Equal(t, uint16(0), updatedSubscriptionContext.Failures)
-	assert.Equal(t, models.Active, updatedSubscriptionContext.Subscription.SubscriptionStatus)
-	assert.Equal(t, "", updatedSubscriptionContext.Subscription.SubscriptionInfo)
+	subscriptionContext = eventRouter.emitQueue[subscriptionID].Subscription()
+	assert.Equal(t, updatedSubscriptionContext.Subscription.CallbackURL, subscriptionContext.Subscription.CallbackURL)
+	assert.Equal(t, uint16(0), subscriptionContext.Failures)
+	assert.Equal(t, models.Active, subscriptionContext.Subscription.SubscriptionStatus)
+	assert.Equal(t, "", subscriptionContext.Subscription.SubscriptionInfo)
 
 	mockStore.AssertExpectations(t)
 }
@@ -244,24 +258,26 @@ func TestEmitEventFailureRetry(t *testing.T) {
 	subscriptionID := "id"
 	subscriptionContext := initSubscription(subscriptionID, port, 0)
 
-	// init mock repository
+	// the repository returns the failures that the router stored
 	mockStore := repository.InitMockRepository()
-	mockStore.On("ReadSubscription", subscriptionID).Return(subscriptionContext, nil).Twice()
-	mockStore.On("UpdateSubscription", subscriptionID).Return(nil, nil).Times(3)
+	mockStore.On("ReadSubscription", subscriptionID).Return(initSubscription(subscriptionID, port, 1), nil).Once()
+	mockStore.On("ReadSubscription", subscriptionID).Return(initSubscription(subscriptionID, port, 2), nil).Once()
+	mockStore.On("UpdateSubscriptionStatus", subscriptionID).Return(nil).Times(3)
 
 	eventsReceived := int32(0)
 	_, event := mockFactomEvent(t)
 	authFailure := func(r *http.Request) bool { return false }
 	startMockServer(t, port, &eventsReceived, authFailure, event)
 
-	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack), maxRetries: maxRetries, retryTimeout: 1 * time.Millisecond}
+	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack), maxRetries: maxRetries, retryTimeout: 1 * time.Millisecond, subscriptions: newSubscriptionIndex()}
 	eventRouter.emitQueue[subscriptionContext.Subscription.ID] = NewSubscriptionStack(subscriptionContext)
-	eventRouter.emitQueue[subscriptionContext.Subscription.ID].Add(event)
+	eventRouter.emitQueue[subscriptionContext.Subscription.ID].Add(&QueuedEvent{EventType: models.EntryCommit, Payload: event})
 
 	// test emit event retry
 	eventRouter.emitEvent(subscriptionID)
 
 	assert.Equal(t, int32(3), eventsReceived)
+	subscriptionContext = eventRouter.emitQueue[subscriptionID].Subscription()
 	assert.Equal(t, maxRetries, subscriptionContext.Failures)
 	assert.Equal(t, models.Suspended, subscriptionContext.Subscription.SubscriptionStatus)
 	assert.NotEqual(t, "", subscriptionContext.Subscription.SubscriptionInfo)
@@ -278,18 +294,20 @@ func TestEmitEventDBTimeout(t *testing.T) {
 
 	// init mock repository
 	mockStore := repository.InitMockRepository()
-	mockStore.On("ReadSubscription", subscriptionID).Return(subscriptionContext, fmt.Errorf("db timeout")).Once()
-	mockStore.On("UpdateSubscription", subscriptionID).Return(nil, nil).Times(1)
+	// the repository returns no subscription with the error, the failure is recorded on the subscription of the queue
+	mockStore.On("ReadSubscription", subscriptionID).Return((*models.SubscriptionContext)(nil), fmt.Errorf("db timeout")).Once()
+	mockStore.On("UpdateSubscriptionStatus", subscriptionID).Return(nil).Times(1)
 
 	_, event := mockFactomEvent(t)
 
-	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack), maxRetries: maxRetries, retryTimeout: 1 * time.Millisecond}
+	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack), maxRetries: maxRetries, retryTimeout: 1 * time.Millisecond, subscriptions: newSubscriptionIndex()}
 	eventRouter.emitQueue[subscriptionContext.Subscription.ID] = NewSubscriptionStack(subscriptionContext)
-	eventRouter.emitQueue[subscriptionContext.Subscription.ID].Add(event)
+	eventRouter.emitQueue[subscriptionContext.Subscription.ID].Add(&QueuedEvent{EventType: models.EntryCommit, Payload: event})
 
 	// test emit event retry
 	eventRouter.emitEvent(subscriptionID)
 
+	subscriptionContext = eventRouter.emitQueue[subscriptionID].Subscription()
 	assert.Equal(t, maxRetries, subscriptionContext.Failures)
 	assert.Equal(t, models.Suspended, subscriptionContext.Subscription.SubscriptionStatus)
 	assert.Contains(t, subscriptionContext.Subscription.SubscriptionInfo, "db timeout")
@@ -297,6 +315,337 @@ func TestEmitEventDBTimeout(t *testing.T) {
 	mockStore.AssertExpectations(t)
 }
 
+func TestQueueStatusAndPurge(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+	subscriptionContext := initSubscription("queue-id", 0, 0)
+	subscriptionContext.Subscription.SubscriptionStatus = models.Suspended
+
+	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack)}
+	assert.Equal(t, models.SubscriptionQueue{SubscriptionID: "queue-id"}, eventRouter.QueueStatus(context.Background(), "queue-id"))
+	assert.Equal(t, 0, eventRouter.PurgeQueue(context.Background(), "queue-id"))
+
+	eventRouter.emitQueue["queue-id"] = NewSubscriptionStack(subscriptionContext)
+	eventRouter.emitQueue["queue-id"].Add(&QueuedEvent{EventType: models.EntryCommit})
+	eventRouter.emitQueue["queue-id"].Add(&QueuedEvent{EventType: models.ChainCommit})
+
+	// the events of a suspended subscription stay in the queue
+	eventRouter.emitEvent("queue-id")
+	assert.Equal(t, models.SubscriptionQueue{SubscriptionID: "queue-id", Depth: 2}, eventRouter.QueueStatus(context.Background(), "queue-id"))
+
+	assert.Equal(t, 2, eventRouter.PurgeQueue(context.Background(), "queue-id"))
+	assert.Equal(t, models.SubscriptionQueue{SubscriptionID: "queue-id"}, eventRouter.QueueStatus(context.Background(), "queue-id"))
+}
+
+func TestUpdateSubscriptionResumesQueue(t *testing.T) {
+	port := 25235
+	subscriptionID := "resume-id"
+	subscriptionContext := initSubscription(subscriptionID, port, 0)
+	subscriptionContext.Subscription.SubscriptionStatus = models.Suspended
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+
+	var eventsReceived int32 = 0
+	_, event := mockFactomEvent(t)
+	startMockServer(t, port, &eventsReceived, nil, event)
+
+	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack)}
+	eventRouter.emitQueue[subscriptionID] = NewSubscriptionStack(subscriptionContext)
+	eventRouter.emitQueue[subscriptionID].Add(&QueuedEvent{EventType: models.EntryCommit, Payload: event})
+	eventRouter.emitQueue[subscriptionID].Add(&QueuedEvent{EventType: models.EntryCommit, Payload: event})
+
+	// unknown subscriptions are ignored
+	eventRouter.UpdateSubscription(context.Background(), initSubscription("unknown", port, 0))
+
+	// the suspended subscription doesn't receive events
+	eventRouter.UpdateSubscription(context.Background(), subscriptionContext)
+	eventRouter.emitEvent(subscriptionID)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&eventsReceived))
+	assert.Equal(t, 2, eventRouter.QueueStatus(context.Background(), subscriptionID).Depth)
+
+	// the reactivated subscription receives the queued events
+	reactivated := initSubscription(subscriptionID, port, 0)
+	eventRouter.UpdateSubscription(context.Background(), reactivated)
+	waitOnEventReceived(&eventsReceived, 2, 5*time.Second)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&eventsReceived))
+}
+
+func TestPausedSubscriptionBuffersEvents(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+
+	var lock sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		received = append(received, string(body))
+	}))
+	defer server.Close()
+
+	subscriptionContext, err := repository.SubscriptionRepository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        server.URL,
+			CallbackType:       models.HTTP,
+			SubscriptionStatus: models.Paused,
+			Filters:            map[models.EventType]models.Filter{models.ChainCommit: {}},
+		},
+	})
+	assert.Nil(t, err)
+	subscriptionID := subscriptionContext.Subscription.ID
+	paused := *subscriptionContext
+
+	eventRouter := NewEventRouter(&config.RouterConfig{PauseBuffer: 2}, nil).(*eventRouter)
+
+	// the events of the paused subscription are buffered up to the limit
+	for _, payload := range []string{"1", "2", "3"} {
+		eventRouter.sendEvent(&paused, &QueuedEvent{EventType: models.ChainCommit, Payload: []byte(payload)})
+	}
+	assert.Equal(t, models.SubscriptionQueue{SubscriptionID: subscriptionID, Buffered: 2}, eventRouter.QueueStatus(context.Background(), subscriptionID))
+
+	// the resumed subscription receives the buffered events in order before the new events, also when the new event
+	// was routed with the paused subscription
+	resumed := paused
+	resumed.Subscription.SubscriptionStatus = models.Active
+	resumed.Version++
+	eventRouter.UpdateSubscription(context.Background(), &resumed)
+	eventRouter.sendEvent(&paused, &QueuedEvent{EventType: models.ChainCommit, Payload: []byte("4")})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && eventRouter.QueueStatus(context.Background(), subscriptionID) != (models.SubscriptionQueue{SubscriptionID: subscriptionID}) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{"1", "2", "4"}, received)
+}
+
+func TestRouterWatchesSubscriptionChanges(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+
+	eventsReceived := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&eventsReceived, 1)
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	queue := make(chan *eventmessages.FactomEvent)
+	router := NewEventRouter(&config.RouterConfig{MaxRetries: 100, RetryTimeout: 1}, queue).(*eventRouter)
+	router.Start()
+
+	// the created subscription receives the routed events
+	subscriptionContext, err := repository.SubscriptionRepository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        server.URL,
+			CallbackType:       models.HTTP,
+			SubscriptionStatus: models.Active,
+			Filters:            map[models.EventType]models.Filter{models.NodeMessage: {}},
+		},
+	})
+	assert.Nil(t, err)
+	subscriptionID := subscriptionContext.Subscription.ID
+	waitUntil(t, func() bool {
+		subscriptionContexts, _ := router.subscriptions.activeSubscriptions(models.NodeMessage)
+		return len(subscriptionContexts) == 1
+	})
+
+	factomEvent, err := SyntheticEvent(models.NodeMessage)
+	assert.Nil(t, err)
+	queue <- factomEvent
+	waitUntil(t, func() bool { return atomic.LoadInt32(&eventsReceived) > 0 })
+
+	// the queue of the deleted subscription is removed and the failing delivery is not retried
+	assert.Nil(t, repository.SubscriptionRepository.DeleteSubscription(context.Background(), subscriptionID))
+	waitUntil(t, func() bool {
+		_, ok := router.queue(subscriptionID)
+		return !ok
+	})
+	subscriptionContexts, err := router.subscriptions.activeSubscriptions(models.NodeMessage)
+	assert.Nil(t, err)
+	assert.Empty(t, subscriptionContexts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, router.Stop(ctx))
+	assert.Equal(t, 0, router.QueueStatus(context.Background(), subscriptionID).Depth)
+}
+
+// the subscriptions are shared between the router, the queues and the index, run with -race to detect unguarded changes
+func TestRouterFailuresWithConcurrentUpdates(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+
+	deliveries := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&deliveries, 1)
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	queue := make(chan *eventmessages.FactomEvent)
+	router := NewEventRouter(&config.RouterConfig{MaxRetries: math.MaxUint16, RetryTimeout: 0}, queue).(*eventRouter)
+	router.Start()
+
+	subscriptionContext, err := repository.SubscriptionRepository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        server.URL,
+			CallbackType:       models.HTTP,
+			SubscriptionStatus: models.Active,
+			Filters:            map[models.EventType]models.Filter{models.NodeMessage: {}},
+		},
+	})
+	assert.Nil(t, err)
+	waitUntil(t, func() bool {
+		subscriptionContexts, _ := router.subscriptions.activeSubscriptions(models.NodeMessage)
+		return len(subscriptionContexts) == 1
+	})
+
+	// the api updates the subscription and the router routes events to the subscription while the deliveries fail
+	stop := make(chan struct{})
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			substitute := *subscriptionContext
+			substitute.Version = 0
+			substitute.Subscription.CallbackHeaders = map[string]string{"X-Update": fmt.Sprint(i)}
+			_, err := repository.SubscriptionRepository.UpdateSubscription(context.Background(), &substitute)
+			assert.Nil(t, err)
+		}
+	}()
+	go func() {
+		defer workers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			subscriptionContexts, err := router.subscriptions.activeSubscriptions(models.NodeMessage)
+			assert.Nil(t, err)
+			for _, subscriptionContext := range subscriptionContexts {
+				assert.True(t, subscriptionContext.Failures < math.MaxUint16)
+			}
+		}
+	}()
+	factomEvent, err := SyntheticEvent(models.NodeMessage)
+	assert.Nil(t, err)
+	for atomic.LoadInt32(&deliveries) < 50 {
+		queue <- factomEvent
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	workers.Wait()
+
+	// the failures that the router writes reach the index
+	waitUntil(t, func() bool {
+		subscriptionContexts, _ := router.subscriptions.activeSubscriptions(models.NodeMessage)
+		return len(subscriptionContexts) == 1 && subscriptionContexts[0].Failures > 0
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Nil(t, router.Stop(ctx))
+}
+
+func TestStopDeliversReceivedEvents(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+
+	eventsReceived := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&eventsReceived, 1)
+	}))
+	defer server.Close()
+
+	_, err := repository.SubscriptionRepository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        server.URL,
+			CallbackType:       models.HTTP,
+			SubscriptionStatus: models.Active,
+			Filters:            map[models.EventType]models.Filter{models.NodeMessage: {}},
+		},
+	})
+	assert.Nil(t, err)
+
+	n := 3
+	queue := make(chan *eventmessages.FactomEvent, n)
+	for i := 0; i < n; i++ {
+		factomEvent, err := SyntheticEvent(models.NodeMessage)
+		assert.Nil(t, err)
+		queue <- factomEvent
+	}
+
+	// the events that are left in the queue are routed and delivered before the router stops
+	router := NewEventRouter(&config.RouterConfig{MaxRetries: 3, RetryTimeout: 1}, queue)
+	router.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, router.Stop(ctx))
+	assert.Equal(t, int32(n), atomic.LoadInt32(&eventsReceived))
+}
+
+func TestStopStoresUndeliveredEvents(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+
+	var lock sync.Mutex
+	var received []string
+	available := false
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		if !available {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received = append(received, string(body))
+	}))
+	defer server.Close()
+
+	subscriptionContext, err := repository.SubscriptionRepository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        server.URL,
+			CallbackType:       models.HTTP,
+			SubscriptionStatus: models.Active,
+			Filters:            map[models.EventType]models.Filter{models.ChainCommit: {}},
+		},
+	})
+	assert.Nil(t, err)
+	subscriptionID := subscriptionContext.Subscription.ID
+	active := *subscriptionContext
+
+	// the router waits for the retry when the router stops, the waiting is interrupted
+	configuration := &config.RouterConfig{MaxRetries: 100, RetryTimeout: 3600}
+	router := NewEventRouter(configuration, make(chan *eventmessages.FactomEvent)).(*eventRouter)
+	for _, payload := range []string{"1", "2", "3"} {
+		router.sendEvent(&active, &QueuedEvent{EventType: models.ChainCommit, Payload: []byte(payload)})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Nil(t, router.Stop(ctx))
+	assert.Equal(t, models.SubscriptionQueue{SubscriptionID: subscriptionID, Buffered: 3}, router.QueueStatus(context.Background(), subscriptionID))
+
+	// the stored events are delivered in order after a restart
+	lock.Lock()
+	available = true
+	lock.Unlock()
+	restarted := NewEventRouter(configuration, make(chan *eventmessages.FactomEvent)).(*eventRouter)
+	restarted.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && restarted.QueueStatus(context.Background(), subscriptionID) != (models.SubscriptionQueue{SubscriptionID: subscriptionID}) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{"1", "2", "3"}, received)
+}
+
 func TestExecuteSendHTTP(t *testing.T) {
 	port := 24231
 	subscription := initSubscription("id", port, 0)
@@ -308,7 +657,7 @@ func TestExecuteSendHTTP(t *testing.T) {
 	startMockServer(t, port, &eventsReceived, nil, event)
 
 	// test send to the http endpoint
-	err := executeSend(&subscription.Subscription, event)
+	_, err := executeSend(&subscription.Subscription, event)
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
@@ -349,7 +698,7 @@ func TestExecuteSendHTTPSBearerToken(t *testing.T) {
 	startMockTLSServer(t, port, certFile, pkFile, &eventsReceived, validateToken(accessToken), event)
 
 	// test send to the http endpoint with oauth2
-	err := executeSend(subscription, event)
+	_, err := executeSend(subscription, event)
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
@@ -392,7 +741,7 @@ func TestExecuteSendHTTPSBasicAuth(t *testing.T) {
 	startMockTLSServer(t, port, certFile, pkFile, &eventsReceived, validateUsernamePassword(username, password), event)
 
 	// test send to the http endpoint with oauth2
-	err := executeSend(subscription, event)
+	_, err := executeSend(subscription, event)
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
@@ -402,21 +751,90 @@ func TestExecuteSendHTTPSBasicAuth(t *testing.T) {
 	assert.Equal(t, int32(1), eventsReceived, "failed to deliver correct number of events: %d expected != %d received", 1, eventsReceived)
 }
 
+func TestExecuteSendOAuth2ClientCredentials(t *testing.T) {
+	port := 24234
+	subscription := &models.Subscription{
+		ID:           "oauth2",
+		CallbackURL:  fmt.Sprintf("http://localhost:%[1]d/callback%[1]d", port),
+		CallbackType: models.OAuth2ClientCredentials,
+		Filters: map[models.EventType]models.Filter{
+			models.ChainCommit: {Filtering: ""},
+		},
+		Credentials: models.Credentials{
+			OAuth2TokenURL:     fmt.Sprintf("http://localhost:%[1]d/token%[1]d", port),
+			OAuth2ClientID:     "client",
+			OAuth2ClientSecret: "secret",
+			OAuth2Scopes:       []string{"events"},
+		},
+	}
+
+	_, event := mockFactomEvent(t)
+
+	// the first issued token is revoked, such that the delivery needs to retry with a new token
+	tokensIssued := int32(0)
+	startMockTokenServer(t, port, &tokensIssued)
+
+	eventsReceived := int32(0)
+	startMockServer(t, port, &eventsReceived, validateToken("token-2"), event)
+
+	_, err := executeSend(subscription, event)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&eventsReceived))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&tokensIssued))
+
+	// the cached token should be reused
+	_, err = executeSend(subscription, event)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&eventsReceived))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&tokensIssued))
+}
+
+func TestExecuteSendCallbackHeaders(t *testing.T) {
+	port := 24235
+	subscription := initSubscription("id", port, 0).Subscription
+	subscription.CallbackMethod = http.MethodPut
+	subscription.CallbackHeaders = map[string]string{
+		"X-Api-Key":    "key",
+		"content-type": "application/vnd.factom+json",
+	}
+
+	_, event := mockFactomEvent(t)
+	eventsReceived := int32(0)
+
+	validateRequest := func(r *http.Request) bool {
+		return r.Method == http.MethodPut && r.Header.Get("X-Api-Key") == "key" && r.Header.Get("Content-Type") == "application/vnd.factom+json"
+	}
+	startMockServer(t, port, &eventsReceived, validateRequest, event)
+
+	_, err := executeSend(&subscription, event)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&eventsReceived))
+}
+
 func TestExecuteSendNoEndpoint(t *testing.T) {
 	subscription := &initSubscription("id", 999, 0).Subscription
 
 	_, event := mockFactomEvent(t)
 
 	// test send to http oauth2 endpoint
-	err := executeSend(subscription, event)
+	_, err := executeSend(subscription, event)
 
 	assert.Contains(t, err.Error(), "connect: connection refused")
 }
 
 func TestHandleSendFailure(t *testing.T) {
 	mockStore := repository.InitMockRepository()
-	mockStore.On("UpdateSubscription", "id").Return(nil, nil).Times(3)
-	mockStore.On("UpdateSubscription", "error").Return(nil, fmt.Errorf("db failure")).Once()
+	mockStore.On("UpdateSubscriptionStatus", "id").Return(nil).Times(3)
+	mockStore.On("UpdateSubscriptionStatus", "error").Return(fmt.Errorf("db failure")).Once()
 
 	maxRetries := uint16(3)
 	testCases := map[string]*models.SubscriptionContext{
@@ -437,21 +855,31 @@ func TestHandleSendFailure(t *testing.T) {
 			Failures:     maxRetries,
 		},
 	}
-	eventRouter := &eventRouter{maxRetries: maxRetries}
+	eventRouter := &eventRouter{maxRetries: maxRetries, subscriptions: newSubscriptionIndex()}
 	for name, subscriptionContext := range testCases {
 		t.Run(name, func(t *testing.T) {
-			eventRouter.handleSendFailure(subscriptionContext, "failed to deliver event")
+			failures := subscriptionContext.Failures
+			stack := NewSubscriptionStack(subscriptionContext)
+			eventRouter.handleSendFailure(stack, subscriptionContext, "failed to deliver event")
+
+			// the shared subscription is not modified, the queue receives the changed copy
+			assert.Equal(t, failures, subscriptionContext.Failures)
+			assert.Equal(t, failures+1, stack.Subscription().Failures)
+			assert.Contains(t, stack.Subscription().Subscription.SubscriptionInfo, "failed to deliver event")
+			if failures+1 >= maxRetries {
+				assert.Equal(t, models.Suspended, stack.Subscription().Subscription.SubscriptionStatus)
+			}
 		})
 
-		mockStore.AssertCalled(t, "UpdateSubscription", subscriptionContext.Subscription.ID)
+		mockStore.AssertCalled(t, "UpdateSubscriptionStatus", subscriptionContext.Subscription.ID)
 	}
 	mockStore.AssertExpectations(t)
 }
 
 func TestHandleSendSuccessful(t *testing.T) {
 	mockStore := repository.InitMockRepository()
-	mockStore.On("UpdateSubscription", "id").Return(nil, nil).Once()
-	mockStore.On("UpdateSubscription", "error").Return(nil, fmt.Errorf("db failure")).Once()
+	mockStore.On("UpdateSubscriptionStatus", "id").Return(nil).Once()
+	mockStore.On("UpdateSubscriptionStatus", "error").Return(fmt.Errorf("db failure")).Once()
 
 	testCases := map[string]*models.SubscriptionContext{
 		"update": {
@@ -467,15 +895,115 @@ func TestHandleSendSuccessful(t *testing.T) {
 			Failures:     1,
 		},
 	}
-	eventRouter := &eventRouter{}
+	eventRouter := &eventRouter{subscriptions: newSubscriptionIndex()}
 	for name, subscriptionContext := range testCases {
 		t.Run(name, func(t *testing.T) {
-			eventRouter.handleSendSuccessful(subscriptionContext)
+			stack := NewSubscriptionStack(subscriptionContext)
+			eventRouter.handleSendSuccessful(stack, subscriptionContext)
+			assert.Equal(t, uint16(0), stack.Subscription().Failures)
 		})
 	}
 	mockStore.AssertExpectations(t)
 }
 
+type delivery struct {
+	callbackType models.CallbackType
+	statusCode   int
+	failed       bool
+}
+
+// testMetrics records the measurements of the router
+type testMetrics struct {
+	lock             sync.Mutex
+	deliveries       []delivery
+	queueDepths      map[string]int
+	filterExecutions int
+}
+
+func (recorder *testMetrics) EventReceived(models.EventType, string)        {}
+func (recorder *testMetrics) EventQueueDepth(int)                           {}
+func (recorder *testMetrics) RepositoryCalled(string, time.Duration, error) {}
+
+func (recorder *testMetrics) SubscriptionQueueDepth(subscriptionID string, depth int) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.queueDepths[subscriptionID] = depth
+}
+
+func (recorder *testMetrics) SubscriptionRemoved(subscriptionID string) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	delete(recorder.queueDepths, subscriptionID)
+}
+
+func (recorder *testMetrics) DeliveryAttempted(callbackType models.CallbackType, statusCode int, _ time.Duration, err error) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.deliveries = append(recorder.deliveries, delivery{callbackType: callbackType, statusCode: statusCode, failed: err != nil})
+}
+
+func (recorder *testMetrics) FilterExecuted(time.Duration) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.filterExecutions++
+}
+
+func TestRouterMetrics(t *testing.T) {
+	recorder := &testMetrics{queueDepths: make(map[string]int)}
+	previous := metrics.Recorder
+	metrics.Recorder = recorder
+	defer func() { metrics.Recorder = previous }()
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+
+	statusCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+
+	subscription := &models.Subscription{
+		ID:                 "metrics",
+		CallbackURL:        server.URL,
+		CallbackType:       models.HTTP,
+		SubscriptionStatus: models.Active,
+		Filters:            map[models.EventType]models.Filter{models.NodeMessage: {Filtering: `{ factomNodeName }`}},
+	}
+	factomEvent, event := mockFactomEvent(t)
+	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack)}
+
+	// the filter of the subscription is timed
+	_, err := buildPayload(subscription, models.NodeMessage, factomEvent, event)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, recorder.filterExecutions)
+
+	// the status class of the response is recorded
+	assert.Nil(t, eventRouter.deliver(subscription, &QueuedEvent{Payload: event}))
+	statusCode = http.StatusServiceUnavailable
+	assert.NotNil(t, eventRouter.deliver(subscription, &QueuedEvent{Payload: event}))
+	subscription.CallbackURL = "http://localhost:1/unreachable"
+	assert.NotNil(t, eventRouter.deliver(subscription, &QueuedEvent{Payload: event}))
+	assert.Equal(t, []delivery{
+		{callbackType: models.HTTP, statusCode: http.StatusOK},
+		{callbackType: models.HTTP, statusCode: http.StatusServiceUnavailable, failed: true},
+		{callbackType: models.HTTP, failed: true},
+	}, recorder.deliveries)
+
+	// the depth of the queue of the subscription is recorded when events are queued and delivered
+	subscription.SubscriptionStatus = models.Suspended
+	eventRouter.sendEvent(&models.SubscriptionContext{Subscription: *subscription}, &QueuedEvent{Payload: event})
+	eventRouter.sendEvent(&models.SubscriptionContext{Subscription: *subscription}, &QueuedEvent{Payload: event})
+	recorder.lock.Lock()
+	assert.Equal(t, 2, recorder.queueDepths["metrics"])
+	recorder.lock.Unlock()
+
+	// the queue depth of a deleted subscription is removed
+	eventRouter.subscriptions = newSubscriptionIndex()
+	eventRouter.handleChange(models.SubscriptionChange{Type: models.Deleted, SubscriptionID: "metrics"})
+	recorder.lock.Lock()
+	assert.NotContains(t, recorder.queueDepths, "metrics")
+	recorder.lock.Unlock()
+}
+
 func initSubscription(subscriptionID string, port int, failures uint16) *models.SubscriptionContext {
 	return &models.SubscriptionContext{
 		Subscription: models.Subscription{
@@ -491,6 +1019,23 @@ func initSubscription(subscriptionID string, port int, failures uint16) *models.
 	}
 }
 
+// initEntryCommitSubscription initializes a subscription that receives the events of the mocked factom event
+func initEntryCommitSubscription(subscriptionID string, port int) *models.SubscriptionContext {
+	subscriptionContext := initSubscription(subscriptionID, port, 0)
+	subscriptionContext.Subscription.Filters = map[models.EventType]models.Filter{models.EntryCommit: {}}
+	return subscriptionContext
+}
+
+func waitUntil(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func mockFactomEvent(t testing.TB) (*eventmessages.FactomEvent, []byte) {
 	factomEvent := eventmessages.NewPopulatedFactomEvent(randomizer, true)
 	expectedEvent, err := json.Marshal(factomEvent)
@@ -540,12 +1085,31 @@ func startMockTLSServer(t testing.TB, port int, certFile string, pkFile string,
 		w.WriteHeader(http.StatusOK)
 	})
 
+	// listen before serving, such that the server is ready when events are send
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatalf("failed to listen on port %d: %v", port, err)
+	}
+
 	if certFile != "" && pkFile != "" {
-		go http.ListenAndServeTLS(fmt.Sprintf(":%d", port), certFile, pkFile, nil)
+		go http.ServeTLS(listener, nil, certFile, pkFile)
 	} else {
-		go http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
+		go http.Serve(listener, nil)
 	}
+}
 
+func startMockTokenServer(t testing.TB, port int, tokensIssued *int32) {
+	http.HandleFunc(fmt.Sprintf("/token%d", port), func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "client_credentials" {
+			t.Error("invalid token request")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token := atomic.AddInt32(tokensIssued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, token)
+	})
 }
 
 func waitOnEventReceived(eventsReceived *int32, n int, timeLimit time.Duration) {
@@ -654,14 +1218,16 @@ func testTempFile(t *testing.T, prefix string, content string) (string, func())
 func BenchmarkHandleEvents(b *testing.B) {
 	// setup test
 	port := 21221
-	subscriptionContext := initSubscription("id", port, 0)
+	subscriptionContext := initEntryCommitSubscription("id", port)
 	subscriptionContexts := models.SubscriptionContexts{subscriptionContext}
 
 	// init mock repository
 	mockStore := repository.InitMockRepository()
-	mockStore.On("GetActiveSubscriptions", models.EntryCommit).Return(subscriptionContexts, nil)
+	mockStore.On("Watch", mock.Anything).Return(make(chan models.SubscriptionChange), nil).Once()
+	mockStore.On("ListSubscriptions", models.SubscriptionQuery{}).Return(subscriptionContexts, len(subscriptionContexts), nil).Once()
+	mockStore.On("ListSubscriptions", models.SubscriptionQuery{Status: models.Active}).Return(models.SubscriptionContexts{}, 0, nil).Once()
 	mockStore.On("ReadSubscription", "id").Return(subscriptionContext, nil)
-	mockStore.On("UpdateSubscription", "id").Return(subscriptionContext, nil)
+	mockStore.On("UpdateSubscriptionStatus", "id").Return(nil)
 
 	eventsReceived := int32(0)
 	factomEvent, expectedEvent := mockFactomEvent(b)
@@ -0,0 +1,256 @@
+package events
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"math/big"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	maxTemplateSize       = 16 * 1024
+	maxTemplateOutputSize = 64 * 1024
+	maxTemplateDuration   = 1 * time.Second
+)
+
+var (
+	entryCreditAddressPrefix = []byte{0x59, 0x2a}
+	factoidAddressPrefix     = []byte{0x5f, 0xb1}
+)
+
+// templateFunctions are the only functions available in a template next to the text/template builtins
+var templateFunctions = template.FuncMap{
+	"hex":             toHex,
+	"ecAddress":       entryCreditAddress,
+	"factoidAddress":  factoidAddress,
+	"timestamp":       timestamp,
+	"formatTimestamp": formatTimestamp,
+	"json":            toJSON,
+}
+
+// ValidateTemplate parses a template to check if it can be used to render events
+func ValidateTemplate(text string) error {
+	_, err := parseTemplate(text)
+	return err
+}
+
+// the parsed templates of the filters of the subscriptions
+var eventTemplates = newTemplateCache()
+
+// templateCache keeps the parsed template of every filter of a subscription, such that a template is only parsed again
+// when the template of the filter is changed
+type templateCache struct {
+	sync.Mutex
+	templates map[string]map[models.EventType]*cachedTemplate
+}
+
+type cachedTemplate struct {
+	text     string
+	template *template.Template
+}
+
+func newTemplateCache() *templateCache {
+	return &templateCache{
+		templates: make(map[string]map[models.EventType]*cachedTemplate),
+	}
+}
+
+// Template returns the parsed template of the filter of the subscription, the template is parsed when it is new or changed
+func (cache *templateCache) Template(subscriptionID string, eventType models.EventType, text string) (*template.Template, error) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cached, ok := cache.templates[subscriptionID][eventType]; ok && cached.text == text {
+		return cached.template, nil
+	}
+
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := cache.templates[subscriptionID]; !ok {
+		cache.templates[subscriptionID] = make(map[models.EventType]*cachedTemplate)
+	}
+	cache.templates[subscriptionID][eventType] = &cachedTemplate{text: text, template: tmpl}
+	return tmpl, nil
+}
+
+// Remove the templates of a deleted subscription
+func (cache *templateCache) Remove(subscriptionID string) {
+	cache.Lock()
+	defer cache.Unlock()
+	delete(cache.templates, subscriptionID)
+}
+
+// RenderTemplate renders the (filtered) json event with the template. The template can only access the event data and
+// the template functions. The output is limited in size and the rendering is aborted when it takes too long.
+func RenderTemplate(text string, event []byte) ([]byte, error) {
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+	return renderTemplate(tmpl, event)
+}
+
+// renderTemplate renders the (filtered) json event with a parsed template
+func renderTemplate(tmpl *template.Template, event []byte) ([]byte, error) {
+	var data interface{}
+	if err := json.Unmarshal(event, &data); err != nil {
+		return nil, fmt.Errorf("failed to read event for template: %v", err)
+	}
+
+	writer := &limitedWriter{
+		limit:    maxTemplateOutputSize,
+		deadline: time.Now().Add(maxTemplateDuration),
+	}
+	if err := tmpl.Execute(writer, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %v", err)
+	}
+	return writer.buffer.Bytes(), nil
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	if len(text) > maxTemplateSize {
+		return nil, fmt.Errorf("template exceeds the maximum size of %d bytes", maxTemplateSize)
+	}
+	tmpl, err := template.New("event").Option("missingkey=zero").Funcs(templateFunctions).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// limitedWriter aborts the template execution when the output is too large or the deadline is passed
+type limitedWriter struct {
+	buffer   bytes.Buffer
+	limit    int
+	deadline time.Time
+}
+
+func (writer *limitedWriter) Write(p []byte) (int, error) {
+	if writer.buffer.Len()+len(p) > writer.limit {
+		return 0, fmt.Errorf("output exceeds the maximum size of %d bytes", writer.limit)
+	}
+	if time.Now().After(writer.deadline) {
+		return 0, fmt.Errorf("rendering exceeds the maximum duration of %v", maxTemplateDuration)
+	}
+	return writer.buffer.Write(p)
+}
+
+// bytes in the json event are base64 encoded
+func toBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytes '%s': %v", v, err)
+		}
+		return decoded, nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid bytes of type %T", value)
+	}
+}
+
+func toHex(value interface{}) (string, error) {
+	data, err := toBytes(value)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// entryCreditAddress formats an entry credit public key as EC address
+func entryCreditAddress(value interface{}) (string, error) {
+	return humanReadableAddress(entryCreditAddressPrefix, value)
+}
+
+// factoidAddress formats a RCD hash as FA address
+func factoidAddress(value interface{}) (string, error) {
+	return humanReadableAddress(factoidAddressPrefix, value)
+}
+
+func humanReadableAddress(prefix []byte, value interface{}) (string, error) {
+	key, err := toBytes(value)
+	if err != nil {
+		return "", err
+	}
+	if len(key) != 32 {
+		return "", fmt.Errorf("invalid address key length: %d", len(key))
+	}
+
+	address := append(append([]byte{}, prefix...), key...)
+	first := sha256.Sum256(address)
+	checksum := sha256.Sum256(first[:])
+	return base58(append(address, checksum[:4]...)), nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func base58(data []byte) string {
+	x := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for x.Sign() > 0 {
+		x.DivMod(x, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	// reverse
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+// timestamp converts a timestamp of the event into the time
+func timestamp(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		seconds, _ := v["seconds"].(float64)
+		nanos, _ := v["nanos"].(float64)
+		return time.Unix(int64(seconds), int64(nanos)).UTC(), nil
+	case float64:
+		return time.Unix(int64(v), 0).UTC(), nil
+	case nil:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid timestamp of type %T", value)
+	}
+}
+
+// formatTimestamp formats a timestamp of the event with the given layout
+func formatTimestamp(layout string, value interface{}) (string, error) {
+	t, err := timestamp(value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(layout), nil
+}
+
+// toJSON can be used to safely put values in a json template
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
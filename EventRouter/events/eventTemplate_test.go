@@ -0,0 +1,125 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/eventmessages/generated/eventmessages"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	key := make([]byte, 32)
+	event := []byte(`{"key": "` + base64Bytes(key) + `", "hash": "AQID", "timestamp": {"seconds": 1577836800, "nanos": 0}, "text": "quote \" me"}`)
+
+	testCases := map[string]struct {
+		Template string
+		Expected string
+	}{
+		"hex":            {Template: `{{hex .hash}}`, Expected: "010203"},
+		"ec address":     {Template: `{{ecAddress .key}}`, Expected: "EC1m9mouvUQeEidmqpUYpYtXg8fvTYi6GNHaKg8KMLbdMBrFfmUa"},
+		"factoid":        {Template: `{{factoidAddress .key}}`, Expected: "FA1y5ZGuHSLmf2TqNf6hVMkPiNGyQpQDTFJvDLRkKQaoPo4bmbgu"},
+		"timestamp":      {Template: `{{formatTimestamp "2006-01-02" .timestamp}}`, Expected: "2020-01-01"},
+		"json":           {Template: `{"text": {{json .text}}}`, Expected: `{"text": "quote \" me"}`},
+		"missing values": {Template: `{{.unknown}}`, Expected: "<no value>"},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			result, err := RenderTemplate(testCase.Template, event)
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.Expected, string(result))
+		})
+	}
+}
+
+func TestRenderTemplateOutputLimit(t *testing.T) {
+	// render a kilobyte for every item of the event
+	template := fmt.Sprintf(`{{range .}}%s{{end}}`, strings.Repeat("x", 1024))
+	event := fmt.Sprintf("[%s0]", strings.Repeat("0, ", maxTemplateOutputSize/1024))
+
+	_, err := RenderTemplate(template, []byte(event))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "output exceeds the maximum size")
+}
+
+func TestValidateTemplate(t *testing.T) {
+	assert.Nil(t, ValidateTemplate(`{"text": {{json .event}}}`))
+	assert.Error(t, ValidateTemplate(`{{.event`))
+	assert.Error(t, ValidateTemplate(`{{call .event}}{{exec "rm"}}`))
+	assert.Error(t, ValidateTemplate(strings.Repeat("x", maxTemplateSize+1)))
+}
+
+func TestBuildPayloadTemplate(t *testing.T) {
+	factomEvent := &eventmessages.FactomEvent{
+		FactomNodeName: "node",
+		Event: &eventmessages.FactomEvent_NodeMessage{
+			NodeMessage: &eventmessages.NodeMessage{
+				MessageCode: eventmessages.NodeMessageCode_STARTED,
+				MessageText: "node started",
+			},
+		},
+	}
+	event, err := json.Marshal(factomEvent)
+	if err != nil {
+		t.Fatalf("failed to marshal event: %v", err)
+	}
+
+	subscription := &models.Subscription{
+		ID: "template",
+		Filters: map[models.EventType]models.Filter{
+			models.NodeMessage: {
+				Filtering: "{ factomNodeName event { ... on NodeMessage { messageText } } }",
+				Template:  `{"text": "{{.event.factomNodeName}}: {{.event.event.messageText}}"}`,
+			},
+		},
+	}
+
+	// the template renders the filtered event
+	payload, err := buildPayload(subscription, models.NodeMessage, factomEvent, event)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"text": "node: node started"}`, string(payload))
+
+	// without filtering the template renders the complete event
+	subscription.Filters[models.NodeMessage] = models.Filter{Template: `{"text": "{{.factomNodeName}}: {{.Event.NodeMessage.messageText}}"}`}
+	payload, err = buildPayload(subscription, models.NodeMessage, factomEvent, event)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"text": "node: node started"}`, string(payload))
+
+	// the example of the filter model renders the filtered event
+	subscription.Filters[models.NodeMessage] = models.Filter{Filtering: "{ factomNodeName }", Template: `{"text": {{json .event.factomNodeName}}}`}
+	payload, err = buildPayload(subscription, models.NodeMessage, factomEvent, event)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"text": "node"}`, string(payload))
+}
+
+func TestTemplateCache(t *testing.T) {
+	cache := newTemplateCache()
+
+	// the template is parsed once per filter of the subscription
+	first, err := cache.Template("id", models.NodeMessage, `{{.factomNodeName}}`)
+	assert.Nil(t, err)
+	second, err := cache.Template("id", models.NodeMessage, `{{.factomNodeName}}`)
+	assert.Nil(t, err)
+	assert.True(t, first == second)
+
+	// the template is parsed again when the template of the filter is changed
+	changed, err := cache.Template("id", models.NodeMessage, `{{.identityChainID}}`)
+	assert.Nil(t, err)
+	assert.False(t, first == changed)
+
+	_, err = cache.Template("id", models.EntryReveal, `{{.event`)
+	assert.NotNil(t, err)
+
+	// the templates of a deleted subscription are removed
+	cache.Remove("id")
+	assert.Empty(t, cache.templates)
+}
+
+func base64Bytes(data []byte) string {
+	encoded, _ := json.Marshal(data)
+	return strings.Trim(string(encoded), `"`)
+}
@@ -0,0 +1,318 @@
+package events
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/gogo/protobuf/proto"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	// files that are not written to for this duration are closed
+	fileIdleTimeout = 1 * time.Minute
+
+	rotatedFileTimeFormat = "20060102T150405Z"
+)
+
+// fileSinks appends the events of subscriptions with the FILE callback type to files in the file sink directory.
+// Subscriptions that write to the same path share the file, the file is closed when no subscription writes to it.
+type fileSinks struct {
+	sync.Mutex
+	directory string
+	files     map[string]*rotatingFile
+}
+
+// filePathData is the data that can be used in the path of a file sink
+type filePathData struct {
+	EventType   models.EventType
+	BlockHeight uint32
+}
+
+func newFileSinks(directory string) *fileSinks {
+	return &fileSinks{
+		directory: directory,
+		files:     make(map[string]*rotatingFile),
+	}
+}
+
+// ValidateFilePath checks if the path of a file sink is a valid template that stays within the file sink directory
+func ValidateFilePath(path string) error {
+	_, err := resolveFilePath("", path, &QueuedEvent{EventType: models.DirectoryBlockCommit})
+	return err
+}
+
+// Write appends the event to the file of the subscription, the file is rotated when it is too large or too old
+func (sinks *fileSinks) Write(subscription *models.Subscription, event *QueuedEvent) error {
+	if sinks == nil || sinks.directory == "" {
+		return fmt.Errorf("failed to write event to '%s': the file sink directory is not configured", subscription.CallbackURL)
+	}
+
+	path, err := resolveFilePath(sinks.directory, subscription.CallbackURL, event)
+	if err != nil {
+		return err
+	}
+
+	record, err := encodeRecord(subscription.FileSink.Format, event.Payload)
+	if err != nil {
+		return err
+	}
+
+	file := sinks.file(path, subscription.ID)
+	if err := file.Write(record, subscription.FileSink); err != nil {
+		return fmt.Errorf("failed to write event to '%s': %v", path, err)
+	}
+	return nil
+}
+
+// file returns the open file of the path for the subscription and closes the files that are no longer written to
+func (sinks *fileSinks) file(path string, subscriptionID string) *rotatingFile {
+	sinks.Lock()
+	defer sinks.Unlock()
+
+	now := time.Now()
+	for idlePath, idleFile := range sinks.files {
+		if idlePath != path && idleFile.IdleSince(now) > fileIdleTimeout {
+			idleFile.Close()
+			delete(sinks.files, idlePath)
+		}
+	}
+
+	file, ok := sinks.files[path]
+	if !ok {
+		file = &rotatingFile{path: path, subscriptions: make(map[string]struct{})}
+		sinks.files[path] = file
+	}
+	file.subscriptions[subscriptionID] = struct{}{}
+	return file
+}
+
+// Remove closes the files of the deleted subscription, the files that other subscriptions write to stay open
+func (sinks *fileSinks) Remove(subscriptionID string) {
+	if sinks == nil {
+		return
+	}
+	sinks.Lock()
+	defer sinks.Unlock()
+
+	for path, file := range sinks.files {
+		delete(file.subscriptions, subscriptionID)
+		if len(file.subscriptions) == 0 {
+			file.Close()
+			delete(sinks.files, path)
+		}
+	}
+}
+
+// Close closes all files, a next write opens the file again
+func (sinks *fileSinks) Close() {
+	if sinks == nil {
+		return
+	}
+	sinks.Lock()
+	defer sinks.Unlock()
+
+	for path, file := range sinks.files {
+		file.Close()
+		delete(sinks.files, path)
+	}
+}
+
+func resolveFilePath(directory string, pathTemplate string, event *QueuedEvent) (string, error) {
+	tmpl, err := template.New("path").Parse(pathTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid file path: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, filePathData{EventType: event.EventType, BlockHeight: event.BlockHeight}); err != nil {
+		return "", fmt.Errorf("invalid file path: %v", err)
+	}
+
+	path := filepath.Clean(buffer.String())
+	if filepath.IsAbs(path) || path == "." || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid file path '%s': the path should be relative within the file sink directory", buffer.String())
+	}
+	return filepath.Join(directory, path), nil
+}
+
+// encodeRecord encodes the event as json line or as length-delimited protobuf
+func encodeRecord(format models.FileFormat, payload []byte) ([]byte, error) {
+	switch format {
+	case models.Protobuf:
+		return append(proto.EncodeVarint(uint64(len(payload))), payload...), nil
+	case models.JSONLines, "":
+		var buffer bytes.Buffer
+		if err := json.Compact(&buffer, payload); err != nil {
+			// the template of the filter doesn't have to render json
+			buffer.Reset()
+			buffer.Write(bytes.TrimRight(payload, "\r\n"))
+		}
+		buffer.WriteByte('\n')
+		return buffer.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown file format: %s", format)
+	}
+}
+
+// rotatingFile appends records to a file. On rotation the file is renamed with the time of rotation and optionally gzipped.
+type rotatingFile struct {
+	sync.Mutex
+	path      string
+	file      *os.File
+	size      int64
+	opened    time.Time
+	lastWrite time.Time
+
+	// the subscriptions that write to the file, guarded by the lock of the file sinks
+	subscriptions map[string]struct{}
+}
+
+// Write the record to the file
+func (file *rotatingFile) Write(record []byte, settings models.FileSink) error {
+	file.Lock()
+	defer file.Unlock()
+
+	now := time.Now()
+	if file.file != nil && file.shouldRotate(now, len(record), settings) {
+		if err := file.rotate(now, settings.Compress); err != nil {
+			return err
+		}
+	}
+
+	if file.file == nil {
+		if err := file.open(now); err != nil {
+			return err
+		}
+	}
+
+	n, err := file.file.Write(record)
+	file.size += int64(n)
+	file.lastWrite = now
+	return err
+}
+
+// IdleSince returns the duration since the last write
+func (file *rotatingFile) IdleSince(now time.Time) time.Duration {
+	file.Lock()
+	defer file.Unlock()
+	return now.Sub(file.lastWrite)
+}
+
+// Close the file, a next write will open the file again
+func (file *rotatingFile) Close() {
+	file.Lock()
+	defer file.Unlock()
+	if file.file != nil {
+		if err := file.file.Close(); err != nil {
+			log.Error("failed to close '%s': %v", file.path, err)
+		}
+		file.file = nil
+	}
+}
+
+func (file *rotatingFile) shouldRotate(now time.Time, recordSize int, settings models.FileSink) bool {
+	if settings.MaxSize > 0 && file.size > 0 && file.size+int64(recordSize) > settings.MaxSize {
+		return true
+	}
+	if settings.MaxAge > 0 && now.Sub(file.opened) >= time.Duration(settings.MaxAge)*time.Second {
+		return true
+	}
+	return false
+}
+
+func (file *rotatingFile) open(now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	file.file = f
+	file.size = info.Size()
+	file.opened = now
+	return nil
+}
+
+func (file *rotatingFile) rotate(now time.Time, compress bool) error {
+	if err := file.file.Close(); err != nil {
+		return err
+	}
+	file.file = nil
+
+	rotatedPath := rotatedFilePath(file.path, now, compress)
+	if err := os.Rename(file.path, rotatedPath); err != nil {
+		return err
+	}
+	log.Debug("rotated '%s' to '%s'", file.path, rotatedPath)
+
+	if compress {
+		if err := compressFile(rotatedPath); err != nil {
+			return fmt.Errorf("failed to compress '%s': %v", rotatedPath, err)
+		}
+	}
+	return nil
+}
+
+// rotatedFilePath adds the time of rotation to the file name, e.g. events.jsonl is rotated to events-20191010T101010Z.jsonl
+func rotatedFilePath(path string, now time.Time, compress bool) string {
+	extension := filepath.Ext(path)
+	base := strings.TrimSuffix(path, extension) + "-" + now.UTC().Format(rotatedFileTimeFormat)
+
+	rotatedPath := base + extension
+	for i := 1; exists(rotatedPath) || (compress && exists(rotatedPath+".gz")); i++ {
+		rotatedPath = fmt.Sprintf("%s-%d%s", base, i, extension)
+	}
+	return rotatedPath
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// compressFile gzips the file to a .gz file and removes the original file
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(target)
+	if _, err := io.Copy(writer, source); err != nil {
+		_ = target.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		_ = target.Close()
+		return err
+	}
+	if err := target.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
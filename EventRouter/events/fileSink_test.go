@@ -0,0 +1,302 @@
+package events
+
+import (
+	"bufio"
+	"compress/gzip"
+	"github.com/FactomProject/live-feed-api/EventRouter/eventmessages/generated/eventmessages"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveFilePath(t *testing.T) {
+	testCases := map[string]struct {
+		Path     string
+		Expected string
+		Error    string
+	}{
+		"static": {
+			Path:     "events.jsonl",
+			Expected: "/archive/events.jsonl",
+		},
+		"templated": {
+			Path:     "{{.EventType}}/{{.BlockHeight}}.jsonl",
+			Expected: "/archive/ENTRY_REVEAL/42.jsonl",
+		},
+		"relative within directory": {
+			Path:     "a/../b/events.jsonl",
+			Expected: "/archive/b/events.jsonl",
+		},
+		"outside directory": {
+			Path:  "../events.jsonl",
+			Error: "invalid file path '../events.jsonl': the path should be relative within the file sink directory",
+		},
+		"absolute": {
+			Path:  "/tmp/events.jsonl",
+			Error: "invalid file path '/tmp/events.jsonl': the path should be relative within the file sink directory",
+		},
+		"unknown field": {
+			Path:  "{{.Unknown}}",
+			Error: `invalid file path: template: path:1:2: executing "path" at <.Unknown>: can't evaluate field Unknown in type events.filePathData`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path, err := resolveFilePath("/archive", testCase.Path, &QueuedEvent{EventType: models.EntryReveal, BlockHeight: 42})
+			if testCase.Error != "" {
+				assert.EqualError(t, err, testCase.Error)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.Expected, path)
+		})
+	}
+}
+
+func TestEncodeRecord(t *testing.T) {
+	record, err := encodeRecord(models.JSONLines, []byte("{\n  \"a\": 1\n}"))
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"a\":1}\n", string(record))
+
+	record, err = encodeRecord(models.JSONLines, []byte("rendered text\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "rendered text\n", string(record))
+
+	record, err = encodeRecord(models.Protobuf, []byte("protobuf"))
+	assert.Nil(t, err)
+	assert.Equal(t, append([]byte{8}, []byte("protobuf")...), record)
+
+	_, err = encodeRecord("CSV", []byte("protobuf"))
+	assert.EqualError(t, err, "unknown file format: CSV")
+}
+
+func TestFileSinkWrite(t *testing.T) {
+	directory, cleanup := createTempDirectory(t)
+	defer cleanup()
+
+	sinks := newFileSinks(directory)
+	subscription := &models.Subscription{
+		ID:           "id",
+		CallbackURL:  "{{.EventType}}/{{.BlockHeight}}.jsonl",
+		CallbackType: models.File,
+	}
+
+	for _, event := range []string{`{"n":1}`, `{"n":2}`} {
+		err := sinks.Write(subscription, &QueuedEvent{EventType: models.EntryCommit, BlockHeight: 10, Payload: []byte(event)})
+		assert.Nil(t, err)
+	}
+	err := sinks.Write(subscription, &QueuedEvent{EventType: models.EntryCommit, BlockHeight: 11, Payload: []byte(`{"n":3}`)})
+	assert.Nil(t, err)
+
+	assert.Equal(t, "{\"n\":1}\n{\"n\":2}\n", readFile(t, filepath.Join(directory, "ENTRY_COMMIT", "10.jsonl")))
+	assert.Equal(t, "{\"n\":3}\n", readFile(t, filepath.Join(directory, "ENTRY_COMMIT", "11.jsonl")))
+}
+
+func TestFileSinkWriteNotConfigured(t *testing.T) {
+	sinks := newFileSinks("")
+	subscription := &models.Subscription{CallbackURL: "events.jsonl", CallbackType: models.File}
+
+	err := sinks.Write(subscription, &QueuedEvent{EventType: models.EntryCommit, Payload: []byte(`{}`)})
+
+	assert.EqualError(t, err, "failed to write event to 'events.jsonl': the file sink directory is not configured")
+}
+
+func TestFileSinkRotateCompressed(t *testing.T) {
+	directory, cleanup := createTempDirectory(t)
+	defer cleanup()
+
+	sinks := newFileSinks(directory)
+	subscription := &models.Subscription{
+		ID:           "id",
+		CallbackURL:  "events.jsonl",
+		CallbackType: models.File,
+		FileSink:     models.FileSink{MaxSize: 10, Compress: true},
+	}
+
+	// every event fills the file, such that the file is rotated before the next event is written
+	for _, event := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		err := sinks.Write(subscription, &QueuedEvent{EventType: models.EntryCommit, Payload: []byte(event)})
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, "{\"n\":3}\n", readFile(t, filepath.Join(directory, "events.jsonl")))
+
+	rotated, err := filepath.Glob(filepath.Join(directory, "events-*.jsonl.gz"))
+	assert.Nil(t, err)
+	if !assert.Len(t, rotated, 2) {
+		return
+	}
+	// the rotated files can have the same timestamp, which makes the order of the file names unreliable
+	assert.ElementsMatch(t, []string{"{\"n\":1}\n", "{\"n\":2}\n"}, []string{readGzipFile(t, rotated[0]), readGzipFile(t, rotated[1])})
+}
+
+func TestFileSinkRemove(t *testing.T) {
+	directory, cleanup := createTempDirectory(t)
+	defer cleanup()
+
+	sinks := newFileSinks(directory)
+	shared := &models.Subscription{ID: "shared", CallbackURL: "events.jsonl", CallbackType: models.File}
+	deleted := &models.Subscription{ID: "deleted", CallbackURL: "{{.EventType}}.jsonl", CallbackType: models.File}
+	for _, subscription := range []*models.Subscription{shared, deleted} {
+		assert.Nil(t, sinks.Write(subscription, &QueuedEvent{EventType: models.EntryCommit, Payload: []byte(`{}`)}))
+	}
+	assert.Nil(t, sinks.Write(deleted, &QueuedEvent{EventType: models.EntryCommit, Payload: []byte(`{}`)}))
+	deleted.CallbackURL = "events.jsonl"
+	assert.Nil(t, sinks.Write(deleted, &QueuedEvent{EventType: models.EntryCommit, Payload: []byte(`{}`)}))
+
+	// the file of the deleted subscription is closed, the shared file stays open
+	sinks.Remove(deleted.ID)
+	assert.Len(t, sinks.files, 1)
+	if assert.Contains(t, sinks.files, filepath.Join(directory, "events.jsonl")) {
+		assert.NotNil(t, sinks.files[filepath.Join(directory, "events.jsonl")].file)
+	}
+
+	sinks.Remove(shared.ID)
+	assert.Empty(t, sinks.files)
+
+	// closed files are opened again by the next write
+	assert.Nil(t, sinks.Write(shared, &QueuedEvent{EventType: models.EntryCommit, Payload: []byte(`{}`)}))
+	sinks.Close()
+	assert.Empty(t, sinks.files)
+	assert.Equal(t, "{}\n{}\n{}\n", readFile(t, filepath.Join(directory, "events.jsonl")))
+}
+
+func TestDeliverFileFiltered(t *testing.T) {
+	directory, cleanup := createTempDirectory(t)
+	defer cleanup()
+
+	subscription := &models.Subscription{
+		ID:           "id",
+		CallbackURL:  "events.jsonl",
+		CallbackType: models.File,
+		Filters:      map[models.EventType]models.Filter{models.EntryCommit: {Filtering: "{ factomNodeName }"}},
+	}
+	factomEvent, event := mockFactomEvent(t)
+	factomEvent.FactomNodeName = "node"
+
+	payload, err := buildPayload(subscription, models.EntryCommit, factomEvent, event)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack), fileSinks: newFileSinks(directory)}
+	err = eventRouter.deliver(subscription, &QueuedEvent{EventType: models.EntryCommit, Payload: payload})
+	assert.Nil(t, err)
+
+	// the json lines contain the filtered event
+	assert.Equal(t, "{\"event\":{\"factomNodeName\":\"node\"}}\n", readFile(t, filepath.Join(directory, "events.jsonl")))
+}
+
+func TestDeliverFileProtobuf(t *testing.T) {
+	directory, cleanup := createTempDirectory(t)
+	defer cleanup()
+
+	subscription := &models.Subscription{
+		ID:           "id",
+		CallbackURL:  "events.pb",
+		CallbackType: models.File,
+		FileSink:     models.FileSink{Format: models.Protobuf},
+	}
+	factomEvent, event := mockFactomEvent(t)
+
+	payload, err := buildPayload(subscription, models.EntryCommit, factomEvent, event)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack), fileSinks: newFileSinks(directory)}
+	err = eventRouter.deliver(subscription, &QueuedEvent{EventType: models.EntryCommit, Payload: payload})
+	assert.Nil(t, err)
+
+	// read the length-delimited event from the file
+	file, err := os.Open(filepath.Join(directory, "events.pb"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(bufio.NewReader(file))
+	assert.Nil(t, err)
+
+	size, n := proto.DecodeVarint(data)
+	assert.Equal(t, len(data)-n, int(size))
+
+	writtenEvent := &eventmessages.FactomEvent{}
+	err = proto.Unmarshal(data[n:], writtenEvent)
+	assert.Nil(t, err)
+	assert.Equal(t, factomEvent.GetEntryCommit(), writtenEvent.GetEntryCommit())
+}
+
+func TestEventBlockHeight(t *testing.T) {
+	testCases := map[string]struct {
+		Event       *eventmessages.FactomEvent
+		BlockHeight uint32
+		Found       bool
+	}{
+		"state change": {
+			Event:       &eventmessages.FactomEvent{Event: &eventmessages.FactomEvent_StateChange{StateChange: &eventmessages.StateChange{BlockHeight: 12}}},
+			BlockHeight: 12,
+			Found:       true,
+		},
+		"new block": {
+			Event:       &eventmessages.FactomEvent{Event: &eventmessages.FactomEvent_ProcessListEvent{ProcessListEvent: &eventmessages.ProcessListEvent{ProcessListEvent: &eventmessages.ProcessListEvent_NewBlockEvent{NewBlockEvent: &eventmessages.NewBlockEvent{NewBlockHeight: 13}}}}},
+			BlockHeight: 13,
+			Found:       true,
+		},
+		"directory block commit": {
+			Event:       &eventmessages.FactomEvent{Event: &eventmessages.FactomEvent_DirectoryBlockCommit{DirectoryBlockCommit: &eventmessages.DirectoryBlockCommit{DirectoryBlock: &eventmessages.DirectoryBlock{Header: &eventmessages.DirectoryBlockHeader{BlockHeight: 14}}}}},
+			BlockHeight: 14,
+			Found:       true,
+		},
+		"entry reveal": {
+			Event: &eventmessages.FactomEvent{Event: &eventmessages.FactomEvent_EntryReveal{EntryReveal: &eventmessages.EntryReveal{}}},
+			Found: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			blockHeight, found := eventBlockHeight(testCase.Event)
+			assert.Equal(t, testCase.BlockHeight, blockHeight)
+			assert.Equal(t, testCase.Found, found)
+		})
+	}
+}
+
+func createTempDirectory(t *testing.T) (string, func()) {
+	directory, err := ioutil.TempDir("", "file-sink")
+	if err != nil {
+		t.Fatalf("failed to create temp directory: %v", err)
+	}
+	return directory, func() { _ = os.RemoveAll(directory) }
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read '%s': %v", path, err)
+	}
+	return string(data)
+}
+
+func readGzipFile(t *testing.T, path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open '%s': %v", path, err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("failed to read '%s': %v", path, err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read '%s': %v", path, err)
+	}
+	return string(data)
+}
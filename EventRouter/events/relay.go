@@ -0,0 +1,178 @@
+package events
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	relayDialTimeout  = 10 * time.Second
+	relayWriteTimeout = 10 * time.Second
+)
+
+// the connections that are used to relay events to subscriptions with the RELAY callback type
+var relayConnections = newRelays()
+
+// relays keeps a connection open for every subscription, such that the events are relayed as one continuous stream
+type relays struct {
+	sync.Mutex
+	connections map[string]*relayConnection
+}
+
+// relayConnection is a tcp connection to a receiver that reads the factomd event stream. A broken connection is closed
+// and a new connection is made with the next event.
+type relayConnection struct {
+	sync.Mutex
+	address string
+	conn    net.Conn
+}
+
+func newRelays() *relays {
+	return &relays{
+		connections: make(map[string]*relayConnection),
+	}
+}
+
+// RelayAddress returns the tcp address of the relay callback url: tcp://host:port
+func RelayAddress(callbackURL string) (string, error) {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "tcp" || u.Hostname() == "" || u.Port() == "" || (u.Path != "" && u.Path != "/") {
+		return "", fmt.Errorf("relay url '%s' should be tcp://host:port", callbackURL)
+	}
+	return u.Host, nil
+}
+
+// Send the protobuf event to the relay of the subscription
+func (relays *relays) Send(subscription *models.Subscription, event []byte) error {
+	address, err := RelayAddress(subscription.CallbackURL)
+	if err != nil {
+		return fmt.Errorf("failed to relay event: %v", err)
+	}
+
+	return relays.connection(subscription.ID, address).Send(event)
+}
+
+func (relays *relays) connection(subscriptionID string, address string) *relayConnection {
+	relays.Lock()
+	defer relays.Unlock()
+
+	connection, ok := relays.connections[subscriptionID]
+	if !ok || connection.address != address {
+		// the callback url of the subscription has been changed
+		if ok {
+			connection.Close()
+		}
+		connection = &relayConnection{address: address}
+		relays.connections[subscriptionID] = connection
+	}
+	return connection
+}
+
+// Update closes the connection of the subscription when the subscription doesn't relay the events anymore or the
+// callback url is changed
+func (relays *relays) Update(subscription *models.Subscription) {
+	relays.Lock()
+	defer relays.Unlock()
+
+	connection, ok := relays.connections[subscription.ID]
+	if !ok {
+		return
+	}
+	address, err := RelayAddress(subscription.CallbackURL)
+	if subscription.CallbackType != models.Relay || err != nil || connection.address != address {
+		connection.Close()
+		delete(relays.connections, subscription.ID)
+	}
+}
+
+// Remove closes the connection of a deleted subscription
+func (relays *relays) Remove(subscriptionID string) {
+	relays.Lock()
+	defer relays.Unlock()
+
+	if connection, ok := relays.connections[subscriptionID]; ok {
+		connection.Close()
+		delete(relays.connections, subscriptionID)
+	}
+}
+
+// Send the event in the same framing as the factomd event stream: the protocol version, the size of the event as
+// little-endian int32 and the protobuf event
+func (connection *relayConnection) Send(event []byte) error {
+	connection.Lock()
+	defer connection.Unlock()
+
+	if connection.conn == nil {
+		if err := connection.connect(); err != nil {
+			return err
+		}
+	}
+
+	frame := make([]byte, 5+len(event))
+	frame[0] = supportedProtocolVersion
+	binary.LittleEndian.PutUint32(frame[1:5], uint32(len(event)))
+	copy(frame[5:], event)
+
+	if err := connection.conn.SetWriteDeadline(time.Now().Add(relayWriteTimeout)); err != nil {
+		connection.disconnect()
+		return fmt.Errorf("failed to relay event to '%s': %v", connection.address, err)
+	}
+	if _, err := connection.conn.Write(frame); err != nil {
+		connection.disconnect()
+		return fmt.Errorf("failed to relay event to '%s': %v", connection.address, err)
+	}
+	return nil
+}
+
+// Close the connection
+func (connection *relayConnection) Close() {
+	connection.Lock()
+	defer connection.Unlock()
+	connection.disconnect()
+}
+
+func (connection *relayConnection) connect() error {
+	conn, err := net.DialTimeout("tcp", connection.address, relayDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to relay '%s': %v", connection.address, err)
+	}
+	log.Info("connected to relay '%s'", connection.address)
+	connection.conn = conn
+
+	go connection.watch(conn)
+	return nil
+}
+
+func (connection *relayConnection) disconnect() {
+	if connection.conn != nil {
+		_ = connection.conn.Close()
+		connection.conn = nil
+	}
+}
+
+// watch the connection for being closed by the receiver, the receiver never writes to the connection. Detecting the
+// close prevents that the next event is written to a connection that is already closed.
+func (connection *relayConnection) watch(conn net.Conn) {
+	_, err := io.Copy(ioutil.Discard, conn)
+	if err == nil {
+		err = io.EOF
+	}
+
+	connection.Lock()
+	defer connection.Unlock()
+	if connection.conn == conn {
+		log.Warn("relay connection to '%s' closed: %v", connection.address, err)
+		connection.disconnect()
+	}
+}
@@ -0,0 +1,229 @@
+package events
+
+import (
+	"github.com/FactomProject/live-feed-api/EventRouter/eventmessages/generated/eventmessages"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRelayAddress(t *testing.T) {
+	testCases := map[string]struct {
+		URL     string
+		Address string
+		Error   string
+	}{
+		"valid": {
+			URL:     "tcp://live-feed:8040",
+			Address: "live-feed:8040",
+		},
+		"ip": {
+			URL:     "tcp://127.0.0.1:8040/",
+			Address: "127.0.0.1:8040",
+		},
+		"http": {
+			URL:   "http://live-feed:8040",
+			Error: "relay url 'http://live-feed:8040' should be tcp://host:port",
+		},
+		"no port": {
+			URL:   "tcp://live-feed",
+			Error: "relay url 'tcp://live-feed' should be tcp://host:port",
+		},
+		"path": {
+			URL:   "tcp://live-feed:8040/events",
+			Error: "relay url 'tcp://live-feed:8040/events' should be tcp://host:port",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			address, err := RelayAddress(testCase.URL)
+			if testCase.Error != "" {
+				assert.EqualError(t, err, testCase.Error)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.Address, address)
+		})
+	}
+}
+
+func TestRelayEvents(t *testing.T) {
+	listener, queue := startRelayReceiver(t)
+	defer listener.Close()
+
+	subscription := &models.Subscription{
+		ID:           "relay",
+		CallbackURL:  "tcp://" + listener.Addr().String(),
+		CallbackType: models.Relay,
+	}
+
+	n := 3
+	factomEvent, event := mockFactomEvent(t)
+	eventRouter := &eventRouter{emitQueue: make(map[string]SubscriptionStack)}
+	for i := 0; i < n; i++ {
+		payload, err := buildPayload(subscription, models.EntryCommit, factomEvent, event)
+		if !assert.Nil(t, err) {
+			return
+		}
+		err = eventRouter.deliver(subscription, &QueuedEvent{EventType: models.EntryCommit, Payload: payload})
+		assert.Nil(t, err)
+	}
+
+	// the events are relayed over the same connection and parsed by the receiver
+	for i := 0; i < n; i++ {
+		select {
+		case relayedEvent := <-queue:
+			assert.Equal(t, factomEvent.GetEntryCommit(), relayedEvent.GetEntryCommit())
+		case <-time.After(10 * time.Second):
+			t.Fatalf("relayed event %d not received", i)
+		}
+	}
+}
+
+func TestRelayReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	// the receiver closes the first connection and reads the events from the second connection
+	queue := make(chan *eventmessages.FactomEvent, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.Close()
+
+		conn, err = listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		receiver := &receiver{eventQueue: queue}
+		_ = receiver.readEvents(conn)
+	}()
+
+	subscription := &models.Subscription{
+		ID:           "relay-reconnect",
+		CallbackURL:  "tcp://" + listener.Addr().String(),
+		CallbackType: models.Relay,
+	}
+	relays := newRelays()
+	factomEvent, _ := mockFactomEvent(t)
+	payload, err := buildPayload(subscription, models.EntryCommit, factomEvent, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// retry like the event router until the event is relayed over a new connection
+	deadline := time.Now().Add(10 * time.Second)
+	received := false
+	for !received && time.Now().Before(deadline) {
+		if err := relays.Send(subscription, payload); err != nil {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		select {
+		case relayedEvent := <-queue:
+			assert.Equal(t, factomEvent.GetEntryCommit(), relayedEvent.GetEntryCommit())
+			received = true
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	assert.True(t, received, "event is not relayed after reconnect")
+}
+
+func TestRelayClose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	// the receiver reports when the relay closes the connection
+	closed := make(chan struct{}, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(ioutil.Discard, conn)
+				closed <- struct{}{}
+			}()
+		}
+	}()
+	waitClosed := func() {
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("relay connection is not closed")
+		}
+	}
+
+	subscription := &models.Subscription{ID: "relay-close", CallbackURL: "tcp://" + listener.Addr().String(), CallbackType: models.Relay}
+	relays := newRelays()
+	assert.Nil(t, relays.Send(subscription, []byte("event")))
+
+	// an unchanged subscription keeps the connection
+	relays.Update(subscription)
+	assert.Len(t, relays.connections, 1)
+
+	// the connection is closed when the subscription doesn't relay the events anymore
+	changed := *subscription
+	changed.CallbackType = models.HTTP
+	relays.Update(&changed)
+	assert.Empty(t, relays.connections)
+	waitClosed()
+
+	// the connection of a deleted subscription is closed
+	assert.Nil(t, relays.Send(subscription, []byte("event")))
+	relays.Remove(subscription.ID)
+	assert.Empty(t, relays.connections)
+	waitClosed()
+}
+
+func TestRelayConnectionFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	subscription := &models.Subscription{ID: "relay-failure", CallbackURL: "tcp://" + address, CallbackType: models.Relay}
+
+	err = newRelays().Send(subscription, []byte("event"))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to connect to relay '"+address+"'")
+}
+
+// startRelayReceiver starts a receiver that parses the relayed event stream
+func startRelayReceiver(t *testing.T) (net.Listener, chan *eventmessages.FactomEvent) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	queue := make(chan *eventmessages.FactomEvent, 10)
+	receiver := &receiver{eventQueue: queue}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go receiver.handleConnection(conn)
+		}
+	}()
+	return listener, queue
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"sync"
+)
+
+// subscriptionIndex caches the subscriptions that receive events by event type, such that the repository is not queried
+// for every event. The index is updated when a subscription changes and resynchronized periodically. The repository is
+// queried as long as the index is not loaded. The index stores and returns copies of the subscriptions, such that the
+// router can change the returned subscriptions without the lock of the index.
+type subscriptionIndex struct {
+	sync.RWMutex
+	loaded      bool
+	byEventType map[models.EventType]models.SubscriptionContexts
+}
+
+func newSubscriptionIndex() *subscriptionIndex {
+	return &subscriptionIndex{byEventType: make(map[models.EventType]models.SubscriptionContexts)}
+}
+
+// activeSubscriptions returns copies of the subscriptions that receive the events of the type
+func (index *subscriptionIndex) activeSubscriptions(eventType models.EventType) (models.SubscriptionContexts, error) {
+	index.RLock()
+	if index.loaded {
+		indexed := index.byEventType[eventType]
+		subscriptionContexts := make(models.SubscriptionContexts, 0, len(indexed))
+		for _, subscriptionContext := range indexed {
+			subscriptionContexts = append(subscriptionContexts, copySubscriptionContext(subscriptionContext))
+		}
+		index.RUnlock()
+		return subscriptionContexts, nil
+	}
+	index.RUnlock()
+	return repository.SubscriptionRepository.GetActiveSubscriptions(context.Background(), eventType)
+}
+
+// resync loads all subscriptions that receive events from the repository
+func (index *subscriptionIndex) resync() error {
+	subscriptionContexts, _, err := repository.SubscriptionRepository.ListSubscriptions(context.Background(), &models.SubscriptionQuery{})
+	if err != nil {
+		return fmt.Errorf("failed to load the subscription index: %v", err)
+	}
+
+	byEventType := make(map[models.EventType]models.SubscriptionContexts)
+	for _, subscriptionContext := range subscriptionContexts {
+		if receivesEvents(subscriptionContext) {
+			indexed := copySubscriptionContext(subscriptionContext)
+			for eventType := range subscriptionContext.Subscription.Filters {
+				byEventType[eventType] = append(byEventType[eventType], indexed)
+			}
+		}
+	}
+
+	index.Lock()
+	defer index.Unlock()
+	index.byEventType = byEventType
+	index.loaded = true
+	log.Debug("loaded %d subscriptions in the subscription index", len(subscriptionContexts))
+	return nil
+}
+
+// update the subscription in the index after the subscription is created, updated or deleted, the subscription context
+// is nil when the subscription is deleted
+func (index *subscriptionIndex) update(subscriptionID string, subscriptionContext *models.SubscriptionContext) {
+	index.Lock()
+	defer index.Unlock()
+	index.replace(subscriptionID, subscriptionContext)
+}
+
+// updateStatus applies the failures and the status that the router wrote to an indexed subscription, a subscription
+// that is not indexed is not added, because it may be deleted in the mean time
+func (index *subscriptionIndex) updateStatus(subscriptionContext *models.SubscriptionContext) {
+	index.Lock()
+	defer index.Unlock()
+	for _, subscriptionContexts := range index.byEventType {
+		for _, indexed := range subscriptionContexts {
+			if indexed.Subscription.ID == subscriptionContext.Subscription.ID {
+				index.replace(subscriptionContext.Subscription.ID, subscriptionContext)
+				return
+			}
+		}
+	}
+}
+
+// replace the subscription in the index with a copy of the subscription, the caller holds the lock of the index
+func (index *subscriptionIndex) replace(subscriptionID string, subscriptionContext *models.SubscriptionContext) {
+	// the index may be reloaded with a newer version already
+	if subscriptionContext != nil {
+		for _, subscriptionContexts := range index.byEventType {
+			for _, indexed := range subscriptionContexts {
+				if indexed.Subscription.ID == subscriptionID && indexed.Version > subscriptionContext.Version {
+					return
+				}
+			}
+		}
+	}
+
+	// the lists are replaced instead of modified, because the lists are used while the events are routed
+	for eventType, subscriptionContexts := range index.byEventType {
+		filtered := make(models.SubscriptionContexts, 0, len(subscriptionContexts))
+		for _, indexed := range subscriptionContexts {
+			if indexed.Subscription.ID != subscriptionID {
+				filtered = append(filtered, indexed)
+			}
+		}
+		index.byEventType[eventType] = filtered
+	}
+
+	if subscriptionContext != nil && receivesEvents(subscriptionContext) {
+		indexed := copySubscriptionContext(subscriptionContext)
+		for eventType := range subscriptionContext.Subscription.Filters {
+			index.byEventType[eventType] = append(index.byEventType[eventType], indexed)
+		}
+	}
+}
+
+// the copy shares the filters, headers and labels with the subscription, those are replaced instead of modified
+func copySubscriptionContext(subscriptionContext *models.SubscriptionContext) *models.SubscriptionContext {
+	copied := *subscriptionContext
+	return &copied
+}
+
+// the active subscriptions receive events, the events of paused subscriptions are buffered
+func receivesEvents(subscriptionContext *models.SubscriptionContext) bool {
+	status := subscriptionContext.Subscription.SubscriptionStatus
+	return status == models.Active || status == models.Paused
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/repository"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSubscriptionIndexResync(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+	active := createIndexedSubscription(t, models.Active, models.ChainCommit, models.EntryCommit)
+	paused := createIndexedSubscription(t, models.Paused, models.ChainCommit)
+	createIndexedSubscription(t, models.Suspended, models.ChainCommit)
+
+	index := newSubscriptionIndex()
+	assert.Nil(t, index.resync())
+
+	assertIndexed(t, index, models.ChainCommit, active, paused)
+	assertIndexed(t, index, models.EntryCommit, active)
+	assertIndexed(t, index, models.NodeMessage)
+}
+
+func TestSubscriptionIndexResyncFailure(t *testing.T) {
+	mockStore := repository.InitMockRepository()
+	mockStore.On("ListSubscriptions", models.SubscriptionQuery{}).Return(models.SubscriptionContexts{}, 0, fmt.Errorf("connection lost")).Once()
+	mockStore.On("GetActiveSubscriptions", models.ChainCommit).Return(models.SubscriptionContexts{}, nil).Once()
+
+	// the repository is queried as long as the index is not loaded
+	index := newSubscriptionIndex()
+	assert.EqualError(t, index.resync(), "failed to load the subscription index: connection lost")
+	subscriptionContexts, err := index.activeSubscriptions(models.ChainCommit)
+
+	assert.Nil(t, err)
+	assert.Empty(t, subscriptionContexts)
+	mockStore.AssertExpectations(t)
+}
+
+func TestSubscriptionIndexUpdate(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+	index := newSubscriptionIndex()
+	assert.Nil(t, index.resync())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := repository.SubscriptionRepository.Watch(ctx)
+	assert.Nil(t, err)
+	applyChange := func() {
+		change := <-changes
+		index.update(change.SubscriptionID, change.SubscriptionContext)
+	}
+
+	// created subscription
+	subscriptionContext := createIndexedSubscription(t, models.Active, models.ChainCommit)
+	applyChange()
+	assertIndexed(t, index, models.ChainCommit, subscriptionContext)
+
+	// changed event types
+	updated := *subscriptionContext
+	updated.Subscription.Filters = map[models.EventType]models.Filter{models.EntryCommit: {}}
+	_, err = repository.SubscriptionRepository.UpdateSubscription(context.Background(), &updated)
+	assert.Nil(t, err)
+	applyChange()
+	assertIndexed(t, index, models.ChainCommit)
+	assertIndexed(t, index, models.EntryCommit, subscriptionContext)
+
+	// suspended subscription
+	suspended := updated
+	suspended.Subscription.SubscriptionStatus = models.Suspended
+	assert.Nil(t, repository.SubscriptionRepository.UpdateSubscriptionStatus(context.Background(), &suspended))
+	applyChange()
+	assertIndexed(t, index, models.EntryCommit)
+
+	// deleted subscription
+	reactivated := suspended
+	reactivated.Subscription.SubscriptionStatus = models.Active
+	assert.Nil(t, repository.SubscriptionRepository.UpdateSubscriptionStatus(context.Background(), &reactivated))
+	applyChange()
+	assertIndexed(t, index, models.EntryCommit, subscriptionContext)
+	assert.Nil(t, repository.SubscriptionRepository.DeleteSubscription(context.Background(), subscriptionContext.Subscription.ID))
+	applyChange()
+	assertIndexed(t, index, models.EntryCommit)
+}
+
+func TestSubscriptionIndexUpdateOlderVersion(t *testing.T) {
+	repository.SubscriptionRepository = repository.NewInMemoryRepository()
+	subscriptionContext := createIndexedSubscription(t, models.Active, models.ChainCommit)
+	index := newSubscriptionIndex()
+	assert.Nil(t, index.resync())
+
+	// a change that is older than the loaded subscription is ignored
+	older := *subscriptionContext
+	older.Version--
+	older.Subscription.SubscriptionStatus = models.Suspended
+	index.update(older.Subscription.ID, &older)
+
+	assertIndexed(t, index, models.ChainCommit, subscriptionContext)
+}
+
+func createIndexedSubscription(t *testing.T, status models.SubscriptionStatus, eventTypes ...models.EventType) *models.SubscriptionContext {
+	filters := make(map[models.EventType]models.Filter)
+	for _, eventType := range eventTypes {
+		filters[eventType] = models.Filter{}
+	}
+	subscriptionContext, err := repository.SubscriptionRepository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        "http://localhost/callback",
+			CallbackType:       models.HTTP,
+			SubscriptionStatus: status,
+			Filters:            filters,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+	return subscriptionContext
+}
+
+func assertIndexed(t *testing.T, index *subscriptionIndex, eventType models.EventType, expected ...*models.SubscriptionContext) {
+	subscriptionContexts, err := index.activeSubscriptions(eventType)
+	assert.Nil(t, err)
+
+	var ids []string
+	for _, subscriptionContext := range subscriptionContexts {
+		ids = append(ids, subscriptionContext.Subscription.ID)
+	}
+	var expectedIDs []string
+	for _, subscriptionContext := range expected {
+		expectedIDs = append(expectedIDs, subscriptionContext.Subscription.ID)
+	}
+	assert.ElementsMatch(t, expectedIDs, ids, "subscriptions of %s mismatch", eventType)
+}
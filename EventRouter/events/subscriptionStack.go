@@ -8,45 +8,62 @@ import (
 type subscriptionStack struct {
 	sync.Mutex
 	subscription *models.SubscriptionContext
-	events       [][]byte
+	events       []*QueuedEvent
 	processing   bool
 }
 
+// QueuedEvent is an event that waits in the stack to be delivered to the subscription
+type QueuedEvent struct {
+	EventType   models.EventType
+	BlockHeight uint32
+	Payload     []byte
+}
+
 // SubscriptionStack is stack to track which subscription should be processed.
 type SubscriptionStack interface {
 	UpdateSubscription(subscription *models.SubscriptionContext)
-	Add([]byte)
-	Push([]byte)
-	Pop() (*models.SubscriptionContext, []byte)
+	Subscription() *models.SubscriptionContext
+	Add(*QueuedEvent)
+	Push(*QueuedEvent)
+	Pop() (*models.SubscriptionContext, *QueuedEvent)
 	Processing(bool)
 	IsProcessing() bool
+
+	// StartProcessing marks the stack as processing, false is returned when the stack is already processing
+	StartProcessing() bool
+
+	// StopProcessing marks the stack as not processing, false is returned when the stack still has events to deliver
+	// to the active subscription
+	StopProcessing() bool
+	Len() int
+	Clear() int
 }
 
 // NewSubscriptionStack creates a subscription stack
 func NewSubscriptionStack(subscription *models.SubscriptionContext) SubscriptionStack {
 	return &subscriptionStack{
 		subscription: subscription,
-		events:       [][]byte{},
+		events:       []*QueuedEvent{},
 		processing:   false,
 	}
 }
 
 // add the event to the back of the list
-func (q *subscriptionStack) Add(item []byte) {
+func (q *subscriptionStack) Add(item *QueuedEvent) {
 	q.Lock()
 	defer q.Unlock()
 	q.events = append(q.events, item)
 }
 
 // add the event to the front of the list
-func (q *subscriptionStack) Push(item []byte) {
+func (q *subscriptionStack) Push(item *QueuedEvent) {
 	q.Lock()
 	defer q.Unlock()
-	q.events = append([][]byte{item}, q.events...)
+	q.events = append([]*QueuedEvent{item}, q.events...)
 }
 
 // get and remove the first item of the list
-func (q *subscriptionStack) Pop() (*models.SubscriptionContext, []byte) {
+func (q *subscriptionStack) Pop() (*models.SubscriptionContext, *QueuedEvent) {
 	q.Lock()
 	defer q.Unlock()
 	if len(q.events) == 0 {
@@ -57,10 +74,22 @@ func (q *subscriptionStack) Pop() (*models.SubscriptionContext, []byte) {
 	return q.subscription, item
 }
 
+// update the subscription of the stack, an outdated version of the subscription is ignored
 func (q *subscriptionStack) UpdateSubscription(subscription *models.SubscriptionContext) {
+	q.Lock()
+	defer q.Unlock()
+	if q.subscription != nil && subscription.Version < q.subscription.Version {
+		return
+	}
 	q.subscription = subscription
 }
 
+func (q *subscriptionStack) Subscription() *models.SubscriptionContext {
+	q.Lock()
+	defer q.Unlock()
+	return q.subscription
+}
+
 func (q *subscriptionStack) Processing(processing bool) {
 	q.Lock()
 	defer q.Unlock()
@@ -72,3 +101,39 @@ func (q *subscriptionStack) IsProcessing() bool {
 	defer q.Unlock()
 	return q.processing
 }
+
+func (q *subscriptionStack) StartProcessing() bool {
+	q.Lock()
+	defer q.Unlock()
+	if q.processing {
+		return false
+	}
+	q.processing = true
+	return true
+}
+
+func (q *subscriptionStack) StopProcessing() bool {
+	q.Lock()
+	defer q.Unlock()
+	if len(q.events) > 0 && q.subscription != nil && q.subscription.Subscription.SubscriptionStatus == models.Active {
+		return false
+	}
+	q.processing = false
+	return true
+}
+
+// the number of events in the list
+func (q *subscriptionStack) Len() int {
+	q.Lock()
+	defer q.Unlock()
+	return len(q.events)
+}
+
+// remove all events from the list and return the number of removed events
+func (q *subscriptionStack) Clear() int {
+	q.Lock()
+	defer q.Unlock()
+	n := len(q.events)
+	q.events = []*QueuedEvent{}
+	return n
+}
@@ -1,14 +1,15 @@
 package events
 
 import (
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestSubscriptionStack_Push(t *testing.T) {
 	stack := NewSubscriptionStack(nil)
-	e1 := []byte("1")
-	e2 := []byte("2")
+	e1 := &QueuedEvent{Payload: []byte("1")}
+	e2 := &QueuedEvent{Payload: []byte("2")}
 
 	stack.Push(e1)
 	stack.Push(e2)
@@ -28,8 +29,8 @@ func TestSubscriptionStack_Push(t *testing.T) {
 
 func TestSubscriptionStack_Add(t *testing.T) {
 	stack := NewSubscriptionStack(nil)
-	e1 := []byte("1")
-	e2 := []byte("2")
+	e1 := &QueuedEvent{Payload: []byte("1")}
+	e2 := &QueuedEvent{Payload: []byte("2")}
 
 	stack.Add(e1)
 	stack.Add(e2)
@@ -56,3 +57,50 @@ func TestSubscriptionStack_IsProcessing(t *testing.T) {
 	stack.Processing(false)
 	assert.Equal(t, false, stack.IsProcessing())
 }
+
+func TestSubscriptionStack_StartProcessing(t *testing.T) {
+	stack := NewSubscriptionStack(nil)
+
+	assert.True(t, stack.StartProcessing())
+	assert.True(t, stack.IsProcessing())
+	assert.False(t, stack.StartProcessing())
+	stack.Processing(false)
+	assert.True(t, stack.StartProcessing())
+}
+
+func TestSubscriptionStack_StopProcessing(t *testing.T) {
+	subscriptionContext := &models.SubscriptionContext{Subscription: models.Subscription{SubscriptionStatus: models.Active}}
+	stack := NewSubscriptionStack(subscriptionContext)
+	assert.True(t, stack.StartProcessing())
+
+	// an event that is added while processing keeps the stack processing
+	stack.Add(&QueuedEvent{Payload: []byte("1")})
+	assert.False(t, stack.StopProcessing())
+	assert.True(t, stack.IsProcessing())
+
+	// the events of a suspended subscription wait in the stack
+	stack.UpdateSubscription(&models.SubscriptionContext{Subscription: models.Subscription{SubscriptionStatus: models.Suspended}})
+	assert.True(t, stack.StopProcessing())
+	assert.False(t, stack.IsProcessing())
+
+	assert.True(t, stack.StartProcessing())
+	stack.Clear()
+	stack.UpdateSubscription(subscriptionContext)
+	assert.True(t, stack.StopProcessing())
+	assert.False(t, stack.IsProcessing())
+}
+
+func TestSubscriptionStack_Clear(t *testing.T) {
+	stack := NewSubscriptionStack(nil)
+	assert.Equal(t, 0, stack.Len())
+
+	stack.Add(&QueuedEvent{Payload: []byte("1")})
+	stack.Add(&QueuedEvent{Payload: []byte("2")})
+	assert.Equal(t, 2, stack.Len())
+
+	assert.Equal(t, 2, stack.Clear())
+	assert.Equal(t, 0, stack.Len())
+
+	_, event := stack.Pop()
+	assert.Nil(t, event)
+}
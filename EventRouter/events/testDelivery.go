@@ -0,0 +1,155 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/eventmessages/generated/eventmessages"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/bi-foundation/protobuf-graphql-extension/graphqlproto/types"
+	"time"
+)
+
+// the block height of the synthetic events
+const syntheticBlockHeight = 1
+
+// SyntheticEvent builds an example event of the event type. The hashes, keys and signatures of the event are not valid.
+func SyntheticEvent(eventType models.EventType) (*eventmessages.FactomEvent, error) {
+	now := time.Now()
+	timestamp := &types.Timestamp{Seconds: now.Unix(), Nanos: int32(now.Nanosecond())}
+
+	factomEvent := &eventmessages.FactomEvent{
+		EventSource:     eventmessages.EventSource_LIVE,
+		FactomNodeName:  "live-feed-test",
+		IdentityChainID: syntheticHash("identity chain"),
+	}
+
+	switch eventType {
+	case models.ChainCommit:
+		factomEvent.Event = &eventmessages.FactomEvent_ChainCommit{ChainCommit: &eventmessages.ChainCommit{
+			EntityState:          eventmessages.EntityState_ACCEPTED,
+			ChainIDHash:          syntheticHash("chain id"),
+			EntryHash:            syntheticHash("entry"),
+			Weld:                 syntheticHash("weld"),
+			Timestamp:            timestamp,
+			Credits:              11,
+			EntryCreditPublicKey: syntheticHash("entry credit public key"),
+			Signature:            append(syntheticHash("signature"), syntheticHash("signature")...),
+		}}
+	case models.EntryCommit:
+		factomEvent.Event = &eventmessages.FactomEvent_EntryCommit{EntryCommit: &eventmessages.EntryCommit{
+			EntityState:          eventmessages.EntityState_ACCEPTED,
+			EntryHash:            syntheticHash("entry"),
+			Timestamp:            timestamp,
+			Credits:              1,
+			EntryCreditPublicKey: syntheticHash("entry credit public key"),
+			Signature:            append(syntheticHash("signature"), syntheticHash("signature")...),
+		}}
+	case models.EntryReveal:
+		factomEvent.Event = &eventmessages.FactomEvent_EntryReveal{EntryReveal: &eventmessages.EntryReveal{
+			EntityState: eventmessages.EntityState_ACCEPTED,
+			Entry: &eventmessages.EntryBlockEntry{
+				Hash:        syntheticHash("entry"),
+				ExternalIDs: [][]byte{[]byte("live feed"), []byte("test")},
+				Content:     []byte("test event of the live feed api"),
+				ChainID:     syntheticHash("chain id"),
+			},
+			Timestamp: timestamp,
+		}}
+	case models.StateChange:
+		factomEvent.Event = &eventmessages.FactomEvent_StateChange{StateChange: &eventmessages.StateChange{
+			EntityHash:  syntheticHash("entry"),
+			EntityState: eventmessages.EntityState_COMMITTED_TO_DIRECTORY_BLOCK,
+			BlockHeight: syntheticBlockHeight,
+		}}
+	case models.DirectoryBlockCommit:
+		factomEvent.Event = &eventmessages.FactomEvent_DirectoryBlockCommit{DirectoryBlockCommit: &eventmessages.DirectoryBlockCommit{
+			DirectoryBlock: &eventmessages.DirectoryBlock{
+				Header: &eventmessages.DirectoryBlockHeader{
+					BodyMerkleRoot:        syntheticHash("body merkle root"),
+					PreviousKeyMerkleRoot: syntheticHash("previous key merkle root"),
+					PreviousFullHash:      syntheticHash("previous full hash"),
+					Timestamp:             timestamp,
+					BlockHeight:           syntheticBlockHeight,
+				},
+				Hash:          syntheticHash("directory block"),
+				KeyMerkleRoot: syntheticHash("key merkle root"),
+			},
+		}}
+	case models.DirectoryBlockAnchor:
+		factomEvent.Event = &eventmessages.FactomEvent_DirectoryBlockAnchor{DirectoryBlockAnchor: &eventmessages.DirectoryBlockAnchor{
+			DirectoryBlockHash:       syntheticHash("directory block"),
+			DirectoryBlockMerkleRoot: syntheticHash("key merkle root"),
+			BlockHeight:              syntheticBlockHeight,
+			Timestamp:                timestamp,
+			BtcTxHash:                syntheticHash("bitcoin transaction"),
+			BtcBlockHash:             syntheticHash("bitcoin block"),
+			BtcConfirmed:             true,
+		}}
+	case models.ProcessListEvent:
+		factomEvent.Event = &eventmessages.FactomEvent_ProcessListEvent{ProcessListEvent: &eventmessages.ProcessListEvent{
+			ProcessListEvent: &eventmessages.ProcessListEvent_NewMinuteEvent{NewMinuteEvent: &eventmessages.NewMinuteEvent{
+				NewMinute:   1,
+				BlockHeight: syntheticBlockHeight,
+			}},
+		}}
+	case models.NodeMessage:
+		factomEvent.Event = &eventmessages.FactomEvent_NodeMessage{NodeMessage: &eventmessages.NodeMessage{
+			MessageCode: eventmessages.NodeMessageCode_GENERAL,
+			Level:       eventmessages.Level_INFO,
+			MessageText: "test event of the live feed api",
+		}}
+	default:
+		return nil, fmt.Errorf("invalid event type: %s", eventType)
+	}
+	return factomEvent, nil
+}
+
+// TestDelivery sends a synthetic event of the event type to the endpoint of the subscription right away. The event is
+// filtered and rendered like a real event, the queue, the failures and the status of the subscription are not affected.
+func TestDelivery(subscription *models.Subscription, eventType models.EventType) (*models.DeliveryResult, error) {
+	switch subscription.CallbackType {
+	case models.File, models.Relay:
+		return nil, fmt.Errorf("test delivery is not supported for callback type %s", subscription.CallbackType)
+	}
+	if _, ok := subscription.Filters[eventType]; !ok {
+		return nil, fmt.Errorf("subscription does not receive events of type %s", eventType)
+	}
+
+	factomEvent, err := SyntheticEvent(eventType)
+	if err != nil {
+		return nil, err
+	}
+	event, err := json.Marshal(factomEvent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create json from factom event")
+	}
+
+	result := &models.DeliveryResult{EventType: eventType}
+	payload, err := buildPayload(subscription, eventType, factomEvent, event)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to build event: %v", err)
+		return result, nil
+	}
+
+	start := time.Now()
+	response, err := executeSend(subscription, payload)
+	result.Latency = time.Since(start).Nanoseconds() / int64(time.Millisecond)
+	if response != nil {
+		result.StatusCode = response.statusCode
+		result.Body = string(response.body)
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Delivered = err == nil
+
+	log.Info("test delivery of %s to subscription %s: %v", eventType, subscription.ID, err)
+	return result, nil
+}
+
+func syntheticHash(name string) []byte {
+	hash := sha256.Sum256([]byte(name))
+	return hash[:]
+}
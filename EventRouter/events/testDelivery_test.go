@@ -0,0 +1,127 @@
+package events
+
+import (
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSyntheticEvent(t *testing.T) {
+	eventTypes := []models.EventType{models.DirectoryBlockCommit, models.DirectoryBlockAnchor, models.ChainCommit, models.EntryCommit, models.EntryReveal, models.StateChange, models.ProcessListEvent, models.NodeMessage}
+
+	for _, eventType := range eventTypes {
+		t.Run(string(eventType), func(t *testing.T) {
+			factomEvent, err := SyntheticEvent(eventType)
+			assert.Nil(t, err)
+
+			mappedEventType, err := mapEventType(factomEvent)
+			assert.Nil(t, err)
+			assert.Equal(t, eventType, mappedEventType)
+
+			// the synthetic events can be filtered like real events
+			_, err = Filter(nonFilteringQuery, factomEvent)
+			assert.Nil(t, err)
+		})
+	}
+
+	_, err := SyntheticEvent("UNKNOWN")
+	assert.NotNil(t, err)
+}
+
+func TestTestDelivery(t *testing.T) {
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		received, _ = ioutil.ReadAll(request.Body)
+		if request.Header.Get("Authorization") != "Bearer token" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			_, _ = writer.Write([]byte("invalid token"))
+			return
+		}
+		_, _ = writer.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	subscription := &models.Subscription{
+		ID:           "id",
+		CallbackURL:  server.URL,
+		CallbackType: models.BearerToken,
+		Credentials:  models.Credentials{AccessToken: "token"},
+		Filters: map[models.EventType]models.Filter{
+			models.NodeMessage: {},
+			models.EntryReveal: {Template: `{{.factomNodeName}}`},
+		},
+	}
+
+	// the complete event is sent when the filter has no filtering and no template
+	result, err := TestDelivery(subscription, models.NodeMessage)
+	assert.Nil(t, err)
+	assert.True(t, result.Delivered)
+	assert.Equal(t, models.NodeMessage, result.EventType)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "ok", result.Body)
+	assert.Empty(t, result.Error)
+	assert.Contains(t, string(received), `"NodeMessage":{"messageText":"test event of the live feed api"}`)
+
+	result, err = TestDelivery(subscription, models.EntryReveal)
+	assert.Nil(t, err)
+	assert.True(t, result.Delivered)
+	assert.Equal(t, "live-feed-test", string(received))
+
+	// the response of a failed delivery is returned
+	subscription.Credentials.AccessToken = "other"
+	result, err = TestDelivery(subscription, models.NodeMessage)
+	assert.Nil(t, err)
+	assert.False(t, result.Delivered)
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+	assert.Equal(t, "invalid token", result.Body)
+	assert.NotEmpty(t, result.Error)
+
+	// the event is filtered with the filter of the subscription, the fields that are not selected are dropped
+	subscription.Credentials.AccessToken = "token"
+	subscription.Filters[models.NodeMessage] = models.Filter{Filtering: `{ event { ... on NodeMessage { messageText } } }`}
+	result, err = TestDelivery(subscription, models.NodeMessage)
+	assert.Nil(t, err)
+	assert.True(t, result.Delivered)
+	assert.JSONEq(t, `{"event":{"event":{"messageText":"test event of the live feed api"}}}`, string(received))
+
+	// the template renders the filtered event
+	subscription.Filters[models.NodeMessage] = models.Filter{
+		Filtering: `{ factomNodeName event { ... on NodeMessage { messageText } } }`,
+		Template:  `{{.event.factomNodeName}}: {{.event.event.messageText}}`,
+	}
+	result, err = TestDelivery(subscription, models.NodeMessage)
+	assert.Nil(t, err)
+	assert.True(t, result.Delivered)
+	assert.Equal(t, "live-feed-test: test event of the live feed api", string(received))
+
+	// a filter that fails is reported without sending the event
+	received = nil
+	subscription.Filters[models.NodeMessage] = models.Filter{Filtering: `{ fieldNotExists }`}
+	result, err = TestDelivery(subscription, models.NodeMessage)
+	assert.Nil(t, err)
+	assert.False(t, result.Delivered)
+	assert.Equal(t, 0, result.StatusCode)
+	assert.NotEmpty(t, result.Error)
+	assert.Nil(t, received)
+
+	// a template that fails is reported without sending the event
+	received = nil
+	subscription.Filters[models.NodeMessage] = models.Filter{Template: `{{hex .factomNodeName}}`}
+	result, err = TestDelivery(subscription, models.NodeMessage)
+	assert.Nil(t, err)
+	assert.False(t, result.Delivered)
+	assert.Equal(t, 0, result.StatusCode)
+	assert.NotEmpty(t, result.Error)
+	assert.Nil(t, received)
+
+	// only the event types of the subscription can be tested
+	_, err = TestDelivery(subscription, models.ChainCommit)
+	assert.NotNil(t, err)
+
+	subscription.CallbackType = models.Relay
+	_, err = TestDelivery(subscription, models.NodeMessage)
+	assert.NotNil(t, err)
+}
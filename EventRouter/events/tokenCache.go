@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"strings"
+	"sync"
+)
+
+// the tokens that are used to deliver events to subscriptions with the oauth2 client credentials callback type
+var oauth2Tokens = newTokenCache()
+
+// tokenCache keeps a token source for every subscription. The token source reuses the access token until it is about
+// to expire, only then a new access token is requested at the token endpoint.
+type tokenCache struct {
+	sync.Mutex
+	sources map[string]*cachedTokenSource
+}
+
+type cachedTokenSource struct {
+	key         string
+	tokenSource oauth2.TokenSource
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		sources: make(map[string]*cachedTokenSource),
+	}
+}
+
+// Token returns a valid access token for the subscription, a new token is requested if there is none or the token is expired
+func (cache *tokenCache) Token(subscription *models.Subscription) (*oauth2.Token, error) {
+	cache.Lock()
+	source, ok := cache.sources[subscription.ID]
+	key := credentialsKey(subscription.Credentials)
+
+	// create a new token source when the subscription is new or the credentials has been changed
+	if !ok || source.key != key {
+		source = &cachedTokenSource{
+			key:         key,
+			tokenSource: newTokenSource(subscription.Credentials),
+		}
+		cache.sources[subscription.ID] = source
+	}
+	cache.Unlock()
+
+	token, err := source.tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to request access token from '%s': %v", subscription.Credentials.OAuth2TokenURL, err)
+	}
+	return token, nil
+}
+
+// Invalidate the access token of the subscription, such that the next token is requested at the token endpoint
+func (cache *tokenCache) Invalidate(subscription *models.Subscription) {
+	cache.Lock()
+	defer cache.Unlock()
+	delete(cache.sources, subscription.ID)
+}
+
+// Update removes the token source of the subscription when the subscription doesn't use the oauth2 client credentials
+// anymore or the credentials or the token url are changed
+func (cache *tokenCache) Update(subscription *models.Subscription) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	source, ok := cache.sources[subscription.ID]
+	if ok && (subscription.CallbackType != models.OAuth2ClientCredentials || source.key != credentialsKey(subscription.Credentials)) {
+		delete(cache.sources, subscription.ID)
+	}
+}
+
+// Remove the token source of a deleted subscription
+func (cache *tokenCache) Remove(subscriptionID string) {
+	cache.Lock()
+	defer cache.Unlock()
+	delete(cache.sources, subscriptionID)
+}
+
+func newTokenSource(credentials models.Credentials) oauth2.TokenSource {
+	configuration := &clientcredentials.Config{
+		ClientID:     credentials.OAuth2ClientID,
+		ClientSecret: credentials.OAuth2ClientSecret,
+		TokenURL:     credentials.OAuth2TokenURL,
+		Scopes:       credentials.OAuth2Scopes,
+	}
+	return configuration.TokenSource(context.Background())
+}
+
+func credentialsKey(credentials models.Credentials) string {
+	return strings.Join([]string{credentials.OAuth2TokenURL, credentials.OAuth2ClientID, credentials.OAuth2ClientSecret, strings.Join(credentials.OAuth2Scopes, " ")}, "\n")
+}
@@ -0,0 +1,59 @@
+package events
+
+import (
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTokenCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"access_token": "token", "token_type": "bearer", "expires_in": 3600}`))
+	}))
+	defer server.Close()
+
+	cache := newTokenCache()
+	subscription := &models.Subscription{
+		ID:           "id",
+		CallbackType: models.OAuth2ClientCredentials,
+		Credentials:  models.Credentials{OAuth2TokenURL: server.URL, OAuth2ClientID: "client", OAuth2ClientSecret: "secret"},
+	}
+
+	// the access token is reused until it expires
+	for i := 0; i < 2; i++ {
+		token, err := cache.Token(subscription)
+		assert.Nil(t, err)
+		assert.Equal(t, "token", token.AccessToken)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+
+	// an unchanged subscription keeps the access token
+	cache.Update(subscription)
+	assert.Len(t, cache.sources, 1)
+
+	// changed credentials remove the access token
+	changed := *subscription
+	changed.Credentials.OAuth2ClientSecret = "other"
+	cache.Update(&changed)
+	assert.Empty(t, cache.sources)
+
+	// a subscription that doesn't use oauth2 anymore removes the access token
+	_, err := cache.Token(subscription)
+	assert.Nil(t, err)
+	changed = *subscription
+	changed.CallbackType = models.HTTP
+	cache.Update(&changed)
+	assert.Empty(t, cache.sources)
+
+	// a deleted subscription removes the access token
+	_, err = cache.Token(subscription)
+	assert.Nil(t, err)
+	cache.Remove(subscription.ID)
+	assert.Empty(t, cache.sources)
+}
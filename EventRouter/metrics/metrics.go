@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"time"
+)
+
+// Metrics records the measurements of the receiver, the router and the repository
+type Metrics interface {
+	// EventReceived counts an event that is received from a factomd connection
+	EventReceived(eventType models.EventType, connection string)
+
+	// EventQueueDepth records the number of received events that wait to be routed
+	EventQueueDepth(depth int)
+
+	// SubscriptionQueueDepth records the number of events that wait to be delivered to the subscription
+	SubscriptionQueueDepth(subscriptionID string, depth int)
+
+	// SubscriptionRemoved removes the measurements of the deleted subscription
+	SubscriptionRemoved(subscriptionID string)
+
+	// DeliveryAttempted records an attempt to deliver an event, the status code is 0 when the endpoint didn't respond
+	// or the callback type doesn't use http
+	DeliveryAttempted(callbackType models.CallbackType, statusCode int, duration time.Duration, err error)
+
+	// FilterExecuted records the time of filtering an event with the filter of a subscription
+	FilterExecuted(duration time.Duration)
+
+	// RepositoryCalled records the time of a call to the repository
+	RepositoryCalled(operation string, duration time.Duration, err error)
+}
+
+// Recorder records the measurements, the measurements are discarded by default
+var Recorder Metrics = discard{}
+
+type discard struct{}
+
+func (discard) EventReceived(models.EventType, string)                           {}
+func (discard) EventQueueDepth(int)                                              {}
+func (discard) SubscriptionQueueDepth(string, int)                               {}
+func (discard) SubscriptionRemoved(string)                                       {}
+func (discard) DeliveryAttempted(models.CallbackType, int, time.Duration, error) {}
+func (discard) FilterExecuted(time.Duration)                                     {}
+func (discard) RepositoryCalled(string, time.Duration, error)                    {}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+const namespace = "livefeed"
+
+type prometheusMetrics struct {
+	eventsReceived         *prometheus.CounterVec
+	eventQueueDepth        prometheus.Gauge
+	subscriptionQueueDepth *prometheus.GaugeVec
+	deliveryAttempts       *prometheus.CounterVec
+	deliverySuccesses      *prometheus.CounterVec
+	deliveryFailures       *prometheus.CounterVec
+	deliveryDuration       *prometheus.HistogramVec
+	filterDuration         prometheus.Histogram
+	repositoryDuration     *prometheus.HistogramVec
+}
+
+// NewPrometheusMetrics creates the metrics and registers them at the registerer, which exposes them to prometheus
+func NewPrometheusMetrics(registerer prometheus.Registerer) (Metrics, error) {
+	metrics := &prometheusMetrics{
+		eventsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_received_total",
+			Help:      "The number of events received from factomd per event type and connection.",
+		}, []string{"event_type", "connection"}),
+		eventQueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "event_queue_depth",
+			Help:      "The number of received events that wait to be routed.",
+		}),
+		subscriptionQueueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "subscription_queue_depth",
+			Help:      "The number of events that wait to be delivered to a subscription.",
+		}, []string{"subscription"}),
+		deliveryAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "delivery_attempts_total",
+			Help:      "The number of attempts to deliver an event per callback type.",
+		}, []string{"callback_type"}),
+		deliverySuccesses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "delivery_successes_total",
+			Help:      "The number of delivered events per status class of the response.",
+		}, []string{"status_class"}),
+		deliveryFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "delivery_failures_total",
+			Help:      "The number of failed deliveries per status class of the response, the status class is none when the endpoint didn't respond.",
+		}, []string{"status_class"}),
+		deliveryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "delivery_duration_seconds",
+			Help:      "The latency of the delivery of an event per callback type.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"callback_type"}),
+		filterDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "filter_duration_seconds",
+			Help:      "The time to filter an event with the filter of a subscription.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}),
+		repositoryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_duration_seconds",
+			Help:      "The latency of the calls to the repository per operation and result.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 8),
+		}, []string{"operation", "result"}),
+	}
+
+	collectors := []prometheus.Collector{
+		metrics.eventsReceived,
+		metrics.eventQueueDepth,
+		metrics.subscriptionQueueDepth,
+		metrics.deliveryAttempts,
+		metrics.deliverySuccesses,
+		metrics.deliveryFailures,
+		metrics.deliveryDuration,
+		metrics.filterDuration,
+		metrics.repositoryDuration,
+	}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register metrics: %v", err)
+		}
+	}
+	return metrics, nil
+}
+
+func (metrics *prometheusMetrics) EventReceived(eventType models.EventType, connection string) {
+	metrics.eventsReceived.WithLabelValues(string(eventType), connection).Inc()
+}
+
+func (metrics *prometheusMetrics) EventQueueDepth(depth int) {
+	metrics.eventQueueDepth.Set(float64(depth))
+}
+
+func (metrics *prometheusMetrics) SubscriptionQueueDepth(subscriptionID string, depth int) {
+	metrics.subscriptionQueueDepth.WithLabelValues(subscriptionID).Set(float64(depth))
+}
+
+func (metrics *prometheusMetrics) SubscriptionRemoved(subscriptionID string) {
+	metrics.subscriptionQueueDepth.DeleteLabelValues(subscriptionID)
+}
+
+func (metrics *prometheusMetrics) DeliveryAttempted(callbackType models.CallbackType, statusCode int, duration time.Duration, err error) {
+	metrics.deliveryAttempts.WithLabelValues(string(callbackType)).Inc()
+	metrics.deliveryDuration.WithLabelValues(string(callbackType)).Observe(duration.Seconds())
+	if err != nil {
+		metrics.deliveryFailures.WithLabelValues(statusClass(statusCode)).Inc()
+	} else {
+		metrics.deliverySuccesses.WithLabelValues(statusClass(statusCode)).Inc()
+	}
+}
+
+func (metrics *prometheusMetrics) FilterExecuted(duration time.Duration) {
+	metrics.filterDuration.Observe(duration.Seconds())
+}
+
+func (metrics *prometheusMetrics) RepositoryCalled(operation string, duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	metrics.repositoryDuration.WithLabelValues(operation, result).Observe(duration.Seconds())
+}
+
+// statusClass returns the class of the http status code, e.g. 2xx, or none without a response
+func statusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "none"
+	}
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
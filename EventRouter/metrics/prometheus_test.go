@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	recorder, err := NewPrometheusMetrics(registry)
+	assert.Nil(t, err)
+	metrics := recorder.(*prometheusMetrics)
+
+	recorder.EventReceived(models.NodeMessage, "127.0.0.1")
+	recorder.EventReceived(models.NodeMessage, "127.0.0.1")
+	recorder.EventReceived(models.EntryReveal, "127.0.0.2")
+	recorder.EventQueueDepth(5)
+	recorder.SubscriptionQueueDepth("id", 3)
+	recorder.DeliveryAttempted(models.HTTP, 200, time.Millisecond, nil)
+	recorder.DeliveryAttempted(models.HTTP, 503, time.Millisecond, fmt.Errorf("unavailable"))
+	recorder.DeliveryAttempted(models.HTTP, 0, time.Second, fmt.Errorf("connection refused"))
+	recorder.FilterExecuted(time.Millisecond)
+	recorder.RepositoryCalled("ReadSubscription", time.Millisecond, nil)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.eventsReceived.WithLabelValues("NODE_MESSAGE", "127.0.0.1")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.eventsReceived.WithLabelValues("ENTRY_REVEAL", "127.0.0.2")))
+	assert.Equal(t, float64(5), testutil.ToFloat64(metrics.eventQueueDepth))
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.subscriptionQueueDepth.WithLabelValues("id")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.deliveryAttempts.WithLabelValues("HTTP")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.deliverySuccesses.WithLabelValues("2xx")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.deliveryFailures.WithLabelValues("5xx")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.deliveryFailures.WithLabelValues("none")))
+
+	expected := `
+		# HELP livefeed_repository_duration_seconds The latency of the calls to the repository per operation and result.
+		# TYPE livefeed_repository_duration_seconds histogram
+		livefeed_repository_duration_seconds_bucket{operation="ReadSubscription",result="success",le="0.0005"} 0
+		livefeed_repository_duration_seconds_bucket{operation="ReadSubscription",result="success",le="0.002"} 1
+		livefeed_repository_duration_seconds_bucket{operation="ReadSubscription",result="success",le="0.008"} 1
+		livefeed_repository_duration_seconds_bucket{operation="ReadSubscription",result="success",le="0.032"} 1
+		livefeed_repository_duration_seconds_bucket{operation="ReadSubscription",result="success",le="0.128"} 1
+		livefeed_repository_duration_seconds_bucket{operation="ReadSubscription",result="success",le="0.512"} 1
+		livefeed_repository_duration_seconds_bucket{operation="ReadSubscription",result="success",le="2.048"} 1
+		livefeed_repository_duration_seconds_bucket{operation="ReadSubscription",result="success",le="8.192"} 1
+		livefeed_repository_duration_seconds_bucket{operation="ReadSubscription",result="success",le="+Inf"} 1
+		livefeed_repository_duration_seconds_sum{operation="ReadSubscription",result="success"} 0.001
+		livefeed_repository_duration_seconds_count{operation="ReadSubscription",result="success"} 1
+	`
+	assert.Nil(t, testutil.CollectAndCompare(metrics.repositoryDuration, strings.NewReader(expected)))
+
+	// the queue depth of a deleted subscription is no longer exported
+	recorder.SubscriptionRemoved("id")
+	assert.Nil(t, testutil.CollectAndCompare(metrics.subscriptionQueueDepth, strings.NewReader("")))
+
+	// the metrics can only be registered once
+	_, err = NewPrometheusMetrics(registry)
+	assert.NotNil(t, err)
+}
+
+func TestStatusClass(t *testing.T) {
+	testCases := map[int]string{
+		0:   "none",
+		200: "2xx",
+		204: "2xx",
+		301: "3xx",
+		404: "4xx",
+		503: "5xx",
+	}
+	for statusCode, expected := range testCases {
+		assert.Equal(t, expected, statusClass(statusCode), "status code %d", statusCode)
+	}
+}
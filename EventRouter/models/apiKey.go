@@ -0,0 +1,20 @@
+package models
+
+// APIKey a key that is issued to a client to access the subscription api
+type APIKey struct {
+
+	// The id of the api key.
+	ID string `json:"id" readonly:"true"`
+
+	// The owner of the subscriptions that are created with the api key. Clients only see the subscriptions of their owner.
+	Owner string `json:"owner" binding:"required" example:"explorer"`
+
+	// Role of the client. USER manages its own subscriptions, ADMIN can also manage the api keys.
+	Role Role `json:"role" example:"USER" enums:"USER,ADMIN"`
+
+	// The api key that is set in the X-API-Key header. The key is only returned when the api key is created.
+	Key string `json:"key,omitempty" readonly:"true"`
+
+	// The sha256 hash of the key, only the hash is stored.
+	KeyHash string `json:"-"`
+}
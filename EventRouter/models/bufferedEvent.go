@@ -0,0 +1,9 @@
+package models
+
+// BufferedEvent is an event that is stored for a paused subscription until the subscription is resumed
+type BufferedEvent struct {
+	ID          int64
+	EventType   EventType
+	BlockHeight uint32
+	Payload     []byte
+}
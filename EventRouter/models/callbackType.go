@@ -5,7 +5,10 @@ type CallbackType string
 
 // Different callback types
 const (
-	HTTP        CallbackType = "HTTP"
-	BearerToken CallbackType = "BEARER_TOKEN"
-	BasicAuth   CallbackType = "BASIC_AUTH"
+	HTTP                    CallbackType = "HTTP"
+	BearerToken             CallbackType = "BEARER_TOKEN"
+	BasicAuth               CallbackType = "BASIC_AUTH"
+	OAuth2ClientCredentials CallbackType = "OAUTH2_CLIENT_CREDENTIALS"
+	File                    CallbackType = "FILE"
+	Relay                   CallbackType = "RELAY"
 )
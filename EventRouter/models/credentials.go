@@ -11,4 +11,16 @@ type Credentials struct {
 
 	// Password for authenticating with basic authentication. This is required when the callback type is set on BASIC_AUTH.
 	BasicAuthPassword string `json:"basicAuthPassword"`
+
+	// Token endpoint of the authorization server where access tokens are requested. This is required when the callback type is set on OAUTH2_CLIENT_CREDENTIALS.
+	OAuth2TokenURL string `json:"oauth2TokenUrl"`
+
+	// Client id to request an access token with the client credentials grant. This is required when the callback type is set on OAUTH2_CLIENT_CREDENTIALS.
+	OAuth2ClientID string `json:"oauth2ClientId"`
+
+	// Client secret to request an access token with the client credentials grant. This is required when the callback type is set on OAUTH2_CLIENT_CREDENTIALS.
+	OAuth2ClientSecret string `json:"oauth2ClientSecret"`
+
+	// Optional scopes that are requested with the access token when the callback type is set on OAUTH2_CLIENT_CREDENTIALS.
+	OAuth2Scopes []string `json:"oauth2Scopes"`
 }
@@ -0,0 +1,17 @@
+package models
+
+// DeliveryResult is the result of a test delivery of an event to a subscription
+type DeliveryResult struct {
+	EventType EventType `json:"eventType"`
+	Delivered bool      `json:"delivered"`
+
+	// the status code and the body of the response of the endpoint, the body is truncated
+	StatusCode int    `json:"statusCode,omitempty"`
+	Body       string `json:"body,omitempty"`
+
+	// the time in milliseconds between sending the event and receiving the response
+	Latency int64 `json:"latency"`
+
+	// the reason that the event is not delivered
+	Error string `json:"error,omitempty"`
+}
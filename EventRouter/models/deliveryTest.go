@@ -0,0 +1,6 @@
+package models
+
+// DeliveryTest requests a test delivery of a synthetic event of the event type to a subscription
+type DeliveryTest struct {
+	EventType EventType `json:"eventType"`
+}
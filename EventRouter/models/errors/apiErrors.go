@@ -30,3 +30,23 @@ func NewInvalidRequestDetailed(reason string) *models.APIError {
 func NewParseError() *models.APIError {
 	return &models.APIError{Code: -410800, Message: "parse error", Details: ""}
 }
+
+// NewUnauthorized create a new unauthorized error
+func NewUnauthorized() *models.APIError {
+	return &models.APIError{Code: -410820, Message: "unauthorized", Details: ""}
+}
+
+// NewForbidden create a new forbidden error
+func NewForbidden() *models.APIError {
+	return &models.APIError{Code: -410821, Message: "forbidden", Details: ""}
+}
+
+// NewPreconditionFailed create a new precondition failed error
+func NewPreconditionFailed(reason string) *models.APIError {
+	return &models.APIError{Code: -410822, Message: "precondition failed", Details: reason}
+}
+
+// NewNotFound create a new not found error with given details
+func NewNotFound(reason string) *models.APIError {
+	return &models.APIError{Code: -410823, Message: "not found", Details: reason}
+}
@@ -13,3 +13,38 @@ type SubscriptionNotFound struct {
 func NewSubscriptionNotFound(id string) SubscriptionNotFound {
 	return SubscriptionNotFound{fmt.Errorf("subscription '%s' not found", id)}
 }
+
+// SubscriptionConflict to handle an update of a subscription that has been changed in the mean time
+type SubscriptionConflict struct {
+	error
+}
+
+// NewSubscriptionConflict create a new subscription conflict error
+func NewSubscriptionConflict(id string, version int64) SubscriptionConflict {
+	return SubscriptionConflict{fmt.Errorf("subscription '%s' has been changed: version %d is outdated", id, version)}
+}
+
+// BufferFull to handle an event that is not buffered because the buffer of the subscription is full
+type BufferFull struct {
+	error
+}
+
+// NewBufferFull create a new buffer full error
+func NewBufferFull(id string, limit int) BufferFull {
+	return BufferFull{fmt.Errorf("buffer of subscription '%s' is full: %d events", id, limit)}
+}
+
+// APIKeyNotFound to handle api key not found error on the type level
+type APIKeyNotFound struct {
+	error
+}
+
+// NewAPIKeyNotFound create a new api key not found error
+func NewAPIKeyNotFound(id string) APIKeyNotFound {
+	return APIKeyNotFound{fmt.Errorf("api key '%s' not found", id)}
+}
+
+// NewUnknownAPIKey create a new api key not found error for a key that is not issued
+func NewUnknownAPIKey() APIKeyNotFound {
+	return APIKeyNotFound{fmt.Errorf("unknown api key")}
+}
@@ -0,0 +1,26 @@
+package models
+
+// FileFormat the format in which the events are written to a file
+type FileFormat string
+
+// Different file formats
+const (
+	JSONLines FileFormat = "JSON_LINES"
+	Protobuf  FileFormat = "PROTOBUF"
+)
+
+// FileSink settings of the file where the events are appended to
+type FileSink struct {
+
+	// Format of the events in the file. JSON_LINES writes every (filtered) event as json on a single line. PROTOBUF writes every event as length-delimited protobuf, the filtering is not applied. When no format is given the events are written as JSON_LINES.
+	Format FileFormat `json:"format" example:"JSON_LINES" enums:"JSON_LINES,PROTOBUF"`
+
+	// Rotate the file when it exceeds the size in bytes. No rotation on size when set to 0.
+	MaxSize int64 `json:"maxSize" example:"104857600"`
+
+	// Rotate the file when it has been written to for the number of seconds. No rotation on time when set to 0.
+	MaxAge uint32 `json:"maxAge" example:"86400"`
+
+	// Gzip the rotated files.
+	Compress bool `json:"compress"`
+}
@@ -19,4 +19,7 @@ const (
 type Filter struct {
 	// Define a Filter on an EventType to filter the event. This allows to reduce the network traffic. The filtering is done with GraphQL
 	Filtering string `json:"filtering" example:"{ identityChainID { hashValue } value { ... on NodeMessage { messageCode messageText } } }"`
+
+	// Optional Go text/template to render the (filtered) event into the body that is delivered, for example to post a message to a chat webhook. The functions hex, ecAddress, factoidAddress, timestamp, formatTimestamp and json are available in the template.
+	Template string `json:"template" example:"{\"text\": {{json .event.factomNodeName}}}"`
 }
@@ -0,0 +1,23 @@
+package models
+
+// HealthStatus is the status of the live feed or of one of its checks
+type HealthStatus string
+
+// the health statuses
+const (
+	Up HealthStatus = "UP"
+
+	Down HealthStatus = "DOWN"
+)
+
+// Health is the status of the live feed with the results of the checks that determine the status
+type Health struct {
+	Status HealthStatus            `json:"status"`
+	Checks map[string]*HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the result of a check of the live feed
+type HealthCheck struct {
+	Status  HealthStatus `json:"status"`
+	Details string       `json:"details,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// Role the permissions of a client of the subscription api
+type Role string
+
+// Different roles
+const (
+	// User manages its own subscriptions
+	User Role = "USER"
+
+	// Admin manages its own subscriptions and the api keys
+	Admin Role = "ADMIN"
+)
@@ -6,17 +6,29 @@ type Subscription struct {
 	// The id of the subscription.
 	ID string `json:"id" readonly:"true"`
 
-	// The callback endpoint to receive the events.
+	// The callback endpoint to receive the events. For the FILE callback type this is the path of the file relative to the file sink directory of the live feed, the path can contain {{.EventType}} and {{.BlockHeight}}. For the RELAY callback type this is the address of the receiver: tcp://host:port.
 	CallbackURL string `json:"callbackUrl" binding:"required" example:"https://server.com/events"`
 
 	// Type of callback.
 	// - HTTP to deliver the events to a http/https endpoint.
 	// - BEARER_TOKEN to deliver the events to a http/https endpoint with a bearer token for authentication.
 	// - BASIC_AUTH to deliver the events to a http/https endpoint with a basic authentication.
-	CallbackType CallbackType `json:"callbackType" binding:"required" example:"HTTP" enums:"HTTP,BEARER_TOKEN,BASIC_AUTH"`
+	// - OAUTH2_CLIENT_CREDENTIALS to deliver the events to a http/https endpoint with an access token that is requested with the oauth2 client credentials grant.
+	// - FILE to append the events to a local file.
+	// - RELAY to forward the complete events in the factomd event stream format to another live feed or receiver.
+	CallbackType CallbackType `json:"callbackType" binding:"required" example:"HTTP" enums:"HTTP,BEARER_TOKEN,BASIC_AUTH,OAUTH2_CLIENT_CREDENTIALS,FILE,RELAY"`
+
+	// The http method that is used to deliver the events to the callback endpoint. When no method is given the events are delivered with POST.
+	CallbackMethod string `json:"callbackMethod" example:"POST" enums:"POST,PUT,PATCH"`
+
+	// Static headers that are added to every delivery to the callback endpoint, for example an api key or a tenant header. The Content-Type header can be overridden, authentication headers are set by the callback type.
+	CallbackHeaders map[string]string `json:"callbackHeaders"`
 
 	// Status of subscription. Normally a subscription is active. When events fail to be delivered the subscription will be suspended. The subscription can become active again by updating the subscription. When the subscription is suspended, the error information is set in the info field.
-	SubscriptionStatus SubscriptionStatus `json:"status" example:"ACTIVE" enums:"ACTIVE,SUSPENDED" readonly:"true"`
+	SubscriptionStatus SubscriptionStatus `json:"status" example:"ACTIVE" enums:"ACTIVE,SUSPENDED,PAUSED,PENDING_VERIFICATION" readonly:"true"`
+
+	// The owner of the subscription, which is the owner of the api key that created the subscription.
+	Owner string `json:"owner" readonly:"true"`
 
 	// Information of the subscription. An information message can be for example about why the subscription is suspended.
 	SubscriptionInfo string `json:"info" readonly:"true"`
@@ -26,4 +38,10 @@ type Subscription struct {
 
 	// Credentials of the callback endpoint where events are delivered.
 	Credentials Credentials `json:"credentials"`
+
+	// Settings of the file where the events are written to when the callback type is FILE.
+	FileSink FileSink `json:"fileSink"`
+
+	// Labels to organize subscriptions, for example by team or environment. Subscriptions can be listed by their labels.
+	Labels map[string]string `json:"labels"`
 }
@@ -0,0 +1,19 @@
+package models
+
+// ChangeType the kind of change of a subscription
+type ChangeType string
+
+// different kinds of changes of a subscription
+const (
+	Created ChangeType = "CREATED"
+	Updated ChangeType = "UPDATED"
+	Deleted ChangeType = "DELETED"
+)
+
+// SubscriptionChange notifies that a subscription is created, updated or deleted, the subscription context is the
+// subscription after the change and nil when the subscription is deleted
+type SubscriptionChange struct {
+	Type                ChangeType
+	SubscriptionID      string
+	SubscriptionContext *SubscriptionContext
+}
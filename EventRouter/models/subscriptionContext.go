@@ -10,6 +10,11 @@ type SubscriptionContext struct {
 	Subscription Subscription `json:"subscription"`
 
 	Failures uint16 `json:"failures"`
+
+	// Version is incremented on every update of the subscription by a user, the failures and the status of the
+	// deliveries don't change the version. An update with a version only succeeds when the subscription still has
+	// that version.
+	Version int64 `json:"version"`
 }
 
 // SubscriptionContexts are a list of subscription contexts
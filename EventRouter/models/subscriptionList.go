@@ -0,0 +1,17 @@
+package models
+
+// SubscriptionList a page of the subscriptions that match the query
+type SubscriptionList struct {
+
+	// The subscriptions on the page ordered by id.
+	Subscriptions []Subscription `json:"subscriptions"`
+
+	// The offset of the first subscription on the page.
+	Offset int `json:"offset" example:"0"`
+
+	// The maximum number of subscriptions on the page.
+	Limit int `json:"limit" example:"25"`
+
+	// The total number of subscriptions that match the query.
+	Total int `json:"total" example:"1"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// SubscriptionQuery the criteria to list subscriptions, criteria that are not set match all subscriptions
+type SubscriptionQuery struct {
+	Owner        string
+	Status       SubscriptionStatus
+	EventType    EventType
+	CallbackHost string
+	Labels       map[string]string
+
+	// Offset of the first subscription in the ordered list of matching subscriptions
+	Offset int
+
+	// Limit the maximum number of subscriptions, no limit when set to 0
+	Limit int
+}
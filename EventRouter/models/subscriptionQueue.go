@@ -0,0 +1,14 @@
+package models
+
+// SubscriptionQueue is the state of the queue with events that wait to be delivered to a subscription
+type SubscriptionQueue struct {
+	SubscriptionID string `json:"subscriptionId"`
+	Depth          int    `json:"depth"`
+	Processing     bool   `json:"processing"`
+
+	// the number of events that are buffered while the subscription is paused
+	Buffered int `json:"buffered"`
+
+	// the number of events that are removed when the queue is purged
+	Purged int `json:"purged,omitempty"`
+}
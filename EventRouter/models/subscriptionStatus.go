@@ -8,4 +8,10 @@ const (
 	Active SubscriptionStatus = "ACTIVE"
 
 	Suspended SubscriptionStatus = "SUSPENDED"
+
+	// Paused subscriptions don't receive events, the events are buffered until the subscription is active again
+	Paused SubscriptionStatus = "PAUSED"
+
+	// PendingVerification subscriptions wait until the endpoint echoes the challenge that verifies the callback url
+	PendingVerification SubscriptionStatus = "PENDING_VERIFICATION"
 )
@@ -0,0 +1,7 @@
+package models
+
+// SubscriptionStatusChange forces the status of a subscription, the reason is stored as subscription info
+type SubscriptionStatusChange struct {
+	Status SubscriptionStatus `json:"status"`
+	Reason string             `json:"reason"`
+}
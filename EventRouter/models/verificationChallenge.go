@@ -0,0 +1,12 @@
+package models
+
+// VerificationChallengeType is the type of the verification challenge, to distinguish the challenge from the events
+const VerificationChallengeType = "CALLBACK_VERIFICATION"
+
+// VerificationChallenge is sent to the callback url of a subscription to verify that the endpoint wants to receive the
+// events of the subscription. The endpoint verifies the callback url by echoing the challenge.
+type VerificationChallenge struct {
+	Type           string `json:"type"`
+	SubscriptionID string `json:"subscriptionId"`
+	Challenge      string `json:"challenge"`
+}
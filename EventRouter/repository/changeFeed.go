@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"sync"
+)
+
+// changeFeed publishes the changes of the subscriptions to the watchers, the changes are queued per watcher such that
+// publishing never blocks the repository on a slow watcher
+type changeFeed struct {
+	sync.Mutex
+	watchers map[*changeWatcher]struct{}
+}
+
+type changeWatcher struct {
+	sync.Mutex
+	pending []models.SubscriptionChange
+	signal  chan struct{}
+	changes chan models.SubscriptionChange
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{watchers: make(map[*changeWatcher]struct{})}
+}
+
+// watch returns the changes that are published until the context is done
+func (feed *changeFeed) watch(ctx context.Context) <-chan models.SubscriptionChange {
+	watcher := &changeWatcher{
+		signal:  make(chan struct{}, 1),
+		changes: make(chan models.SubscriptionChange),
+	}
+
+	feed.Lock()
+	feed.watchers[watcher] = struct{}{}
+	feed.Unlock()
+
+	go func() {
+		defer close(watcher.changes)
+		watcher.forward(ctx)
+
+		feed.Lock()
+		delete(feed.watchers, watcher)
+		feed.Unlock()
+	}()
+	return watcher.changes
+}
+
+// publish queues the change for every watcher, the watchers receive the changes in the order they are published
+func (feed *changeFeed) publish(change models.SubscriptionChange) {
+	feed.Lock()
+	defer feed.Unlock()
+	for watcher := range feed.watchers {
+		watcher.Lock()
+		watcher.pending = append(watcher.pending, change)
+		watcher.Unlock()
+
+		select {
+		case watcher.signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// forward the queued changes to the channel of the watcher until the context is done
+func (watcher *changeWatcher) forward(ctx context.Context) {
+	for {
+		watcher.Lock()
+		pending := watcher.pending
+		watcher.pending = nil
+		watcher.Unlock()
+
+		for _, change := range pending {
+			select {
+			case watcher.changes <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-watcher.signal:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -1,109 +1,416 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"github.com/FactomProject/live-feed-api/EventRouter/log"
 	"github.com/FactomProject/live-feed-api/EventRouter/models"
 	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 )
 
+// inMemoryRepository stores the subscriptions by id and indexes the subscriptions that receive events by event type.
+// The stored subscriptions are copied when they are stored or returned, such that the callers never share a stored
+// subscription.
 type inMemoryRepository struct {
 	sync.RWMutex
-	id int
-	db models.SubscriptionContexts
+	id            int
+	subscriptions map[string]*models.SubscriptionContext
+	byEventType   map[models.EventType]map[string]*models.SubscriptionContext
+
+	apiKeyID int
+	apiKeys  []*models.APIKey
+
+	bufferID int64
+	buffers  map[string][]*models.BufferedEvent
+
+	changes *changeFeed
 }
 
 // NewInMemoryRepository create a new in memory repository
 func NewInMemoryRepository() Repository {
 	return &inMemoryRepository{
-		id: 0,
+		id:            0,
+		subscriptions: make(map[string]*models.SubscriptionContext),
+		byEventType:   make(map[models.EventType]map[string]*models.SubscriptionContext),
+		buffers:       make(map[string][]*models.BufferedEvent),
+		changes:       newChangeFeed(),
 	}
 }
 
 // CreateSubscription create a subscription
-func (repository *inMemoryRepository) CreateSubscription(subscriptionContext *models.SubscriptionContext) (*models.SubscriptionContext, error) {
+func (repository *inMemoryRepository) CreateSubscription(ctx context.Context, subscriptionContext *models.SubscriptionContext) (*models.SubscriptionContext, error) {
 	repository.Lock()
 	defer repository.Unlock()
 
 	subscriptionContext.Subscription.ID = strconv.Itoa(repository.id)
-	repository.db = append(repository.db, subscriptionContext)
+	subscriptionContext.Version = 1
+	stored := copySubscriptionContext(subscriptionContext)
+	repository.subscriptions[stored.Subscription.ID] = stored
+	repository.index(stored)
 	repository.id++
-	log.Debug("stored subscription: %v", subscriptionContext)
+	repository.publish(models.Created, stored)
+	log.Debug("stored subscription: %v", stored)
 	return subscriptionContext, nil
 }
 
 // ReadSubscription read a subscription
-func (repository *inMemoryRepository) ReadSubscription(id string) (*models.SubscriptionContext, error) {
-	_, subscriptionContext, err := repository.findSubscription(id)
+func (repository *inMemoryRepository) ReadSubscription(ctx context.Context, id string) (*models.SubscriptionContext, error) {
+	repository.RLock()
+	defer repository.RUnlock()
+
+	subscriptionContext, err := repository.findSubscription(id)
 	if err != nil {
 		return nil, err
 	}
 
 	log.Info("read subscription: %v", subscriptionContext)
-	return subscriptionContext, nil
+	return copySubscriptionContext(subscriptionContext), nil
 }
 
 // UpdateSubscription update a subscription
-func (repository *inMemoryRepository) UpdateSubscription(substituteSubscriptionContext *models.SubscriptionContext) (*models.SubscriptionContext, error) {
-	index, subscriptionContext, err := repository.findSubscription(substituteSubscriptionContext.Subscription.ID)
+func (repository *inMemoryRepository) UpdateSubscription(ctx context.Context, substituteSubscriptionContext *models.SubscriptionContext) (*models.SubscriptionContext, error) {
+	repository.Lock()
+	defer repository.Unlock()
+
+	subscriptionContext, err := repository.findSubscription(substituteSubscriptionContext.Subscription.ID)
 	if err != nil {
 		return nil, err
 	}
+	if substituteSubscriptionContext.Version > 0 && substituteSubscriptionContext.Version != subscriptionContext.Version {
+		return nil, errors.NewSubscriptionConflict(subscriptionContext.Subscription.ID, substituteSubscriptionContext.Version)
+	}
+
+	log.Debug("update subscription: %v with: %v", subscriptionContext, substituteSubscriptionContext.Subscription)
+	substitute := copySubscriptionContext(substituteSubscriptionContext)
+	updated := copySubscriptionContext(subscriptionContext)
+	updated.Subscription.CallbackURL = substitute.Subscription.CallbackURL
+	updated.Subscription.CallbackType = substitute.Subscription.CallbackType
+	updated.Subscription.CallbackMethod = substitute.Subscription.CallbackMethod
+	updated.Subscription.CallbackHeaders = substitute.Subscription.CallbackHeaders
+	updated.Subscription.SubscriptionStatus = substitute.Subscription.SubscriptionStatus
+	updated.Subscription.SubscriptionInfo = substitute.Subscription.SubscriptionInfo
+	updated.Subscription.Credentials = substitute.Subscription.Credentials
+	updated.Subscription.FileSink = substitute.Subscription.FileSink
+	updated.Subscription.Labels = substitute.Subscription.Labels
+	updated.Subscription.Filters = substitute.Subscription.Filters
+	updated.Failures = substitute.Failures
+	updated.Version++
+	repository.replace(subscriptionContext, updated)
 
+	substituteSubscriptionContext.Version = updated.Version
+	repository.publish(models.Updated, updated)
+	return substituteSubscriptionContext, nil
+}
+
+// UpdateSubscriptionStatus update only the failures, status and info of a subscription, the version is not incremented
+func (repository *inMemoryRepository) UpdateSubscriptionStatus(ctx context.Context, substituteSubscriptionContext *models.SubscriptionContext) error {
 	repository.Lock()
 	defer repository.Unlock()
-	log.Debug("update subscription: %v with: %v", subscriptionContext, substituteSubscriptionContext.Subscription)
-	repository.db[index].Subscription.CallbackURL = substituteSubscriptionContext.Subscription.CallbackURL
-	repository.db[index].Subscription.CallbackType = substituteSubscriptionContext.Subscription.CallbackType
-	repository.db[index].Subscription.SubscriptionStatus = substituteSubscriptionContext.Subscription.SubscriptionStatus
-	repository.db[index].Subscription.SubscriptionInfo = substituteSubscriptionContext.Subscription.SubscriptionInfo
-	repository.db[index].Subscription.Credentials.AccessToken = substituteSubscriptionContext.Subscription.Credentials.AccessToken
-	repository.db[index].Subscription.Credentials.BasicAuthUsername = substituteSubscriptionContext.Subscription.Credentials.BasicAuthUsername
-	repository.db[index].Subscription.Credentials.BasicAuthPassword = substituteSubscriptionContext.Subscription.Credentials.BasicAuthPassword
-	repository.db[index].Subscription.Filters = substituteSubscriptionContext.Subscription.Filters
-	return substituteSubscriptionContext, err
-}
-
-func (repository *inMemoryRepository) findSubscription(id string) (int, *models.SubscriptionContext, error) {
-	repository.RLock()
-	defer repository.RUnlock()
 
-	for i, subscriptionContext := range repository.db {
-		if subscriptionContext.Subscription.ID == id {
-			return i, subscriptionContext, nil
+	subscriptionContext, err := repository.findSubscription(substituteSubscriptionContext.Subscription.ID)
+	if err != nil {
+		return err
+	}
+
+	updated := copySubscriptionContext(subscriptionContext)
+	updated.Subscription.SubscriptionStatus = substituteSubscriptionContext.Subscription.SubscriptionStatus
+	updated.Subscription.SubscriptionInfo = substituteSubscriptionContext.Subscription.SubscriptionInfo
+	updated.Failures = substituteSubscriptionContext.Failures
+	repository.replace(subscriptionContext, updated)
+	repository.publish(models.Updated, updated)
+	return nil
+}
+
+// findSubscription returns the stored subscription, the caller holds the lock of the repository
+func (repository *inMemoryRepository) findSubscription(id string) (*models.SubscriptionContext, error) {
+	subscriptionContext, ok := repository.subscriptions[id]
+	if !ok {
+		log.Debug("subscription not found: %s", id)
+		return nil, errors.NewSubscriptionNotFound(id)
+	}
+	return subscriptionContext, nil
+}
+
+// replace the stored subscription with the updated subscription, the stored subscription is not modified such that
+// the copies that are handed out before stay consistent
+func (repository *inMemoryRepository) replace(stored *models.SubscriptionContext, updated *models.SubscriptionContext) {
+	repository.unindex(stored)
+	repository.subscriptions[updated.Subscription.ID] = updated
+	repository.index(updated)
+}
+
+// index the subscription by the event types of the filters when the subscription receives events
+func (repository *inMemoryRepository) index(subscriptionContext *models.SubscriptionContext) {
+	status := subscriptionContext.Subscription.SubscriptionStatus
+	if status != models.Active && status != models.Paused {
+		return
+	}
+	for eventType := range subscriptionContext.Subscription.Filters {
+		subscriptionContexts, ok := repository.byEventType[eventType]
+		if !ok {
+			subscriptionContexts = make(map[string]*models.SubscriptionContext)
+			repository.byEventType[eventType] = subscriptionContexts
+		}
+		subscriptionContexts[subscriptionContext.Subscription.ID] = subscriptionContext
+	}
+}
+
+// unindex removes the subscription from the indexes of the event types
+func (repository *inMemoryRepository) unindex(subscriptionContext *models.SubscriptionContext) {
+	for eventType := range subscriptionContext.Subscription.Filters {
+		subscriptionContexts := repository.byEventType[eventType]
+		delete(subscriptionContexts, subscriptionContext.Subscription.ID)
+		if len(subscriptionContexts) == 0 {
+			delete(repository.byEventType, eventType)
 		}
 	}
-	log.Debug("subscription not found: %s", id)
-	return -1, nil, errors.NewSubscriptionNotFound(id)
 }
 
 // DeleteSubscription delete a subscription
-func (repository *inMemoryRepository) DeleteSubscription(id string) error {
-	index, _, err := repository.findSubscription(id)
+func (repository *inMemoryRepository) DeleteSubscription(ctx context.Context, id string) error {
+	repository.Lock()
+	defer repository.Unlock()
+
+	subscriptionContext, err := repository.findSubscription(id)
 	if err != nil {
 		return fmt.Errorf("failed to delete subscription: %v", err)
 	}
 
+	repository.unindex(subscriptionContext)
+	delete(repository.subscriptions, id)
+	delete(repository.buffers, id)
+	repository.changes.publish(models.SubscriptionChange{Type: models.Deleted, SubscriptionID: id})
+	log.Debug("deleted subscription: %s", id)
+	return nil
+}
+
+// GetActiveSubscriptions retrieve all subscriptions that receive events, the active and the paused subscriptions
+func (repository *inMemoryRepository) GetActiveSubscriptions(ctx context.Context, eventType models.EventType) (models.SubscriptionContexts, error) {
+	repository.RLock()
+	defer repository.RUnlock()
+
+	subscriptionContexts := make(models.SubscriptionContexts, 0, len(repository.byEventType[eventType]))
+	for _, subscriptionContext := range repository.byEventType[eventType] {
+		subscriptionContexts = append(subscriptionContexts, copySubscriptionContext(subscriptionContext))
+	}
+	sortByID(subscriptionContexts)
+	return subscriptionContexts, nil
+}
+
+// Watch returns the changes of the subscriptions, the changes are published while the subscriptions are changed
+func (repository *inMemoryRepository) Watch(ctx context.Context) (<-chan models.SubscriptionChange, error) {
+	return repository.changes.watch(ctx), nil
+}
+
+// publish a copy of the changed subscription, such that the watchers don't share the stored subscription
+func (repository *inMemoryRepository) publish(changeType models.ChangeType, subscriptionContext *models.SubscriptionContext) {
+	repository.changes.publish(models.SubscriptionChange{
+		Type:                changeType,
+		SubscriptionID:      subscriptionContext.Subscription.ID,
+		SubscriptionContext: copySubscriptionContext(subscriptionContext),
+	})
+}
+
+// ListSubscriptions retrieve a page of the subscriptions that match the query and the total number of matching subscriptions
+func (repository *inMemoryRepository) ListSubscriptions(ctx context.Context, query *models.SubscriptionQuery) (models.SubscriptionContexts, int, error) {
+	repository.RLock()
+	defer repository.RUnlock()
+
+	matches := make(models.SubscriptionContexts, 0)
+	for _, subscriptionContext := range repository.subscriptions {
+		if matchesQuery(&subscriptionContext.Subscription, query) {
+			matches = append(matches, subscriptionContext)
+		}
+	}
+	// the ids are assigned in order of creation
+	sortByID(matches)
+
+	subscriptionContexts := make(models.SubscriptionContexts, 0)
+	for i := query.Offset; i < len(matches) && (query.Limit <= 0 || len(subscriptionContexts) < query.Limit); i++ {
+		subscriptionContexts = append(subscriptionContexts, copySubscriptionContext(matches[i]))
+	}
+	return subscriptionContexts, len(matches), nil
+}
+
+// sortByID sorts the subscriptions by the numeric ids of the in memory repository
+func sortByID(subscriptionContexts models.SubscriptionContexts) {
+	sort.Slice(subscriptionContexts, func(i, j int) bool {
+		a, _ := strconv.Atoi(subscriptionContexts[i].Subscription.ID)
+		b, _ := strconv.Atoi(subscriptionContexts[j].Subscription.ID)
+		return a < b
+	})
+}
+
+// copySubscriptionContext returns a deep copy of the subscription, the maps and slices of the copy are not shared
+func copySubscriptionContext(subscriptionContext *models.SubscriptionContext) *models.SubscriptionContext {
+	copied := *subscriptionContext
+	subscription := &copied.Subscription
+	if subscription.CallbackHeaders != nil {
+		subscription.CallbackHeaders = make(map[string]string, len(subscriptionContext.Subscription.CallbackHeaders))
+		for name, value := range subscriptionContext.Subscription.CallbackHeaders {
+			subscription.CallbackHeaders[name] = value
+		}
+	}
+	if subscription.Filters != nil {
+		subscription.Filters = make(map[models.EventType]models.Filter, len(subscriptionContext.Subscription.Filters))
+		for eventType, filter := range subscriptionContext.Subscription.Filters {
+			subscription.Filters[eventType] = filter
+		}
+	}
+	if subscription.Labels != nil {
+		subscription.Labels = make(map[string]string, len(subscriptionContext.Subscription.Labels))
+		for name, value := range subscriptionContext.Subscription.Labels {
+			subscription.Labels[name] = value
+		}
+	}
+	if subscription.Credentials.OAuth2Scopes != nil {
+		subscription.Credentials.OAuth2Scopes = append([]string{}, subscriptionContext.Subscription.Credentials.OAuth2Scopes...)
+	}
+	return &copied
+}
+
+func matchesQuery(subscription *models.Subscription, query *models.SubscriptionQuery) bool {
+	if query.Owner != "" && subscription.Owner != query.Owner {
+		return false
+	}
+	if query.Status != "" && subscription.SubscriptionStatus != query.Status {
+		return false
+	}
+	if query.EventType != "" {
+		if _, ok := subscription.Filters[query.EventType]; !ok {
+			return false
+		}
+	}
+	if query.CallbackHost != "" && callbackHost(subscription) != strings.ToLower(query.CallbackHost) {
+		return false
+	}
+	for name, value := range query.Labels {
+		if label, ok := subscription.Labels[name]; !ok || label != value {
+			return false
+		}
+	}
+	return true
+}
+
+// BufferEvent buffer an event of a paused subscription, the event is not buffered when the buffer contains the limit
+func (repository *inMemoryRepository) BufferEvent(ctx context.Context, subscriptionID string, event *models.BufferedEvent, limit int) error {
 	repository.Lock()
 	defer repository.Unlock()
-	repository.db = append(repository.db[:index], repository.db[index+1:]...)
-	log.Debug("deleted subscription: %s", id)
+
+	buffer := repository.buffers[subscriptionID]
+	if len(buffer) >= limit {
+		return errors.NewBufferFull(subscriptionID, limit)
+	}
+
+	repository.bufferID++
+	bufferedEvent := *event
+	bufferedEvent.ID = repository.bufferID
+	repository.buffers[subscriptionID] = append(buffer, &bufferedEvent)
 	return nil
 }
 
-// GetActiveSubscriptions retrieve all active subscriptions
-func (repository *inMemoryRepository) GetActiveSubscriptions(eventType models.EventType) (models.SubscriptionContexts, error) {
+// PopBufferedEvents read and remove the buffered events of a subscription in the order they are buffered
+func (repository *inMemoryRepository) PopBufferedEvents(ctx context.Context, subscriptionID string) ([]*models.BufferedEvent, error) {
+	repository.Lock()
+	defer repository.Unlock()
+
+	events := repository.buffers[subscriptionID]
+	delete(repository.buffers, subscriptionID)
+	if events == nil {
+		events = []*models.BufferedEvent{}
+	}
+	return events, nil
+}
+
+// CountBufferedEvents count the buffered events of a subscription
+func (repository *inMemoryRepository) CountBufferedEvents(ctx context.Context, subscriptionID string) (int, error) {
+	repository.RLock()
+	defer repository.RUnlock()
+
+	return len(repository.buffers[subscriptionID]), nil
+}
+
+// ClearBufferedEvents remove the buffered events of a subscription and return the number of removed events
+func (repository *inMemoryRepository) ClearBufferedEvents(ctx context.Context, subscriptionID string) (int, error) {
+	repository.Lock()
+	defer repository.Unlock()
+
+	n := len(repository.buffers[subscriptionID])
+	delete(repository.buffers, subscriptionID)
+	return n, nil
+}
+
+// CreateAPIKey create an api key
+func (repository *inMemoryRepository) CreateAPIKey(ctx context.Context, apiKey *models.APIKey) (*models.APIKey, error) {
+	repository.Lock()
+	defer repository.Unlock()
+
+	for _, existing := range repository.apiKeys {
+		if existing.KeyHash == apiKey.KeyHash {
+			return nil, fmt.Errorf("failed to create api key: duplicate key")
+		}
+	}
+
+	apiKey.ID = strconv.Itoa(repository.apiKeyID)
+	storedAPIKey := *apiKey
+	repository.apiKeys = append(repository.apiKeys, &storedAPIKey)
+	repository.apiKeyID++
+	log.Debug("stored api key: %s of %s", apiKey.ID, apiKey.Owner)
+	return apiKey, nil
+}
+
+// FindAPIKey find an api key by the hash of the key
+func (repository *inMemoryRepository) FindAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
 	repository.RLock()
 	defer repository.RUnlock()
 
-	subscriptionContexts := repository.db[:0]
-	for _, subscriptionContext := range repository.db {
-		if _, ok := subscriptionContext.Subscription.Filters[eventType]; ok && subscriptionContext.Subscription.SubscriptionStatus == models.Active {
-			subscriptionContexts = append(subscriptionContexts, subscriptionContext)
+	for _, apiKey := range repository.apiKeys {
+		if apiKey.KeyHash == keyHash {
+			found := *apiKey
+			return &found, nil
 		}
 	}
+	return nil, errors.NewUnknownAPIKey()
+}
 
-	return subscriptionContexts, nil
+// ListAPIKeys list the api keys
+func (repository *inMemoryRepository) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	repository.RLock()
+	defer repository.RUnlock()
+
+	apiKeys := make([]*models.APIKey, 0, len(repository.apiKeys))
+	for _, apiKey := range repository.apiKeys {
+		listed := *apiKey
+		apiKeys = append(apiKeys, &listed)
+	}
+	return apiKeys, nil
+}
+
+// DeleteAPIKey delete an api key
+func (repository *inMemoryRepository) DeleteAPIKey(ctx context.Context, id string) error {
+	repository.Lock()
+	defer repository.Unlock()
+
+	for i, apiKey := range repository.apiKeys {
+		if apiKey.ID == id {
+			repository.apiKeys = append(repository.apiKeys[:i], repository.apiKeys[i+1:]...)
+			log.Debug("deleted api key: %s", id)
+			return nil
+		}
+	}
+	return errors.NewAPIKeyNotFound(id)
+}
+
+// Ping check the connection to the repository, the in memory repository is always available
+func (repository *inMemoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close the repository, the in memory repository has no connection to close
+func (repository *inMemoryRepository) Close() error {
+	return nil
 }
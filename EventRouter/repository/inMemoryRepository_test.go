@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"github.com/FactomProject/live-feed-api/EventRouter/models"
 	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
@@ -29,7 +30,7 @@ func TestCRUD(t *testing.T) {
 			},
 		},
 	}
-	createdSubscription, err := repo.CreateSubscription(subscriptionContext)
+	createdSubscription, err := repo.CreateSubscription(context.Background(), subscriptionContext)
 
 	id := strconv.Itoa(initID)
 
@@ -37,12 +38,12 @@ func TestCRUD(t *testing.T) {
 	assert.Equal(t, id, createdSubscription.Subscription.ID)
 	assert.Equal(t, subscriptionContext.Subscription.CallbackURL, createdSubscription.Subscription.CallbackURL)
 
-	readSubscription, err := repo.ReadSubscription(subscriptionContext.Subscription.ID)
+	readSubscription, err := repo.ReadSubscription(context.Background(), subscriptionContext.Subscription.ID)
 	assert.Nil(t, err)
 	assert.Equal(t, id, readSubscription.Subscription.ID)
 	assert.Equal(t, subscriptionContext.Subscription.CallbackURL, readSubscription.Subscription.CallbackURL)
 
-	allSubscriptions, err := repo.GetActiveSubscriptions(models.NodeMessage)
+	allSubscriptions, err := repo.GetActiveSubscriptions(context.Background(), models.NodeMessage)
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(allSubscriptions))
 	assert.Equal(t, id, allSubscriptions[0].Subscription.ID)
@@ -56,15 +57,35 @@ func TestCRUD(t *testing.T) {
 		},
 	}
 
-	updatedSubscription, err := repo.UpdateSubscription(substituteSubscriptionContext)
+	updatedSubscription, err := repo.UpdateSubscription(context.Background(), substituteSubscriptionContext)
 	assert.Nil(t, err)
 	assert.Equal(t, id, updatedSubscription.Subscription.ID)
 	assert.Equal(t, substituteSubscriptionContext.Subscription.CallbackURL, updatedSubscription.Subscription.CallbackURL)
+	assert.Equal(t, int64(2), updatedSubscription.Version)
 
-	err = repo.DeleteSubscription(subscriptionContext.Subscription.ID)
+	// an update of an outdated version is a conflict
+	outdatedSubscriptionContext := &models.SubscriptionContext{Subscription: substituteSubscriptionContext.Subscription, Version: 1}
+	_, err = repo.UpdateSubscription(context.Background(), outdatedSubscriptionContext)
+	assert.IsType(t, errors.SubscriptionConflict{}, err)
+
+	// a status update changes only the status and keeps the version
+	statusSubscriptionContext := &models.SubscriptionContext{
+		Subscription: models.Subscription{ID: id, CallbackURL: "ignored", SubscriptionStatus: models.Suspended},
+		Failures:     3,
+	}
+	err = repo.UpdateSubscriptionStatus(context.Background(), statusSubscriptionContext)
+	assert.Nil(t, err)
+	readSubscription, err = repo.ReadSubscription(context.Background(), id)
 	assert.Nil(t, err)
+	assert.Equal(t, "updated-url", readSubscription.Subscription.CallbackURL)
+	assert.Equal(t, models.Suspended, readSubscription.Subscription.SubscriptionStatus)
+	assert.Equal(t, uint16(3), readSubscription.Failures)
+	assert.Equal(t, int64(2), readSubscription.Version)
 
-	unknownSubscription, err := repo.ReadSubscription(subscriptionContext.Subscription.ID)
+	err = repo.DeleteSubscription(context.Background(), subscriptionContext.Subscription.ID)
+	assert.Nil(t, err)
+
+	unknownSubscription, err := repo.ReadSubscription(context.Background(), subscriptionContext.Subscription.ID)
 	assert.IsType(t, errors.SubscriptionNotFound{}, err)
 	assert.Nil(t, unknownSubscription)
 }
@@ -82,12 +103,268 @@ func TestConcurrency(t *testing.T) {
 				},
 			}
 
-			subscriptionContext, err := repo.CreateSubscription(subscriptionContext)
+			subscriptionContext, err := repo.CreateSubscription(context.Background(), subscriptionContext)
 			assert.Nil(t, err)
 			// t.Logf("%d: created %s", x, subscriptionContext.Subscription.ID)
 		}(i)
 	}
 	wait.Wait()
 
-	assert.Equal(t, n, len(repo.db))
+	assert.Equal(t, n, len(repo.subscriptions))
+}
+
+func TestInMemoryWatch(t *testing.T) {
+	repository := NewInMemoryRepository()
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, err := repository.Watch(ctx)
+	assert.Nil(t, err)
+
+	subscriptionContext, err := repository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{CallbackURL: "url", SubscriptionStatus: models.Active},
+	})
+	assert.Nil(t, err)
+	id := subscriptionContext.Subscription.ID
+	suspended := *subscriptionContext
+	suspended.Subscription.SubscriptionStatus = models.Suspended
+	assert.Nil(t, repository.UpdateSubscriptionStatus(context.Background(), &suspended))
+	assert.Nil(t, repository.DeleteSubscription(context.Background(), id))
+
+	// the changes are queued, such that the repository doesn't wait on the watcher
+	created := <-changes
+	assert.Equal(t, models.Created, created.Type)
+	assert.Equal(t, id, created.SubscriptionID)
+	assert.EqualValues(t, 1, created.SubscriptionContext.Version)
+
+	updated := <-changes
+	assert.Equal(t, models.Updated, updated.Type)
+	assert.Equal(t, models.Suspended, updated.SubscriptionContext.Subscription.SubscriptionStatus)
+	assert.EqualValues(t, 1, updated.SubscriptionContext.Version)
+	assert.Equal(t, models.SubscriptionChange{Type: models.Deleted, SubscriptionID: id}, <-changes)
+
+	// the published subscription is a copy of the stored subscription
+	assert.False(t, created.SubscriptionContext == subscriptionContext)
+
+	cancel()
+	_, open := <-changes
+	assert.False(t, open, "changes should be closed after the watch is cancelled")
+}
+
+func TestInMemoryListSubscriptions(t *testing.T) {
+	repository := NewInMemoryRepository()
+	subscriptions := []models.Subscription{
+		{CallbackURL: "https://one.example.com/events", SubscriptionStatus: models.Active, Labels: map[string]string{"team": "explorer"}, Filters: map[models.EventType]models.Filter{models.EntryCommit: {}}},
+		{CallbackURL: "https://two.example.com/events", SubscriptionStatus: models.Suspended, Labels: map[string]string{"team": "explorer"}},
+		{CallbackURL: "https://one.example.com/other", SubscriptionStatus: models.Active, Owner: "wallet", Labels: map[string]string{"team": "wallet"}, Filters: map[models.EventType]models.Filter{models.EntryCommit: {}}},
+		{CallbackURL: "https://ONE.example.com/upper", SubscriptionStatus: models.Active},
+	}
+	for _, subscription := range subscriptions {
+		_, err := repository.CreateSubscription(context.Background(), &models.SubscriptionContext{Subscription: subscription})
+		assert.Nil(t, err)
+	}
+
+	testCases := map[string]struct {
+		Query models.SubscriptionQuery
+		IDs   []string
+		Total int
+	}{
+		"all":           {Query: models.SubscriptionQuery{}, IDs: []string{"0", "1", "2", "3"}, Total: 4},
+		"status":        {Query: models.SubscriptionQuery{Status: models.Active}, IDs: []string{"0", "2", "3"}, Total: 3},
+		"event type":    {Query: models.SubscriptionQuery{EventType: models.EntryCommit}, IDs: []string{"0", "2"}, Total: 2},
+		"callback host": {Query: models.SubscriptionQuery{CallbackHost: "one.example.com"}, IDs: []string{"0", "2", "3"}, Total: 3},
+		"owner":         {Query: models.SubscriptionQuery{Owner: "wallet"}, IDs: []string{"2"}, Total: 1},
+		"labels":        {Query: models.SubscriptionQuery{Labels: map[string]string{"team": "explorer"}}, IDs: []string{"0", "1"}, Total: 2},
+		"combined":      {Query: models.SubscriptionQuery{Status: models.Active, Labels: map[string]string{"team": "explorer"}}, IDs: []string{"0"}, Total: 1},
+		"page":          {Query: models.SubscriptionQuery{Offset: 1, Limit: 2}, IDs: []string{"1", "2"}, Total: 4},
+		"after last":    {Query: models.SubscriptionQuery{Offset: 10, Limit: 2}, IDs: []string{}, Total: 4},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			subscriptionContexts, total, err := repository.ListSubscriptions(context.Background(), &testCase.Query)
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.Total, total)
+
+			ids := make([]string, 0)
+			for _, subscriptionContext := range subscriptionContexts {
+				ids = append(ids, subscriptionContext.Subscription.ID)
+			}
+			assert.Equal(t, testCase.IDs, ids)
+		})
+	}
+}
+
+func TestInMemoryAPIKeys(t *testing.T) {
+	repository := NewInMemoryRepository()
+
+	apiKey, err := repository.CreateAPIKey(context.Background(), &models.APIKey{Owner: "explorer", Role: models.User, KeyHash: "hash"})
+	assert.Nil(t, err)
+	assert.Equal(t, "0", apiKey.ID)
+
+	_, err = repository.CreateAPIKey(context.Background(), &models.APIKey{Owner: "wallet", Role: models.User, KeyHash: "hash"})
+	assert.EqualError(t, err, "failed to create api key: duplicate key")
+
+	found, err := repository.FindAPIKey(context.Background(), "hash")
+	assert.Nil(t, err)
+	assert.Equal(t, apiKey, found)
+
+	apiKeys, err := repository.ListAPIKeys(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []*models.APIKey{apiKey}, apiKeys)
+
+	err = repository.DeleteAPIKey(context.Background(), apiKey.ID)
+	assert.Nil(t, err)
+
+	_, err = repository.FindAPIKey(context.Background(), "hash")
+	assert.IsType(t, errors.APIKeyNotFound{}, err)
+
+	err = repository.DeleteAPIKey(context.Background(), apiKey.ID)
+	assert.IsType(t, errors.APIKeyNotFound{}, err)
+}
+
+func TestInMemoryBufferedEvents(t *testing.T) {
+	repository := NewInMemoryRepository()
+
+	for _, payload := range []string{"1", "2", "3"} {
+		err := repository.BufferEvent(context.Background(), "1", &models.BufferedEvent{EventType: models.ChainCommit, Payload: []byte(payload)}, 2)
+		if payload == "3" {
+			assert.IsType(t, errors.BufferFull{}, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+
+	n, err := repository.CountBufferedEvents(context.Background(), "1")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	events, err := repository.PopBufferedEvents(context.Background(), "1")
+	assert.Nil(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "1", string(events[0].Payload))
+		assert.Equal(t, "2", string(events[1].Payload))
+	}
+
+	events, err = repository.PopBufferedEvents(context.Background(), "1")
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+
+	assert.Nil(t, repository.BufferEvent(context.Background(), "1", &models.BufferedEvent{EventType: models.ChainCommit}, 2))
+	n, err = repository.ClearBufferedEvents(context.Background(), "1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
+
+// the returned subscriptions are copies, changing them doesn't change the stored subscriptions
+func TestInMemoryDefensiveCopies(t *testing.T) {
+	repository := NewInMemoryRepository()
+	for i := 0; i < 3; i++ {
+		_, err := repository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+			Subscription: models.Subscription{
+				CallbackURL:        fmt.Sprintf("url %d", i),
+				SubscriptionStatus: models.Active,
+				Filters:            map[models.EventType]models.Filter{models.EntryCommit: {Filtering: "filtering"}},
+				Labels:             map[string]string{"team": "explorer"},
+			},
+		})
+		assert.Nil(t, err)
+	}
+
+	active, err := repository.GetActiveSubscriptions(context.Background(), models.EntryCommit)
+	assert.Nil(t, err)
+	assert.Len(t, active, 3)
+	active[0].Failures = 5
+	active[0].Subscription.Labels["team"] = "wallet"
+	active[0].Subscription.Filters[models.EntryCommit] = models.Filter{Filtering: "changed"}
+
+	// reading the active subscriptions doesn't overwrite the stored subscriptions
+	listed, total, err := repository.ListSubscriptions(context.Background(), &models.SubscriptionQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, total)
+	for i, subscriptionContext := range listed {
+		assert.Equal(t, strconv.Itoa(i), subscriptionContext.Subscription.ID)
+		assert.Equal(t, fmt.Sprintf("url %d", i), subscriptionContext.Subscription.CallbackURL)
+		assert.Zero(t, subscriptionContext.Failures)
+		assert.Equal(t, "explorer", subscriptionContext.Subscription.Labels["team"])
+		assert.Equal(t, "filtering", subscriptionContext.Subscription.Filters[models.EntryCommit].Filtering)
+	}
+
+	// the event type index follows the status and the filters of the subscription
+	suspended := *listed[1]
+	suspended.Subscription.SubscriptionStatus = models.Suspended
+	assert.Nil(t, repository.UpdateSubscriptionStatus(context.Background(), &suspended))
+	updated := *listed[2]
+	updated.Subscription.Filters = map[models.EventType]models.Filter{models.ChainCommit: {}}
+	_, err = repository.UpdateSubscription(context.Background(), &updated)
+	assert.Nil(t, err)
+
+	active, err = repository.GetActiveSubscriptions(context.Background(), models.EntryCommit)
+	assert.Nil(t, err)
+	if assert.Len(t, active, 1) {
+		assert.Equal(t, "0", active[0].Subscription.ID)
+	}
+	active, err = repository.GetActiveSubscriptions(context.Background(), models.ChainCommit)
+	assert.Nil(t, err)
+	if assert.Len(t, active, 1) {
+		assert.Equal(t, "2", active[0].Subscription.ID)
+	}
+}
+
+// the subscriptions are created, updated, read and deleted concurrently, run with -race to detect shared state
+func TestInMemoryConcurrentStress(t *testing.T) {
+	repository := NewInMemoryRepository()
+	n := 50
+	wait := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wait.Add(1)
+		go func(x int) {
+			defer wait.Done()
+			created, err := repository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+				Subscription: models.Subscription{
+					CallbackURL:        fmt.Sprintf("url %d", x),
+					SubscriptionStatus: models.Active,
+					Filters:            map[models.EventType]models.Filter{models.EntryCommit: {}},
+					Labels:             map[string]string{"worker": strconv.Itoa(x)},
+				},
+			})
+			if !assert.Nil(t, err) {
+				return
+			}
+			id := created.Subscription.ID
+
+			for j := 0; j < 20; j++ {
+				read, err := repository.ReadSubscription(context.Background(), id)
+				if !assert.Nil(t, err) {
+					return
+				}
+				read.Failures++
+				read.Subscription.Labels["round"] = strconv.Itoa(j)
+				assert.Nil(t, repository.UpdateSubscriptionStatus(context.Background(), read))
+
+				active, err := repository.GetActiveSubscriptions(context.Background(), models.EntryCommit)
+				assert.Nil(t, err)
+				for _, subscriptionContext := range active {
+					// the router changes the subscriptions it receives
+					subscriptionContext.Failures = 0
+					subscriptionContext.Subscription.Labels["seen"] = id
+				}
+				_, _, err = repository.ListSubscriptions(context.Background(), &models.SubscriptionQuery{Labels: map[string]string{"worker": strconv.Itoa(x)}})
+				assert.Nil(t, err)
+			}
+
+			if x%2 == 0 {
+				assert.Nil(t, repository.DeleteSubscription(context.Background(), id))
+			}
+		}(i)
+	}
+	wait.Wait()
+
+	active, err := repository.GetActiveSubscriptions(context.Background(), models.EntryCommit)
+	assert.Nil(t, err)
+	assert.Len(t, active, n/2)
+	for _, subscriptionContext := range active {
+		assert.EqualValues(t, 20, subscriptionContext.Failures)
+		assert.EqualValues(t, 1, subscriptionContext.Version)
+		assert.NotContains(t, subscriptionContext.Subscription.Labels, "round")
+		assert.NotContains(t, subscriptionContext.Subscription.Labels, "seen")
+	}
 }
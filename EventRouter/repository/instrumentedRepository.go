@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"github.com/FactomProject/live-feed-api/EventRouter/metrics"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"time"
+)
+
+type instrumentedRepository struct {
+	repository Repository
+}
+
+// Instrument wraps the repository to record the latency of every call with the metrics recorder
+func Instrument(repository Repository) Repository {
+	return &instrumentedRepository{repository: repository}
+}
+
+func record(operation string, start time.Time, err error) {
+	metrics.Recorder.RepositoryCalled(operation, time.Since(start), err)
+}
+
+func (instrumented *instrumentedRepository) CreateSubscription(ctx context.Context, subscription *models.SubscriptionContext) (*models.SubscriptionContext, error) {
+	start := time.Now()
+	result, err := instrumented.repository.CreateSubscription(ctx, subscription)
+	record("CreateSubscription", start, err)
+	return result, err
+}
+
+func (instrumented *instrumentedRepository) ReadSubscription(ctx context.Context, id string) (*models.SubscriptionContext, error) {
+	start := time.Now()
+	result, err := instrumented.repository.ReadSubscription(ctx, id)
+	record("ReadSubscription", start, err)
+	return result, err
+}
+
+func (instrumented *instrumentedRepository) UpdateSubscription(ctx context.Context, subscription *models.SubscriptionContext) (*models.SubscriptionContext, error) {
+	start := time.Now()
+	result, err := instrumented.repository.UpdateSubscription(ctx, subscription)
+	record("UpdateSubscription", start, err)
+	return result, err
+}
+
+func (instrumented *instrumentedRepository) UpdateSubscriptionStatus(ctx context.Context, subscription *models.SubscriptionContext) error {
+	start := time.Now()
+	err := instrumented.repository.UpdateSubscriptionStatus(ctx, subscription)
+	record("UpdateSubscriptionStatus", start, err)
+	return err
+}
+
+func (instrumented *instrumentedRepository) DeleteSubscription(ctx context.Context, id string) error {
+	start := time.Now()
+	err := instrumented.repository.DeleteSubscription(ctx, id)
+	record("DeleteSubscription", start, err)
+	return err
+}
+
+func (instrumented *instrumentedRepository) GetActiveSubscriptions(ctx context.Context, eventType models.EventType) (models.SubscriptionContexts, error) {
+	start := time.Now()
+	result, err := instrumented.repository.GetActiveSubscriptions(ctx, eventType)
+	record("GetActiveSubscriptions", start, err)
+	return result, err
+}
+
+func (instrumented *instrumentedRepository) ListSubscriptions(ctx context.Context, query *models.SubscriptionQuery) (models.SubscriptionContexts, int, error) {
+	start := time.Now()
+	result, total, err := instrumented.repository.ListSubscriptions(ctx, query)
+	record("ListSubscriptions", start, err)
+	return result, total, err
+}
+
+func (instrumented *instrumentedRepository) BufferEvent(ctx context.Context, subscriptionID string, event *models.BufferedEvent, limit int) error {
+	start := time.Now()
+	err := instrumented.repository.BufferEvent(ctx, subscriptionID, event, limit)
+	record("BufferEvent", start, err)
+	return err
+}
+
+func (instrumented *instrumentedRepository) PopBufferedEvents(ctx context.Context, subscriptionID string) ([]*models.BufferedEvent, error) {
+	start := time.Now()
+	result, err := instrumented.repository.PopBufferedEvents(ctx, subscriptionID)
+	record("PopBufferedEvents", start, err)
+	return result, err
+}
+
+func (instrumented *instrumentedRepository) CountBufferedEvents(ctx context.Context, subscriptionID string) (int, error) {
+	start := time.Now()
+	result, err := instrumented.repository.CountBufferedEvents(ctx, subscriptionID)
+	record("CountBufferedEvents", start, err)
+	return result, err
+}
+
+func (instrumented *instrumentedRepository) ClearBufferedEvents(ctx context.Context, subscriptionID string) (int, error) {
+	start := time.Now()
+	result, err := instrumented.repository.ClearBufferedEvents(ctx, subscriptionID)
+	record("ClearBufferedEvents", start, err)
+	return result, err
+}
+
+func (instrumented *instrumentedRepository) CreateAPIKey(ctx context.Context, apiKey *models.APIKey) (*models.APIKey, error) {
+	start := time.Now()
+	result, err := instrumented.repository.CreateAPIKey(ctx, apiKey)
+	record("CreateAPIKey", start, err)
+	return result, err
+}
+
+func (instrumented *instrumentedRepository) FindAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	start := time.Now()
+	result, err := instrumented.repository.FindAPIKey(ctx, keyHash)
+	record("FindAPIKey", start, err)
+	return result, err
+}
+
+func (instrumented *instrumentedRepository) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	start := time.Now()
+	result, err := instrumented.repository.ListAPIKeys(ctx)
+	record("ListAPIKeys", start, err)
+	return result, err
+}
+
+func (instrumented *instrumentedRepository) DeleteAPIKey(ctx context.Context, id string) error {
+	start := time.Now()
+	err := instrumented.repository.DeleteAPIKey(ctx, id)
+	record("DeleteAPIKey", start, err)
+	return err
+}
+
+func (instrumented *instrumentedRepository) Watch(ctx context.Context) (<-chan models.SubscriptionChange, error) {
+	start := time.Now()
+	changes, err := instrumented.repository.Watch(ctx)
+	record("Watch", start, err)
+	return changes, err
+}
+
+func (instrumented *instrumentedRepository) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := instrumented.repository.Ping(ctx)
+	record("Ping", start, err)
+	return err
+}
+
+func (instrumented *instrumentedRepository) Close() error {
+	start := time.Now()
+	err := instrumented.repository.Close()
+	record("Close", start, err)
+	return err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/metrics"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type repositoryCall struct {
+	operation string
+	failed    bool
+}
+
+// testMetrics records the calls to the repository
+type testMetrics struct {
+	calls []repositoryCall
+}
+
+func (recorder *testMetrics) EventReceived(models.EventType, string)                           {}
+func (recorder *testMetrics) EventQueueDepth(int)                                              {}
+func (recorder *testMetrics) SubscriptionQueueDepth(string, int)                               {}
+func (recorder *testMetrics) SubscriptionRemoved(string)                                       {}
+func (recorder *testMetrics) DeliveryAttempted(models.CallbackType, int, time.Duration, error) {}
+func (recorder *testMetrics) FilterExecuted(time.Duration)                                     {}
+
+func (recorder *testMetrics) RepositoryCalled(operation string, _ time.Duration, err error) {
+	recorder.calls = append(recorder.calls, repositoryCall{operation: operation, failed: err != nil})
+}
+
+func TestInstrument(t *testing.T) {
+	recorder := &testMetrics{}
+	previous := metrics.Recorder
+	metrics.Recorder = recorder
+	defer func() { metrics.Recorder = previous }()
+
+	mockRepository := &MockRepository{}
+	mockRepository.On("ReadSubscription", "id").Return(&models.SubscriptionContext{}, nil).Once()
+	mockRepository.On("ReadSubscription", "unknown").Return((*models.SubscriptionContext)(nil), fmt.Errorf("not found")).Once()
+	mockRepository.On("Ping").Return(nil).Once()
+
+	repository := Instrument(mockRepository)
+	subscriptionContext, err := repository.ReadSubscription(context.Background(), "id")
+	assert.Nil(t, err)
+	assert.NotNil(t, subscriptionContext)
+	_, err = repository.ReadSubscription(context.Background(), "unknown")
+	assert.NotNil(t, err)
+	assert.Nil(t, repository.Ping(context.Background()))
+
+	assert.Equal(t, []repositoryCall{
+		{operation: "ReadSubscription"},
+		{operation: "ReadSubscription", failed: true},
+		{operation: "Ping"},
+	}, recorder.calls)
+	mockRepository.AssertExpectations(t)
+}
@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/config"
+	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+)
+
+const (
+	createMigrationsTableSQL = `CREATE TABLE IF NOT EXISTS schema_migrations (version INT NOT NULL PRIMARY KEY, description VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP);`
+	selectMigrationsSQL      = `SELECT version, applied_at FROM schema_migrations ORDER BY version;`
+	insertMigrationSQL       = `INSERT INTO schema_migrations (version, description) VALUES(?, ?);`
+	deleteMigrationSQL       = `DELETE FROM schema_migrations WHERE version = ?`
+)
+
+// migration changes the schema of the database to the version of the migration, the down statements revert the up
+// statements. The statements are executed one by one, because not every driver supports multiple statements.
+type migration struct {
+	version     int
+	description string
+	up          []string
+	down        []string
+
+	// upgrade converts the rows after the up statements are executed, for conversions that can't be expressed in the
+	// sql of every database
+	upgrade func(tx *sql.Tx, dialect sqlDialect) error
+
+	// rebuildsTables disables the foreign keys of sqlite while the migration is executed, because a table that is
+	// referenced by other tables can't be replaced otherwise. The foreign keys are checked before the migration is
+	// committed.
+	rebuildsTables bool
+}
+
+// MigrationStatus the status of a schema migration, the applied at time is empty when the migration is not applied
+type MigrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   string
+}
+
+// Migrator applies the schema migrations of the sql database, the applied migrations are registered in the
+// schema_migrations table
+type Migrator interface {
+	// Up applies the migrations that are not applied in order and returns the applied migrations
+	Up() ([]MigrationStatus, error)
+
+	// Down reverts the last applied migration, nil is returned when no migration is applied
+	Down() (*MigrationStatus, error)
+
+	// Status returns the status of all migrations in order
+	Status() ([]MigrationStatus, error)
+
+	Close() error
+}
+
+type sqlMigrator struct {
+	db         *sql.DB
+	dialect    sqlDialect
+	migrations []migration
+}
+
+// NewMigrator create a new migrator of a mysql, postgres or sqlite database
+func NewMigrator(configuration *config.DatabaseConfig) (Migrator, error) {
+	dialect, err := newSQLDialect(configuration.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := connect(dialect, configuration)
+	if err != nil {
+		return nil, err
+	}
+	return newSQLMigrator(dialect, db), nil
+}
+
+func newSQLMigrator(dialect sqlDialect, db *sql.DB) *sqlMigrator {
+	return &sqlMigrator{db: db, dialect: dialect, migrations: dialect.migrations()}
+}
+
+// Up applies the migrations that are not applied in order, every migration is applied in its own transaction
+func (migrator *sqlMigrator) Up() ([]MigrationStatus, error) {
+	applied, err := migrator.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated []MigrationStatus
+	for _, migration := range migrator.migrations {
+		if _, ok := applied[migration.version]; ok {
+			continue
+		}
+
+		err := migrator.execute(migration, migration.up, migration.upgrade, func(tx *sql.Tx) error {
+			_, err := tx.Exec(migrator.dialect.rebind(insertMigrationSQL), migration.version, migration.description)
+			return err
+		})
+		if err != nil {
+			return migrated, err
+		}
+
+		log.Info("applied migration %d: %s", migration.version, migration.description)
+		migrated = append(migrated, MigrationStatus{Version: migration.version, Description: migration.description})
+	}
+	return migrated, nil
+}
+
+// Down reverts the last applied migration
+func (migrator *sqlMigrator) Down() (*MigrationStatus, error) {
+	applied, err := migrator.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(migrator.migrations) - 1; i >= 0; i-- {
+		migration := migrator.migrations[i]
+		appliedAt, ok := applied[migration.version]
+		if !ok {
+			continue
+		}
+
+		err := migrator.execute(migration, migration.down, nil, func(tx *sql.Tx) error {
+			_, err := tx.Exec(migrator.dialect.rebind(deleteMigrationSQL), migration.version)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		log.Info("reverted migration %d: %s", migration.version, migration.description)
+		return &MigrationStatus{Version: migration.version, Description: migration.description, AppliedAt: appliedAt}, nil
+	}
+	return nil, nil
+}
+
+// Status returns the status of all migrations
+func (migrator *sqlMigrator) Status() ([]MigrationStatus, error) {
+	applied, err := migrator.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrator.migrations))
+	for _, migration := range migrator.migrations {
+		status = append(status, MigrationStatus{Version: migration.version, Description: migration.description, AppliedAt: applied[migration.version]})
+	}
+	return status, nil
+}
+
+// Close to close the connection to the database
+func (migrator *sqlMigrator) Close() error {
+	return migrator.db.Close()
+}
+
+// applied returns the applied at time of the applied migrations by version
+func (migrator *sqlMigrator) applied() (map[int]string, error) {
+	if _, err := migrator.db.Exec(createMigrationsTableSQL); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+
+	rows, err := migrator.db.Query(migrator.dialect.rebind(selectMigrationsSQL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %v", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %v", err)
+	}
+	return applied, nil
+}
+
+// execute the statements of the migration, upgrade the rows and register the migration in one transaction, mysql commits
+// the schema changes implicitly such that a failed migration may be applied partially
+func (migrator *sqlMigrator) execute(migration migration, statements []string, upgrade func(tx *sql.Tx, dialect sqlDialect) error, register func(tx *sql.Tx) error) (err error) {
+	ctx := context.Background()
+	conn, err := migrator.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect for migration %d: %v", migration.version, err)
+	}
+	defer conn.Close()
+
+	// the foreign keys can only be disabled outside of a transaction
+	if migration.rebuildsTables {
+		if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF;`); err != nil {
+			return fmt.Errorf("failed to disable foreign keys for migration %d: %v", migration.version, err)
+		}
+		defer func() {
+			_, _ = conn.ExecContext(ctx, `PRAGMA foreign_keys = ON;`)
+		}()
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create migration transaction: %v", err)
+	}
+	// commit or rollback when there is an error
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to execute migration %d, %s: %v", migration.version, migration.description, err)
+		}
+	}
+	if upgrade != nil {
+		if err = upgrade(tx, migrator.dialect); err != nil {
+			return fmt.Errorf("failed to execute migration %d, %s: %v", migration.version, migration.description, err)
+		}
+	}
+	if migration.rebuildsTables {
+		if err = checkForeignKeys(tx); err != nil {
+			return fmt.Errorf("failed to execute migration %d, %s: %v", migration.version, migration.description, err)
+		}
+	}
+	if err = register(tx); err != nil {
+		return fmt.Errorf("failed to register migration %d: %v", migration.version, err)
+	}
+	return nil
+}
+
+// checkForeignKeys fails when a row of the sqlite database references a row that doesn't exist
+func checkForeignKeys(tx *sql.Tx) error {
+	rows, err := tx.Query(`PRAGMA foreign_key_check;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return fmt.Errorf("foreign key constraint failed")
+	}
+	return rows.Err()
+}
+
+// backfillCallbackHosts sets the callback host of the subscriptions that were created before the callback hosts were
+// stored, the hosts are derived from the callback urls like the hosts of new subscriptions
+func backfillCallbackHosts(tx *sql.Tx, dialect sqlDialect) error {
+	rows, err := tx.Query(`SELECT id, callback, callback_type FROM subscriptions;`)
+	if err != nil {
+		return fmt.Errorf("failed to read callback urls: %v", err)
+	}
+
+	var ids []int64
+	var hosts []string
+	for rows.Next() {
+		var id int64
+		subscription := &models.Subscription{}
+		if err := rows.Scan(&id, &subscription.CallbackURL, &subscription.CallbackType); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to read callback urls: %v", err)
+		}
+		ids = append(ids, id)
+		hosts = append(hosts, callbackHost(subscription))
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to read callback urls: %v", err)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read callback urls: %v", err)
+	}
+
+	for i, id := range ids {
+		if _, err := tx.Exec(dialect.rebind(`UPDATE subscriptions SET callback_host = ? WHERE id = ?;`), hosts[i], id); err != nil {
+			return fmt.Errorf("failed to set callback host of subscription %d: %v", id, err)
+		}
+	}
+	return nil
+}
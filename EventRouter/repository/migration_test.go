@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FactomProject/live-feed-api/EventRouter/config"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationsOrdered(t *testing.T) {
+	for _, database := range []string{"mysql", "postgres", "sqlite"} {
+		dialect, err := newSQLDialect(database)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		migrations := dialect.migrations()
+		assert.Len(t, migrations, len(mysqlMigrations), "%s has a different number of migrations", database)
+		for i, migration := range migrations {
+			assert.Equal(t, i+1, migration.version, "%s migration %d is out of order", database, migration.version)
+			assert.Equal(t, mysqlMigrations[i].description, migration.description, "%s migration %d differs", database, migration.version)
+			assert.NotEmpty(t, migration.up, "%s migration %d has no up statements", database, migration.version)
+			assert.NotEmpty(t, migration.down, "%s migration %d has no down statements", database, migration.version)
+		}
+	}
+}
+
+var testMigrations = []migration{
+	{version: 1, description: "create a", up: []string{`CREATE TABLE a (id INT);`}, down: []string{`DROP TABLE a;`}},
+	{version: 2, description: "create b", up: []string{`CREATE TABLE b (id INT);`, `CREATE INDEX b_id ON b (id);`}, down: []string{`DROP TABLE b;`}},
+}
+
+func testMigrateUp(t *testing.T) {
+	repository, mock := initTest(t)
+	migrator := &sqlMigrator{db: repository.db, dialect: repository.dialect, migrations: testMigrations}
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations ORDER BY version`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, "2019-10-01 12:00:00"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE b \(id INT\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX b_id ON b \(id\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations \(version, description\) VALUES\(\?, \?\)`).WithArgs(2, "create b").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	migrated, err := migrator.Up()
+	assert.Nil(t, err)
+	assert.Equal(t, []MigrationStatus{{Version: 2, Description: "create b"}}, migrated)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testMigrateUpRollbackOnFailure(t *testing.T) {
+	repository, mock := initTest(t)
+	migrator := &sqlMigrator{db: repository.db, dialect: repository.dialect, migrations: testMigrations}
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE a \(id INT\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(1, "create a").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE b \(id INT\)`).WillReturnError(fmt.Errorf("some error"))
+	mock.ExpectRollback()
+
+	migrated, err := migrator.Up()
+	assert.EqualError(t, err, "failed to execute migration 2, create b: some error")
+	assert.Equal(t, []MigrationStatus{{Version: 1, Description: "create a"}}, migrated)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testMigrateUpBackfillCallbackHosts(t *testing.T) {
+	repository, mock := initTest(t)
+	migrator := &sqlMigrator{db: repository.db, dialect: repository.dialect, migrations: []migration{
+		{version: 1, description: "add callback hosts", up: []string{`ALTER TABLE subscriptions ADD COLUMN callback_host VARCHAR(255);`}, upgrade: backfillCallbackHosts},
+	}}
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE subscriptions ADD COLUMN callback_host`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT id, callback, callback_type FROM subscriptions`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "callback", "callback_type"}).
+			AddRow(1, "https://Example.com:8443/callback", "HTTP").
+			AddRow(2, "events.jsonl", "FILE"))
+	mock.ExpectExec(`UPDATE subscriptions SET callback_host = \? WHERE id = \?`).WithArgs("example.com", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE subscriptions SET callback_host = \? WHERE id = \?`).WithArgs("", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(1, "add callback hosts").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	migrated, err := migrator.Up()
+	assert.Nil(t, err)
+	assert.Equal(t, []MigrationStatus{{Version: 1, Description: "add callback hosts"}}, migrated)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testMigrateDown(t *testing.T) {
+	repository, mock := initTest(t)
+	migrator := &sqlMigrator{db: repository.db, dialect: repository.dialect, migrations: testMigrations}
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, "2019-10-01 12:00:00").AddRow(2, "2019-10-02 12:00:00"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DROP TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations WHERE version = \?`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	reverted, err := migrator.Down()
+	assert.Nil(t, err)
+	assert.Equal(t, &MigrationStatus{Version: 2, Description: "create b", AppliedAt: "2019-10-02 12:00:00"}, reverted)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testMigrateDownNothingApplied(t *testing.T) {
+	repository, mock := initTest(t)
+	migrator := &sqlMigrator{db: repository.db, dialect: repository.dialect, migrations: testMigrations}
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+
+	reverted, err := migrator.Down()
+	assert.Nil(t, err)
+	assert.Nil(t, reverted)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSQLiteMigrate(t *testing.T) {
+	repository, cleanup := initSQLiteTest(t)
+	defer cleanup()
+	migrator := newSQLMigrator(repository.dialect, repository.db)
+
+	// the migrations are applied when the repository is opened
+	migrated, err := migrator.Up()
+	assert.Nil(t, err)
+	assert.Empty(t, migrated)
+
+	status, err := migrator.Status()
+	assert.Nil(t, err)
+	if assert.Len(t, status, len(sqliteMigrations)) {
+		for _, migration := range status {
+			assert.NotEmpty(t, migration.AppliedAt, "migration %d is not applied", migration.Version)
+		}
+	}
+
+	reverted, err := migrator.Down()
+	assert.Nil(t, err)
+	if assert.NotNil(t, reverted) {
+		assert.Equal(t, len(sqliteMigrations), reverted.Version)
+	}
+	_, err = repository.db.Exec(`SELECT COUNT(*) FROM subscription_changes`)
+	assert.NotNil(t, err, "the table of the reverted migration exists")
+
+	status, err = migrator.Status()
+	assert.Nil(t, err)
+	assert.Empty(t, status[len(status)-1].AppliedAt)
+
+	migrated, err = migrator.Up()
+	assert.Nil(t, err)
+	if assert.Len(t, migrated, 1) {
+		assert.Equal(t, len(sqliteMigrations), migrated[0].Version)
+	}
+}
+
+// the schema of the sql-schema.sql of the first release in sqlite
+var baselineSchema = []string{
+	`CREATE TABLE subscriptions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	failures INTEGER NOT NULL,
+	callback TEXT NOT NULL,
+	callback_type TEXT NOT NULL,
+	status TEXT NOT NULL,
+	info TEXT,
+	access_token TEXT,
+	username TEXT,
+	password TEXT
+);`,
+	`CREATE TABLE filters (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	subscription INTEGER REFERENCES subscriptions(id),
+	event_type TEXT NOT NULL,
+	filtering TEXT
+);`,
+	`INSERT INTO subscriptions (failures, callback, callback_type, status, info, access_token, username, password) VALUES (0, 'http://localhost/callback', 'HTTP', 'ACTIVE', '', '', '', '');`,
+	`INSERT INTO filters (subscription, event_type, filtering) VALUES (1, 'CHAIN_COMMIT', 'filtering');`,
+}
+
+// a database that is created with the schema of the first release is taken over by the migrations
+func TestSQLiteMigrateBaselineSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "live-feed")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "live-feed.db")
+
+	db, err := sqliteDialect{}.open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range baselineSchema {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("failed to create the baseline schema: %v", err)
+		}
+	}
+	_ = db.Close()
+
+	repository, err := NewSQLRepository(&config.DatabaseConfig{Database: "sqlite", ConnectionString: path, Migrate: true})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer repository.Close()
+
+	subscriptionContext, err := repository.ReadSubscription(context.Background(), "1")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "http://localhost/callback", subscriptionContext.Subscription.CallbackURL)
+	assert.Equal(t, "filtering", subscriptionContext.Subscription.Filters[models.ChainCommit].Filtering)
+	assert.EqualValues(t, 1, subscriptionContext.Version)
+
+	// the callback host of the existing subscription is derived from the callback url
+	hosted, count, err := repository.ListSubscriptions(context.Background(), &models.SubscriptionQuery{CallbackHost: "localhost"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Len(t, hosted, 1)
+
+	subscriptionContext.Subscription.Labels = map[string]string{"team": "explorer"}
+	subscriptionContext.Subscription.Filters[models.ChainCommit] = models.Filter{Filtering: "filtering", Template: "template"}
+	_, err = repository.UpdateSubscription(context.Background(), subscriptionContext)
+	assert.Nil(t, err)
+	active, err := repository.GetActiveSubscriptions(context.Background(), models.ChainCommit)
+	assert.Nil(t, err)
+	if assert.Len(t, active, 1) {
+		assert.Equal(t, "template", active[0].Subscription.Filters[models.ChainCommit].Template)
+	}
+}
+
+// every migration is reverted while the tables contain rows, the rows of the first release are kept
+func TestSQLiteMigrateDownAll(t *testing.T) {
+	repository, cleanup := initSQLiteTest(t)
+	defer cleanup()
+	migrator := newSQLMigrator(repository.dialect, repository.db)
+
+	_, err := repository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        "http://localhost/callback",
+			CallbackType:       models.HTTP,
+			SubscriptionStatus: models.Active,
+			Owner:              "explorer",
+			Filters:            map[models.EventType]models.Filter{models.ChainCommit: {Filtering: "filtering", Template: "template"}},
+			Labels:             map[string]string{"team": "explorer"},
+		},
+	})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	for version := len(sqliteMigrations); version > 1; version-- {
+		reverted, err := migrator.Down()
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, version, reverted.Version)
+	}
+
+	var callback, filtering string
+	err = repository.db.QueryRow(`SELECT callback, filtering FROM subscriptions JOIN filters ON filters.subscription = subscriptions.id`).Scan(&callback, &filtering)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost/callback", callback)
+	assert.Equal(t, "filtering", filtering)
+
+	migrated, err := migrator.Up()
+	assert.Nil(t, err)
+	assert.Len(t, migrated, len(sqliteMigrations)-1)
+	read, err := repository.ReadSubscription(context.Background(), "1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "http://localhost/callback", read.Subscription.CallbackURL)
+		assert.Equal(t, "filtering", read.Subscription.Filters[models.ChainCommit].Filtering)
+	}
+}
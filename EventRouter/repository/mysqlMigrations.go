@@ -0,0 +1,156 @@
+package repository
+
+// the schema migrations of the mysql database in order, the first migration is the schema of the first release such
+// that a database that is created with the former sql-schema.sql is taken over
+var mysqlMigrations = []migration{
+	{
+		version:     1,
+		description: "create subscriptions and filters",
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS subscriptions (
+	id SERIAL PRIMARY KEY,
+	failures int NOT NULL,
+	callback VARCHAR(2083) NOT NULL,
+	callback_type VARCHAR(25) NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	info TEXT,
+	access_token VARCHAR(255),
+	username VARCHAR(255),
+	password VARCHAR(255)
+);`,
+			`CREATE TABLE IF NOT EXISTS filters (
+	id SERIAL PRIMARY KEY,
+	subscription BIGINT(20) REFERENCES subscriptions(id),
+	event_type VARCHAR(25) NOT NULL,
+	filtering TEXT
+);`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS filters;`,
+			`DROP TABLE IF EXISTS subscriptions;`,
+		},
+	},
+	{
+		version:     2,
+		description: "add oauth2 client credentials",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN token_url VARCHAR(2083), ADD COLUMN client_id VARCHAR(255), ADD COLUMN client_secret VARCHAR(255), ADD COLUMN scopes VARCHAR(1024);`,
+			`UPDATE subscriptions SET token_url = '', client_id = '', client_secret = '', scopes = '';`,
+		},
+		down: []string{
+			`ALTER TABLE subscriptions DROP COLUMN token_url, DROP COLUMN client_id, DROP COLUMN client_secret, DROP COLUMN scopes;`,
+		},
+	},
+	{
+		version:     3,
+		description: "add callback method and headers",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN method VARCHAR(10), ADD COLUMN headers TEXT;`,
+			`UPDATE subscriptions SET method = '', headers = '';`,
+		},
+		down: []string{
+			`ALTER TABLE subscriptions DROP COLUMN method, DROP COLUMN headers;`,
+		},
+	},
+	{
+		version:     4,
+		description: "add filter templates",
+		up: []string{
+			`ALTER TABLE filters ADD COLUMN template TEXT;`,
+		},
+		down: []string{
+			`ALTER TABLE filters DROP COLUMN template;`,
+		},
+	},
+	{
+		version:     5,
+		description: "add file sinks",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN file_sink TEXT;`,
+			`UPDATE subscriptions SET file_sink = '';`,
+		},
+		down: []string{
+			`ALTER TABLE subscriptions DROP COLUMN file_sink;`,
+		},
+	},
+	{
+		version:     6,
+		description: "add callback hosts and labels",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN callback_host VARCHAR(255), ADD INDEX subscriptions_callback_host (callback_host);`,
+			`CREATE TABLE IF NOT EXISTS labels (
+	id SERIAL PRIMARY KEY,
+	subscription BIGINT(20) REFERENCES subscriptions(id),
+	name VARCHAR(63) NOT NULL,
+	value VARCHAR(255) NOT NULL,
+	INDEX (name, value)
+);`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS labels;`,
+			`ALTER TABLE subscriptions DROP COLUMN callback_host;`,
+		},
+		upgrade: backfillCallbackHosts,
+	},
+	{
+		version:     7,
+		description: "add owners and api keys",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN owner VARCHAR(255), ADD INDEX subscriptions_owner (owner);`,
+			`UPDATE subscriptions SET owner = '';`,
+			`CREATE TABLE IF NOT EXISTS api_keys (
+	id SERIAL PRIMARY KEY,
+	owner VARCHAR(255) NOT NULL,
+	role VARCHAR(20) NOT NULL,
+	key_hash CHAR(64) NOT NULL UNIQUE
+);`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS api_keys;`,
+			`ALTER TABLE subscriptions DROP COLUMN owner;`,
+		},
+	},
+	{
+		version:     8,
+		description: "add subscription versions",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN version BIGINT NOT NULL DEFAULT 1;`,
+		},
+		down: []string{
+			`ALTER TABLE subscriptions DROP COLUMN version;`,
+		},
+	},
+	{
+		version:     9,
+		description: "create buffered events",
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS buffered_events (
+	id SERIAL PRIMARY KEY,
+	subscription BIGINT(20) REFERENCES subscriptions(id),
+	event_type VARCHAR(25) NOT NULL,
+	block_height INT UNSIGNED NOT NULL,
+	payload MEDIUMBLOB NOT NULL,
+	INDEX (subscription)
+);`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS buffered_events;`,
+		},
+	},
+	{
+		version:     10,
+		description: "create subscription changes",
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS subscription_changes (
+	id SERIAL PRIMARY KEY,
+	subscription BIGINT(20) NOT NULL,
+	change_type VARCHAR(10) NOT NULL,
+	changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	INDEX (changed_at)
+);`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS subscription_changes;`,
+		},
+	},
+}
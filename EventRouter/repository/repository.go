@@ -1,12 +1,32 @@
 package repository
 
-import "github.com/FactomProject/live-feed-api/EventRouter/models"
+import (
+	"context"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+)
 
-// Repository for storing and retrieving subscriptions
+// Repository for storing and retrieving subscriptions and the api keys to manage them
 type Repository interface {
-	CreateSubscription(subscription *models.SubscriptionContext) (*models.SubscriptionContext, error)
-	ReadSubscription(id string) (*models.SubscriptionContext, error)
-	UpdateSubscription(subscription *models.SubscriptionContext) (*models.SubscriptionContext, error)
-	DeleteSubscription(id string) error
-	GetActiveSubscriptions(models.EventType) (models.SubscriptionContexts, error)
+	CreateSubscription(ctx context.Context, subscription *models.SubscriptionContext) (*models.SubscriptionContext, error)
+	ReadSubscription(ctx context.Context, id string) (*models.SubscriptionContext, error)
+	UpdateSubscription(ctx context.Context, subscription *models.SubscriptionContext) (*models.SubscriptionContext, error)
+	UpdateSubscriptionStatus(ctx context.Context, subscription *models.SubscriptionContext) error
+	DeleteSubscription(ctx context.Context, id string) error
+	GetActiveSubscriptions(ctx context.Context, eventType models.EventType) (models.SubscriptionContexts, error)
+	ListSubscriptions(ctx context.Context, query *models.SubscriptionQuery) (models.SubscriptionContexts, int, error)
+
+	// Watch returns the changes of the subscriptions in the order they are made, until the context is done and the
+	// channel is closed. Only the changes after the call are returned.
+	Watch(ctx context.Context) (<-chan models.SubscriptionChange, error)
+
+	BufferEvent(ctx context.Context, subscriptionID string, event *models.BufferedEvent, limit int) error
+	PopBufferedEvents(ctx context.Context, subscriptionID string) ([]*models.BufferedEvent, error)
+	CountBufferedEvents(ctx context.Context, subscriptionID string) (int, error)
+	ClearBufferedEvents(ctx context.Context, subscriptionID string) (int, error)
+	CreateAPIKey(ctx context.Context, apiKey *models.APIKey) (*models.APIKey, error)
+	FindAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*models.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+	Ping(ctx context.Context) error
+	Close() error
 }
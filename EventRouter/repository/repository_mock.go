@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"github.com/FactomProject/live-feed-api/EventRouter/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -11,7 +12,7 @@ type MockRepository struct {
 }
 
 // CreateSubscription create a subscription
-func (m *MockRepository) CreateSubscription(subscriptionContext *models.SubscriptionContext) (*models.SubscriptionContext, error) {
+func (m *MockRepository) CreateSubscription(ctx context.Context, subscriptionContext *models.SubscriptionContext) (*models.SubscriptionContext, error) {
 	rets := m.Called(subscriptionContext.Subscription.CallbackURL)
 	/* Since `rets.Get()` is a generic method, that returns whatever we pass to it,
 	 * we need to typecast it to the type we expect, which in this case is []*subscription
@@ -20,7 +21,7 @@ func (m *MockRepository) CreateSubscription(subscriptionContext *models.Subscrip
 }
 
 // ReadSubscription read a subscription
-func (m *MockRepository) ReadSubscription(id string) (*models.SubscriptionContext, error) {
+func (m *MockRepository) ReadSubscription(ctx context.Context, id string) (*models.SubscriptionContext, error) {
 	rets := m.Called(id)
 	/* Since `rets.Get()` is a generic method, that returns whatever we pass to it,
 	 * we need to typecast it to the type we expect, which in this case is []*subscription
@@ -29,7 +30,7 @@ func (m *MockRepository) ReadSubscription(id string) (*models.SubscriptionContex
 }
 
 // UpdateSubscription update a subscription
-func (m *MockRepository) UpdateSubscription(subscriptionContext *models.SubscriptionContext) (*models.SubscriptionContext, error) {
+func (m *MockRepository) UpdateSubscription(ctx context.Context, subscriptionContext *models.SubscriptionContext) (*models.SubscriptionContext, error) {
 	rets := m.Called(subscriptionContext.Subscription.ID)
 	/* Since `rets.Get()` is a generic method, that returns whatever we pass to it,
 	 * we need to typecast it to the type we expect, which in this case is []*subscription
@@ -37,8 +38,14 @@ func (m *MockRepository) UpdateSubscription(subscriptionContext *models.Subscrip
 	return subscriptionContext, rets.Error(1)
 }
 
+// UpdateSubscriptionStatus update the failures, status and info of a subscription
+func (m *MockRepository) UpdateSubscriptionStatus(ctx context.Context, subscriptionContext *models.SubscriptionContext) error {
+	rets := m.Called(subscriptionContext.Subscription.ID)
+	return rets.Error(0)
+}
+
 // DeleteSubscription delete a subscription
-func (m *MockRepository) DeleteSubscription(id string) error {
+func (m *MockRepository) DeleteSubscription(ctx context.Context, id string) error {
 	/* When this method is called, `m.Called` records the call, and also returns the result that we pass to it
 	 * (which you will see in the handler tests)
 	 */
@@ -48,7 +55,7 @@ func (m *MockRepository) DeleteSubscription(id string) error {
 }
 
 // GetActiveSubscriptions get active subscriptions
-func (m *MockRepository) GetActiveSubscriptions(eventType models.EventType) (models.SubscriptionContexts, error) {
+func (m *MockRepository) GetActiveSubscriptions(ctx context.Context, eventType models.EventType) (models.SubscriptionContexts, error) {
 	rets := m.Called(eventType)
 	/* Since `rets.Get()` is a generic method, that returns whatever we pass to it,
 	 * we need to typecast it to the type we expect, which in this case is []*subscription
@@ -56,6 +63,78 @@ func (m *MockRepository) GetActiveSubscriptions(eventType models.EventType) (mod
 	return rets.Get(0).(models.SubscriptionContexts), rets.Error(1)
 }
 
+// ListSubscriptions list subscriptions
+func (m *MockRepository) ListSubscriptions(ctx context.Context, query *models.SubscriptionQuery) (models.SubscriptionContexts, int, error) {
+	rets := m.Called(*query)
+	return rets.Get(0).(models.SubscriptionContexts), rets.Int(1), rets.Error(2)
+}
+
+// CreateAPIKey create an api key
+func (m *MockRepository) CreateAPIKey(ctx context.Context, apiKey *models.APIKey) (*models.APIKey, error) {
+	rets := m.Called(apiKey.Owner)
+	return apiKey, rets.Error(1)
+}
+
+// FindAPIKey find an api key by the hash of the key
+func (m *MockRepository) FindAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	rets := m.Called(keyHash)
+	return rets.Get(0).(*models.APIKey), rets.Error(1)
+}
+
+// BufferEvent buffer an event of a paused subscription
+func (m *MockRepository) BufferEvent(ctx context.Context, subscriptionID string, event *models.BufferedEvent, limit int) error {
+	rets := m.Called(subscriptionID, event, limit)
+	return rets.Error(0)
+}
+
+// PopBufferedEvents read and remove the buffered events of a subscription
+func (m *MockRepository) PopBufferedEvents(ctx context.Context, subscriptionID string) ([]*models.BufferedEvent, error) {
+	rets := m.Called(subscriptionID)
+	return rets.Get(0).([]*models.BufferedEvent), rets.Error(1)
+}
+
+// CountBufferedEvents count the buffered events of a subscription
+func (m *MockRepository) CountBufferedEvents(ctx context.Context, subscriptionID string) (int, error) {
+	rets := m.Called(subscriptionID)
+	return rets.Int(0), rets.Error(1)
+}
+
+// ClearBufferedEvents remove the buffered events of a subscription
+func (m *MockRepository) ClearBufferedEvents(ctx context.Context, subscriptionID string) (int, error) {
+	rets := m.Called(subscriptionID)
+	return rets.Int(0), rets.Error(1)
+}
+
+// ListAPIKeys list the api keys
+func (m *MockRepository) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	rets := m.Called()
+	return rets.Get(0).([]*models.APIKey), rets.Error(1)
+}
+
+// DeleteAPIKey delete an api key
+func (m *MockRepository) DeleteAPIKey(ctx context.Context, id string) error {
+	rets := m.Called(id)
+	return rets.Error(0)
+}
+
+// Watch watch the changes of the subscriptions
+func (m *MockRepository) Watch(ctx context.Context) (<-chan models.SubscriptionChange, error) {
+	rets := m.Called(ctx)
+	return rets.Get(0).(chan models.SubscriptionChange), rets.Error(1)
+}
+
+// Ping check the connection to the repository
+func (m *MockRepository) Ping(ctx context.Context) error {
+	rets := m.Called()
+	return rets.Error(0)
+}
+
+// Close close the repository
+func (m *MockRepository) Close() error {
+	rets := m.Called()
+	return rets.Error(0)
+}
+
 // InitMockRepository initialize repository
 func InitMockRepository() *MockRepository {
 	/*
@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlDialect covers the differences between the sql databases, the statements of the repository are written with ?
+// placeholders and rebound to the placeholders of the database
+type sqlDialect interface {
+	// driverName the name of the registered database/sql driver
+	driverName() string
+
+	// open the database of the data source name
+	open(dataSourceName string) (*sql.DB, error)
+
+	// versionSQL the query to select the version of the database server
+	versionSQL() string
+
+	// rebind replaces the ? placeholders of the statement with the placeholders of the database
+	rebind(statement string) string
+
+	// insert executes the insert statement with ? placeholders and returns the generated id
+	insert(ctx context.Context, executor sqlExecutor, statement string, args ...interface{}) (int64, error)
+
+	// ago returns the expression of the current time minus the duration
+	ago(duration time.Duration) string
+
+	// forUpdate returns the clause that locks the selected rows until the transaction ends
+	forUpdate() string
+
+	// migrations the schema migrations of the database in order
+	migrations() []migration
+
+	// transient reports whether the error is temporary, such that the statement may succeed when it is retried
+	transient(err error) bool
+}
+
+// sqlExecutor executes statements on the database or in a transaction
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func newSQLDialect(database string) (sqlDialect, error) {
+	switch database {
+	case "mysql":
+		return mysqlDialect{}, nil
+	case "postgres":
+		return postgresDialect{}, nil
+	case "sqlite":
+		return sqliteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unknown sql database: %s", database)
+	}
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) driverName() string {
+	return "mysql"
+}
+
+func (dialect mysqlDialect) open(dataSourceName string) (*sql.DB, error) {
+	return sql.Open(dialect.driverName(), dataSourceName)
+}
+
+func (mysqlDialect) versionSQL() string {
+	return "SELECT VERSION()"
+}
+
+func (mysqlDialect) rebind(statement string) string {
+	return statement
+}
+
+func (dialect mysqlDialect) insert(ctx context.Context, executor sqlExecutor, statement string, args ...interface{}) (int64, error) {
+	result, err := executor.ExecContext(ctx, dialect.rebind(statement), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (mysqlDialect) ago(duration time.Duration) string {
+	return fmt.Sprintf("NOW() - INTERVAL %d SECOND", int64(duration.Seconds()))
+}
+
+func (mysqlDialect) forUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (mysqlDialect) migrations() []migration {
+	return mysqlMigrations
+}
+
+// transient errors of mysql are lost connections, lock wait timeouts, deadlocks and too many connections
+func (mysqlDialect) transient(err error) bool {
+	if err == mysql.ErrInvalidConn {
+		return true
+	}
+	if mysqlError, ok := err.(*mysql.MySQLError); ok {
+		switch mysqlError.Number {
+		case 1040, 1205, 1213:
+			return true
+		}
+	}
+	return isConnectionError(err)
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) driverName() string {
+	return "postgres"
+}
+
+func (dialect postgresDialect) open(dataSourceName string) (*sql.DB, error) {
+	return sql.Open(dialect.driverName(), dataSourceName)
+}
+
+func (postgresDialect) versionSQL() string {
+	return "SELECT VERSION()"
+}
+
+// rebind replaces the placeholders with the numbered $n placeholders of postgres
+func (postgresDialect) rebind(statement string) string {
+	var builder strings.Builder
+	n := 0
+	for _, c := range statement {
+		if c != '?' {
+			builder.WriteRune(c)
+			continue
+		}
+		n++
+		builder.WriteString("$" + strconv.Itoa(n))
+	}
+	return builder.String()
+}
+
+// insert returns the generated id with a returning clause, because postgres doesn't report the last insert id
+func (dialect postgresDialect) insert(ctx context.Context, executor sqlExecutor, statement string, args ...interface{}) (int64, error) {
+	var id int64
+	statement = strings.TrimSuffix(strings.TrimSpace(statement), ";") + " RETURNING id;"
+	err := executor.QueryRowContext(ctx, dialect.rebind(statement), args...).Scan(&id)
+	return id, err
+}
+
+func (postgresDialect) ago(duration time.Duration) string {
+	return fmt.Sprintf("NOW() - INTERVAL '%d seconds'", int64(duration.Seconds()))
+}
+
+func (postgresDialect) forUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (postgresDialect) migrations() []migration {
+	return postgresMigrations
+}
+
+// transient errors of postgres are connection exceptions, serialization failures, deadlocks, too many connections and
+// a server that is starting up or shutting down
+func (postgresDialect) transient(err error) bool {
+	if pqError, ok := err.(*pq.Error); ok {
+		switch pqError.Code {
+		case "40001", "40P01", "53300", "57P03":
+			return true
+		}
+		return pqError.Code.Class() == "08"
+	}
+	return isConnectionError(err)
+}
+
+// sqliteDialect stores the subscriptions in a local database file
+type sqliteDialect struct{}
+
+func (sqliteDialect) driverName() string {
+	return "sqlite3"
+}
+
+// open the database file in write-ahead log mode, such that reading doesn't block writing. Sqlite allows one writer at a
+// time, the transactions take the write lock when they begin and wait for the lock instead of failing with a deadlock
+// when another transaction holds the lock.
+func (dialect sqliteDialect) open(dataSourceName string) (*sql.DB, error) {
+	separator := "?"
+	if strings.Contains(dataSourceName, "?") {
+		separator = "&"
+	}
+	dataSourceName = fmt.Sprintf("file:%s%s_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate&_foreign_keys=1", dataSourceName, separator, sqliteBusyTimeout/time.Millisecond)
+
+	return sql.Open(dialect.driverName(), dataSourceName)
+}
+
+func (sqliteDialect) versionSQL() string {
+	return "SELECT sqlite_version()"
+}
+
+func (sqliteDialect) rebind(statement string) string {
+	return statement
+}
+
+func (dialect sqliteDialect) insert(ctx context.Context, executor sqlExecutor, statement string, args ...interface{}) (int64, error) {
+	result, err := executor.ExecContext(ctx, dialect.rebind(statement), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (sqliteDialect) ago(duration time.Duration) string {
+	return fmt.Sprintf("datetime('now', '-%d seconds')", int64(duration.Seconds()))
+}
+
+// forUpdate is not needed for sqlite, the transactions of sqlite hold the write lock of the database from the start
+func (sqliteDialect) forUpdate() string {
+	return ""
+}
+
+func (sqliteDialect) migrations() []migration {
+	return sqliteMigrations
+}
+
+// transient errors of sqlite are a database file or table that stays locked longer than the busy timeout
+func (sqliteDialect) transient(err error) bool {
+	if sqliteError, ok := err.(sqlite3.Error); ok {
+		return sqliteError.Code == sqlite3.ErrBusy || sqliteError.Code == sqlite3.ErrLocked
+	}
+	return isConnectionError(err)
+}
+
+// the time to wait for the write lock of the sqlite database
+const sqliteBusyTimeout = 5 * time.Second
+
+// isConnectionError reports whether the connection to the database failed or was lost
+func isConnectionError(err error) bool {
+	if err == driver.ErrBadConn || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewSQLDialect(t *testing.T) {
+	dialect, err := newSQLDialect("mysql")
+	assert.Nil(t, err)
+	assert.Equal(t, "mysql", dialect.driverName())
+
+	dialect, err = newSQLDialect("postgres")
+	assert.Nil(t, err)
+	assert.Equal(t, "postgres", dialect.driverName())
+
+	dialect, err = newSQLDialect("sqlite")
+	assert.Nil(t, err)
+	assert.Equal(t, "sqlite3", dialect.driverName())
+
+	_, err = newSQLDialect("oracle")
+	assert.EqualError(t, err, "unknown sql database: oracle")
+}
+
+func TestRebind(t *testing.T) {
+	statement := `UPDATE labels SET value = ? WHERE subscription = ? AND name = ?`
+
+	assert.Equal(t, statement, mysqlDialect{}.rebind(statement))
+	assert.Equal(t, statement, sqliteDialect{}.rebind(statement))
+	assert.Equal(t, `UPDATE labels SET value = $1 WHERE subscription = $2 AND name = $3`, postgresDialect{}.rebind(statement))
+}
+
+func TestAgo(t *testing.T) {
+	assert.Equal(t, "NOW() - INTERVAL 3600 SECOND", mysqlDialect{}.ago(time.Hour))
+	assert.Equal(t, "NOW() - INTERVAL '3600 seconds'", postgresDialect{}.ago(time.Hour))
+	assert.Equal(t, "datetime('now', '-3600 seconds')", sqliteDialect{}.ago(time.Hour))
+}
+
+func TestTransient(t *testing.T) {
+	connectionError := &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}
+	for _, dialect := range []sqlDialect{mysqlDialect{}, postgresDialect{}, sqliteDialect{}} {
+		assert.True(t, dialect.transient(driver.ErrBadConn), dialect.driverName())
+		assert.True(t, dialect.transient(connectionError), dialect.driverName())
+		assert.False(t, dialect.transient(fmt.Errorf("syntax error")), dialect.driverName())
+	}
+
+	assert.True(t, mysqlDialect{}.transient(mysql.ErrInvalidConn))
+	assert.True(t, mysqlDialect{}.transient(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"}))
+	assert.False(t, mysqlDialect{}.transient(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}))
+
+	assert.True(t, postgresDialect{}.transient(&pq.Error{Code: "40P01"}))
+	assert.True(t, postgresDialect{}.transient(&pq.Error{Code: "08006"}))
+	assert.False(t, postgresDialect{}.transient(&pq.Error{Code: "23505"}))
+
+	assert.True(t, sqliteDialect{}.transient(sqlite3.Error{Code: sqlite3.ErrBusy}))
+	assert.False(t, sqliteDialect{}.transient(sqlite3.Error{Code: sqlite3.ErrConstraint}))
+}
@@ -1,77 +1,246 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"github.com/FactomProject/live-feed-api/EventRouter/config"
 	"github.com/FactomProject/live-feed-api/EventRouter/log"
 	"github.com/FactomProject/live-feed-api/EventRouter/models"
 	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
-	// import the sql driver
-	_ "github.com/go-sql-driver/mysql"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	selectSubscriptionSQL   = `SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = ?;`
-	selectSubscriptionsSQL  = `SELECT subscription, failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE event_type = ? AND status = 'ACTIVE';`
-	insertSubscriptionSQL   = `INSERT INTO subscriptions (failures, callback, callback_type, status, info, access_token, username, password) VALUES(?, ?, ?, ?, ?, ?, ?, ?);`
-	insertFilterSQL         = `INSERT INTO filters (subscription, event_type, filtering) VALUES(?, ?, ?);`
-	updateSubscriptionQuery = `UPDATE subscriptions SET failures = ?, callback = ?, callback_type = ?, status = ?, info = ?, access_token = ?, username = ?, password = ? WHERE id = ?`
-	updateFilterQuery       = `UPDATE filters SET filtering = ? WHERE subscription = ? AND event_type = ?`
+	selectSubscriptionSQL   = `SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = ?;`
+	selectSubscriptionsSQL  = `SELECT subscription, failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE event_type = ? AND status IN ('ACTIVE', 'PAUSED');`
+	insertSubscriptionSQL   = `INSERT INTO subscriptions (failures, callback, callback_host, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	insertFilterSQL         = `INSERT INTO filters (subscription, event_type, filtering, template) VALUES(?, ?, ?, ?);`
+	updateSubscriptionQuery = `UPDATE subscriptions SET %sversion = version + 1 WHERE id = ?%s`
+	updateStatusQuery       = `UPDATE subscriptions SET failures = ?, status = ?, info = ? WHERE id = ?`
+	updateFilterQuery       = `UPDATE filters SET filtering = ?, template = ? WHERE subscription = ? AND event_type = ?`
 	deleteFilterSQL         = `DELETE FROM filters WHERE subscription = ? AND event_type = ?`
 	deleteFiltersSQL        = `DELETE FROM filters WHERE subscription = ?`
 	deleteSubscriptionsSQL  = `DELETE FROM subscriptions WHERE id = ?`
+
+	selectLabelsSQL  = `SELECT subscription, name, value FROM labels WHERE subscription IN (%s);`
+	insertLabelSQL   = `INSERT INTO labels (subscription, name, value) VALUES(?, ?, ?);`
+	updateLabelQuery = `UPDATE labels SET value = ? WHERE subscription = ? AND name = ?`
+	deleteLabelSQL   = `DELETE FROM labels WHERE subscription = ? AND name = ?`
+	deleteLabelsSQL  = `DELETE FROM labels WHERE subscription = ?`
+
+	countSubscriptionsSQL      = `SELECT COUNT(*) FROM subscriptions%s;`
+	selectSubscriptionIDsSQL   = `SELECT id FROM subscriptions%s ORDER BY id LIMIT ? OFFSET ?;`
+	selectSubscriptionsByIDSQL = `SELECT subscriptions.id, failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id IN (%s) ORDER BY subscriptions.id;`
+
+	insertAPIKeySQL  = `INSERT INTO api_keys (owner, role, key_hash) VALUES(?, ?, ?);`
+	selectAPIKeySQL  = `SELECT id, owner, role FROM api_keys WHERE key_hash = ?;`
+	selectAPIKeysSQL = `SELECT id, owner, role FROM api_keys ORDER BY id;`
+	deleteAPIKeySQL  = `DELETE FROM api_keys WHERE id = ?`
+
+	lockSubscriptionSQL     = `SELECT id FROM subscriptions WHERE id = ?`
+	countBufferedEventsSQL  = `SELECT COUNT(*) FROM buffered_events WHERE subscription = ?;`
+	insertBufferedEventSQL  = `INSERT INTO buffered_events (subscription, event_type, block_height, payload) VALUES(?, ?, ?, ?);`
+	selectBufferedEventsSQL = `SELECT id, event_type, block_height, payload FROM buffered_events WHERE subscription = ? ORDER BY id;`
+	deleteBufferedEventsSQL = `DELETE FROM buffered_events WHERE subscription = ?`
+	deleteBufferedEventSQL  = `DELETE FROM buffered_events WHERE subscription = ? AND id <= ?`
+
+	insertChangeSQL     = `INSERT INTO subscription_changes (subscription, change_type) VALUES(?, ?);`
+	selectLastChangeSQL = `SELECT COALESCE(MAX(id), 0) FROM subscription_changes;`
+	selectChangesSQL    = `SELECT id, subscription, change_type FROM subscription_changes WHERE id > ? ORDER BY id;`
+	deleteChangesQuery  = `DELETE FROM subscription_changes WHERE changed_at < %s`
 )
 
-var connection *sql.DB
+const (
+	// the changes are kept for an hour, a watcher that falls further behind misses changes
+	changeRetention = time.Hour
+
+	// the interval to remove the old changes of the subscriptions
+	pruneInterval = 10 * time.Minute
 
-type sqlRepository struct{}
+	// a missing change is polled again for a minute, which is longer than the transaction that inserts the change, and
+	// at most the last maxChangeGaps missing changes are polled again
+	changeGapTimeout = time.Minute
+	maxChangeGaps    = 1000
 
-// NewSQLRepository create a new repository that uses a mysql database
-func NewSQLRepository(configuration *config.DatabaseConfig) (Repository, error) {
-	repository := &sqlRepository{}
-	return repository.connect(configuration)
+	// the statements that fail with a transient error are retried with a backoff that doubles after every attempt
+	retryAttempts = 3
+	retryBackoff  = 100 * time.Millisecond
+
+	// the database may not be available yet when the live feed starts
+	connectBackoff = time.Second
+)
+
+type sqlRepository struct {
+	db           *sql.DB
+	dialect      sqlDialect
+	pollInterval time.Duration
+	queryTimeout time.Duration
+	stopPruning  context.CancelFunc
 }
 
-func (repository *sqlRepository) connect(configuration *config.DatabaseConfig) (Repository, error) {
-	// open new connection if connection is nil or not open (if there is such a state)
-	// you can also check "once.Do" if that suits your needs better
-	if connection == nil {
-		// TODO make configurable: driverName, user, password, url
-		db, err := sql.Open("mysql", configuration.ConnectionString)
-		if err != nil {
-			return nil, fmt.Errorf("failed to connect to sql database: %v", err)
-		}
+// NewSQLRepository create a new repository that uses a mysql, postgres or sqlite database
+func NewSQLRepository(configuration *config.DatabaseConfig) (Repository, error) {
+	dialect, err := newSQLDialect(configuration.Database)
+	if err != nil {
+		return nil, err
+	}
 
-		err = db.Ping()
-		if err != nil {
-			return nil, fmt.Errorf("ping failed: %v", err)
-		}
+	db, err := connect(dialect, configuration)
+	if err != nil {
+		return nil, err
+	}
 
-		// Connect and check the server version
-		var version string
-		err = db.QueryRow("SELECT VERSION()").Scan(&version)
-		if err != nil {
-			return nil, fmt.Errorf("failed to connect to server for version: %v", err)
+	if configuration.Migrate {
+		if _, err := newSQLMigrator(dialect, db).Up(); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("failed to migrate database: %v", err)
 		}
-
-		connection = db
-		log.Info("sql repository connected to: %s", version)
 	}
+	repository := newSQLRepository(dialect, db, configuration)
 
+	// every instance prunes the change log, also when the instance doesn't watch the changes
+	ctx, cancel := context.WithCancel(context.Background())
+	repository.stopPruning = cancel
+	go repository.pruneChangesEvery(ctx, pruneInterval)
 	return repository, nil
 }
 
+func newSQLRepository(dialect sqlDialect, db *sql.DB, configuration *config.DatabaseConfig) *sqlRepository {
+	return &sqlRepository{
+		db:           db,
+		dialect:      dialect,
+		pollInterval: time.Duration(configuration.PollInterval) * time.Second,
+		queryTimeout: time.Duration(configuration.QueryTimeout) * time.Second,
+	}
+}
+
+// connect opens the pool of connections to the database, the connection is retried while the database is not available
+func connect(dialect sqlDialect, configuration *config.DatabaseConfig) (*sql.DB, error) {
+	db, err := dialect.open(configuration.ConnectionString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to sql database: %v", err)
+	}
+	db.SetMaxOpenConns(configuration.MaxOpenConnections)
+	db.SetMaxIdleConns(configuration.MaxIdleConnections)
+	db.SetConnMaxLifetime(time.Duration(configuration.ConnectionMaxLifetime) * time.Second)
+
+	err = retry(context.Background(), dialect.transient, int(configuration.ConnectRetries)+1, connectBackoff, db.Ping)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("ping failed: %v", err)
+	}
+
+	// Connect and check the server version
+	var version string
+	err = db.QueryRow(dialect.versionSQL()).Scan(&version)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to connect to server for version: %v", err)
+	}
+
+	log.Info("sql repository connected to: %s", version)
+	return db, nil
+}
+
+// rebind the placeholders of the statement to the placeholders of the database
+func (repository *sqlRepository) rebind(statement string) string {
+	return repository.dialect.rebind(statement)
+}
+
+// withTimeout limits the context to the query timeout, the context is not limited when the query timeout is 0
+func (repository *sqlRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repository.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repository.queryTimeout)
+}
+
+// retry the operation while it fails with a transient error of the database, only idempotent operations are retried
+// because an operation that fails on a lost connection may have been executed by the database
+func (repository *sqlRepository) retry(ctx context.Context, operation func() error) error {
+	return retry(ctx, repository.dialect.transient, retryAttempts, retryBackoff, operation)
+}
+
+// begin a transaction, the transaction is retried when no connection is available. The statements in the transaction
+// are not retried, because a failed transaction is rolled back.
+func (repository *sqlRepository) begin(ctx context.Context) (*sql.Tx, error) {
+	var tx *sql.Tx
+	err := repository.retry(ctx, func() (err error) {
+		tx, err = repository.db.BeginTx(ctx, nil)
+		return err
+	})
+	return tx, err
+}
+
+// query executes the query and retries the query when it fails with a transient error
+func (repository *sqlRepository) query(ctx context.Context, statement string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := repository.retry(ctx, func() (err error) {
+		rows, err = repository.db.QueryContext(ctx, statement, args...)
+		return err
+	})
+	return rows, err
+}
+
+// queryRow executes the query of a single row, scans the row in the destinations and retries the query when it fails
+// with a transient error
+func (repository *sqlRepository) queryRow(ctx context.Context, dest []interface{}, statement string, args ...interface{}) error {
+	return repository.retry(ctx, func() error {
+		return repository.db.QueryRowContext(ctx, statement, args...).Scan(dest...)
+	})
+}
+
+// exec executes an idempotent statement and retries the statement when it fails with a transient error, inserts are
+// executed without a retry
+func (repository *sqlRepository) exec(ctx context.Context, statement string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := repository.retry(ctx, func() (err error) {
+		result, err = repository.db.ExecContext(ctx, statement, args...)
+		return err
+	})
+	return result, err
+}
+
+// retry the operation with a backoff that doubles after every attempt, while the operation fails with a transient error
+// and the context is not done
+func retry(ctx context.Context, transient func(error) bool, attempts int, backoff time.Duration, operation func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := operation()
+		if err == nil || attempt >= attempts || !transient(err) {
+			return err
+		}
+
+		log.Warn("retry in %v after transient database error: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
 // Close to close the connection to the database
 func (repository *sqlRepository) Close() error {
 	log.Info("closing connection")
-	return connection.Close()
+	if repository.stopPruning != nil {
+		repository.stopPruning()
+	}
+	return repository.db.Close()
 }
 
 // CreateSubscription create a subscription
-func (repository *sqlRepository) CreateSubscription(createSubscriptionContext *models.SubscriptionContext) (subscriptionContext *models.SubscriptionContext, err error) {
-	tx, err := connection.Begin()
+func (repository *sqlRepository) CreateSubscription(ctx context.Context, createSubscriptionContext *models.SubscriptionContext) (subscriptionContext *models.SubscriptionContext, err error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := repository.begin(ctx)
 	if err != nil {
 		err = fmt.Errorf("failed to create subscription transaction: %v", err)
 		return nil, err
@@ -85,28 +254,20 @@ func (repository *sqlRepository) CreateSubscription(createSubscriptionContext *m
 		err = tx.Commit()
 	}()
 
-	// prepare statements
-	subscriptionStmt, err := tx.Prepare(insertSubscriptionSQL)
-	if err != nil {
-		err = fmt.Errorf("failed to create subscription statement: %v", err)
-		return nil, err
-	}
-
 	// insert subscription
 	createSubscription := &createSubscriptionContext.Subscription
-	result, err := subscriptionStmt.Exec(createSubscriptionContext.Failures, createSubscription.CallbackURL, createSubscription.CallbackType, createSubscription.SubscriptionStatus, createSubscription.SubscriptionInfo, createSubscription.Credentials.AccessToken, createSubscription.Credentials.BasicAuthUsername, createSubscription.Credentials.BasicAuthPassword)
+	credentials := &createSubscription.Credentials
+	headers, err := encodeHeaders(createSubscription.CallbackHeaders)
 	if err != nil {
 		err = fmt.Errorf("failed to create subscription: %v", err)
 		return nil, err
 	}
-	rows, err := result.RowsAffected()
-	if rows != 1 || err != nil {
+	fileSink, err := encodeFileSink(createSubscription.FileSink)
+	if err != nil {
 		err = fmt.Errorf("failed to create subscription: %v", err)
 		return nil, err
 	}
-
-	// extract inserted subscription id
-	id, err := result.LastInsertId()
+	id, err := repository.dialect.insert(ctx, tx, insertSubscriptionSQL, createSubscriptionContext.Failures, createSubscription.CallbackURL, callbackHost(createSubscription), createSubscription.CallbackType, createSubscription.SubscriptionStatus, createSubscription.SubscriptionInfo, credentials.AccessToken, credentials.BasicAuthUsername, credentials.BasicAuthPassword, credentials.OAuth2TokenURL, credentials.OAuth2ClientID, credentials.OAuth2ClientSecret, joinScopes(credentials.OAuth2Scopes), createSubscription.CallbackMethod, headers, fileSink, createSubscription.Owner)
 	if err != nil {
 		err = fmt.Errorf("failed to create subscription: %v", err)
 		return nil, err
@@ -117,10 +278,11 @@ func (repository *sqlRepository) CreateSubscription(createSubscriptionContext *m
 	subscriptionContext = &models.SubscriptionContext{
 		Subscription: subscription,
 		Failures:     0,
+		Version:      1,
 	}
 
 	if len(createSubscription.Filters) > 0 {
-		filterStmt, err := tx.Prepare(insertFilterSQL)
+		filterStmt, err := tx.PrepareContext(ctx, repository.rebind(insertFilterSQL))
 		if err != nil {
 			err = fmt.Errorf("failed to create subscription statement: %v", err)
 			return nil, err
@@ -128,23 +290,47 @@ func (repository *sqlRepository) CreateSubscription(createSubscriptionContext *m
 
 		// insert filters
 		for eventType, filter := range createSubscription.Filters {
-			if _, err = filterStmt.Exec(id, eventType, filter.Filtering); err != nil {
+			if _, err = filterStmt.ExecContext(ctx, id, eventType, filter.Filtering, filter.Template); err != nil {
 				err = fmt.Errorf("failed to create subscription filter: %v", err)
 				return nil, err
 			}
 		}
 	}
+
+	if len(createSubscription.Labels) > 0 {
+		labelStmt, err := tx.PrepareContext(ctx, repository.rebind(insertLabelSQL))
+		if err != nil {
+			err = fmt.Errorf("failed to create subscription statement: %v", err)
+			return nil, err
+		}
+
+		// insert labels
+		for name, value := range createSubscription.Labels {
+			if _, err = labelStmt.ExecContext(ctx, id, name, value); err != nil {
+				err = fmt.Errorf("failed to create subscription label: %v", err)
+				return nil, err
+			}
+		}
+	}
+	if _, err = tx.ExecContext(ctx, repository.rebind(insertChangeSQL), id, models.Created); err != nil {
+		err = fmt.Errorf("failed to create subscription change: %v", err)
+		return nil, err
+	}
 	log.Info("stored subscription: %v", subscriptionContext)
 	return subscriptionContext, err
 }
 
 // ReadSubscription read a subscription
-func (repository *sqlRepository) ReadSubscription(id string) (subscriptionContext *models.SubscriptionContext, err error) {
-	rows, err := connection.Query(selectSubscriptionSQL, id)
+func (repository *sqlRepository) ReadSubscription(ctx context.Context, id string) (subscriptionContext *models.SubscriptionContext, err error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := repository.query(ctx, repository.rebind(selectSubscriptionSQL), id)
 	if err != nil {
 		err = fmt.Errorf("failed to read subscription: %v", err)
 		return nil, err
 	}
+	defer rows.Close()
 
 	subscriptionContext = &models.SubscriptionContext{
 		Subscription: models.Subscription{
@@ -159,42 +345,74 @@ func (repository *sqlRepository) ReadSubscription(id string) (subscriptionContex
 	for rows.Next() {
 		found = true
 
+		var scopes string
+		var headers string
+		var fileSink string
 		var eventTypeValue sql.NullString
 		var filteringValue sql.NullString
+		var templateValue sql.NullString
 
-		err = rows.Scan(&subscriptionContext.Failures, &subscription.CallbackURL, &subscription.CallbackType, &subscription.SubscriptionStatus, &subscription.SubscriptionInfo, &subscription.Credentials.AccessToken, &subscription.Credentials.BasicAuthUsername, &subscription.Credentials.BasicAuthPassword, &eventTypeValue, &filteringValue)
+		credentials := &subscription.Credentials
+		err = rows.Scan(&subscriptionContext.Failures, &subscription.CallbackURL, &subscription.CallbackType, &subscription.SubscriptionStatus, &subscription.SubscriptionInfo, &credentials.AccessToken, &credentials.BasicAuthUsername, &credentials.BasicAuthPassword, &credentials.OAuth2TokenURL, &credentials.OAuth2ClientID, &credentials.OAuth2ClientSecret, &scopes, &subscription.CallbackMethod, &headers, &fileSink, &subscription.Owner, &subscriptionContext.Version, &eventTypeValue, &filteringValue, &templateValue)
 		if err != nil {
 			err = fmt.Errorf("failed to read subscription: %v", err)
 			return nil, err
 		}
+		credentials.OAuth2Scopes = splitScopes(scopes)
+		if subscription.CallbackHeaders, err = decodeHeaders(headers); err != nil {
+			err = fmt.Errorf("failed to read subscription: %v", err)
+			return nil, err
+		}
+		if subscription.FileSink, err = decodeFileSink(fileSink); err != nil {
+			err = fmt.Errorf("failed to read subscription: %v", err)
+			return nil, err
+		}
 
 		if eventTypeValue.Valid {
 			filter := models.Filter{}
 			if filteringValue.Valid {
 				filter.Filtering = filteringValue.String
 			}
+			if templateValue.Valid {
+				filter.Template = templateValue.String
+			}
 			eventType := models.EventType(eventTypeValue.String)
 			subscription.Filters[eventType] = filter
 		}
 	}
+	if err = rows.Err(); err != nil {
+		err = fmt.Errorf("failed to read subscription: %v", err)
+		return nil, err
+	}
 
 	if !found {
 		return nil, errors.NewSubscriptionNotFound(id)
 	}
 
+	if err = repository.readLabels(ctx, models.SubscriptionContexts{subscriptionContext}); err != nil {
+		err = fmt.Errorf("failed to read subscription: %v", err)
+		return nil, err
+	}
+
 	log.Info("read subscription: %v", subscriptionContext)
 	return subscriptionContext, err
 }
 
 // UpdateSubscription update a subscription
-func (repository *sqlRepository) UpdateSubscription(updateSubscriptionContext *models.SubscriptionContext) (subscriptionContext *models.SubscriptionContext, err error) {
+func (repository *sqlRepository) UpdateSubscription(ctx context.Context, updateSubscriptionContext *models.SubscriptionContext) (subscriptionContext *models.SubscriptionContext, err error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
 	updateSubscription := &updateSubscriptionContext.Subscription
-	oldSubscriptionContext, err := repository.ReadSubscription(updateSubscription.ID)
+	oldSubscriptionContext, err := repository.ReadSubscription(ctx, updateSubscription.ID)
 	if err != nil {
 		return nil, err
 	}
+	if updateSubscriptionContext.Version > 0 && updateSubscriptionContext.Version != oldSubscriptionContext.Version {
+		return nil, errors.NewSubscriptionConflict(updateSubscription.ID, updateSubscriptionContext.Version)
+	}
 
-	tx, err := connection.Begin()
+	tx, err := repository.begin(ctx)
 	if err != nil {
 		err = fmt.Errorf("failed to update subscription transaction: %v", err)
 		return nil, err
@@ -209,31 +427,51 @@ func (repository *sqlRepository) UpdateSubscription(updateSubscriptionContext *m
 		err = tx.Commit()
 	}()
 
-	// check if the subscription needs to be updated
-	oldSubscription := &oldSubscriptionContext.Subscription
-	if updateSubscriptionContext.Failures != oldSubscriptionContext.Failures ||
-		updateSubscription.CallbackURL != oldSubscription.CallbackURL ||
-		updateSubscription.CallbackType != oldSubscription.CallbackType ||
-		updateSubscription.SubscriptionStatus != oldSubscription.SubscriptionStatus ||
-		updateSubscription.SubscriptionInfo != oldSubscription.SubscriptionInfo ||
-		updateSubscription.Credentials.AccessToken != oldSubscription.Credentials.AccessToken ||
-		updateSubscription.Credentials.BasicAuthUsername != oldSubscription.Credentials.BasicAuthUsername ||
-		updateSubscription.Credentials.BasicAuthPassword != oldSubscription.Credentials.BasicAuthPassword {
-
-		_, err = tx.Exec(updateSubscriptionQuery, updateSubscriptionContext.Failures, updateSubscription.CallbackURL, updateSubscription.CallbackType, updateSubscription.SubscriptionStatus, updateSubscription.SubscriptionInfo, updateSubscription.Credentials.AccessToken, updateSubscription.Credentials.BasicAuthUsername, updateSubscription.Credentials.BasicAuthPassword, updateSubscription.ID)
-		if err != nil {
-			err = fmt.Errorf("failed to update subscription: %v", err)
-			return nil, err
-		}
+	// only update the columns that are changed, the version is always incremented and checked when it is given
+	columns, args, err := changedColumns(oldSubscriptionContext, updateSubscriptionContext)
+	if err != nil {
+		err = fmt.Errorf("failed to update subscription: %v", err)
+		return nil, err
+	}
+	assignments := ""
+	for _, column := range columns {
+		assignments += column + ", "
+	}
+	args = append(args, updateSubscription.ID)
+	versionCondition := ""
+	if updateSubscriptionContext.Version > 0 {
+		versionCondition = " AND version = ?"
+		args = append(args, updateSubscriptionContext.Version)
+	}
+
+	result, err := tx.ExecContext(ctx, repository.rebind(fmt.Sprintf(updateSubscriptionQuery, assignments, versionCondition)), args...)
+	if err != nil {
+		err = fmt.Errorf("failed to update subscription: %v", err)
+		return nil, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to update subscription: %v", err)
+		return nil, err
 	}
 
+	// the subscription is changed or deleted in the mean time
+	if rows == 0 && updateSubscriptionContext.Version > 0 {
+		err = errors.NewSubscriptionConflict(updateSubscription.ID, updateSubscriptionContext.Version)
+		return nil, err
+	} else if rows == 0 {
+		err = errors.NewSubscriptionNotFound(updateSubscription.ID)
+		return nil, err
+	}
+	oldSubscription := &oldSubscriptionContext.Subscription
+
 	oldFilters := oldSubscription.Filters
 	for eventType, filter := range updateSubscription.Filters {
 		// update existing filter or insert new filter
 		if oldFilter, ok := oldFilters[eventType]; ok {
 			// change update filtering, otherwise nothing changed
-			if oldFilter.Filtering != filter.Filtering {
-				_, err = tx.Exec(updateFilterQuery, filter.Filtering, updateSubscription.ID, eventType)
+			if oldFilter.Filtering != filter.Filtering || oldFilter.Template != filter.Template {
+				_, err = tx.ExecContext(ctx, repository.rebind(updateFilterQuery), filter.Filtering, filter.Template, updateSubscription.ID, eventType)
 				if err != nil {
 					err = fmt.Errorf("failed to update subscription filter: %v", err)
 					return nil, err
@@ -243,7 +481,7 @@ func (repository *sqlRepository) UpdateSubscription(updateSubscriptionContext *m
 			// keep track of filter such that removed filter can be deleted from the db
 			delete(oldFilters, eventType)
 		} else {
-			_, err = tx.Exec(insertFilterSQL, updateSubscription.ID, eventType, filter.Filtering)
+			_, err = tx.ExecContext(ctx, repository.rebind(insertFilterSQL), updateSubscription.ID, eventType, filter.Filtering, filter.Template)
 			if err != nil {
 				err = fmt.Errorf("failed to update subscription new filter: %v", err)
 				return nil, err
@@ -252,21 +490,157 @@ func (repository *sqlRepository) UpdateSubscription(updateSubscriptionContext *m
 	}
 
 	for eventType := range oldFilters {
-		_, err = tx.Exec(deleteFilterSQL, updateSubscription.ID, eventType)
+		_, err = tx.ExecContext(ctx, repository.rebind(deleteFilterSQL), updateSubscription.ID, eventType)
 		if err != nil {
 			err = fmt.Errorf("failed to update subscription removed filter: %v", err)
 			return nil, err
 		}
 	}
 
+	oldLabels := oldSubscription.Labels
+	for name, value := range updateSubscription.Labels {
+		// update existing label or insert new label
+		if oldValue, ok := oldLabels[name]; ok {
+			if oldValue != value {
+				_, err = tx.ExecContext(ctx, repository.rebind(updateLabelQuery), value, updateSubscription.ID, name)
+				if err != nil {
+					err = fmt.Errorf("failed to update subscription label: %v", err)
+					return nil, err
+				}
+			}
+
+			// keep track of label such that removed labels can be deleted from the db
+			delete(oldLabels, name)
+		} else {
+			_, err = tx.ExecContext(ctx, repository.rebind(insertLabelSQL), updateSubscription.ID, name, value)
+			if err != nil {
+				err = fmt.Errorf("failed to update subscription new label: %v", err)
+				return nil, err
+			}
+		}
+	}
+
+	for name := range oldLabels {
+		_, err = tx.ExecContext(ctx, repository.rebind(deleteLabelSQL), updateSubscription.ID, name)
+		if err != nil {
+			err = fmt.Errorf("failed to update subscription removed label: %v", err)
+			return nil, err
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, repository.rebind(insertChangeSQL), updateSubscription.ID, models.Updated); err != nil {
+		err = fmt.Errorf("failed to update subscription change: %v", err)
+		return nil, err
+	}
+
 	subscriptionContext = updateSubscriptionContext
+	subscriptionContext.Version = oldSubscriptionContext.Version + 1
 	log.Info("update subscription: %v", subscriptionContext)
 	return subscriptionContext, err
 }
 
+// UpdateSubscriptionStatus update only the failures, status and info of a subscription, which are the fields of the
+// delivery of the events. The version is not incremented, such that the updates of the users are not refused after a
+// failed delivery.
+func (repository *sqlRepository) UpdateSubscriptionStatus(ctx context.Context, subscriptionContext *models.SubscriptionContext) (err error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := repository.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription status transaction: %v", err)
+	}
+
+	// commit or rollback when there is an error
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	subscription := &subscriptionContext.Subscription
+	result, err := tx.ExecContext(ctx, repository.rebind(updateStatusQuery), subscriptionContext.Failures, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.ID)
+	if err != nil {
+		err = fmt.Errorf("failed to update subscription status: %v", err)
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to update subscription status: %v", err)
+		return err
+	}
+	if rows == 0 {
+		err = errors.NewSubscriptionNotFound(subscription.ID)
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, repository.rebind(insertChangeSQL), subscription.ID, models.Updated); err != nil {
+		err = fmt.Errorf("failed to update subscription status change: %v", err)
+		return err
+	}
+
+	log.Info("update subscription status: %s %s", subscription.ID, subscription.SubscriptionStatus)
+	return nil
+}
+
+// changedColumns returns the assignments and the values of the subscription columns that differ between the old and the
+// updated subscription
+func changedColumns(oldSubscriptionContext *models.SubscriptionContext, updateSubscriptionContext *models.SubscriptionContext) ([]string, []interface{}, error) {
+	var columns []string
+	var args []interface{}
+	set := func(changed bool, column string, value interface{}) {
+		if changed {
+			columns = append(columns, column+" = ?")
+			args = append(args, value)
+		}
+	}
+
+	oldSubscription := &oldSubscriptionContext.Subscription
+	updateSubscription := &updateSubscriptionContext.Subscription
+	oldCredentials := &oldSubscription.Credentials
+	credentials := &updateSubscription.Credentials
+
+	headers, err := encodeHeaders(updateSubscription.CallbackHeaders)
+	if err != nil {
+		return nil, nil, err
+	}
+	oldHeaders, err := encodeHeaders(oldSubscription.CallbackHeaders)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileSink, err := encodeFileSink(updateSubscription.FileSink)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	set(updateSubscriptionContext.Failures != oldSubscriptionContext.Failures, "failures", updateSubscriptionContext.Failures)
+	set(updateSubscription.CallbackURL != oldSubscription.CallbackURL, "callback", updateSubscription.CallbackURL)
+	set(callbackHost(updateSubscription) != callbackHost(oldSubscription), "callback_host", callbackHost(updateSubscription))
+	set(updateSubscription.CallbackType != oldSubscription.CallbackType, "callback_type", updateSubscription.CallbackType)
+	set(updateSubscription.SubscriptionStatus != oldSubscription.SubscriptionStatus, "status", updateSubscription.SubscriptionStatus)
+	set(updateSubscription.SubscriptionInfo != oldSubscription.SubscriptionInfo, "info", updateSubscription.SubscriptionInfo)
+	set(credentials.AccessToken != oldCredentials.AccessToken, "access_token", credentials.AccessToken)
+	set(credentials.BasicAuthUsername != oldCredentials.BasicAuthUsername, "username", credentials.BasicAuthUsername)
+	set(credentials.BasicAuthPassword != oldCredentials.BasicAuthPassword, "password", credentials.BasicAuthPassword)
+	set(credentials.OAuth2TokenURL != oldCredentials.OAuth2TokenURL, "token_url", credentials.OAuth2TokenURL)
+	set(credentials.OAuth2ClientID != oldCredentials.OAuth2ClientID, "client_id", credentials.OAuth2ClientID)
+	set(credentials.OAuth2ClientSecret != oldCredentials.OAuth2ClientSecret, "client_secret", credentials.OAuth2ClientSecret)
+	set(joinScopes(credentials.OAuth2Scopes) != joinScopes(oldCredentials.OAuth2Scopes), "scopes", joinScopes(credentials.OAuth2Scopes))
+	set(updateSubscription.CallbackMethod != oldSubscription.CallbackMethod, "method", updateSubscription.CallbackMethod)
+	set(headers != oldHeaders, "headers", headers)
+	set(updateSubscription.FileSink != oldSubscription.FileSink, "file_sink", fileSink)
+	return columns, args, nil
+}
+
 // DeleteSubscription delete a subscription
-func (repository *sqlRepository) DeleteSubscription(id string) (err error) {
-	tx, err := connection.Begin()
+func (repository *sqlRepository) DeleteSubscription(ctx context.Context, id string) (err error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := repository.begin(ctx)
 	if err != nil {
 		err = fmt.Errorf("failed to delete subscription: %v", err)
 		return err
@@ -281,13 +655,31 @@ func (repository *sqlRepository) DeleteSubscription(id string) (err error) {
 		err = tx.Commit()
 	}()
 
-	_, err = tx.Exec(deleteFiltersSQL, id)
+	_, err = tx.ExecContext(ctx, repository.rebind(deleteFiltersSQL), id)
+	if err != nil {
+		err = fmt.Errorf("failed to delete subscription: %v", err)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, repository.rebind(deleteLabelsSQL), id)
+	if err != nil {
+		err = fmt.Errorf("failed to delete subscription: %v", err)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, repository.rebind(deleteBufferedEventsSQL), id)
 	if err != nil {
 		err = fmt.Errorf("failed to delete subscription: %v", err)
 		return err
 	}
 
-	_, err = tx.Exec(deleteSubscriptionsSQL, id)
+	_, err = tx.ExecContext(ctx, repository.rebind(deleteSubscriptionsSQL), id)
+	if err != nil {
+		err = fmt.Errorf("failed to delete subscription: %v", err)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, repository.rebind(insertChangeSQL), id, models.Deleted)
 	if err != nil {
 		err = fmt.Errorf("failed to delete subscription: %v", err)
 		return err
@@ -297,57 +689,683 @@ func (repository *sqlRepository) DeleteSubscription(id string) (err error) {
 	return err
 }
 
-// GetActiveSubscriptions retrieve all active subscriptions
-func (repository *sqlRepository) GetActiveSubscriptions(eventType models.EventType) (subscriptionContexts models.SubscriptionContexts, err error) {
-	subContexts := make(map[string]*models.SubscriptionContext)
+// GetActiveSubscriptions retrieve all subscriptions that receive events, the active and the paused subscriptions. The
+// labels are not read, because the subscriptions are read for every event and the events are routed without the labels.
+func (repository *sqlRepository) GetActiveSubscriptions(ctx context.Context, eventType models.EventType) (subscriptionContexts models.SubscriptionContexts, err error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := repository.query(ctx, repository.rebind(selectSubscriptionsSQL), eventType)
+	if err != nil {
+		err = fmt.Errorf("failed to get subscriptions: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
 
-	rows, err := connection.Query(selectSubscriptionsSQL, eventType)
+	subscriptionContexts, err = scanSubscriptions(rows)
 	if err != nil {
 		err = fmt.Errorf("failed to get subscriptions: %v", err)
 		return nil, err
 	}
 
+	log.Debug("get subscriptions: %v", subscriptionContexts)
+	return subscriptionContexts, err
+}
+
+// ListSubscriptions retrieve a page of the subscriptions that match the query and the total number of matching subscriptions
+func (repository *sqlRepository) ListSubscriptions(ctx context.Context, query *models.SubscriptionQuery) (subscriptionContexts models.SubscriptionContexts, total int, err error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	conditions, args := queryConditions(query)
+
+	err = repository.queryRow(ctx, []interface{}{&total}, repository.rebind(fmt.Sprintf(countSubscriptionsSQL, conditions)), args...)
+	if err != nil {
+		err = fmt.Errorf("failed to list subscriptions: %v", err)
+		return nil, 0, err
+	}
+
+	// mysql doesn't support an offset without a limit
+	limit := query.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+
+	// select the ids of the page first, because the subscriptions are joined with multiple filters
+	ids, err := repository.selectSubscriptionIDs(ctx, fmt.Sprintf(selectSubscriptionIDsSQL, conditions), append(args, limit, query.Offset)...)
+	if err != nil {
+		err = fmt.Errorf("failed to list subscriptions: %v", err)
+		return nil, 0, err
+	}
+
+	subscriptionContexts = make(models.SubscriptionContexts, 0, len(ids))
+	if len(ids) == 0 {
+		return subscriptionContexts, total, nil
+	}
+
+	rows, err := repository.query(ctx, repository.rebind(fmt.Sprintf(selectSubscriptionsByIDSQL, placeholders(len(ids)))), ids...)
+	if err != nil {
+		err = fmt.Errorf("failed to list subscriptions: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	subscriptionContexts, err = scanSubscriptions(rows)
+	if err != nil {
+		err = fmt.Errorf("failed to list subscriptions: %v", err)
+		return nil, 0, err
+	}
+
+	if err = repository.readLabels(ctx, subscriptionContexts); err != nil {
+		err = fmt.Errorf("failed to list subscriptions: %v", err)
+		return nil, 0, err
+	}
+
+	log.Debug("list subscriptions: %v", subscriptionContexts)
+	return subscriptionContexts, total, nil
+}
+
+// selectSubscriptionIDs reads the ids of a page of subscriptions
+func (repository *sqlRepository) selectSubscriptionIDs(ctx context.Context, statement string, args ...interface{}) ([]interface{}, error) {
+	rows, err := repository.query(ctx, repository.rebind(statement), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []interface{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Watch polls the change log of the subscriptions, such that also the changes of other instances are returned
+func (repository *sqlRepository) Watch(ctx context.Context) (<-chan models.SubscriptionChange, error) {
+	if repository.pollInterval <= 0 {
+		return nil, fmt.Errorf("failed to watch subscriptions: polling is disabled")
+	}
+
+	queryCtx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	cursor := newChangeCursor()
+	if err := repository.queryRow(queryCtx, []interface{}{&cursor.lastID}, repository.rebind(selectLastChangeSQL)); err != nil {
+		return nil, fmt.Errorf("failed to watch subscriptions: %v", err)
+	}
+
+	changes := make(chan models.SubscriptionChange)
+	go repository.pollChanges(ctx, cursor, changes)
+	return changes, nil
+}
+
+// pollChanges sends the changes after the cursor to the channel every poll interval until the context is done
+func (repository *sqlRepository) pollChanges(ctx context.Context, cursor *changeCursor, changes chan<- models.SubscriptionChange) {
+	defer close(changes)
+	ticker := time.NewTicker(repository.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if err := repository.sendChanges(ctx, cursor, changes); err != nil {
+			log.Error("failed to poll subscription changes: %v", err)
+		}
+	}
+}
+
+// pruneChangesEvery removes the changes that are older than the retention every interval until the context is done
+func (repository *sqlRepository) pruneChangesEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			repository.pruneChanges(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pruneChanges removes the changes that are older than the retention
+func (repository *sqlRepository) pruneChanges(ctx context.Context) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := repository.exec(ctx, fmt.Sprintf(deleteChangesQuery, repository.dialect.ago(changeRetention))); err != nil {
+		log.Error("failed to remove old subscription changes: %v", err)
+	}
+}
+
+// sendChanges sends the changes after the cursor and moves the cursor past the sent changes
+func (repository *sqlRepository) sendChanges(ctx context.Context, cursor *changeCursor, changes chan<- models.SubscriptionChange) error {
+	type changeEntry struct {
+		id     int64
+		change models.SubscriptionChange
+	}
+
+	entries, err := func() ([]changeEntry, error) {
+		ctx, cancel := repository.withTimeout(ctx)
+		defer cancel()
+
+		rows, err := repository.query(ctx, repository.rebind(selectChangesSQL), cursor.from(time.Now()))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var entries []changeEntry
+		for rows.Next() {
+			var entry changeEntry
+			if err := rows.Scan(&entry.id, &entry.change.SubscriptionID, &entry.change.Type); err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+		return entries, rows.Err()
+	}()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !cursor.pending(entry.id) {
+			continue
+		}
+
+		change := entry.change
+		if change.Type != models.Deleted {
+			change.SubscriptionContext, err = repository.ReadSubscription(ctx, change.SubscriptionID)
+			if _, ok := err.(errors.SubscriptionNotFound); ok {
+				// the subscription is deleted after the change, which is sent by the change that follows
+				cursor.advance(entry.id, time.Now())
+				continue
+			} else if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case changes <- change:
+			cursor.advance(entry.id, time.Now())
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// changeCursor is the position of a watch in the change log. The id of a change is assigned when the change is
+// inserted, but the change is only visible when its transaction commits, such that a change with a lower id can
+// appear after a change with a higher id. The missing ids below the last id are polled again until the change
+// appears or the gap expires, the ids of rolled back transactions never appear.
+type changeCursor struct {
+	lastID int64
+	gaps   map[int64]time.Time
+}
+
+func newChangeCursor() *changeCursor {
+	return &changeCursor{gaps: make(map[int64]time.Time)}
+}
+
+// from removes the expired gaps and returns the id after which the changes are polled
+func (cursor *changeCursor) from(now time.Time) int64 {
+	from := cursor.lastID
+	for id, expires := range cursor.gaps {
+		if now.After(expires) {
+			delete(cursor.gaps, id)
+		} else if id-1 < from {
+			from = id - 1
+		}
+	}
+	return from
+}
+
+// pending returns whether the change is not sent yet
+func (cursor *changeCursor) pending(id int64) bool {
+	if id > cursor.lastID {
+		return true
+	}
+	_, ok := cursor.gaps[id]
+	return ok
+}
+
+// advance moves the cursor past the sent change, the skipped ids are gaps of changes that may still appear
+func (cursor *changeCursor) advance(id int64, now time.Time) {
+	if id <= cursor.lastID {
+		delete(cursor.gaps, id)
+		return
+	}
+	missing := cursor.lastID + 1
+	if id-missing > maxChangeGaps {
+		missing = id - maxChangeGaps
+	}
+	for ; missing < id; missing++ {
+		cursor.gaps[missing] = now.Add(changeGapTimeout)
+	}
+	cursor.lastID = id
+}
+
+// queryConditions creates the where clause of the query
+func queryConditions(query *models.SubscriptionQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if query.Owner != "" {
+		conditions = append(conditions, "owner = ?")
+		args = append(args, query.Owner)
+	}
+
+	if query.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, query.Status)
+	}
+	if query.EventType != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM filters WHERE filters.subscription = subscriptions.id AND filters.event_type = ?)")
+		args = append(args, query.EventType)
+	}
+	if query.CallbackHost != "" {
+		conditions = append(conditions, "callback_host = ?")
+		args = append(args, strings.ToLower(query.CallbackHost))
+	}
+
+	// sort the labels to create the same query for the same labels
+	names := make([]string, 0, len(query.Labels))
+	for name := range query.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM labels WHERE labels.subscription = subscriptions.id AND labels.name = ? AND labels.value = ?)")
+		args = append(args, name, query.Labels[name])
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// scanSubscriptions reads the rows of subscriptions joined with their filters, the order of the subscriptions is kept
+func scanSubscriptions(rows *sql.Rows) (models.SubscriptionContexts, error) {
+	subscriptionContexts := make(models.SubscriptionContexts, 0)
+	subContexts := make(map[string]*models.SubscriptionContext)
+
 	for rows.Next() {
-		var eventType models.EventType
 		subscriptionContext := &models.SubscriptionContext{
 			Subscription: models.Subscription{
 				Filters: make(map[models.EventType]models.Filter),
 			},
 		}
 		subscription := &subscriptionContext.Subscription
-		filter := models.Filter{}
 
+		var scopes string
+		var headers string
+		var fileSink string
 		var eventTypeValue sql.NullString
 		var filteringValue sql.NullString
+		var templateValue sql.NullString
 
-		err = rows.Scan(&subscription.ID, &subscriptionContext.Failures, &subscription.CallbackURL, &subscription.CallbackType, &subscription.SubscriptionStatus, &subscription.SubscriptionInfo, &subscription.Credentials.AccessToken, &subscription.Credentials.BasicAuthUsername, &subscription.Credentials.BasicAuthPassword, &eventTypeValue, &filteringValue)
+		credentials := &subscription.Credentials
+		err := rows.Scan(&subscription.ID, &subscriptionContext.Failures, &subscription.CallbackURL, &subscription.CallbackType, &subscription.SubscriptionStatus, &subscription.SubscriptionInfo, &credentials.AccessToken, &credentials.BasicAuthUsername, &credentials.BasicAuthPassword, &credentials.OAuth2TokenURL, &credentials.OAuth2ClientID, &credentials.OAuth2ClientSecret, &scopes, &subscription.CallbackMethod, &headers, &fileSink, &subscription.Owner, &subscriptionContext.Version, &eventTypeValue, &filteringValue, &templateValue)
 		if err != nil {
-			err = fmt.Errorf("failed to get subscriptions: %v", err)
 			return nil, err
 		}
 
+		// add the filter to the subscription that is already read
+		if existing, ok := subContexts[subscription.ID]; ok {
+			subscriptionContext = existing
+		} else {
+			credentials.OAuth2Scopes = splitScopes(scopes)
+			if subscription.CallbackHeaders, err = decodeHeaders(headers); err != nil {
+				return nil, err
+			}
+			if subscription.FileSink, err = decodeFileSink(fileSink); err != nil {
+				return nil, err
+			}
+			subContexts[subscription.ID] = subscriptionContext
+			subscriptionContexts = append(subscriptionContexts, subscriptionContext)
+		}
+
 		if eventTypeValue.Valid {
 			filter := models.Filter{}
 			if filteringValue.Valid {
 				filter.Filtering = filteringValue.String
 			}
+			if templateValue.Valid {
+				filter.Template = templateValue.String
+			}
 			eventType := models.EventType(eventTypeValue.String)
-			subscription.Filters[eventType] = filter
+			subscriptionContext.Subscription.Filters[eventType] = filter
 		}
+	}
 
-		if _, ok := subContexts[subscription.ID]; !ok {
-			subContexts[subscription.ID] = subscriptionContext
+	return subscriptionContexts, rows.Err()
+}
+
+// readLabels reads the labels of the subscriptions
+func (repository *sqlRepository) readLabels(ctx context.Context, subscriptionContexts models.SubscriptionContexts) error {
+	if len(subscriptionContexts) == 0 {
+		return nil
+	}
+
+	subContexts := make(map[string]*models.SubscriptionContext, len(subscriptionContexts))
+	ids := make([]interface{}, 0, len(subscriptionContexts))
+	for _, subscriptionContext := range subscriptionContexts {
+		subContexts[subscriptionContext.Subscription.ID] = subscriptionContext
+		ids = append(ids, subscriptionContext.Subscription.ID)
+	}
+
+	rows, err := repository.query(ctx, repository.rebind(fmt.Sprintf(selectLabelsSQL, placeholders(len(ids)))), ids...)
+	if err != nil {
+		return fmt.Errorf("failed to read labels: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name, value string
+		if err := rows.Scan(&id, &name, &value); err != nil {
+			return fmt.Errorf("failed to read labels: %v", err)
 		}
 
-		subContexts[subscription.ID].Subscription.Filters[eventType] = filter
+		subscription := &subContexts[id].Subscription
+		if subscription.Labels == nil {
+			subscription.Labels = make(map[string]string)
+		}
+		subscription.Labels[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read labels: %v", err)
 	}
+	return nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// BufferEvent buffer an event of a paused subscription, the event is not buffered when the buffer contains the limit
+func (repository *sqlRepository) BufferEvent(ctx context.Context, subscriptionID string, event *models.BufferedEvent, limit int) (err error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
 
-	// preallocate memory for the slice
-	subscriptionContexts = make(models.SubscriptionContexts, 0, len(subContexts))
-	for _, s := range subContexts {
-		subscriptionContexts = append(subscriptionContexts, s)
+	tx, err := repository.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to buffer event: %v", err)
 	}
 
-	log.Debug("get subscriptions: %v", subscriptionContexts)
-	return subscriptionContexts, err
+	// commit or rollback when there is an error
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	// lock the subscription, such that the events of the subscription are counted and buffered one at a time
+	var id string
+	err = tx.QueryRowContext(ctx, repository.rebind(lockSubscriptionSQL+repository.dialect.forUpdate()), subscriptionID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return errors.NewSubscriptionNotFound(subscriptionID)
+	} else if err != nil {
+		return fmt.Errorf("failed to buffer event: %v", err)
+	}
+
+	var n int
+	if err = tx.QueryRowContext(ctx, repository.rebind(countBufferedEventsSQL), subscriptionID).Scan(&n); err != nil {
+		return fmt.Errorf("failed to buffer event: %v", err)
+	}
+	if n >= limit {
+		return errors.NewBufferFull(subscriptionID, limit)
+	}
+
+	// the insert is not retried, a connection that fails after the commit would buffer the event twice
+	event.ID, err = repository.dialect.insert(ctx, tx, insertBufferedEventSQL, subscriptionID, event.EventType, event.BlockHeight, event.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to buffer event: %v", err)
+	}
+	log.Debug("buffered event %d of subscription %s", event.ID, subscriptionID)
+	return nil
+}
+
+// PopBufferedEvents read and remove the buffered events of a subscription in the order they are buffered
+func (repository *sqlRepository) PopBufferedEvents(ctx context.Context, subscriptionID string) (events []*models.BufferedEvent, err error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := repository.begin(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to pop buffered events: %v", err)
+		return nil, err
+	}
+
+	// commit or rollback when there is an error
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	rows, err := tx.QueryContext(ctx, repository.rebind(selectBufferedEventsSQL), subscriptionID)
+	if err != nil {
+		err = fmt.Errorf("failed to pop buffered events: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events = make([]*models.BufferedEvent, 0)
+	for rows.Next() {
+		event := &models.BufferedEvent{}
+		if err = rows.Scan(&event.ID, &event.EventType, &event.BlockHeight, &event.Payload); err != nil {
+			err = fmt.Errorf("failed to pop buffered events: %v", err)
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		err = fmt.Errorf("failed to pop buffered events: %v", err)
+		return nil, err
+	}
+	if len(events) == 0 {
+		return events, nil
+	}
+
+	// only the read events are removed, events that are buffered in the mean time stay in the buffer
+	_, err = tx.ExecContext(ctx, repository.rebind(deleteBufferedEventSQL), subscriptionID, events[len(events)-1].ID)
+	if err != nil {
+		err = fmt.Errorf("failed to pop buffered events: %v", err)
+		return nil, err
+	}
+
+	log.Info("popped %d buffered events of subscription %s", len(events), subscriptionID)
+	return events, nil
+}
+
+// CountBufferedEvents count the buffered events of a subscription
+func (repository *sqlRepository) CountBufferedEvents(ctx context.Context, subscriptionID string) (int, error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	var n int
+	if err := repository.queryRow(ctx, []interface{}{&n}, repository.rebind(countBufferedEventsSQL), subscriptionID); err != nil {
+		return 0, fmt.Errorf("failed to count buffered events: %v", err)
+	}
+	return n, nil
+}
+
+// ClearBufferedEvents remove the buffered events of a subscription and return the number of removed events
+func (repository *sqlRepository) ClearBufferedEvents(ctx context.Context, subscriptionID string) (int, error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	result, err := repository.exec(ctx, repository.rebind(deleteBufferedEventsSQL), subscriptionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear buffered events: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear buffered events: %v", err)
+	}
+	log.Info("cleared %d buffered events of subscription %s", rows, subscriptionID)
+	return int(rows), nil
+}
+
+// CreateAPIKey create an api key
+func (repository *sqlRepository) CreateAPIKey(ctx context.Context, apiKey *models.APIKey) (*models.APIKey, error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	// the insert is not retried, a connection that fails after the commit would create the api key twice
+	id, err := repository.dialect.insert(ctx, repository.db, insertAPIKeySQL, apiKey.Owner, apiKey.Role, apiKey.KeyHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %v", err)
+	}
+
+	apiKey.ID = strconv.FormatInt(id, 10)
+	log.Info("stored api key: %s of %s", apiKey.ID, apiKey.Owner)
+	return apiKey, nil
+}
+
+// FindAPIKey find an api key by the hash of the key
+func (repository *sqlRepository) FindAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	apiKey := &models.APIKey{KeyHash: keyHash}
+	err := repository.queryRow(ctx, []interface{}{&apiKey.ID, &apiKey.Owner, &apiKey.Role}, repository.rebind(selectAPIKeySQL), keyHash)
+	if err == sql.ErrNoRows {
+		return nil, errors.NewUnknownAPIKey()
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to find api key: %v", err)
+	}
+	return apiKey, nil
+}
+
+// ListAPIKeys list the api keys
+func (repository *sqlRepository) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := repository.query(ctx, repository.rebind(selectAPIKeysSQL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %v", err)
+	}
+	defer rows.Close()
+
+	apiKeys := make([]*models.APIKey, 0)
+	for rows.Next() {
+		apiKey := &models.APIKey{}
+		if err := rows.Scan(&apiKey.ID, &apiKey.Owner, &apiKey.Role); err != nil {
+			return nil, fmt.Errorf("failed to list api keys: %v", err)
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %v", err)
+	}
+	return apiKeys, nil
+}
+
+// DeleteAPIKey delete an api key
+func (repository *sqlRepository) DeleteAPIKey(ctx context.Context, id string) error {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	result, err := repository.exec(ctx, repository.rebind(deleteAPIKeySQL), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete api key: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete api key: %v", err)
+	}
+	if rows == 0 {
+		return errors.NewAPIKeyNotFound(id)
+	}
+	log.Info("deleted api key: %s", id)
+	return nil
+}
+
+// Ping check the connection to the database
+func (repository *sqlRepository) Ping(ctx context.Context) error {
+	ctx, cancel := repository.withTimeout(ctx)
+	defer cancel()
+
+	if err := repository.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %v", err)
+	}
+	return nil
+}
+
+// scopes are stored space separated, which is the notation of the oauth2 scope parameter
+func joinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+func splitScopes(scopes string) []string {
+	if scopes == "" {
+		return nil
+	}
+	return strings.Fields(scopes)
+}
+
+// headers are stored as json object
+func encodeHeaders(headers map[string]string) (string, error) {
+	if len(headers) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode headers: %v", err)
+	}
+	return string(encoded), nil
+}
+
+func decodeHeaders(headers string) (map[string]string, error) {
+	if headers == "" {
+		return nil, nil
+	}
+	decoded := make(map[string]string)
+	if err := json.Unmarshal([]byte(headers), &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode headers: %v", err)
+	}
+	return decoded, nil
+}
+
+// the file sink settings are stored as json object
+func encodeFileSink(fileSink models.FileSink) (string, error) {
+	if fileSink == (models.FileSink{}) {
+		return "", nil
+	}
+	encoded, err := json.Marshal(fileSink)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode file sink: %v", err)
+	}
+	return string(encoded), nil
+}
+
+func decodeFileSink(fileSink string) (models.FileSink, error) {
+	decoded := models.FileSink{}
+	if fileSink == "" {
+		return decoded, nil
+	}
+	if err := json.Unmarshal([]byte(fileSink), &decoded); err != nil {
+		return decoded, fmt.Errorf("failed to decode file sink: %v", err)
+	}
+	return decoded, nil
 }
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+	"database/sql/driver"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/FactomProject/live-feed-api/EventRouter/config"
@@ -9,30 +11,113 @@ import (
 	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
 	_ "github.com/proullon/ramsql/driver"
 	"github.com/stretchr/testify/assert"
+	"math"
+	"net"
+	"regexp"
 	"testing"
+	"time"
 )
 
+// the sql repository tests run against the dialect of every sql database
+var testDatabase = "mysql"
+
+func TestSQLRepository(t *testing.T) {
+	testCases := map[string]func(*testing.T){
+		"ReadSubscription":                          testReadSubscription,
+		"ReadSubscriptionUnknownId":                 testReadSubscriptionUnknownId,
+		"ReadSubscriptionWithFilterNil":             testReadSubscriptionWithFilterNil,
+		"ReadSubscriptionTimeout":                   testReadSubscriptionTimeout,
+		"ReadSubscriptionOAuth2ClientCredentials":   testReadSubscriptionOAuth2ClientCredentials,
+		"CreateSubscriptionCallbackHeaders":         testCreateSubscriptionCallbackHeaders,
+		"ReadSubscriptionCallbackHeaders":           testReadSubscriptionCallbackHeaders,
+		"CreateSubscriptionFileSink":                testCreateSubscriptionFileSink,
+		"ReadSubscriptionFileSink":                  testReadSubscriptionFileSink,
+		"CreateSubscription":                        testCreateSubscription,
+		"CreateSubscriptionAddFilter":               testCreateSubscriptionAddFilter,
+		"CreateSubscriptionRollbackOnFailure":       testCreateSubscriptionRollbackOnFailure,
+		"UpdateSubscription":                        testUpdateSubscription,
+		"UpdateSubscriptionVersionConflict":         testUpdateSubscriptionVersionConflict,
+		"UpdateSubscriptionStatus":                  testUpdateSubscriptionStatus,
+		"UpdateSubscriptionAddFilter":               testUpdateSubscriptionAddFilter,
+		"UpdateSubscriptionUpdateFilter":            testUpdateSubscriptionUpdateFilter,
+		"UpdateSubscriptionDeleteFilter":            testUpdateSubscriptionDeleteFilter,
+		"UpdateSubscriptionRollbackOnUpdateFailure": testUpdateSubscriptionRollbackOnUpdateFailure,
+		"UpdateSubscriptionUnkownId":                testUpdateSubscriptionUnkownId,
+		"UpdateSubscriptionRollbackOnDeleteFailure": testUpdateSubscriptionRollbackOnDeleteFailure,
+		"ChangedColumns":                            testChangedColumns,
+		"DeleteSubscription":                        testDeleteSubscription,
+		"DeleteSubscriptionRollbackOnFailure":       testDeleteSubscriptionRollbackOnFailure,
+		"GetActiveSubscriptions":                    testGetActiveSubscriptions,
+		"CreateSubscriptionLabels":                  testCreateSubscriptionLabels,
+		"UpdateSubscriptionLabels":                  testUpdateSubscriptionLabels,
+		"ListSubscriptions":                         testListSubscriptions,
+		"ListSubscriptionsEmptyPage":                testListSubscriptionsEmptyPage,
+		"ListSubscriptionsOfOwner":                  testListSubscriptionsOfOwner,
+		"CreateAPIKey":                              testCreateAPIKey,
+		"FindAPIKey":                                testFindAPIKey,
+		"ListAPIKeys":                               testListAPIKeys,
+		"ListAPIKeysRowError":                       testListAPIKeysRowError,
+		"DeleteAPIKey":                              testDeleteAPIKey,
+		"BufferEvent":                               testBufferEvent,
+		"PopBufferedEvents":                         testPopBufferedEvents,
+		"PopBufferedEventsRollbackOnFailure":        testPopBufferedEventsRollbackOnFailure,
+		"ClearBufferedEvents":                       testClearBufferedEvents,
+		"RetryTransientError":                       testRetryTransientError,
+		"RetryTransientErrorAttempts":               testRetryTransientErrorAttempts,
+		"InsertNotRetried":                          testInsertNotRetried,
+		"Watch":                                     testWatch,
+		"WatchLateChange":                           testWatchLateChange,
+		"PruneChanges":                              testPruneChanges,
+		"Close":                                     testClose,
+		"MigrateUp":                                 testMigrateUp,
+		"MigrateUpRollbackOnFailure":                testMigrateUpRollbackOnFailure,
+		"MigrateUpBackfillCallbackHosts":            testMigrateUpBackfillCallbackHosts,
+		"MigrateDown":                               testMigrateDown,
+		"MigrateDownNothingApplied":                 testMigrateDownNothingApplied,
+	}
+
+	for _, database := range []string{"mysql", "postgres", "sqlite"} {
+		testDatabase = database
+		t.Run(database, func(t *testing.T) {
+			for name, test := range testCases {
+				t.Run(name, test)
+			}
+		})
+	}
+}
+
 func initTest(t *testing.T) (*sqlRepository, sqlmock.Sqlmock) {
 	log.SetLevel(log.D)
 
-	// init mock
-	db, mock, err := sqlmock.New()
+	// init mock, the expectations are written with ? placeholders for every dialect
+	placeholders := regexp.MustCompile(`\$\d+`)
+	matcher := sqlmock.QueryMatcherFunc(func(expectedSQL, actualSQL string) error {
+		return sqlmock.QueryMatcherRegexp.Match(expectedSQL, placeholders.ReplaceAllString(actualSQL, "?"))
+	})
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection\n", err)
 	}
 
-	dbConfig := &config.DatabaseConfig{
-		Database:         "",
-		ConnectionString: "",
+	dialect, err := newSQLDialect(testDatabase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the mocked connection is used instead of connecting to the database
+	return newSQLRepository(dialect, db, &config.DatabaseConfig{Database: testDatabase}), mock
+}
+
+// expectInsert expects the insert of a row with the generated id in the dialect under test
+func expectInsert(mock sqlmock.Sqlmock, sqlRegexStr string, id int64, args ...driver.Value) {
+	if testDatabase == "postgres" {
+		mock.ExpectQuery(sqlRegexStr).WithArgs(args...).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+		return
 	}
-	repository, _ := NewSQLRepository(dbConfig) // Ignore connection errors when using mocked connection
-	repo, _ := repository.(*sqlRepository)
-	connection = db
-	return repo, mock
+	mock.ExpectExec(sqlRegexStr).WithArgs(args...).WillReturnResult(sqlmock.NewResult(id, 1))
 }
 
 // test read subscription
-func TestReadSubscription(t *testing.T) {
+func testReadSubscription(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to create
@@ -50,15 +135,18 @@ func TestReadSubscription(t *testing.T) {
 		Failures:     0,
 	}
 
-	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
 		WithArgs(subscription.ID).
 		WillReturnRows(sqlmock.NewRows(columns).
-			AddRow(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, models.DirectoryBlockCommit, subscription.Filters[models.DirectoryBlockCommit].Filtering).
-			AddRow(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, models.EntryCommit, subscription.Filters[models.EntryCommit].Filtering))
+			AddRow(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, models.DirectoryBlockCommit, subscription.Filters[models.DirectoryBlockCommit].Filtering, nil).
+			AddRow(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, models.EntryCommit, subscription.Filters[models.EntryCommit].Filtering, nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
 
 	// now we execute our methods
-	readSubscriptionContext, err := repository.ReadSubscription(subscription.ID)
+	readSubscriptionContext, err := repository.ReadSubscription(context.Background(), subscription.ID)
 	if err != nil {
 		t.Errorf("error was not expected creating subscription: %s", err)
 	}
@@ -71,17 +159,17 @@ func TestReadSubscription(t *testing.T) {
 	}
 }
 
-func TestReadSubscriptionUnknownId(t *testing.T) {
+func testReadSubscriptionUnknownId(t *testing.T) {
 	repository, mock := initTest(t)
 
 	id := "1"
-	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
 		WithArgs(id).
 		WillReturnRows(sqlmock.NewRows(columns))
 
 	// now we execute our methods
-	readSubscriptionContext, err := repository.ReadSubscription(id)
+	readSubscriptionContext, err := repository.ReadSubscription(context.Background(), id)
 	if err == nil {
 		t.Errorf("was expecting an error, but there was none")
 	}
@@ -95,8 +183,26 @@ func TestReadSubscriptionUnknownId(t *testing.T) {
 	}
 }
 
+// the query is cancelled when it takes longer than the query timeout
+func testReadSubscriptionTimeout(t *testing.T) {
+	repository, mock := initTest(t)
+	repository.queryTimeout = 10 * time.Millisecond
+
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+		WithArgs("1").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	start := time.Now()
+	readSubscriptionContext, err := repository.ReadSubscription(context.Background(), "1")
+	assert.NotNil(t, err)
+	assert.Nil(t, readSubscriptionContext)
+	assert.True(t, time.Since(start) < time.Second, "the query is not cancelled after the query timeout")
+}
+
 // test read subscription
-func TestReadSubscriptionWithFilterNil(t *testing.T) {
+func testReadSubscriptionWithFilterNil(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to create
@@ -111,18 +217,220 @@ func TestReadSubscriptionWithFilterNil(t *testing.T) {
 		Failures:     1,
 	}
 
-	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
 		WithArgs(subscription.ID).
 		WillReturnRows(sqlmock.NewRows(columns).
-			AddRow(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, nil, nil))
+			AddRow(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, nil, nil, nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
 
 	// now we execute our methods
-	readSubscriptionContext, err := repository.ReadSubscription(subscription.ID)
+	readSubscriptionContext, err := repository.ReadSubscription(context.Background(), subscription.ID)
+	if err != nil {
+		t.Errorf("error was not expected creating subscription: %s", err)
+	}
+
+	assertSubscription(t, subscriptionContext, readSubscriptionContext)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// test read subscription with oauth2 client credentials
+func testReadSubscriptionOAuth2ClientCredentials(t *testing.T) {
+	repository, mock := initTest(t)
+
+	subscription := models.Subscription{
+		ID:           "1",
+		CallbackURL:  "url",
+		CallbackType: models.OAuth2ClientCredentials,
+		Filters:      map[models.EventType]models.Filter{},
+		Credentials: models.Credentials{
+			OAuth2TokenURL:     "https://auth/token",
+			OAuth2ClientID:     "client",
+			OAuth2ClientSecret: "secret",
+			OAuth2Scopes:       []string{"events", "write"},
+		},
+	}
+	subscriptionContext := &models.SubscriptionContext{
+		Subscription: subscription,
+		Failures:     0,
+	}
+
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, "", "", "", "https://auth/token", "client", "secret", "events write", "", "", "", "", 1, nil, nil, nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
+
+	readSubscriptionContext, err := repository.ReadSubscription(context.Background(), subscription.ID)
+	if err != nil {
+		t.Errorf("error was not expected reading subscription: %s", err)
+	}
+
+	assertSubscription(t, subscriptionContext, readSubscriptionContext)
+	assert.Equal(t, subscription.Credentials, readSubscriptionContext.Subscription.Credentials)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// test insert subscription with callback method and headers
+func testCreateSubscriptionCallbackHeaders(t *testing.T) {
+	repository, mock := initTest(t)
+
+	subscription := models.Subscription{
+		CallbackURL:    "url",
+		CallbackType:   models.HTTP,
+		CallbackMethod: "PUT",
+		CallbackHeaders: map[string]string{
+			"X-Tenant":  "tenant",
+			"X-Api-Key": "key",
+		},
+	}
+	subscriptionContext := &models.SubscriptionContext{
+		Subscription: subscription,
+		Failures:     0,
+	}
+
+	mock.ExpectBegin()
+	expectInsert(mock, `INSERT INTO subscriptions`, 1, subscriptionContext.Failures, subscription.CallbackURL, "", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, "", "", "", "", "", "", "", "PUT", `{"X-Api-Key":"key","X-Tenant":"tenant"}`, "", "")
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs(1, models.Created).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	createdSubscriptionContext, err := repository.CreateSubscription(context.Background(), subscriptionContext)
+	if err != nil {
+		t.Errorf("error was not expected creating subscription: %s", err)
+	}
+
+	assertSubscription(t, subscriptionContext, createdSubscriptionContext)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// test read subscription with callback method and headers
+func testReadSubscriptionCallbackHeaders(t *testing.T) {
+	repository, mock := initTest(t)
+
+	subscription := models.Subscription{
+		ID:             "1",
+		CallbackURL:    "url",
+		CallbackType:   models.HTTP,
+		CallbackMethod: "PUT",
+		CallbackHeaders: map[string]string{
+			"X-Api-Key": "key",
+		},
+		Filters: map[models.EventType]models.Filter{},
+	}
+	subscriptionContext := &models.SubscriptionContext{
+		Subscription: subscription,
+		Failures:     0,
+	}
+
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT (.+) FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, "", "", "", "", "", "", "", "PUT", `{"X-Api-Key":"key"}`, "", "", 1, nil, nil, nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
+
+	readSubscriptionContext, err := repository.ReadSubscription(context.Background(), subscription.ID)
+	if err != nil {
+		t.Errorf("error was not expected reading subscription: %s", err)
+	}
+
+	assertSubscription(t, subscriptionContext, readSubscriptionContext)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// test insert subscription with a file sink
+func testCreateSubscriptionFileSink(t *testing.T) {
+	repository, mock := initTest(t)
+
+	subscription := models.Subscription{
+		CallbackURL:  "{{.EventType}}/events.jsonl",
+		CallbackType: models.File,
+		FileSink: models.FileSink{
+			Format:   models.Protobuf,
+			MaxSize:  1024,
+			MaxAge:   3600,
+			Compress: true,
+		},
+	}
+	subscriptionContext := &models.SubscriptionContext{
+		Subscription: subscription,
+		Failures:     0,
+	}
+
+	mock.ExpectBegin()
+	expectInsert(mock, `INSERT INTO subscriptions`, 1, subscriptionContext.Failures, subscription.CallbackURL, "", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, "", "", "", "", "", "", "", "", "", `{"format":"PROTOBUF","maxSize":1024,"maxAge":3600,"compress":true}`, "")
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs(1, models.Created).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	createdSubscriptionContext, err := repository.CreateSubscription(context.Background(), subscriptionContext)
 	if err != nil {
 		t.Errorf("error was not expected creating subscription: %s", err)
 	}
 
+	assertSubscription(t, subscriptionContext, createdSubscriptionContext)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// test read subscription with a file sink
+func testReadSubscriptionFileSink(t *testing.T) {
+	repository, mock := initTest(t)
+
+	subscription := models.Subscription{
+		ID:           "1",
+		CallbackURL:  "{{.EventType}}/events.jsonl",
+		CallbackType: models.File,
+		FileSink: models.FileSink{
+			Format:  models.JSONLines,
+			MaxSize: 1024,
+		},
+		Filters: map[models.EventType]models.Filter{},
+	}
+	subscriptionContext := &models.SubscriptionContext{
+		Subscription: subscription,
+		Failures:     0,
+	}
+
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT (.+) FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, "", "", "", "", "", "", "", "", "", `{"format":"JSON_LINES","maxSize":1024}`, "", 1, nil, nil, nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
+
+	readSubscriptionContext, err := repository.ReadSubscription(context.Background(), subscription.ID)
+	if err != nil {
+		t.Errorf("error was not expected reading subscription: %s", err)
+	}
+
 	assertSubscription(t, subscriptionContext, readSubscriptionContext)
 
 	// we make sure that all expectations were met
@@ -132,7 +440,7 @@ func TestReadSubscriptionWithFilterNil(t *testing.T) {
 }
 
 // test insert subscription
-func TestCreateSubscription(t *testing.T) {
+func testCreateSubscription(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to create
@@ -146,17 +454,18 @@ func TestCreateSubscription(t *testing.T) {
 	}
 
 	mock.ExpectBegin()
-	mock.ExpectPrepare(`INSERT INTO subscriptions \(failures, callback, callback_type, status, info, access_token, username, password\) VALUES\(\?, \?, \?, \?, \?, \?, \?, \?\);`)
-	mock.ExpectExec(`INSERT INTO subscriptions`).WithArgs(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectInsert(mock, `INSERT INTO subscriptions`, 1, subscriptionContext.Failures, subscription.CallbackURL, "", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "")
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs(1, models.Created).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	// now we execute our method
-	createdSubscriptionContext, err := repository.CreateSubscription(subscriptionContext)
+	createdSubscriptionContext, err := repository.CreateSubscription(context.Background(), subscriptionContext)
 	if err != nil {
 		t.Errorf("error was not expected creating subscription: %s", err)
 	}
 
 	assertSubscription(t, subscriptionContext, createdSubscriptionContext)
+	assert.EqualValues(t, 1, createdSubscriptionContext.Version)
 
 	// we make sure that all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -165,7 +474,7 @@ func TestCreateSubscription(t *testing.T) {
 }
 
 // test insert subscription with filters
-func TestCreateSubscriptionAddFilter(t *testing.T) {
+func testCreateSubscriptionAddFilter(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to create
@@ -181,14 +490,14 @@ func TestCreateSubscriptionAddFilter(t *testing.T) {
 		Failures:     0,
 	}
 	mock.ExpectBegin()
-	mock.ExpectPrepare(`INSERT INTO subscriptions \(failures, callback, callback_type, status, info, access_token, username, password\) VALUES\(\?, \?, \?, \?, \?, \?, \?, \?\);`)
-	mock.ExpectExec(`INSERT INTO subscriptions`).WithArgs(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword).WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectPrepare(`INSERT INTO filters \(subscription, event_type, filtering\) VALUES\(\?, \?, \?\);`)
-	mock.ExpectExec(`INSERT INTO filters`).WithArgs(1, models.DirectoryBlockCommit, subscription.Filters[models.DirectoryBlockCommit].Filtering).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectInsert(mock, `INSERT INTO subscriptions`, 1, subscriptionContext.Failures, subscription.CallbackURL, "", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "")
+	mock.ExpectPrepare(`INSERT INTO filters \(subscription, event_type, filtering, template\) VALUES\(\?, \?, \?, \?\);`)
+	mock.ExpectExec(`INSERT INTO filters`).WithArgs(1, models.DirectoryBlockCommit, subscription.Filters[models.DirectoryBlockCommit].Filtering, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs(1, models.Created).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	// now we execute our method
-	if _, err := repository.CreateSubscription(subscriptionContext); err != nil {
+	if _, err := repository.CreateSubscription(context.Background(), subscriptionContext); err != nil {
 		t.Errorf("error was not expected creating subscription: %s", err)
 	}
 
@@ -199,7 +508,7 @@ func TestCreateSubscriptionAddFilter(t *testing.T) {
 }
 
 // test a rollback on insert subscription
-func TestCreateSubscriptionRollbackOnFailure(t *testing.T) {
+func testCreateSubscriptionRollbackOnFailure(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to create
@@ -216,15 +525,14 @@ func TestCreateSubscriptionRollbackOnFailure(t *testing.T) {
 	}
 
 	mock.ExpectBegin()
-	mock.ExpectPrepare(`INSERT INTO subscriptions \(failures, callback, callback_type, status, info, access_token, username, password\) VALUES\(\?, \?, \?, \?, \?, \?, \?, \?\);`)
-	mock.ExpectExec(`INSERT INTO subscriptions`).WithArgs(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword).WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectPrepare(`INSERT INTO filters \(subscription, event_type, filtering\) VALUES\(\?, \?, \?\);`)
-	mock.ExpectExec(`INSERT INTO filters`).WithArgs(1, models.DirectoryBlockCommit, subscription.Filters[models.DirectoryBlockCommit].Filtering).
+	expectInsert(mock, `INSERT INTO subscriptions`, 1, subscriptionContext.Failures, subscription.CallbackURL, "", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "")
+	mock.ExpectPrepare(`INSERT INTO filters \(subscription, event_type, filtering, template\) VALUES\(\?, \?, \?, \?\);`)
+	mock.ExpectExec(`INSERT INTO filters`).WithArgs(1, models.DirectoryBlockCommit, subscription.Filters[models.DirectoryBlockCommit].Filtering, "").
 		WillReturnError(fmt.Errorf("some error"))
 	mock.ExpectRollback()
 
 	// now we execute our method
-	if _, err := repository.CreateSubscription(subscriptionContext); err == nil {
+	if _, err := repository.CreateSubscription(context.Background(), subscriptionContext); err == nil {
 		t.Errorf("was expecting an error, but there was none")
 	}
 
@@ -235,7 +543,7 @@ func TestCreateSubscriptionRollbackOnFailure(t *testing.T) {
 }
 
 // test update a subscription without filters
-func TestUpdateSubscription(t *testing.T) {
+func testUpdateSubscription(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to update
@@ -249,17 +557,21 @@ func TestUpdateSubscription(t *testing.T) {
 		Failures:     0,
 	}
 
-	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
 		WithArgs(subscription.ID).
-		WillReturnRows(sqlmock.NewRows(columns).AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, nil, nil))
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, nil, nil, nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE subscriptions`).WithArgs(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.ID).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`UPDATE subscriptions SET callback = \?, version = version \+ 1 WHERE id = \?`).WithArgs(subscription.CallbackURL, subscription.ID).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs("42", models.Updated).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	// now we execute our method
-	updatedSubscriptionContext, err := repository.UpdateSubscription(subscriptionContext)
+	updatedSubscriptionContext, err := repository.UpdateSubscription(context.Background(), subscriptionContext)
 	if err != nil {
 		t.Errorf("error was not expected creating subscription: %s", err)
 	}
@@ -272,8 +584,97 @@ func TestUpdateSubscription(t *testing.T) {
 	}
 }
 
+// test update a subscription with an outdated version
+func testUpdateSubscriptionVersionConflict(t *testing.T) {
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	subscription := models.Subscription{
+		ID:           "42",
+		CallbackURL:  "url",
+		CallbackType: models.HTTP,
+	}
+
+	t.Run("outdated on read", func(t *testing.T) {
+		repository, mock := initTest(t)
+
+		mock.ExpectQuery(`SELECT (.+) FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+			WithArgs(subscription.ID).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(0, "url-change", subscription.CallbackType, "", "", "", "", "", "", "", "", "", "", "", "", "", 3, nil, nil, nil))
+		mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+			WithArgs(subscription.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
+
+		_, err := repository.UpdateSubscription(context.Background(), &models.SubscriptionContext{Subscription: subscription, Version: 2})
+		assert.IsType(t, errors.SubscriptionConflict{}, err)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("changed during update", func(t *testing.T) {
+		repository, mock := initTest(t)
+
+		mock.ExpectQuery(`SELECT (.+) FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+			WithArgs(subscription.ID).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(0, "url-change", subscription.CallbackType, "", "", "", "", "", "", "", "", "", "", "", "", "", 2, nil, nil, nil))
+		mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+			WithArgs(subscription.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE subscriptions SET callback = \?, version = version \+ 1 WHERE id = \? AND version = \?`).
+			WithArgs(subscription.CallbackURL, subscription.ID, 2).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, err := repository.UpdateSubscription(context.Background(), &models.SubscriptionContext{Subscription: subscription, Version: 2})
+		assert.IsType(t, errors.SubscriptionConflict{}, err)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+// test update only the status of a subscription
+func testUpdateSubscriptionStatus(t *testing.T) {
+	repository, mock := initTest(t)
+
+	subscriptionContext := &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			ID:                 "42",
+			CallbackURL:        "url",
+			SubscriptionStatus: models.Suspended,
+			SubscriptionInfo:   "3: failed",
+		},
+		Failures: 3,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE subscriptions SET failures = \?, status = \?, info = \? WHERE id = \?`).
+		WithArgs(3, models.Suspended, "3: failed", "42").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs("42", models.Updated).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE subscriptions SET failures = \?, status = \?, info = \? WHERE id = \?`).
+		WithArgs(3, models.Suspended, "3: failed", "42").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := repository.UpdateSubscriptionStatus(context.Background(), subscriptionContext)
+	assert.Nil(t, err)
+
+	err = repository.UpdateSubscriptionStatus(context.Background(), subscriptionContext)
+	assert.IsType(t, errors.SubscriptionNotFound{}, err)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 // test update subscription add one filter to the existing filters
-func TestUpdateSubscriptionAddFilter(t *testing.T) {
+func testUpdateSubscriptionAddFilter(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to update
@@ -291,19 +692,23 @@ func TestUpdateSubscriptionAddFilter(t *testing.T) {
 		Failures:     0,
 	}
 
-	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
 		WithArgs(subscription.ID).
 		WillReturnRows(sqlmock.NewRows(columns).
-			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, models.DirectoryBlockCommit, "no change filtering"))
+			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, models.DirectoryBlockCommit, "no change filtering", nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE subscriptions`).WithArgs(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.ID).WillReturnResult(sqlmock.NewResult(42, 1))
-	mock.ExpectExec(`INSERT INTO filters`).WithArgs("42", models.EntryReveal, subscription.Filters[models.EntryReveal].Filtering).WillReturnResult(sqlmock.NewResult(1, 1)).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`UPDATE subscriptions SET callback = \?, version = version \+ 1 WHERE id = \?`).WithArgs(subscription.CallbackURL, subscription.ID).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`INSERT INTO filters`).WithArgs("42", models.EntryReveal, subscription.Filters[models.EntryReveal].Filtering, "").WillReturnResult(sqlmock.NewResult(1, 1)).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs("42", models.Updated).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	// now we execute our method
-	updatedSubscriptionContext, err := repository.UpdateSubscription(subscriptionContext)
+	updatedSubscriptionContext, err := repository.UpdateSubscription(context.Background(), subscriptionContext)
 	if err != nil {
 		t.Errorf("error was not expected creating subscription: %s", err)
 	}
@@ -317,7 +722,7 @@ func TestUpdateSubscriptionAddFilter(t *testing.T) {
 }
 
 // test update subscription with updating a filter
-func TestUpdateSubscriptionUpdateFilter(t *testing.T) {
+func testUpdateSubscriptionUpdateFilter(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to update
@@ -335,20 +740,24 @@ func TestUpdateSubscriptionUpdateFilter(t *testing.T) {
 		Failures:     0,
 	}
 
-	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
 		WithArgs(subscription.ID).
 		WillReturnRows(sqlmock.NewRows(columns).
-			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, models.DirectoryBlockCommit, "no change filtering").
-			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, models.EntryCommit, "this will be changed"))
+			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, models.DirectoryBlockCommit, "no change filtering", nil).
+			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, models.EntryCommit, "this will be changed", nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE subscriptions`).WithArgs(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.ID).WillReturnResult(sqlmock.NewResult(42, 1))
-	mock.ExpectExec(`UPDATE filters`).WithArgs(subscription.Filters[models.EntryCommit].Filtering, "42", models.EntryCommit).WillReturnResult(sqlmock.NewResult(1, 1)).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`UPDATE subscriptions SET callback = \?, version = version \+ 1 WHERE id = \?`).WithArgs(subscription.CallbackURL, subscription.ID).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`UPDATE filters`).WithArgs(subscription.Filters[models.EntryCommit].Filtering, "", "42", models.EntryCommit).WillReturnResult(sqlmock.NewResult(1, 1)).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs("42", models.Updated).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	// now we execute our method
-	updatedSubscriptionContext, err := repository.UpdateSubscription(subscriptionContext)
+	updatedSubscriptionContext, err := repository.UpdateSubscription(context.Background(), subscriptionContext)
 	if err != nil {
 		t.Errorf("error was not expected creating subscription: %s", err)
 	}
@@ -362,7 +771,7 @@ func TestUpdateSubscriptionUpdateFilter(t *testing.T) {
 }
 
 // test update subscription and delete one filter
-func TestUpdateSubscriptionDeleteFilter(t *testing.T) {
+func testUpdateSubscriptionDeleteFilter(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to update
@@ -379,20 +788,24 @@ func TestUpdateSubscriptionDeleteFilter(t *testing.T) {
 		Failures:     0,
 	}
 
-	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
 		WithArgs(subscription.ID).
 		WillReturnRows(sqlmock.NewRows(columns).
-			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, models.DirectoryBlockCommit, "no change filtering").
-			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, models.ChainCommit, "this will be deleted"))
+			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, models.DirectoryBlockCommit, "no change filtering", nil).
+			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, models.ChainCommit, "this will be deleted", nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE subscriptions`).WithArgs(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.ID).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`UPDATE subscriptions SET callback = \?, version = version \+ 1 WHERE id = \?`).WithArgs(subscription.CallbackURL, subscription.ID).WillReturnResult(sqlmock.NewResult(42, 1))
 	mock.ExpectExec(`DELETE FROM filters`).WithArgs(subscription.ID, models.ChainCommit).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs("42", models.Updated).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	// now we execute our method
-	updatedSubscriptionContext, err := repository.UpdateSubscription(subscriptionContext)
+	updatedSubscriptionContext, err := repository.UpdateSubscription(context.Background(), subscriptionContext)
 	if err != nil {
 		t.Errorf("error was not expected creating subscription: %s", err)
 	}
@@ -406,7 +819,7 @@ func TestUpdateSubscriptionDeleteFilter(t *testing.T) {
 }
 
 // test a rollback on update subscription when updating the subscription
-func TestUpdateSubscriptionRollbackOnUpdateFailure(t *testing.T) {
+func testUpdateSubscriptionRollbackOnUpdateFailure(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to update
@@ -421,20 +834,23 @@ func TestUpdateSubscriptionRollbackOnUpdateFailure(t *testing.T) {
 		Failures:     0,
 	}
 
-	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
 		WithArgs(subscription.ID).
 		WillReturnRows(sqlmock.NewRows(columns).
-			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, models.EntryCommit, "filtering"))
+			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, models.EntryCommit, "filtering", nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE subscriptions`).
-		WithArgs(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.ID).
+	mock.ExpectExec(`UPDATE subscriptions SET callback = \?, version = version \+ 1 WHERE id = \?`).
+		WithArgs(subscription.CallbackURL, subscription.ID).
 		WillReturnError(fmt.Errorf("some error"))
 	mock.ExpectRollback()
 
 	// now we execute our method
-	if _, err := repository.UpdateSubscription(subscriptionContext); err == nil {
+	if _, err := repository.UpdateSubscription(context.Background(), subscriptionContext); err == nil {
 		t.Errorf("was expecting an error, but there was none")
 	}
 
@@ -445,7 +861,7 @@ func TestUpdateSubscriptionRollbackOnUpdateFailure(t *testing.T) {
 }
 
 // test a rollback on update subscription when updating the subscription
-func TestUpdateSubscriptionUnkownId(t *testing.T) {
+func testUpdateSubscriptionUnkownId(t *testing.T) {
 	repository, mock := initTest(t)
 
 	subscription := models.Subscription{
@@ -459,13 +875,13 @@ func TestUpdateSubscriptionUnkownId(t *testing.T) {
 		Failures:     0,
 	}
 
-	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
 		WithArgs(subscription.ID).
 		WillReturnRows(sqlmock.NewRows(columns))
 
 	// now we execute our method
-	updateSubscription, err := repository.UpdateSubscription(subscriptionContext)
+	updateSubscription, err := repository.UpdateSubscription(context.Background(), subscriptionContext)
 	if err == nil {
 		t.Errorf("was expecting an error, but there was none")
 	}
@@ -480,7 +896,7 @@ func TestUpdateSubscriptionUnkownId(t *testing.T) {
 }
 
 // test a rollback on update subscription when delete a removed subscription
-func TestUpdateSubscriptionRollbackOnDeleteFailure(t *testing.T) {
+func testUpdateSubscriptionRollbackOnDeleteFailure(t *testing.T) {
 	repository, mock := initTest(t)
 
 	// subscription to update
@@ -495,19 +911,22 @@ func TestUpdateSubscriptionRollbackOnDeleteFailure(t *testing.T) {
 		Failures:     0,
 	}
 
-	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
 		WithArgs(subscription.ID).
 		WillReturnRows(sqlmock.NewRows(columns).
-			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, models.EntryCommit, "this will be deleted"))
+			AddRow(subscriptionContext.Failures, "url-change", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.Credentials.OAuth2TokenURL, subscription.Credentials.OAuth2ClientID, subscription.Credentials.OAuth2ClientSecret, "", subscription.CallbackMethod, "", "", "", 1, models.EntryCommit, "this will be deleted", nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE subscriptions`).WithArgs(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, subscription.Credentials.AccessToken, subscription.Credentials.BasicAuthUsername, subscription.Credentials.BasicAuthPassword, subscription.ID).WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`UPDATE subscriptions SET callback = \?, version = version \+ 1 WHERE id = \?`).WithArgs(subscription.CallbackURL, subscription.ID).WillReturnResult(sqlmock.NewResult(42, 1))
 	mock.ExpectExec(`DELETE FROM filters`).WithArgs(subscription.ID, models.EntryCommit).WillReturnError(fmt.Errorf("some error"))
 	mock.ExpectRollback()
 
 	// now we execute our method
-	if _, err := repository.UpdateSubscription(subscriptionContext); err == nil {
+	if _, err := repository.UpdateSubscription(context.Background(), subscriptionContext); err == nil {
 		t.Errorf("was expecting an error, but there was none")
 	}
 
@@ -517,18 +936,80 @@ func TestUpdateSubscriptionRollbackOnDeleteFailure(t *testing.T) {
 	}
 }
 
-func TestDeleteSubscription(t *testing.T) {
+func testChangedColumns(t *testing.T) {
+	old := &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			ID:                 "42",
+			CallbackURL:        "https://server.com/events",
+			CallbackType:       models.BearerToken,
+			SubscriptionStatus: models.Suspended,
+			SubscriptionInfo:   "1: failed",
+			Credentials:        models.Credentials{AccessToken: "token"},
+		},
+		Failures: 3,
+	}
+
+	testCases := map[string]struct {
+		Update  func(subscriptionContext *models.SubscriptionContext)
+		Columns []string
+		Args    []interface{}
+	}{
+		"unchanged": {
+			Update: func(subscriptionContext *models.SubscriptionContext) {},
+		},
+		"reactivate": {
+			Update: func(subscriptionContext *models.SubscriptionContext) {
+				subscriptionContext.Failures = 0
+				subscriptionContext.Subscription.SubscriptionStatus = models.Active
+				subscriptionContext.Subscription.SubscriptionInfo = ""
+			},
+			Columns: []string{"failures = ?", "status = ?", "info = ?"},
+			Args:    []interface{}{uint16(0), models.Active, ""},
+		},
+		"callback": {
+			Update: func(subscriptionContext *models.SubscriptionContext) {
+				subscriptionContext.Subscription.CallbackURL = "https://other.com/events"
+			},
+			Columns: []string{"callback = ?", "callback_host = ?"},
+			Args:    []interface{}{"https://other.com/events", "other.com"},
+		},
+		"headers": {
+			Update: func(subscriptionContext *models.SubscriptionContext) {
+				subscriptionContext.Subscription.CallbackHeaders = map[string]string{"X-Tenant": "tenant"}
+			},
+			Columns: []string{"headers = ?"},
+			Args:    []interface{}{`{"X-Tenant":"tenant"}`},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			update := *old
+			testCase.Update(&update)
+
+			columns, args, err := changedColumns(old, &update)
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.Columns, columns)
+			assert.Equal(t, testCase.Args, args)
+		})
+	}
+}
+
+func testDeleteSubscription(t *testing.T) {
 	repository, mock := initTest(t)
 
 	id := "42"
 
 	mock.ExpectBegin()
 	mock.ExpectExec(`DELETE FROM filters`).WithArgs(id).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM labels`).WithArgs(id).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM buffered_events`).WithArgs(id).WillReturnResult(sqlmock.NewResult(1, 3))
 	mock.ExpectExec(`DELETE FROM subscriptions`).WithArgs(id).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs(id, models.Deleted).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	// now we execute our method
-	if err := repository.DeleteSubscription(id); err != nil {
+	if err := repository.DeleteSubscription(context.Background(), id); err != nil {
 		t.Errorf("error was not expected creating subscription: %s", err)
 	}
 
@@ -538,18 +1019,20 @@ func TestDeleteSubscription(t *testing.T) {
 	}
 }
 
-func TestDeleteSubscriptionRollbackOnFailure(t *testing.T) {
+func testDeleteSubscriptionRollbackOnFailure(t *testing.T) {
 	repository, mock := initTest(t)
 
 	id := "42"
 
 	mock.ExpectBegin()
 	mock.ExpectExec(`DELETE FROM filters`).WithArgs(id).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM labels`).WithArgs(id).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM buffered_events`).WithArgs(id).WillReturnResult(sqlmock.NewResult(1, 3))
 	mock.ExpectExec(`DELETE FROM subscriptions`).WithArgs(id).WillReturnError(fmt.Errorf("some error"))
 	mock.ExpectRollback()
 
 	// now we execute our method
-	err := repository.DeleteSubscription(id)
+	err := repository.DeleteSubscription(context.Background(), id)
 	if err == nil {
 		t.Errorf("was expecting an error, but there was none")
 	}
@@ -560,26 +1043,658 @@ func TestDeleteSubscriptionRollbackOnFailure(t *testing.T) {
 	}
 }
 
-func TestGetActiveSubscriptions(t *testing.T) {
+func testGetActiveSubscriptions(t *testing.T) {
 	repository, mock := initTest(t)
 
-	columns := []string{"subscription", "failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "event_type", "filtering"}
-	mock.ExpectQuery(`SELECT subscription, failures, callback, callback_type, status, info, access_token, username, password, event_type, filtering FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE event_type = \? AND status = 'ACTIVE'`).
+	columns := []string{"subscription", "failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT subscription, failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE event_type = \? AND status IN \('ACTIVE', 'PAUSED'\)`).
 		WithArgs(models.DirectoryBlockCommit).
 		WillReturnRows(sqlmock.NewRows(columns).
-			AddRow(1, 0, "url", models.HTTP, models.Active, "", "", "", "", models.DirectoryBlockCommit, "should be returned").
-			AddRow(1, 0, "url", models.HTTP, models.Active, "", "", "", "", models.EntryCommit, "should be returned").
-			AddRow(2, 1, "url", models.HTTP, models.Active, "", "", "", "", nil, nil).
-			AddRow(3, 2, "url", models.HTTP, models.Active, "", "", "", "", models.DirectoryBlockCommit, "return"))
+			AddRow(1, 0, "url", models.HTTP, models.Active, "", "", "", "", "", "", "", "", "", "", "", "", 1, models.DirectoryBlockCommit, "should be returned", nil).
+			AddRow(1, 0, "url", models.HTTP, models.Active, "", "", "", "", "", "", "", "", "", "", "", "", 1, models.EntryCommit, "should be returned", nil).
+			AddRow(2, 1, "url", models.HTTP, models.Active, "", "", "", "", "", "", "", "", "", "", "", "", 1, nil, nil, nil).
+			AddRow(3, 2, "url", models.HTTP, models.Paused, "", "", "", "", "", "", "", "", "", "", "", "", 1, models.DirectoryBlockCommit, "return", nil))
 
 	// now we execute our methods
-	subscriptionContexts, err := repository.GetActiveSubscriptions(models.DirectoryBlockCommit)
+	subscriptionContexts, err := repository.GetActiveSubscriptions(context.Background(), models.DirectoryBlockCommit)
 	if err != nil {
 		t.Errorf("error was not expected creating subscription: %s", err)
 	}
 
 	assert.Equal(t, 3, len(subscriptionContexts))
 
+	// the labels are not read
+	assert.Nil(t, subscriptionContexts[0].Subscription.Labels)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// test insert subscription with labels
+func testCreateSubscriptionLabels(t *testing.T) {
+	repository, mock := initTest(t)
+
+	subscription := models.Subscription{
+		CallbackURL:  "https://Example.com/events",
+		CallbackType: models.HTTP,
+		Labels:       map[string]string{"team": "explorer"},
+	}
+	subscriptionContext := &models.SubscriptionContext{
+		Subscription: subscription,
+		Failures:     0,
+	}
+
+	mock.ExpectBegin()
+	expectInsert(mock, `INSERT INTO subscriptions`, 1, subscriptionContext.Failures, subscription.CallbackURL, "example.com", subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, "", "", "", "", "", "", "", "", "", "", "")
+	mock.ExpectPrepare(`INSERT INTO labels \(subscription, name, value\) VALUES\(\?, \?, \?\);`)
+	mock.ExpectExec(`INSERT INTO labels`).WithArgs(1, "team", "explorer").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs(1, models.Created).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	createdSubscriptionContext, err := repository.CreateSubscription(context.Background(), subscriptionContext)
+	if err != nil {
+		t.Errorf("error was not expected creating subscription: %s", err)
+	}
+
+	assertSubscription(t, subscriptionContext, createdSubscriptionContext)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// test update subscription with an added, an updated and a removed label
+func testUpdateSubscriptionLabels(t *testing.T) {
+	repository, mock := initTest(t)
+
+	subscription := models.Subscription{
+		ID:           "42",
+		CallbackURL:  "url",
+		CallbackType: models.HTTP,
+		Labels:       map[string]string{"team": "wallet", "env": "prod"},
+	}
+	subscriptionContext := &models.SubscriptionContext{
+		Subscription: subscription,
+		Failures:     0,
+	}
+
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT (.+) FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(subscriptionContext.Failures, subscription.CallbackURL, subscription.CallbackType, subscription.SubscriptionStatus, subscription.SubscriptionInfo, "", "", "", "", "", "", "", "", "", "", "", 1, nil, nil, nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs(subscription.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}).
+			AddRow(42, "team", "explorer").
+			AddRow(42, "owner", "removed"))
+
+	mock.ExpectBegin()
+	// only the version of the subscription itself is changed, the order of the label statements depends on the map iteration
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec(`UPDATE subscriptions SET version = version \+ 1 WHERE id = \?`).WithArgs("42").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE labels SET value = \? WHERE subscription = \? AND name = \?`).WithArgs("wallet", "42", "team").WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`INSERT INTO labels`).WithArgs("42", "env", "prod").WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`DELETE FROM labels WHERE subscription = \? AND name = \?`).WithArgs("42", "owner").WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(`INSERT INTO subscription_changes`).WithArgs("42", models.Updated).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	updatedSubscriptionContext, err := repository.UpdateSubscription(context.Background(), subscriptionContext)
+	if err != nil {
+		t.Errorf("error was not expected updating subscription: %s", err)
+	}
+
+	assertSubscription(t, subscriptionContext, updatedSubscriptionContext)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testListSubscriptions(t *testing.T) {
+	repository, mock := initTest(t)
+
+	conditions := ` WHERE status = \? AND EXISTS \(SELECT 1 FROM filters WHERE filters.subscription = subscriptions.id AND filters.event_type = \?\) AND callback_host = \? AND EXISTS \(SELECT 1 FROM labels WHERE labels.subscription = subscriptions.id AND labels.name = \? AND labels.value = \?\) AND EXISTS \(SELECT 1 FROM labels WHERE labels.subscription = subscriptions.id AND labels.name = \? AND labels.value = \?\)`
+	query := &models.SubscriptionQuery{
+		Status:       models.Active,
+		EventType:    models.EntryCommit,
+		CallbackHost: "Example.com",
+		Labels:       map[string]string{"team": "explorer", "env": "prod"},
+		Offset:       10,
+		Limit:        2,
+	}
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM subscriptions`+conditions+`;`).
+		WithArgs(models.Active, models.EntryCommit, "example.com", "env", "prod", "team", "explorer").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery(`SELECT id FROM subscriptions`+conditions+` ORDER BY id LIMIT \? OFFSET \?;`).
+		WithArgs(models.Active, models.EntryCommit, "example.com", "env", "prod", "team", "explorer", 2, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11).AddRow(12))
+
+	columns := []string{"id", "failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT subscriptions.id, failures, (.+) FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id IN \(\?, \?\) ORDER BY subscriptions.id;`).
+		WithArgs("11", "12").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(11, 0, "https://example.com/a", models.HTTP, models.Active, "", "", "", "", "", "", "", "", "", "", "", "", 1, models.EntryCommit, "", nil).
+			AddRow(11, 0, "https://example.com/a", models.HTTP, models.Active, "", "", "", "", "", "", "", "", "", "", "", "", 1, models.EntryReveal, "", nil).
+			AddRow(12, 0, "https://example.com/b", models.HTTP, models.Active, "", "", "", "", "", "", "", "", "", "", "", "", 1, models.EntryCommit, "", nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?, \?\)`).
+		WithArgs("11", "12").
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}).
+			AddRow(11, "team", "explorer").AddRow(11, "env", "prod").
+			AddRow(12, "team", "explorer").AddRow(12, "env", "prod"))
+
+	subscriptionContexts, total, err := repository.ListSubscriptions(context.Background(), query)
+	if err != nil {
+		t.Errorf("error was not expected listing subscriptions: %s", err)
+	}
+
+	assert.Equal(t, 12, total)
+	if assert.Equal(t, 2, len(subscriptionContexts)) {
+		assert.Equal(t, "11", subscriptionContexts[0].Subscription.ID)
+		assert.Equal(t, 2, len(subscriptionContexts[0].Subscription.Filters))
+		assert.Equal(t, query.Labels, subscriptionContexts[0].Subscription.Labels)
+		assert.Equal(t, "12", subscriptionContexts[1].Subscription.ID)
+	}
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testListSubscriptionsEmptyPage(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM subscriptions;`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`SELECT id FROM subscriptions ORDER BY id LIMIT \? OFFSET \?;`).
+		WithArgs(math.MaxInt32, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	subscriptionContexts, total, err := repository.ListSubscriptions(context.Background(), &models.SubscriptionQuery{Offset: 5})
+	if err != nil {
+		t.Errorf("error was not expected listing subscriptions: %s", err)
+	}
+
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 0, len(subscriptionContexts))
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testListSubscriptionsOfOwner(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM subscriptions WHERE owner = \? AND status = \?;`).
+		WithArgs("explorer", models.Active).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT id FROM subscriptions WHERE owner = \? AND status = \? ORDER BY id LIMIT \? OFFSET \?;`).
+		WithArgs("explorer", models.Active, 25, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	subscriptionContexts, total, err := repository.ListSubscriptions(context.Background(), &models.SubscriptionQuery{Owner: "explorer", Status: models.Active, Limit: 25})
+	if err != nil {
+		t.Errorf("error was not expected listing subscriptions: %s", err)
+	}
+
+	assert.Equal(t, 0, total)
+	assert.Equal(t, 0, len(subscriptionContexts))
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testCreateAPIKey(t *testing.T) {
+	repository, mock := initTest(t)
+
+	apiKey := &models.APIKey{Owner: "explorer", Role: models.User, KeyHash: "hash"}
+	expectInsert(mock, `INSERT INTO api_keys \(owner, role, key_hash\) VALUES\(\?, \?, \?\)`, 7, "explorer", models.User, "hash")
+
+	createdAPIKey, err := repository.CreateAPIKey(context.Background(), apiKey)
+	if err != nil {
+		t.Errorf("error was not expected creating api key: %s", err)
+	}
+	assert.Equal(t, "7", createdAPIKey.ID)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testFindAPIKey(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectQuery(`SELECT id, owner, role FROM api_keys WHERE key_hash = \?;`).
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner", "role"}).AddRow(7, "explorer", models.User))
+	mock.ExpectQuery(`SELECT id, owner, role FROM api_keys WHERE key_hash = \?;`).
+		WithArgs("unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner", "role"}))
+
+	apiKey, err := repository.FindAPIKey(context.Background(), "hash")
+	if err != nil {
+		t.Errorf("error was not expected finding api key: %s", err)
+	}
+	assert.Equal(t, &models.APIKey{ID: "7", Owner: "explorer", Role: models.User, KeyHash: "hash"}, apiKey)
+
+	apiKey, err = repository.FindAPIKey(context.Background(), "unknown")
+	assert.IsType(t, errors.APIKeyNotFound{}, err)
+	assert.Nil(t, apiKey)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testListAPIKeys(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectQuery(`SELECT id, owner, role FROM api_keys ORDER BY id;`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner", "role"}).
+			AddRow(1, "operator", models.Admin).
+			AddRow(2, "explorer", models.User))
+
+	apiKeys, err := repository.ListAPIKeys(context.Background())
+	if err != nil {
+		t.Errorf("error was not expected listing api keys: %s", err)
+	}
+	assert.Equal(t, []*models.APIKey{{ID: "1", Owner: "operator", Role: models.Admin}, {ID: "2", Owner: "explorer", Role: models.User}}, apiKeys)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testListAPIKeysRowError(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectQuery(`SELECT id, owner, role FROM api_keys ORDER BY id;`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner", "role"}).
+			AddRow(1, "operator", models.Admin).
+			AddRow(2, "explorer", models.User).
+			RowError(1, fmt.Errorf("connection lost"))).
+		RowsWillBeClosed()
+
+	apiKeys, err := repository.ListAPIKeys(context.Background())
+	assert.EqualError(t, err, "failed to list api keys: connection lost")
+	assert.Nil(t, apiKeys)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testDeleteAPIKey(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectExec(`DELETE FROM api_keys WHERE id = \?`).WithArgs("7").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM api_keys WHERE id = \?`).WithArgs("8").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repository.DeleteAPIKey(context.Background(), "7")
+	assert.Nil(t, err)
+
+	err = repository.DeleteAPIKey(context.Background(), "8")
+	assert.IsType(t, errors.APIKeyNotFound{}, err)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testBufferEvent(t *testing.T) {
+	repository, mock := initTest(t)
+
+	event := &models.BufferedEvent{EventType: models.ChainCommit, BlockHeight: 12, Payload: []byte("event")}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM subscriptions WHERE id = \?` + regexp.QuoteMeta(repository.dialect.forUpdate())).WithArgs("42").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("42"))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM buffered_events WHERE subscription = \?`).WithArgs("42").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	expectInsert(mock, `INSERT INTO buffered_events \(subscription, event_type, block_height, payload\)`, 7, "42", models.ChainCommit, uint32(12), []byte("event"))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM subscriptions WHERE id = \?`).WithArgs("42").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("42"))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM buffered_events WHERE subscription = \?`).WithArgs("42").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM subscriptions WHERE id = \?`).WithArgs("43").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	err := repository.BufferEvent(context.Background(), "42", event, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), event.ID)
+
+	// the buffer is full
+	err = repository.BufferEvent(context.Background(), "42", event, 2)
+	assert.IsType(t, errors.BufferFull{}, err)
+
+	// the subscription is deleted
+	err = repository.BufferEvent(context.Background(), "43", event, 2)
+	assert.IsType(t, errors.SubscriptionNotFound{}, err)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testPopBufferedEvents(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, event_type, block_height, payload FROM buffered_events WHERE subscription = \? ORDER BY id`).
+		WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "block_height", "payload"}).
+			AddRow(3, models.ChainCommit, 12, []byte("first")).
+			AddRow(5, models.EntryCommit, 13, []byte("second")))
+	mock.ExpectExec(`DELETE FROM buffered_events WHERE subscription = \? AND id <= \?`).WithArgs("42", int64(5)).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	events, err := repository.PopBufferedEvents(context.Background(), "42")
+	assert.Nil(t, err)
+	assert.Equal(t, []*models.BufferedEvent{
+		{ID: 3, EventType: models.ChainCommit, BlockHeight: 12, Payload: []byte("first")},
+		{ID: 5, EventType: models.EntryCommit, BlockHeight: 13, Payload: []byte("second")},
+	}, events)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testPopBufferedEventsRollbackOnFailure(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, event_type, block_height, payload FROM buffered_events`).
+		WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "block_height", "payload"}).AddRow(3, models.ChainCommit, 12, []byte("first")))
+	mock.ExpectExec(`DELETE FROM buffered_events`).WithArgs("42", int64(3)).WillReturnError(fmt.Errorf("some error"))
+	mock.ExpectRollback()
+
+	_, err := repository.PopBufferedEvents(context.Background(), "42")
+	assert.NotNil(t, err)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testClearBufferedEvents(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectExec(`DELETE FROM buffered_events WHERE subscription = \?`).WithArgs("42").WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := repository.ClearBufferedEvents(context.Background(), "42")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// the statement is retried when the connection to the database is lost
+func testRetryTransientError(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectExec(`DELETE FROM buffered_events WHERE subscription = \?`).WithArgs("42").
+		WillReturnError(&net.OpError{Op: "read", Net: "tcp", Err: fmt.Errorf("connection reset by peer")})
+	mock.ExpectExec(`DELETE FROM buffered_events WHERE subscription = \?`).WithArgs("42").WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := repository.ClearBufferedEvents(context.Background(), "42")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// the transaction fails when the database is not available after the retries
+func testRetryTransientErrorAttempts(t *testing.T) {
+	repository, mock := initTest(t)
+
+	for i := 0; i < retryAttempts; i++ {
+		mock.ExpectBegin().WillReturnError(&net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")})
+	}
+
+	_, err := repository.PopBufferedEvents(context.Background(), "42")
+	assert.NotNil(t, err)
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// an insert is not retried, because it may be committed before the connection failed
+func testInsertNotRetried(t *testing.T) {
+	repository, mock := initTest(t)
+
+	connectionErr := &net.OpError{Op: "read", Net: "tcp", Err: fmt.Errorf("connection reset by peer")}
+	if testDatabase == "postgres" {
+		mock.ExpectQuery(`INSERT INTO api_keys \(owner, role, key_hash\)`).WillReturnError(connectionErr)
+	} else {
+		mock.ExpectExec(`INSERT INTO api_keys \(owner, role, key_hash\)`).WillReturnError(connectionErr)
+	}
+	expectInsert(mock, `INSERT INTO api_keys \(owner, role, key_hash\)`, 7, "operator", models.Admin, "hash")
+
+	_, err := repository.CreateAPIKey(context.Background(), &models.APIKey{Owner: "operator", Role: models.Admin, KeyHash: "hash"})
+	assert.NotNil(t, err)
+
+	// the second insert is not executed
+	assert.NotNil(t, mock.ExpectationsWereMet())
+}
+
+func TestChangeCursor(t *testing.T) {
+	now := time.Now()
+	cursor := newChangeCursor()
+	cursor.lastID = 5
+
+	// the skipped ids are polled again
+	assert.True(t, cursor.pending(8))
+	cursor.advance(8, now)
+	assert.EqualValues(t, 5, cursor.from(now))
+	assert.True(t, cursor.pending(6))
+	assert.False(t, cursor.pending(8))
+
+	cursor.advance(6, now)
+	assert.False(t, cursor.pending(6))
+	assert.True(t, cursor.pending(7))
+	assert.EqualValues(t, 6, cursor.from(now))
+
+	// the gaps of rolled back changes expire
+	assert.EqualValues(t, 8, cursor.from(now.Add(changeGapTimeout+time.Second)))
+	assert.False(t, cursor.pending(7))
+
+	// at most the last gaps are tracked
+	cursor.advance(8+2*maxChangeGaps, now)
+	assert.Len(t, cursor.gaps, maxChangeGaps)
+	assert.EqualValues(t, 8+maxChangeGaps-1, cursor.from(now))
+}
+
+func TestRetry(t *testing.T) {
+	transient := func(err error) bool { return err.Error() == "transient" }
+
+	attempts := 0
+	err := retry(context.Background(), transient, 3, time.Millisecond, func() error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("transient")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// a permanent error is not retried
+	attempts = 0
+	err = retry(context.Background(), transient, 3, time.Millisecond, func() error {
+		attempts++
+		return fmt.Errorf("permanent")
+	})
+	assert.EqualError(t, err, "permanent")
+	assert.Equal(t, 1, attempts)
+
+	// the retries stop when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts = 0
+	err = retry(ctx, transient, 3, time.Hour, func() error {
+		attempts++
+		return fmt.Errorf("transient")
+	})
+	assert.EqualError(t, err, "transient")
+	assert.Equal(t, 1, attempts)
+}
+
+func testWatch(t *testing.T) {
+	repository, mock := initTest(t)
+	repository.pollInterval = 10 * time.Millisecond
+
+	columns := []string{"failures", "callback", "callback_type", "status", "info", "access_token", "username", "password", "token_url", "client_id", "client_secret", "scopes", "method", "headers", "file_sink", "owner", "version", "event_type", "filtering", "template"}
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(id\), 0\) FROM subscription_changes`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(`SELECT id, subscription, change_type FROM subscription_changes WHERE id > \? ORDER BY id`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription", "change_type"}).
+			AddRow(6, "42", models.Updated).
+			AddRow(7, "43", models.Deleted))
+	mock.ExpectQuery(`SELECT failures, callback, callback_type, status, info, access_token, username, password, token_url, client_id, client_secret, scopes, method, headers, file_sink, owner, version, event_type, filtering, template FROM subscriptions LEFT JOIN filters ON filters.subscription = subscriptions.id WHERE subscriptions.id = \?`).
+		WithArgs("42").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(0, "url", models.HTTP, models.Suspended, "", "", "", "", "", "", "", "", "", "", "", "", 3, models.ChainCommit, "", nil))
+	mock.ExpectQuery(`SELECT subscription, name, value FROM labels WHERE subscription IN \(\?\)`).
+		WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"subscription", "name", "value"}))
+	mock.ExpectQuery(`SELECT id, subscription, change_type FROM subscription_changes WHERE id > \? ORDER BY id`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription", "change_type"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, err := repository.Watch(ctx)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	updated := <-changes
+	assert.Equal(t, models.Updated, updated.Type)
+	assert.Equal(t, "42", updated.SubscriptionID)
+	if assert.NotNil(t, updated.SubscriptionContext) {
+		assert.Equal(t, models.Suspended, updated.SubscriptionContext.Subscription.SubscriptionStatus)
+		assert.EqualValues(t, 3, updated.SubscriptionContext.Version)
+	}
+	assert.Equal(t, models.SubscriptionChange{Type: models.Deleted, SubscriptionID: "43"}, <-changes)
+
+	// the changes are polled until the watch is cancelled
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && mock.ExpectationsWereMet() != nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	for range changes {
+	}
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// a change that commits after a change with a higher id is sent when it appears
+func testWatchLateChange(t *testing.T) {
+	repository, mock := initTest(t)
+	repository.pollInterval = 10 * time.Millisecond
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(id\), 0\) FROM subscription_changes`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(`SELECT id, subscription, change_type FROM subscription_changes WHERE id > \? ORDER BY id`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription", "change_type"}).AddRow(7, "43", models.Deleted))
+	mock.ExpectQuery(`SELECT id, subscription, change_type FROM subscription_changes WHERE id > \? ORDER BY id`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription", "change_type"}).
+			AddRow(6, "42", models.Deleted).
+			AddRow(7, "43", models.Deleted))
+	mock.ExpectQuery(`SELECT id, subscription, change_type FROM subscription_changes WHERE id > \? ORDER BY id`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription", "change_type"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, err := repository.Watch(ctx)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	// the late change is sent once and the change with the higher id is not sent again
+	assert.Equal(t, models.SubscriptionChange{Type: models.Deleted, SubscriptionID: "43"}, <-changes)
+	assert.Equal(t, models.SubscriptionChange{Type: models.Deleted, SubscriptionID: "42"}, <-changes)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && mock.ExpectationsWereMet() != nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	for change := range changes {
+		t.Errorf("unexpected change: %v", change)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// the old changes are pruned every interval, independent of a watch
+func testPruneChanges(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectExec(`DELETE FROM subscription_changes WHERE changed_at < `).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		repository.pruneChangesEvery(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && mock.ExpectationsWereMet() != nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// we make sure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func testClose(t *testing.T) {
+	repository, mock := initTest(t)
+
+	mock.ExpectClose()
+
+	err := repository.Close()
+	assert.Nil(t, err)
+
 	// we make sure that all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
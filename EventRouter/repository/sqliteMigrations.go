@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the schema migrations of the sqlite database in order, the migrations follow the migrations of mysql. Sqlite can't
+// drop columns, the migrations that add columns rebuild the table without the columns when they are reverted.
+var sqliteMigrations = []migration{
+	{
+		version:     1,
+		description: "create subscriptions and filters",
+		up: []string{
+			sqliteCreateSubscriptions("subscriptions", sqliteBaselineColumns),
+			sqliteCreateFilters("filters"),
+			`CREATE INDEX IF NOT EXISTS filters_subscription ON filters (subscription);`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS filters;`,
+			`DROP TABLE IF EXISTS subscriptions;`,
+		},
+	},
+	{
+		version:     2,
+		description: "add oauth2 client credentials",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN token_url TEXT;`,
+			`ALTER TABLE subscriptions ADD COLUMN client_id TEXT;`,
+			`ALTER TABLE subscriptions ADD COLUMN client_secret TEXT;`,
+			`ALTER TABLE subscriptions ADD COLUMN scopes TEXT;`,
+			`UPDATE subscriptions SET token_url = '', client_id = '', client_secret = '', scopes = '';`,
+		},
+		down:           sqliteRebuildSubscriptions(sqliteBaselineColumns),
+		rebuildsTables: true,
+	},
+	{
+		version:     3,
+		description: "add callback method and headers",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN method TEXT;`,
+			`ALTER TABLE subscriptions ADD COLUMN headers TEXT;`,
+			`UPDATE subscriptions SET method = '', headers = '';`,
+		},
+		down:           sqliteRebuildSubscriptions(sqliteBaselineColumns + 4),
+		rebuildsTables: true,
+	},
+	{
+		version:     4,
+		description: "add filter templates",
+		up: []string{
+			`ALTER TABLE filters ADD COLUMN template TEXT;`,
+		},
+		down: sqliteRebuildTable("filters", sqliteCreateFilters("filters_rebuild"), "id, subscription, event_type, filtering",
+			`CREATE INDEX IF NOT EXISTS filters_subscription ON filters (subscription);`),
+		rebuildsTables: true,
+	},
+	{
+		version:     5,
+		description: "add file sinks",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN file_sink TEXT;`,
+			`UPDATE subscriptions SET file_sink = '';`,
+		},
+		down:           sqliteRebuildSubscriptions(sqliteBaselineColumns + 6),
+		rebuildsTables: true,
+	},
+	{
+		version:     6,
+		description: "add callback hosts and labels",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN callback_host TEXT;`,
+			`CREATE INDEX IF NOT EXISTS subscriptions_callback_host ON subscriptions (callback_host);`,
+			`CREATE TABLE IF NOT EXISTS labels (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	subscription INTEGER REFERENCES subscriptions(id),
+	name TEXT NOT NULL,
+	value TEXT NOT NULL
+);`,
+			`CREATE INDEX IF NOT EXISTS labels_name_value ON labels (name, value);`,
+		},
+		down: append([]string{
+			`DROP TABLE IF EXISTS labels;`,
+		}, sqliteRebuildSubscriptions(sqliteBaselineColumns+7)...),
+		rebuildsTables: true,
+		upgrade:        backfillCallbackHosts,
+	},
+	{
+		version:     7,
+		description: "add owners and api keys",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN owner TEXT;`,
+			`UPDATE subscriptions SET owner = '';`,
+			`CREATE INDEX IF NOT EXISTS subscriptions_owner ON subscriptions (owner);`,
+			`CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner TEXT NOT NULL,
+	role TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE
+);`,
+		},
+		down: append([]string{
+			`DROP TABLE IF EXISTS api_keys;`,
+		}, sqliteRebuildSubscriptions(sqliteBaselineColumns+8)...),
+		rebuildsTables: true,
+	},
+	{
+		version:     8,
+		description: "add subscription versions",
+		up: []string{
+			`ALTER TABLE subscriptions ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+		},
+		down:           sqliteRebuildSubscriptions(sqliteBaselineColumns + 9),
+		rebuildsTables: true,
+	},
+	{
+		version:     9,
+		description: "create buffered events",
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS buffered_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	subscription INTEGER REFERENCES subscriptions(id),
+	event_type TEXT NOT NULL,
+	block_height INTEGER NOT NULL,
+	payload BLOB NOT NULL
+);`,
+			`CREATE INDEX IF NOT EXISTS buffered_events_subscription ON buffered_events (subscription);`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS buffered_events;`,
+		},
+	},
+	{
+		version:     10,
+		description: "create subscription changes",
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS subscription_changes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	subscription INTEGER NOT NULL,
+	change_type TEXT NOT NULL,
+	changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);`,
+			`CREATE INDEX IF NOT EXISTS subscription_changes_changed_at ON subscription_changes (changed_at);`,
+		},
+		down: []string{
+			`DROP TABLE IF EXISTS subscription_changes;`,
+		},
+	},
+}
+
+// the columns of the subscriptions table in the order they are added by the migrations
+var sqliteSubscriptionColumns = []string{
+	"id INTEGER PRIMARY KEY AUTOINCREMENT",
+	"failures INTEGER NOT NULL",
+	"callback TEXT NOT NULL",
+	"callback_type TEXT NOT NULL",
+	"status TEXT NOT NULL",
+	"info TEXT",
+	"access_token TEXT",
+	"username TEXT",
+	"password TEXT",
+	"token_url TEXT",
+	"client_id TEXT",
+	"client_secret TEXT",
+	"scopes TEXT",
+	"method TEXT",
+	"headers TEXT",
+	"file_sink TEXT",
+	"callback_host TEXT",
+	"owner TEXT",
+	"version INTEGER NOT NULL DEFAULT 1",
+}
+
+// the number of columns of the subscriptions table in the first migration
+const sqliteBaselineColumns = 9
+
+// sqliteCreateSubscriptions returns the statement to create the subscriptions table with the first n columns
+func sqliteCreateSubscriptions(table string, n int) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n);", table, strings.Join(sqliteSubscriptionColumns[:n], ",\n\t"))
+}
+
+// sqliteCreateFilters returns the statement to create the filters table of the first migration
+func sqliteCreateFilters(table string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\tid INTEGER PRIMARY KEY AUTOINCREMENT,\n\tsubscription INTEGER REFERENCES subscriptions(id),\n\tevent_type TEXT NOT NULL,\n\tfiltering TEXT\n);", table)
+}
+
+// sqliteRebuildSubscriptions rebuilds the subscriptions table with the first n columns and the indexes of the columns
+func sqliteRebuildSubscriptions(n int) []string {
+	names := make([]string, n)
+	for i, column := range sqliteSubscriptionColumns[:n] {
+		names[i] = strings.Fields(column)[0]
+	}
+
+	var indexes []string
+	for _, column := range []string{"callback_host", "owner"} {
+		for _, name := range names {
+			if name == column {
+				indexes = append(indexes, fmt.Sprintf("CREATE INDEX IF NOT EXISTS subscriptions_%s ON subscriptions (%s);", column, column))
+			}
+		}
+	}
+	return sqliteRebuildTable("subscriptions", sqliteCreateSubscriptions("subscriptions_rebuild", n), strings.Join(names, ", "), indexes...)
+}
+
+// sqliteRebuildTable copies the columns of the table to the rebuilt table, which replaces the table. The rows of other
+// tables that reference the table are kept, the foreign keys are disabled while the table is rebuilt.
+func sqliteRebuildTable(table string, create string, columns string, indexes ...string) []string {
+	statements := []string{
+		create,
+		fmt.Sprintf("INSERT INTO %s_rebuild (%s) SELECT %s FROM %s;", table, columns, columns, table),
+		fmt.Sprintf("DROP TABLE %s;", table),
+		fmt.Sprintf("ALTER TABLE %s_rebuild RENAME TO %s;", table, table),
+	}
+	return append(statements, indexes...)
+}
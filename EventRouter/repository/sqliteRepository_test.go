@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/FactomProject/live-feed-api/EventRouter/config"
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"github.com/FactomProject/live-feed-api/EventRouter/models/errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// initSQLiteTest opens a sqlite repository in a temporary database file
+func initSQLiteTest(t *testing.T) (*sqlRepository, func()) {
+	return initSQLiteTestWithPool(t, 0, 2)
+}
+
+// initSQLiteTestWithPool opens a sqlite repository with the connection pool settings in a temporary database file
+func initSQLiteTestWithPool(t *testing.T, maxOpenConnections int, maxIdleConnections int) (*sqlRepository, func()) {
+	dir, err := ioutil.TempDir("", "live-feed")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+
+	repository, err := NewSQLRepository(&config.DatabaseConfig{
+		Database:         "sqlite",
+		ConnectionString: filepath.Join(dir, "live-feed.db"),
+		PollInterval:     1,
+		Migrate:          true,
+
+		MaxOpenConnections: maxOpenConnections,
+		MaxIdleConnections: maxIdleConnections,
+	})
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		t.Fatalf("failed to open sqlite repository: %v", err)
+	}
+
+	return repository.(*sqlRepository), func() {
+		_ = repository.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestSQLiteSubscriptions(t *testing.T) {
+	repository, cleanup := initSQLiteTest(t)
+	defer cleanup()
+
+	created, err := repository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{
+			CallbackURL:        "http://localhost/callback",
+			CallbackType:       models.HTTP,
+			SubscriptionStatus: models.Active,
+			Filters: map[models.EventType]models.Filter{
+				models.ChainCommit: {Filtering: "filtering"},
+			},
+			Labels: map[string]string{"team": "explorer"},
+		},
+	})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	id := created.Subscription.ID
+
+	// the created subscription has the stored version
+	read, err := repository.ReadSubscription(context.Background(), id)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, created.Version)
+	assert.Equal(t, created.Version, read.Version)
+	assert.Equal(t, "http://localhost/callback", read.Subscription.CallbackURL)
+	assert.Equal(t, "filtering", read.Subscription.Filters[models.ChainCommit].Filtering)
+	assert.Equal(t, map[string]string{"team": "explorer"}, read.Subscription.Labels)
+
+	read.Subscription.CallbackURL = "http://localhost/updated"
+	read.Subscription.Filters = map[models.EventType]models.Filter{models.EntryCommit: {Filtering: "updated"}}
+	updated, err := repository.UpdateSubscription(context.Background(), read)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, updated.Version)
+
+	active, err := repository.GetActiveSubscriptions(context.Background(), models.EntryCommit)
+	assert.Nil(t, err)
+	if assert.Len(t, active, 1) {
+		assert.Equal(t, id, active[0].Subscription.ID)
+		assert.Equal(t, "http://localhost/updated", active[0].Subscription.CallbackURL)
+	}
+	active, err = repository.GetActiveSubscriptions(context.Background(), models.ChainCommit)
+	assert.Nil(t, err)
+	assert.Empty(t, active)
+
+	listed, total, err := repository.ListSubscriptions(context.Background(), &models.SubscriptionQuery{Labels: map[string]string{"team": "explorer"}, Limit: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, total)
+	assert.Len(t, listed, 1)
+
+	updated.Subscription.SubscriptionStatus = models.Suspended
+	assert.Nil(t, repository.UpdateSubscriptionStatus(context.Background(), updated))
+	read, err = repository.ReadSubscription(context.Background(), id)
+	assert.Nil(t, err)
+	assert.Equal(t, models.Suspended, read.Subscription.SubscriptionStatus)
+
+	// the status of the deliveries is not a change of the user and keeps the version
+	assert.EqualValues(t, 2, read.Version)
+
+	assert.Nil(t, repository.DeleteSubscription(context.Background(), id))
+	_, err = repository.ReadSubscription(context.Background(), id)
+	assert.IsType(t, errors.SubscriptionNotFound{}, err)
+}
+
+func TestSQLiteBufferedEvents(t *testing.T) {
+	repository, cleanup := initSQLiteTest(t)
+	defer cleanup()
+
+	created, err := repository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{CallbackURL: "url", CallbackType: models.HTTP, SubscriptionStatus: models.Paused},
+	})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	id := created.Subscription.ID
+
+	for i := 0; i < 2; i++ {
+		event := &models.BufferedEvent{EventType: models.ChainCommit, BlockHeight: uint32(12 + i), Payload: []byte(fmt.Sprintf("event %d", i))}
+		assert.Nil(t, repository.BufferEvent(context.Background(), id, event, 2))
+		assert.NotZero(t, event.ID)
+	}
+	assert.IsType(t, errors.BufferFull{}, repository.BufferEvent(context.Background(), id, &models.BufferedEvent{Payload: []byte("full")}, 2))
+
+	events, err := repository.PopBufferedEvents(context.Background(), id)
+	assert.Nil(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, uint32(12), events[0].BlockHeight)
+		assert.Equal(t, []byte("event 1"), events[1].Payload)
+	}
+
+	count, err := repository.CountBufferedEvents(context.Background(), id)
+	assert.Nil(t, err)
+	assert.Zero(t, count)
+}
+
+func TestSQLiteAPIKeys(t *testing.T) {
+	repository, cleanup := initSQLiteTest(t)
+	defer cleanup()
+
+	created, err := repository.CreateAPIKey(context.Background(), &models.APIKey{Owner: "explorer", Role: models.User, KeyHash: "hash"})
+	assert.Nil(t, err)
+
+	found, err := repository.FindAPIKey(context.Background(), "hash")
+	assert.Nil(t, err)
+	assert.Equal(t, created.ID, found.ID)
+	assert.Equal(t, "explorer", found.Owner)
+
+	assert.Nil(t, repository.DeleteAPIKey(context.Background(), created.ID))
+	apiKeys, err := repository.ListAPIKeys(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, apiKeys)
+}
+
+func TestSQLiteWatch(t *testing.T) {
+	repository, cleanup := initSQLiteTest(t)
+	defer cleanup()
+	repository.pollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := repository.Watch(ctx)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	created, err := repository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{CallbackURL: "url", CallbackType: models.HTTP, SubscriptionStatus: models.Active},
+	})
+	assert.Nil(t, err)
+	select {
+	case change := <-changes:
+		assert.Equal(t, models.Created, change.Type)
+		assert.Equal(t, created.Subscription.ID, change.SubscriptionID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change of the created subscription")
+	}
+
+	assert.Nil(t, repository.DeleteSubscription(context.Background(), created.Subscription.ID))
+	select {
+	case change := <-changes:
+		assert.Equal(t, models.SubscriptionChange{Type: models.Deleted, SubscriptionID: created.Subscription.ID}, change)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change of the deleted subscription")
+	}
+}
+
+// the concurrent transactions wait for the write lock of the database file
+func TestSQLiteConcurrentWrites(t *testing.T) {
+	repository, cleanup := initSQLiteTest(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := repository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+				Subscription: models.Subscription{
+					CallbackURL:        fmt.Sprintf("http://localhost/%d", i),
+					CallbackType:       models.HTTP,
+					SubscriptionStatus: models.Active,
+					Filters:            map[models.EventType]models.Filter{models.ChainCommit: {}},
+				},
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+	active, err := repository.GetActiveSubscriptions(context.Background(), models.ChainCommit)
+	assert.Nil(t, err)
+	assert.Len(t, active, 20)
+}
+
+// the concurrent events of a subscription are buffered one at a time, such that the buffer doesn't exceed the limit
+func TestSQLiteConcurrentBufferedEvents(t *testing.T) {
+	repository, cleanup := initSQLiteTestWithPool(t, 10, 2)
+	defer cleanup()
+
+	created, err := repository.CreateSubscription(context.Background(), &models.SubscriptionContext{
+		Subscription: models.Subscription{CallbackURL: "url", CallbackType: models.HTTP, SubscriptionStatus: models.Paused},
+	})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	id := created.Subscription.ID
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repository.BufferEvent(context.Background(), id, &models.BufferedEvent{Payload: []byte(fmt.Sprintf("event %d", i))}, 5)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	full := 0
+	for err := range errs {
+		if err != nil {
+			assert.IsType(t, errors.BufferFull{}, err)
+			full++
+		}
+	}
+	assert.Equal(t, 15, full)
+
+	count, err := repository.CountBufferedEvents(context.Background(), id)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, count)
+}
+
+// every repository has its own pool of connections, closing one repository doesn't close the others
+func TestSQLiteConnectionPool(t *testing.T) {
+	first, cleanupFirst := initSQLiteTestWithPool(t, 3, 1)
+	defer cleanupFirst()
+	second, cleanupSecond := initSQLiteTest(t)
+	defer cleanupSecond()
+
+	assert.Equal(t, 3, first.db.Stats().MaxOpenConnections)
+	assert.Equal(t, 0, second.db.Stats().MaxOpenConnections)
+
+	assert.Nil(t, first.Close())
+	assert.NotNil(t, first.Ping(context.Background()))
+	assert.Nil(t, second.Ping(context.Background()))
+}
@@ -1,4 +1,22 @@
 package repository
 
+import (
+	"github.com/FactomProject/live-feed-api/EventRouter/models"
+	"net/url"
+	"strings"
+)
+
 // SubscriptionRepository the repository that is used in the api
 var SubscriptionRepository Repository = NewInMemoryRepository()
+
+// callbackHost returns the host of the callback url, which is used to list the subscriptions of a host
+func callbackHost(subscription *models.Subscription) string {
+	if subscription.CallbackType == models.File {
+		return ""
+	}
+	u, err := url.Parse(subscription.CallbackURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
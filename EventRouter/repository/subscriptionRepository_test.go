@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"github.com/FactomProject/live-feed-api/EventRouter/log"
 	"github.com/FactomProject/live-feed-api/EventRouter/models"
@@ -72,31 +73,31 @@ func testCRUD(t *testing.T) {
 }
 
 func testCreate(t *testing.T, repository Repository, subscriptionContext *models.SubscriptionContext) {
-	createdSubscriptionContext, err := repository.CreateSubscription(subscriptionContext)
+	createdSubscriptionContext, err := repository.CreateSubscription(context.Background(), subscriptionContext)
 	assertNilError(t, err)
 	assertSubscription(t, subscriptionContext, createdSubscriptionContext)
 	subscriptionContext.Subscription.ID = createdSubscriptionContext.Subscription.ID
 }
 
 func testRead(t *testing.T, repository Repository, subscriptionContext *models.SubscriptionContext) {
-	readSubscriptionContext, err := repository.ReadSubscription(subscriptionContext.Subscription.ID)
+	readSubscriptionContext, err := repository.ReadSubscription(context.Background(), subscriptionContext.Subscription.ID)
 	assertNilError(t, err)
 	assertSubscription(t, subscriptionContext, readSubscriptionContext)
 }
 
 func testUpdate(t *testing.T, repository Repository, subscriptionContext *models.SubscriptionContext) {
-	updatedSubscriptionContext, err := repository.UpdateSubscription(subscriptionContext)
+	updatedSubscriptionContext, err := repository.UpdateSubscription(context.Background(), subscriptionContext)
 	assertNilError(t, err)
 	assertSubscription(t, subscriptionContext, updatedSubscriptionContext)
 }
 
 func testDelete(t *testing.T, repository Repository, subscriptionContext *models.SubscriptionContext) {
-	err := repository.DeleteSubscription(subscriptionContext.Subscription.ID)
+	err := repository.DeleteSubscription(context.Background(), subscriptionContext.Subscription.ID)
 	assertNilError(t, err)
 }
 
 func testNoExits(t *testing.T, repository Repository, subscriptionContext *models.SubscriptionContext) {
-	unknownSubscriptionContext, err := repository.ReadSubscription(subscriptionContext.Subscription.ID)
+	unknownSubscriptionContext, err := repository.ReadSubscription(context.Background(), subscriptionContext.Subscription.ID)
 	assert.NotNil(t, err)
 	assert.Nil(t, unknownSubscriptionContext)
 }
@@ -110,16 +111,26 @@ func assertSubscription(t *testing.T, expected *models.SubscriptionContext, actu
 	assert.Equal(t, expected.Failures, actual.Failures)
 	assert.Equal(t, expected.Subscription.CallbackURL, actual.Subscription.CallbackURL)
 	assert.Equal(t, expected.Subscription.CallbackType, actual.Subscription.CallbackType)
+	assert.Equal(t, expected.Subscription.CallbackMethod, actual.Subscription.CallbackMethod)
+	assert.Equal(t, expected.Subscription.CallbackHeaders, actual.Subscription.CallbackHeaders)
+	assert.Equal(t, expected.Subscription.FileSink, actual.Subscription.FileSink)
+	assert.Equal(t, expected.Subscription.Labels, actual.Subscription.Labels)
+	assert.Equal(t, expected.Subscription.Owner, actual.Subscription.Owner)
 	assert.Equal(t, expected.Subscription.SubscriptionStatus, actual.Subscription.SubscriptionStatus)
 	assert.Equal(t, expected.Subscription.SubscriptionInfo, actual.Subscription.SubscriptionInfo)
 	assert.Equal(t, expected.Subscription.Credentials.AccessToken, actual.Subscription.Credentials.AccessToken)
 	assert.Equal(t, expected.Subscription.Credentials.BasicAuthUsername, actual.Subscription.Credentials.BasicAuthUsername)
 	assert.Equal(t, expected.Subscription.Credentials.BasicAuthPassword, actual.Subscription.Credentials.BasicAuthPassword)
+	assert.Equal(t, expected.Subscription.Credentials.OAuth2TokenURL, actual.Subscription.Credentials.OAuth2TokenURL)
+	assert.Equal(t, expected.Subscription.Credentials.OAuth2ClientID, actual.Subscription.Credentials.OAuth2ClientID)
+	assert.Equal(t, expected.Subscription.Credentials.OAuth2ClientSecret, actual.Subscription.Credentials.OAuth2ClientSecret)
+	assert.Equal(t, expected.Subscription.Credentials.OAuth2Scopes, actual.Subscription.Credentials.OAuth2Scopes)
 	assert.Equal(t, len(expected.Subscription.Filters), len(actual.Subscription.Filters))
 
 	for eventType, filter := range expected.Subscription.Filters {
 		assert.NotNil(t, actual.Subscription.Filters[eventType])
 		assert.Equal(t, filter.Filtering, actual.Subscription.Filters[eventType].Filtering)
+		assert.Equal(t, filter.Template, actual.Subscription.Filters[eventType].Template)
 	}
 }
 
@@ -147,7 +158,7 @@ func testConcurrency(t *testing.T, repository Repository) {
 
 	// calculate the offset if the database already has entries
 	// Although the database should be clean and clean-up afterwards,
-	previousSubscriptions, err := repository.GetActiveSubscriptions(eventType)
+	previousSubscriptions, err := repository.GetActiveSubscriptions(context.Background(), eventType)
 	offset := len(previousSubscriptions)
 
 	n := 100
@@ -157,14 +168,14 @@ func testConcurrency(t *testing.T, repository Repository) {
 		go func(x int) {
 			defer wait.Done()
 
-			subscriptionContext, err := repository.CreateSubscription(subscriptionContext)
+			subscriptionContext, err := repository.CreateSubscription(context.Background(), subscriptionContext)
 			assert.Nil(t, err)
 			t.Logf("%d: created %s", x, subscriptionContext.Subscription.ID)
 		}(i)
 	}
 	wait.Wait()
 
-	subscriptions, err := repository.GetActiveSubscriptions(eventType)
+	subscriptions, err := repository.GetActiveSubscriptions(context.Background(), eventType)
 	assert.Nil(t, err)
 	assert.Equal(t, n, len(subscriptions)-offset)
 }
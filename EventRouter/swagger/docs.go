@@ -1,6 +1,6 @@
 // GENERATED BY THE COMMAND ABOVE; DO NOT EDIT
 // This file was generated by swaggo/swag at
-// 2020-01-16 15:20:28.906173726 +0100 CET m=+0.095445369
+// 2026-10-19 00:59:11.13373831 +0000 UTC m=+0.197034600
 
 package docs
 
@@ -29,8 +29,554 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/subscriptions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the subscriptions of all owners ordered by id. The subscriptions can be filtered on owner, status, event type, callback host and labels. Only administrators can list all subscriptions.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "list all subscriptions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "owner of the subscriptions",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "ACTIVE",
+                            "SUSPENDED",
+                            "PAUSED",
+                            "PENDING_VERIFICATION"
+                        ],
+                        "type": "string",
+                        "description": "subscription status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "DIRECTORY_BLOCK_COMMIT",
+                            "DIRECTORY_BLOCK_ANCHOR",
+                            "CHAIN_COMMIT",
+                            "ENTRY_COMMIT",
+                            "ENTRY_REVEAL",
+                            "STATE_CHANGE",
+                            "PROCESS_LIST_EVENT",
+                            "NODE_MESSAGE"
+                        ],
+                        "type": "string",
+                        "description": "event type of one of the filters",
+                        "name": "eventType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "host of the callback url",
+                        "name": "callbackHost",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "format": "multi",
+                        "items": {
+                            "type": "string"
+                        },
+                        "description": "label formatted as name:value, the parameter can be repeated to match multiple labels",
+                        "name": "label",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "offset of the first subscription",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 25,
+                        "description": "maximum number of subscriptions",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "subscriptions",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/subscriptions/{id}/queue": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Return the number of events that wait to be delivered to a subscription of any owner. Only administrators can inspect the queues.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "inspect the queue of a subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "queue",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionQueue"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove the events that wait to be delivered to a subscription of any owner. The events are lost. Only administrators can purge the queues.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "purge the queue of a subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "queue after the purge with the number of purged events",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionQueue"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/subscriptions/{id}/status": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Force the status of a subscription of any owner, to suspend an abusive subscription or to reactivate a subscription. The reason is stored as the subscription info and the failures are reset. A reactivated subscription receives the events in its queue. Only administrators can change the status.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "change the status of a subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "the new status",
+                        "name": "statusChange",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionStatusChange"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "subscription updated",
+                        "schema": {
+                            "$ref": "#/definitions/models.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/apikeys": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the api keys that are issued. The keys themselves are not returned. Only administrators can list api keys.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "list api keys",
+                "responses": {
+                    "200": {
+                        "description": "api keys",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.APIKey"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issue an api key to a client. The key is only returned in this response, the live feed only stores a hash of the key. Only administrators can create api keys.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "create an api key",
+                "parameters": [
+                    {
+                        "description": "api key to be created",
+                        "name": "apiKey",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.APIKey"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "api key created",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIKey"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/apikeys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke an api key. Only administrators can delete api keys.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "delete an api key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "api key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "api key deleted"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            }
+        },
         "/subscriptions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the subscriptions ordered by id. The subscriptions can be filtered on status, event type, callback host and labels. Subscriptions match when they match all the given criteria.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "list subscriptions",
+                "parameters": [
+                    {
+                        "enum": [
+                            "ACTIVE",
+                            "SUSPENDED",
+                            "PAUSED",
+                            "PENDING_VERIFICATION"
+                        ],
+                        "type": "string",
+                        "description": "subscription status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "DIRECTORY_BLOCK_COMMIT",
+                            "DIRECTORY_BLOCK_ANCHOR",
+                            "CHAIN_COMMIT",
+                            "ENTRY_COMMIT",
+                            "ENTRY_REVEAL",
+                            "STATE_CHANGE",
+                            "PROCESS_LIST_EVENT",
+                            "NODE_MESSAGE"
+                        ],
+                        "type": "string",
+                        "description": "event type of one of the filters",
+                        "name": "eventType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "host of the callback url",
+                        "name": "callbackHost",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "format": "multi",
+                        "items": {
+                            "type": "string"
+                        },
+                        "description": "label formatted as name:value, the parameter can be repeated to match multiple labels",
+                        "name": "label",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "offset of the first subscription",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 25,
+                        "description": "maximum number of subscriptions",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "subscriptions",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            },
             "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Subscribe an application to receive events.",
                 "consumes": [
                     "application/json"
@@ -41,20 +587,149 @@ var doc = `{
                 "summary": "subscribe an application",
                 "parameters": [
                     {
-                        "description": "subscription to be created",
+                        "description": "subscription to be created",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Subscription"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "subscription created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Return a subscription with the given id.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "get a subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "subscription",
+                        "schema": {
+                            "$ref": "#/definitions/models.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "entity tag of the subscription"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update a subscription for receiving events. Updating the subscription can be used to change the endpoint url, adjust the filtering, add of remove the subscription for event types. When the subscription failed to deliver and got SUSPENDED, the endpoint can used to re-ACTIVATE the subscription. With the If-Match header the update only succeeds when the subscription still has the given entity tag.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "update a subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "subscription to be updated",
                         "name": "subscription",
                         "in": "body",
                         "required": true,
                         "schema": {
                             "$ref": "#/definitions/models.Subscription"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "entity tag of the subscription, the update fails when the subscription has been changed",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "subscription created",
+                    "200": {
+                        "description": "subscription updated",
                         "schema": {
                             "$ref": "#/definitions/models.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "entity tag of the updated subscription"
+                            }
                         }
                     },
                     "400": {
@@ -62,20 +737,44 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/models.APIError"
                         }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
                     }
                 }
-            }
-        },
-        "/subscriptions/{id}": {
-            "get": {
-                "description": "Return a subscription with the given id.",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Unsubscribe an application from receiving events.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "get a subscription",
+                "summary": "delete a subscription",
                 "parameters": [
                     {
                         "type": "integer",
@@ -87,10 +786,7 @@ var doc = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "subscription",
-                        "schema": {
-                            "$ref": "#/definitions/models.Subscription"
-                        }
+                        "description": "subscription deleted"
                     },
                     "400": {
                         "description": "Bad Request",
@@ -98,6 +794,12 @@ var doc = `{
                             "$ref": "#/definitions/models.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -106,15 +808,23 @@ var doc = `{
                     }
                 }
             },
-            "put": {
-                "description": "Update a subscription for receiving events. Updating the subscription can be used to change the endpoint url, adjust the filtering, add of remove the subscription for event types. When the subscription failed to deliver and got SUSPENDED, the endpoint can used to re-ACTIVATE the subscription.",
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Partially update a subscription with a JSON merge patch (RFC 7396). Only the given fields are changed, a field that is null is removed, for example a filter of an event type. The patched subscription is validated like an updated subscription. Patching the status re-activates a SUSPENDED subscription. With the If-Match header the patch only succeeds when the subscription still has the given entity tag.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "update a subscription",
+                "summary": "patch a subscription",
                 "parameters": [
                     {
                         "type": "integer",
@@ -124,20 +834,32 @@ var doc = `{
                         "required": true
                     },
                     {
-                        "description": "subscription to be updated",
-                        "name": "subscription",
+                        "description": "merge patch with the fields to change",
+                        "name": "patch",
                         "in": "body",
                         "required": true,
                         "schema": {
                             "$ref": "#/definitions/models.Subscription"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "entity tag of the subscription, the patch fails when the subscription has been changed",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "subscription updated",
+                        "description": "subscription patched",
                         "schema": {
                             "$ref": "#/definitions/models.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "entity tag of the patched subscription"
+                            }
                         }
                     },
                     "400": {
@@ -146,23 +868,51 @@ var doc = `{
                             "$ref": "#/definitions/models.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/models.APIError"
                         }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
                     }
                 }
-            },
-            "delete": {
-                "description": "Unsubscribe an application from receiving events.",
+            }
+        },
+        "/subscriptions/{id}/test": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Send a synthetic event of the event type to the subscription right away, to check the endpoint without waiting for a real event. The event is filtered and rendered with the filter of the event type, like a real event. The hashes and signatures of the synthetic event are not valid. The failures, status and queue of the subscription are not affected. The delivery result contains the status code, the latency and the body of the response of the endpoint. Only subscriptions that deliver events over http can be tested.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "delete a subscription",
+                "summary": "test the delivery to a subscription",
                 "parameters": [
                     {
                         "type": "integer",
@@ -170,11 +920,84 @@ var doc = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "the event type of the synthetic event",
+                        "name": "deliveryTest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeliveryTest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "subscription deleted"
+                        "description": "delivery result",
+                        "schema": {
+                            "$ref": "#/definitions/models.DeliveryResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/verification": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Send a new challenge to the callback url of a subscription that is PENDING_VERIFICATION. The subscription becomes ACTIVE when the endpoint echoes the challenge, otherwise the subscription stays PENDING_VERIFICATION and the info contains the reason.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "re-send the verification challenge",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "subscription after the verification",
+                        "schema": {
+                            "$ref": "#/definitions/models.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "entity tag of the subscription"
+                            }
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -182,6 +1005,12 @@ var doc = `{
                             "$ref": "#/definitions/models.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIError"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -210,6 +1039,38 @@ var doc = `{
                 }
             }
         },
+        "models.APIKey": {
+            "type": "object",
+            "required": [
+                "owner"
+            ],
+            "properties": {
+                "id": {
+                    "description": "The id of the api key.",
+                    "type": "string",
+                    "readOnly": true
+                },
+                "key": {
+                    "description": "The api key that is set in the X-API-Key header. The key is only returned when the api key is created.",
+                    "type": "string",
+                    "readOnly": true
+                },
+                "owner": {
+                    "description": "The owner of the subscriptions that are created with the api key. Clients only see the subscriptions of their owner.",
+                    "type": "string",
+                    "example": "explorer"
+                },
+                "role": {
+                    "description": "Role of the client. USER manages its own subscriptions, ADMIN can also manage the api keys.",
+                    "type": "string",
+                    "enum": [
+                        "USER",
+                        "ADMIN"
+                    ],
+                    "example": "USER"
+                }
+            }
+        },
         "models.Credentials": {
             "type": "object",
             "properties": {
@@ -224,6 +1085,87 @@ var doc = `{
                 "basicAuthUsername": {
                     "description": "Username for authenticating with basic authentication. This is required when the callback type is set on BASIC_AUTH.",
                     "type": "string"
+                },
+                "oauth2ClientId": {
+                    "description": "Client id to request an access token with the client credentials grant. This is required when the callback type is set on OAUTH2_CLIENT_CREDENTIALS.",
+                    "type": "string"
+                },
+                "oauth2ClientSecret": {
+                    "description": "Client secret to request an access token with the client credentials grant. This is required when the callback type is set on OAUTH2_CLIENT_CREDENTIALS.",
+                    "type": "string"
+                },
+                "oauth2Scopes": {
+                    "description": "Optional scopes that are requested with the access token when the callback type is set on OAUTH2_CLIENT_CREDENTIALS.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "oauth2TokenUrl": {
+                    "description": "Token endpoint of the authorization server where access tokens are requested. This is required when the callback type is set on OAUTH2_CLIENT_CREDENTIALS.",
+                    "type": "string"
+                }
+            }
+        },
+        "models.DeliveryResult": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "string"
+                },
+                "delivered": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "description": "the reason that the event is not delivered",
+                    "type": "string"
+                },
+                "eventType": {
+                    "type": "string"
+                },
+                "latency": {
+                    "description": "the time in milliseconds between sending the event and receiving the response",
+                    "type": "integer"
+                },
+                "statusCode": {
+                    "description": "the status code and the body of the response of the endpoint, the body is truncated",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.DeliveryTest": {
+            "type": "object",
+            "properties": {
+                "eventType": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FileSink": {
+            "type": "object",
+            "properties": {
+                "compress": {
+                    "description": "Gzip the rotated files.",
+                    "type": "boolean"
+                },
+                "format": {
+                    "description": "Format of the events in the file. JSON_LINES writes every (filtered) event as json on a single line. PROTOBUF writes every event as length-delimited protobuf, the filtering is not applied. When no format is given the events are written as JSON_LINES.",
+                    "type": "string",
+                    "enum": [
+                        "JSON_LINES",
+                        "PROTOBUF"
+                    ],
+                    "example": "JSON_LINES"
+                },
+                "maxAge": {
+                    "description": "Rotate the file when it has been written to for the number of seconds. No rotation on time when set to 0.",
+                    "type": "integer",
+                    "example": 86400
+                },
+                "maxSize": {
+                    "description": "Rotate the file when it exceeds the size in bytes. No rotation on size when set to 0.",
+                    "type": "integer",
+                    "example": 104857600
                 }
             }
         },
@@ -234,6 +1176,11 @@ var doc = `{
                     "description": "Define a Filter on an EventType to filter the event. This allows to reduce the network traffic. The filtering is done with GraphQL",
                     "type": "string",
                     "example": "{ identityChainID { hashValue } value { ... on NodeMessage { messageCode messageText } } }"
+                },
+                "template": {
+                    "description": "Optional Go text/template to render the (filtered) event into the body that is delivered, for example to post a message to a chat webhook. The functions hex, ecAddress, factoidAddress, timestamp, formatTimestamp and json are available in the template.",
+                    "type": "string",
+                    "example": "{\"text\": {{json .event.factomNodeName}}}"
                 }
             }
         },
@@ -244,18 +1191,38 @@ var doc = `{
                 "callbackUrl"
             ],
             "properties": {
+                "callbackHeaders": {
+                    "description": "Static headers that are added to every delivery to the callback endpoint, for example an api key or a tenant header. The Content-Type header can be overridden, authentication headers are set by the callback type.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "callbackMethod": {
+                    "description": "The http method that is used to deliver the events to the callback endpoint. When no method is given the events are delivered with POST.",
+                    "type": "string",
+                    "enum": [
+                        "POST",
+                        "PUT",
+                        "PATCH"
+                    ],
+                    "example": "POST"
+                },
                 "callbackType": {
-                    "description": "Type of callback.\n- HTTP to deliver the events to a http/https endpoint.\n- BEARER_TOKEN to deliver the events to a http/https endpoint with a bearer token for authentication.\n- BASIC_AUTH to deliver the events to a http/https endpoint with a basic authentication.",
+                    "description": "Type of callback.\n- HTTP to deliver the events to a http/https endpoint.\n- BEARER_TOKEN to deliver the events to a http/https endpoint with a bearer token for authentication.\n- BASIC_AUTH to deliver the events to a http/https endpoint with a basic authentication.\n- OAUTH2_CLIENT_CREDENTIALS to deliver the events to a http/https endpoint with an access token that is requested with the oauth2 client credentials grant.\n- FILE to append the events to a local file.\n- RELAY to forward the complete events in the factomd event stream format to another live feed or receiver.",
                     "type": "string",
                     "enum": [
                         "HTTP",
                         "BEARER_TOKEN",
-                        "BASIC_AUTH"
+                        "BASIC_AUTH",
+                        "OAUTH2_CLIENT_CREDENTIALS",
+                        "FILE",
+                        "RELAY"
                     ],
                     "example": "HTTP"
                 },
                 "callbackUrl": {
-                    "description": "The callback endpoint to receive the events.",
+                    "description": "The callback endpoint to receive the events. For the FILE callback type this is the path of the file relative to the file sink directory of the live feed, the path can contain {{.EventType}} and {{.BlockHeight}}. For the RELAY callback type this is the address of the receiver: tcp://host:port.",
                     "type": "string",
                     "example": "https://server.com/events"
                 },
@@ -264,6 +1231,11 @@ var doc = `{
                     "type": "object",
                     "$ref": "#/definitions/models.Credentials"
                 },
+                "fileSink": {
+                    "description": "Settings of the file where the events are written to when the callback type is FILE.",
+                    "type": "object",
+                    "$ref": "#/definitions/models.FileSink"
+                },
                 "filters": {
                     "description": "The emitted event can be filter to receive not all data from an event type. Subscribe on one or more event types. For every event type a filtering can be defined.",
                     "type": "object",
@@ -281,17 +1253,103 @@ var doc = `{
                     "type": "string",
                     "readOnly": true
                 },
+                "labels": {
+                    "description": "Labels to organize subscriptions, for example by team or environment. Subscriptions can be listed by their labels.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "owner": {
+                    "description": "The owner of the subscription, which is the owner of the api key that created the subscription.",
+                    "type": "string",
+                    "readOnly": true
+                },
                 "status": {
                     "description": "Status of subscription. Normally a subscription is active. When events fail to be delivered the subscription will be suspended. The subscription can become active again by updating the subscription. When the subscription is suspended, the error information is set in the info field.",
                     "type": "string",
                     "enum": [
                         "ACTIVE",
-                        "SUSPENDED"
+                        "SUSPENDED",
+                        "PAUSED",
+                        "PENDING_VERIFICATION"
                     ],
                     "readOnly": true,
                     "example": "ACTIVE"
                 }
             }
+        },
+        "models.SubscriptionList": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "description": "The maximum number of subscriptions on the page.",
+                    "type": "integer",
+                    "example": 25
+                },
+                "offset": {
+                    "description": "The offset of the first subscription on the page.",
+                    "type": "integer",
+                    "example": 0
+                },
+                "subscriptions": {
+                    "description": "The subscriptions on the page ordered by id.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Subscription"
+                    }
+                },
+                "total": {
+                    "description": "The total number of subscriptions that match the query.",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "models.SubscriptionQueue": {
+            "type": "object",
+            "properties": {
+                "buffered": {
+                    "description": "the number of events that are buffered while the subscription is paused",
+                    "type": "integer"
+                },
+                "depth": {
+                    "type": "integer"
+                },
+                "processing": {
+                    "type": "boolean"
+                },
+                "purged": {
+                    "description": "the number of events that are removed when the queue is purged",
+                    "type": "integer"
+                },
+                "subscriptionId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SubscriptionStatusChange": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
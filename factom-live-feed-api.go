@@ -4,15 +4,27 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"github.com/FactomProject/live-feed-api/EventRouter/api"
 	"github.com/FactomProject/live-feed-api/EventRouter/config"
 	"github.com/FactomProject/live-feed-api/EventRouter/events"
 	"github.com/FactomProject/live-feed-api/EventRouter/log"
+	"github.com/FactomProject/live-feed-api/EventRouter/metrics"
 	"github.com/FactomProject/live-feed-api/EventRouter/repository"
 	docs "github.com/FactomProject/live-feed-api/EventRouter/swagger"
+	"github.com/prometheus/client_golang/prometheus"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// the time the subscription api completes the requests in progress on shutdown
+const apiShutdownTimeout = 10 * time.Second
+
 func main() {
 	// use info from swagger to init will be called to register the swagger which is provided through an endpoint
 	info := docs.SwaggerInfo
@@ -20,6 +32,12 @@ func main() {
 
 	configuration := loadConfiguration()
 	log.SetLevel(log.Parse(configuration.Log.LogLevel))
+	if flag.NArg() > 0 {
+		runCommand(configuration, flag.Args())
+		return
+	}
+
+	setupMetrics()
 	setupDatabase(configuration.Database)
 
 	eventServer := events.NewReceiver(configuration.Receiver)
@@ -28,9 +46,38 @@ func main() {
 	eventServer.Start()
 	eventRouter.Start()
 
-	api.NewSubscriptionAPI(configuration.Subscription).Start()
+	subscriptionAPI := api.NewSubscriptionAPI(configuration.Subscription, eventRouter, eventServer)
+	subscriptionAPI.Start()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	log.Info("received %v, shutting down", <-signals)
+
+	shutdown(configuration, eventServer, eventRouter, subscriptionAPI)
+}
+
+// shutdown stops receiving events, delivers the received events within the shutdown timeout of the router, stores the
+// events that are not delivered and closes the api and the repository
+func shutdown(configuration *config.Config, eventServer events.EventReceiver, eventRouter events.EventRouter, subscriptionAPI api.SubscriptionAPI) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(configuration.Router.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := eventServer.Stop(ctx); err != nil {
+		log.Error("failed to stop event receiver: %v", err)
+	}
+	if err := eventRouter.Stop(ctx); err != nil {
+		log.Error("failed to stop event router: %v", err)
+	}
+
+	apiCtx, apiCancel := context.WithTimeout(context.Background(), apiShutdownTimeout)
+	defer apiCancel()
+	if err := subscriptionAPI.Stop(apiCtx); err != nil {
+		log.Error("failed to stop subscription api: %v", err)
+	}
 
-	select {}
+	if err := repository.SubscriptionRepository.Close(); err != nil {
+		log.Error("failed to close repository: %v", err)
+	}
+	log.Info("stopped %s", docs.SwaggerInfo.Title)
 }
 
 func loadConfiguration() (configuration *config.Config) {
@@ -52,17 +99,87 @@ func loadConfiguration() (configuration *config.Config) {
 	return configuration
 }
 
+// runCommand runs the command of the arguments instead of the live feed api
+func runCommand(configuration *config.Config, args []string) {
+	switch args[0] {
+	case "migrate":
+		if err := migrate(configuration.Database, args[1:]); err != nil {
+			log.Fatal("%v", err)
+		}
+	default:
+		log.Fatal("unknown command: %s", strings.Join(args, " "))
+	}
+}
+
+// migrate applies or reverts the schema migrations of the sql database, or shows the status of the migrations. The
+// database is closed before the error is returned.
+func migrate(configuration *config.DatabaseConfig, args []string) error {
+	if len(args) != 1 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		return fmt.Errorf("usage: migrate up|down|status")
+	}
+
+	migrator, err := repository.NewMigrator(configuration)
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %v", err)
+	}
+	defer func() {
+		if err := migrator.Close(); err != nil {
+			log.Error("failed to close database: %v", err)
+		}
+	}()
+
+	switch args[0] {
+	case "up":
+		migrated, err := migrator.Up()
+		if err != nil {
+			return fmt.Errorf("failed to migrate database: %v", err)
+		}
+		if len(migrated) == 0 {
+			log.Info("database schema is up to date")
+		}
+	case "down":
+		reverted, err := migrator.Down()
+		if err != nil {
+			return fmt.Errorf("failed to migrate database: %v", err)
+		}
+		if reverted == nil {
+			log.Info("no migration is applied")
+		}
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return fmt.Errorf("failed to read migration status: %v", err)
+		}
+		for _, migration := range status {
+			if migration.AppliedAt == "" {
+				log.Info("migration %d: %s, pending", migration.Version, migration.Description)
+			} else {
+				log.Info("migration %d: %s, applied at %s", migration.Version, migration.Description, migration.AppliedAt)
+			}
+		}
+	}
+	return nil
+}
+
 func setupDatabase(configuration *config.DatabaseConfig) {
 	switch configuration.Database {
 	case "inmemory":
-		repository.SubscriptionRepository = repository.NewInMemoryRepository()
-	case "mysql":
+		repository.SubscriptionRepository = repository.Instrument(repository.NewInMemoryRepository())
+	case "mysql", "postgres", "sqlite":
 		repo, err := repository.NewSQLRepository(configuration)
 		if err != nil {
 			log.Fatal("failed to configure database: %v", err)
 		}
-		repository.SubscriptionRepository = repo
+		repository.SubscriptionRepository = repository.Instrument(repo)
 	default:
 		log.Fatal("failed to configure database: %v", configuration.Database)
 	}
 }
+
+func setupMetrics() {
+	recorder, err := metrics.NewPrometheusMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		log.Fatal("failed to configure metrics: %v", err)
+	}
+	metrics.Recorder = recorder
+}
@@ -51,6 +51,53 @@ func TestSetupUnknownDatabase(t *testing.T) {
 	assert.Contains(t, output, "[FATAL] failed to configure database: something")
 }
 
+func TestUnknownCommand(t *testing.T) {
+	testable := func() {
+		runCommand(&config.Config{}, []string{"unknown", "command"})
+	}
+	output := testApplicationExit(t, "TestUnknownCommand", testable)
+
+	t.Log(output)
+	assert.Contains(t, output, "[FATAL] unknown command: unknown command")
+}
+
+func TestMigrateUnknownDirection(t *testing.T) {
+	testable := func() {
+		runCommand(&config.Config{Database: &config.DatabaseConfig{Database: "mysql"}}, []string{"migrate", "sideways"})
+	}
+	output := testApplicationExit(t, "TestMigrateUnknownDirection", testable)
+
+	t.Log(output)
+	assert.Contains(t, output, "[FATAL] usage: migrate up|down|status")
+}
+
+func TestMigrateInMemoryDatabase(t *testing.T) {
+	testable := func() {
+		runCommand(&config.Config{Database: &config.DatabaseConfig{Database: "inmemory"}}, []string{"migrate", "up"})
+	}
+	output := testApplicationExit(t, "TestMigrateInMemoryDatabase", testable)
+
+	t.Log(output)
+	assert.Contains(t, output, "[FATAL] failed to migrate database: unknown sql database: inmemory")
+}
+
+// the migrations return their errors instead of exiting, such that the database is closed
+func TestMigrateSQLiteDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	configuration := &config.DatabaseConfig{Database: "sqlite", ConnectionString: dir + "/live-feed.db"}
+	for _, command := range []string{"up", "status", "down"} {
+		assert.Nil(t, migrate(configuration, []string{command}), command)
+	}
+
+	err = migrate(&config.DatabaseConfig{Database: "sqlite", ConnectionString: dir + "/missing/live-feed.db"}, []string{"up"})
+	assert.NotNil(t, err)
+}
+
 func TestUnknownConfigFileArgument(t *testing.T) {
 	testable := func() {
 		configuration := loadConfiguration()